@@ -1,23 +1,62 @@
 package bot
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"github.com/dir01/parcels/parcels_api"
+	"html"
+	"regexp"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/dir01/tg-parcels/core"
+	"github.com/dir01/tg-parcels/feed"
+	"github.com/dir01/tg-parcels/metrics"
+	"github.com/dir01/tg-parcels/notify"
+	"github.com/dir01/tg-parcels/routemap"
 	"github.com/hori-ryota/zaperr"
 	"go.uber.org/zap"
 	tele "gopkg.in/telebot.v3"
 )
 
-const TRACK_CMD_HELP = "/track <tracking number> [[name]] - start receiving updates about a parcel"
+const TRACK_CMD_HELP = "/track <tracking number> [[@carrier]] [[name]] [[#tags]] - start receiving updates about a parcel, " +
+	"several numbers separated by spaces or new lines share the name, e.g. \"Order 123 (1/3)\". " +
+	"The carrier, e.g. @dhl, is guessed from the number if not given"
 const INFO_CMD_HELP = "/info <tracking number> - get info about a parcel"
 const STOP_CMD_HELP = "/stop <tracking number> - stop receiving updates about a parcel"
-const LIST_CMD_HELP = "/list - list all tracked parcels"
+const LIST_CMD_HELP = "/list [[#tag]] - list all tracked parcels, or only the tagged ones"
+const CLEANUP_CMD_HELP = "/cleanup [[#tag]] - stop receiving updates about delivered and returned parcels, or only the tagged ones"
+const REFRESH_CMD_HELP = "/refresh <tracking number> - check for updates right now"
+const RESTORE_CMD_HELP = "/restore <tracking number> - resume receiving updates about a recently stopped parcel"
+const UNDO_CMD_HELP = "/undo - resume receiving updates about the parcel stopped a moment ago"
+const NOTE_CMD_HELP = "/note <tracking number> <text> - attach a note to a parcel (what's inside, the seller), " +
+	"/note <tracking number> - removes it"
+const TAG_CMD_HELP = "/tag <tracking number> <#tags> - tag a parcel, e.g. /tag RR123456789CN #gift #aliexpress"
+const UNTAG_CMD_HELP = "/untag <tracking number> [[#tags]] - remove the tags of a parcel, all of them if none are given"
+const HISTORY_CMD_HELP = "/history <tracking number> - show when a parcel was added, renamed or deleted"
+const LASTUPDATE_CMD_HELP = "/lastupdate <tracking number> - show the latest update of a parcel again, e.g. if you've missed it"
+const DELETE_MY_DATA_CMD_HELP = "/deletemydata - stop tracking everything and delete all your data"
+const SETTINGS_CMD_HELP = "/settings - receive updates by email and other channels besides Telegram"
+const NOTIFICATIONS_CMD_HELP = "/notifications - choose what to be notified of: everything, milestones, only delivery or nothing, " +
+	"the 🔔 button of an update sets that for a single parcel"
+const STATUS_CMD_HELP = "/status - see when your parcels were last checked and whether the tracking service works fine"
+const SUMMARY_CMD_HELP = "/summary - get a weekly summary of your parcels on Mondays, /summary <day> [hour] picks another time (UTC), " +
+	"/summary now sends it right away, /summary off stops it"
+const MYSTATS_CMD_HELP = "/mystats - see how many parcels you've tracked, how long deliveries take and which carriers are the fastest"
+const COMPARE_CMD_HELP = "/compare [country] - compare carriers by how fast parcels of everyone using the bot were delivered, " +
+	"e.g. /compare CN for parcels sent by post from China"
+const NAME_CMD_HELP = "/name <name> - in reply to a message about a parcel, rename the parcel. Replying with just the name works too"
+const TOPIC_CMD_HELP = "/topic <tracking number> - in a topic of a group, send updates about a parcel to the topic, " +
+	"outside of topics - send them to you as before. Parcels tracked in a topic are sent there right away"
+const FEED_CMD_HELP = "/feed - get links to a personal feed of updates and a calendar of deliveries, /feed off revokes them"
+const MANUAL_CMD_HELP = "/manual <name> [[YYYY-MM-DD]] - add a parcel without a tracking number, e.g. from a local courier, " +
+	"to be reminded of it on the expected date"
+const ATTACH_CMD_HELP = "/attach <manual parcel> <tracking number> - start tracking a parcel added with /manual once it has a tracking number"
 
 var HELP = strings.Join([]string{`
 Hello! I'm a bot that can help you to track your parcels.
@@ -27,28 +66,188 @@ Hello! I'm a bot that can help you to track your parcels.
 	INFO_CMD_HELP,
 	STOP_CMD_HELP,
 	LIST_CMD_HELP,
+	CLEANUP_CMD_HELP,
+	REFRESH_CMD_HELP,
+	RESTORE_CMD_HELP,
+	UNDO_CMD_HELP,
+	NOTE_CMD_HELP,
+	TAG_CMD_HELP,
+	UNTAG_CMD_HELP,
+	HISTORY_CMD_HELP,
+	LASTUPDATE_CMD_HELP,
+	DELETE_MY_DATA_CMD_HELP,
+	SETTINGS_CMD_HELP,
+	NOTIFICATIONS_CMD_HELP,
+	STATUS_CMD_HELP,
+	SUMMARY_CMD_HELP,
+	MYSTATS_CMD_HELP,
+	COMPARE_CMD_HELP,
+	NAME_CMD_HELP,
+	TOPIC_CMD_HELP,
+	FEED_CMD_HELP,
+	MANUAL_CMD_HELP,
+	ATTACH_CMD_HELP,
 	"/help - show this message",
 }, "\n")
 
-func New(service core.Service, storage Storage, token string, logger *zap.Logger) (*Bot, error) {
-	b, err := tele.NewBot(tele.Settings{
+// listPageSize is how many trackings are shown per /list message
+const listPageSize = 10
+
+// listPageBtn is a prototype of inline "next page" buttons of /list, its data is a cursor of the page
+// followed by the tag the list is filtered by, if any
+var listPageBtn = tele.Btn{Unique: "list_page"}
+
+// refreshBtn is a prototype of inline "refresh" buttons attached to update messages, its data is a tracking number
+var refreshBtn = tele.Btn{Unique: "refresh"}
+
+// trackAnywayBtn is a prototype of inline buttons confirming tracking of numbers that fail their checksums,
+// its data is a tracking number
+var trackAnywayBtn = tele.Btn{Unique: "track_anyway"}
+
+// pickCarrierBtn is a prototype of inline buttons picking the carrier of a number whose format fits several ones,
+// its data is a carrier code, empty to let the carrier be guessed, and a tracking number
+var pickCarrierBtn = tele.Btn{Unique: "pick_carrier"}
+
+// maxTrackBatch limits how many numbers can be tracked with a single /track
+const maxTrackBatch = 20
+
+// notificationLevelBtn is a prototype of inline buttons of /notifications, its data is a core.NotificationLevel
+var notificationLevelBtn = tele.Btn{Unique: "notification_level"}
+
+// notificationLevelNames are what the levels are called in /notifications and per-tracking settings
+var notificationLevelNames = map[core.NotificationLevel]string{
+	core.NotificationLevelAll:        "All updates",
+	core.NotificationLevelMilestones: "Milestones, delivery and stuck parcels",
+	core.NotificationLevelDelivery:   "Out for delivery and delivered only",
+	core.NotificationLevelDelivered:  "Delivered only",
+	core.NotificationLevelMuted:      "Nothing",
+}
+
+// renameBtn is a prototype of inline "rename" buttons of /info and /list, its data is a tracking number
+var renameBtn = tele.Btn{Unique: "rename"}
+
+// trackingLevelsBtn is a prototype of inline buttons of update messages showing per-tracking notification levels,
+// its data is a tracking number
+var trackingLevelsBtn = tele.Btn{Unique: "tracking_levels"}
+
+// trackingLevelBtn is a prototype of inline buttons setting a per-tracking notification level,
+// its data is a core.NotificationLevel, empty for the user's level, and a tracking number
+var trackingLevelBtn = tele.Btn{Unique: "tracking_level"}
+
+// undoBtn is a prototype of inline "undo" buttons of /delete, its data is unix time of deletion and a tracking number
+var undoBtn = tele.Btn{Unique: "undo"}
+
+// undoCleanupBtn is a prototype of inline "undo" buttons of /cleanup, its data is unix time of the cleanup.
+// Cleaned up tracking numbers don't fit into callback data, they are kept in Bot.cleanups
+var undoCleanupBtn = tele.Btn{Unique: "undo_cleanup"}
+
+// undoWindow is how long a deletion can be undone with the button or /undo, /restore works for much longer
+const undoWindow = 5 * time.Minute
+
+// confirmDeliveryBtn is a prototype of inline buttons of delivery updates asking whether the parcel has actually
+// been received, its data is "yes" or "no" and a tracking number
+var confirmDeliveryBtn = tele.Btn{Unique: "confirm_delivery"}
+
+// deleteMyDataBtn is a prototype of inline buttons confirming or cancelling /deletemydata, its data is "yes" or "no"
+var deleteMyDataBtn = tele.Btn{Unique: "delete_my_data"}
+
+// New creates the bot. channels are notification channels users can set up in /settings, it may be nil.
+// Updates of a tracking arriving within debounceWindow of the first one are sent as one message, 0 sends every update
+// right away, and maintenance is how often and to whom core.Service.Cleanup runs and reports
+func New(service core.Service, storage Storage, channels *notify.Channels, feeds *feed.Feeds, maps *routemap.Maps, token string, debounceWindow time.Duration, maintenance Maintenance, registry *metrics.Registry, logger *zap.Logger) (*Bot, error) {
+	tb, err := tele.NewBot(tele.Settings{
 		Token:  token,
 		Poller: &tele.LongPoller{Timeout: 10 * time.Second},
+		OnError: func(err error, c tele.Context) {
+			logger.Error("failed to handle update", zaperr.ToField(err))
+		},
 	})
 	if err != nil {
 		return nil, err
 	}
-	return &Bot{
-		service: service,
-		storage: storage,
-		bot:     b,
-		logger:  logger,
-	}, nil
+	b := &Bot{
+		service:     service,
+		storage:     storage,
+		channels:    channels,
+		feeds:       feeds,
+		maps:        maps,
+		maintenance: maintenance,
+		bot:         tb,
+		logger:      logger,
+		deletions:   make(map[int64]deletion),
+		cleanups:    make(map[int64]cleanup),
+		unchecked:   make(map[int64]uncheckedTrack),
+		renames:     make(map[int64]pendingRename),
+		postalCodes: make(map[int64]pendingPostalCode),
+		sends: registry.NewCounterVec(
+			"tg_parcels_telegram_sends_total", "Tracking updates sent to Telegram by result.", "result",
+		),
+	}
+	b.debouncer = newDebouncer(debounceWindow, b.notifyUserOfTrackingUpdate)
+	return b, nil
+}
+
+// CheckToken makes sure Telegram is reachable and accepts the token
+func CheckToken(token string) error {
+	_, err := tele.NewBot(tele.Settings{Token: token})
+	return err
 }
 
+//go:generate moq -pkg mocks -out mocks/storage.go . Storage
+
 type Storage interface {
 	UserChatID(ctx context.Context, userID int64) (int64, error)
-	SaveUserChatID(ctx context.Context, userID int64, chatID int64) error
+	// HasUser tells whether the user has ever started the bot and hasn't deleted their data since
+	HasUser(ctx context.Context, userID int64) (bool, error)
+	// SaveUserChatID saves the chat to send updates to, languageCode is only saved if the user has none yet
+	SaveUserChatID(ctx context.Context, userID int64, chatID int64, languageCode string) error
+	DeleteUserData(ctx context.Context, userID int64) error
+	// CountNewUsersByDay counts users that started the bot on each day since the time, days without new users are omitted
+	CountNewUsersByDay(ctx context.Context, since time.Time) ([]core.DailyCount, error)
+	// ListUsers lists users ordered by ID, starting after afterUserID (0 for the first page)
+	ListUsers(ctx context.Context, afterUserID int64, limit int) ([]*User, error)
+	// SaveTrackingTopic binds the tracking to the topic, nil topic unbinds it
+	SaveTrackingTopic(ctx context.Context, userID int64, trackingNumber string, topic *Topic) error
+	// TrackingTopic is the topic the tracking is bound to, nil if it isn't bound to any
+	TrackingTopic(ctx context.Context, userID int64, trackingNumber string) (*Topic, error)
+	// SaveMessageTracking remembers which tracking a sent message is about
+	SaveMessageTracking(ctx context.Context, chatID int64, messageID int, userID int64, trackingNumber string) error
+	// MessageTracking is the tracking a sent message is about, trackingNumber is empty for unknown messages
+	MessageTracking(ctx context.Context, chatID int64, messageID int) (userID int64, trackingNumber string, err error)
+	// SaveSummarySchedule opts the user in to weekly summaries, nil schedule opts them out
+	SaveSummarySchedule(ctx context.Context, userID int64, schedule *SummarySchedule) error
+	// SummarySchedule is nil for users who haven't opted in to weekly summaries
+	SummarySchedule(ctx context.Context, userID int64) (*SummarySchedule, error)
+	// SetSummaryNextAt reschedules the next summary of the user, it does nothing for users who opted out
+	SetSummaryNextAt(ctx context.Context, userID int64, nextAt time.Time) error
+	// ListDueSummarySchedules lists up to limit schedules with NextAt not after now, earliest first
+	ListDueSummarySchedules(ctx context.Context, now time.Time, limit int) ([]*SummarySchedule, error)
+}
+
+// SummarySchedule is when a user gets weekly summaries, in UTC
+type SummarySchedule struct {
+	UserID  int64
+	Weekday time.Weekday
+	Hour    int
+	// NextAt is when the next summary is due
+	NextAt time.Time
+}
+
+// Topic is a topic of a forum-enabled supergroup, updates of trackings bound to it are sent there
+type Topic struct {
+	ChatID   int64
+	ThreadID int
+}
+
+// User is someone who has talked to the bot
+type User struct {
+	ID     int64
+	ChatID int64
+	// CreatedAt is when the user started the bot, it is nil for users who did that before it was recorded
+	CreatedAt *time.Time
+	// LanguageCode is IETF language tag of the user's Telegram client when it was first recorded, it may be empty.
+	// It is the default for localized messages
+	LanguageCode string
 }
 
 type Bot struct {
@@ -56,32 +255,154 @@ type Bot struct {
 	bot     *tele.Bot
 	logger  *zap.Logger
 	storage Storage
+	// channels is nil unless notification channels other than Telegram are configured
+	channels *notify.Channels
+	// feeds is nil unless feeds are configured
+	feeds *feed.Feeds
+	// maps is nil unless route maps are configured
+	maps        *routemap.Maps
+	maintenance Maintenance
+	sends       *metrics.CounterVec
+	// handlers tracks handlers in flight, so that shutdown can wait for them
+	handlers         sync.WaitGroup
+	handlersInFlight atomic.Int64
+	// deletions are the latest deletions of users, for /undo. They are lost on restart, /restore still works then
+	deletionsMu sync.Mutex
+	deletions   map[int64]deletion
+	// cleanups are the latest /cleanup of users, for undoCleanupBtn. They are lost on restart just like deletions
+	cleanupsMu sync.Mutex
+	cleanups   map[int64]cleanup
+	// unchecked are the latest unconfirmed /track commands of users, display names don't fit into buttons' data
+	uncheckedMu sync.Mutex
+	unchecked   map[int64]uncheckedTrack
+	// renames are renames started with renameBtn, waiting for users to send new names
+	renamesMu sync.Mutex
+	renames   map[int64]pendingRename
+	// postalCodes are /track commands of numbers needing postal codes, waiting for users to send them
+	postalCodesMu sync.Mutex
+	postalCodes   map[int64]pendingPostalCode
+	// debouncer merges updates of a tracking arriving close together into one message
+	debouncer *debouncer
+}
+
+// pendingRename is a rename waiting for the user to send the new name to the chat
+type pendingRename struct {
+	trackingNumber string
+	chatID         int64
+	// promptID is the message asking for the new name, replies to other messages don't rename anything
+	promptID int
+	askedAt  time.Time
+}
+
+// pendingPostalCode is a /track of a number needing a postal code, waiting for the user to send the postal code
+type pendingPostalCode struct {
+	track  uncheckedTrack
+	chatID int64
+	// promptID is the message asking for the postal code, just like pendingRename.promptID
+	promptID int
+	askedAt  time.Time
+}
+
+// renameTimeout is how long the bot waits for a new name after renameBtn is pressed
+const renameTimeout = 10 * time.Minute
+
+// uncheckedTrack is a /track of a number that fails its checksum, or whose carrier is ambiguous,
+// waiting for the user to confirm it
+type uncheckedTrack struct {
+	trackingNumber string
+	displayName    string
+	tags           []string
+	// carrier is the carrier code the user has given, see core.TrackingParams
+	carrier string
+}
+
+type deletion struct {
+	trackingNumber string
+	deletedAt      time.Time
 }
 
-func (b *Bot) Start(ctx context.Context) {
+type cleanup struct {
+	trackingNumbers []string
+	deletedAt       time.Time
+}
+
+// Start handles Telegram updates and notifies users of tracking updates until ctx is done, then shuts down in order:
+// it stops receiving Telegram updates and waits for handlers in flight, stops the service and waits for its fetches
+// in flight, and sends tracking updates left in the buffer. If that takes longer than shutdownTimeout,
+// the rest is abandoned and ErrShutdownTimedOut is returned
+func (b *Bot) Start(ctx context.Context, shutdownTimeout time.Duration) error {
 	handlers := b.bot.Group()
-	handlers.Use(b.saveChatIDMiddleware)
+	handlers.Use(b.inFlightMiddleware, b.recoverMiddleware, b.saveChatIDMiddleware, b.dialogMiddleware)
 	handlers.Handle("/start", b.handleHelpCmd)
 	handlers.Handle("/help", b.handleHelpCmd)
 	handlers.Handle("/track", b.handleTrackCmd)
+	handlers.Handle(&trackAnywayBtn, b.handleTrackAnywayBtn)
+	handlers.Handle(&pickCarrierBtn, b.handlePickCarrierBtn)
 	handlers.Handle("/info", b.handleInfoCmd)
 	handlers.Handle("/list", b.handleListCmd)
 	handlers.Handle("/delete", b.handleDeleteCmd)
+	handlers.Handle("/refresh", b.handleRefreshCmd)
+	handlers.Handle(&refreshBtn, b.handleRefreshBtn)
+	handlers.Handle(&listPageBtn, b.handleListPageBtn)
+	handlers.Handle("/cleanup", b.handleCleanupCmd)
+	handlers.Handle("/history", b.handleHistoryCmd)
+	handlers.Handle("/lastupdate", b.handleLastUpdateCmd)
+	handlers.Handle("/note", b.handleNoteCmd)
+	handlers.Handle("/manual", b.handleManualCmd)
+	handlers.Handle("/attach", b.handleAttachCmd)
+	handlers.Handle("/tag", b.handleTagCmd)
+	handlers.Handle("/untag", b.handleUntagCmd)
+	handlers.Handle("/restore", b.handleRestoreCmd)
+	handlers.Handle("/undo", b.handleUndoCmd)
+	handlers.Handle(&undoBtn, b.handleUndoBtn)
+	handlers.Handle(&undoCleanupBtn, b.handleUndoCleanupBtn)
+	handlers.Handle(&confirmDeliveryBtn, b.handleConfirmDeliveryBtn)
+	handlers.Handle("/deletemydata", b.handleDeleteMyDataCmd)
+	handlers.Handle(&deleteMyDataBtn, b.handleDeleteMyDataBtn)
+	handlers.Handle("/settings", b.handleSettingsCmd)
+	handlers.Handle("/notifications", b.handleNotificationsCmd)
+	handlers.Handle(&notificationLevelBtn, b.handleNotificationLevelBtn)
+	handlers.Handle(&trackingLevelsBtn, b.handleTrackingLevelsBtn)
+	handlers.Handle(&trackingLevelBtn, b.handleTrackingLevelBtn)
+	handlers.Handle("/feed", b.handleFeedCmd)
+	handlers.Handle("/topic", b.handleTopicCmd)
+	handlers.Handle("/name", b.handleNameCmd)
+	handlers.Handle(&renameBtn, b.handleRenameBtn)
+	handlers.Handle("/status", b.handleStatusCmd)
+	handlers.Handle("/summary", b.handleSummaryCmd)
+	handlers.Handle("/mystats", b.handleMyStatsCmd)
+	handlers.Handle("/compare", b.handleCompareCmd)
+	handlers.Handle(tele.OnText, b.handleText)
 
+	updates := b.service.Subscribe()
+	defer b.service.Unsubscribe(updates)
+	stopWorker := make(chan struct{})
+	workerStopped := make(chan struct{})
 	go func() {
+		defer close(workerStopped)
 		for {
 			select {
-			case <-ctx.Done():
-				b.logger.Debug("context cancelled, stopping updates worker")
+			case <-stopWorker:
+				b.logger.Debug("stopping updates worker")
 				return
-			case update := <-b.service.Updates():
-				b.notifyUserOfTrackingUpdate(update)
+			case update := <-updates:
+				b.debouncer.add(update)
 			}
 		}
 	}()
 
 	b.service.Start(ctx)
 	b.logger.Debug("service started")
+	b.handlers.Add(1)
+	go func() {
+		defer b.handlers.Done()
+		b.runSummaries(ctx)
+	}()
+	b.handlers.Add(1)
+	go func() {
+		defer b.handlers.Done()
+		b.runMaintenance(ctx)
+	}()
 	go func() {
 		b.logger.Debug("starting bot termination watcher")
 		<-ctx.Done()
@@ -92,180 +413,2220 @@ func (b *Bot) Start(ctx context.Context) {
 	}()
 	b.logger.Debug("starting bot")
 	b.bot.Start()
+
+	b.logger.Info("shutting down", zap.Duration("timeout", shutdownTimeout))
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	var abandoned []zap.Field
+	if !waitGroupWait(shutdownCtx, &b.handlers) {
+		abandoned = append(abandoned, zap.Int64("handlers_in_flight", b.handlersInFlight.Load()))
+	}
+	if err := b.service.Wait(shutdownCtx); err != nil {
+		abandoned = append(abandoned, zaperr.ToField(err))
+	}
+	// nothing is published anymore, unless fetches were abandoned, so the rest of the buffer can be sent
+	close(stopWorker)
+	select {
+	case <-workerStopped:
+		b.drainUpdates(shutdownCtx, updates)
+		b.debouncer.flushAll()
+	case <-shutdownCtx.Done():
+	}
+	if shutdownCtx.Err() != nil {
+		// updates with notifications are delivered again on next start
+		abandoned = append(abandoned, zap.Int("undelivered_updates", len(updates)))
+		b.logger.Error("shutdown timed out, abandoning the rest", abandoned...)
+		return core.ErrShutdownTimedOut
+	}
+	b.logger.Info("shut down")
+	return nil
 }
 
-func (b *Bot) notifyUserOfTrackingUpdate(update core.TrackingUpdate) {
-	fields := []zap.Field{
-		zap.Any("update", update),
+// drainUpdates sends updates left in the buffer until it is empty or ctx is done
+func (b *Bot) drainUpdates(ctx context.Context, updates <-chan core.TrackingUpdate) {
+	for ctx.Err() == nil {
+		select {
+		case update := <-updates:
+			b.debouncer.add(update)
+		default:
+			return
+		}
+	}
+}
+
+// waitGroupWait waits for wg, it returns false if ctx is done first
+func waitGroupWait(ctx context.Context, wg *sync.WaitGroup) bool {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
 	}
-	b.logger.Debug("handling tracking update", fields...)
+}
+
+// notifyUserOfTrackingUpdate sends the update, which is made of the merged updates, see debouncer
+func (b *Bot) notifyUserOfTrackingUpdate(update core.TrackingUpdate, merged []core.TrackingUpdate) {
+	fields := core.UpdateFields(&update)
+	b.logger.Debug("handling tracking update", append(fields, zap.Any("update", update))...)
 
-	chatID, err := b.storage.UserChatID(context.Background(), update.UserID)
+	chatID, threadID, err := b.recipientOf(&update)
 	if err != nil {
-		b.logger.Error("failed to get chat id", fields...)
+		b.logger.Error("failed to get chat id", append(fields, zaperr.ToField(err))...)
 		return
 	}
 	if chatID == 0 {
+		// there is nowhere to deliver it, ever
 		b.logger.Debug("no chat id found for user", fields...)
+		b.markUpdatesDelivered(merged)
 		return
 	}
 
-	if errors.Is(update.TrackingError, core.ErrNoTrackingInfo) {
-		msg := "Tracking info not found at the moment, but we will keep trying to find it and will update of any changes"
-		if _, err := b.bot.Send(tele.ChatID(chatID), msg); err != nil {
-			zapFields := append(fields, zap.Int64("chat_id", chatID))
-			b.logger.Error("failed to send message", zapFields...)
+	if update.TrackingError != nil {
+		msg := trackingErrorMessage(update.TrackingNumber, update.ErrorCode)
+		if _, err := b.bot.Send(tele.ChatID(chatID), msg, &tele.SendOptions{ThreadID: threadID}); err != nil {
+			b.sends.Inc("error")
+			b.logger.Error("failed to send message", append(fields, core.ChatIDField(chatID), zaperr.ToField(err))...)
+			return
 		}
+		b.sends.Inc("ok")
 		return
 	}
 
-	if update.TrackingError != nil {
-		msg := "Failed to get tracking info"
-		if _, err := b.bot.Send(tele.ChatID(chatID), msg); err != nil {
-			zapFields := append(fields, zap.Int64("chat_id", chatID))
-			b.logger.Error("failed to send message", zapFields...)
-		}
+	msg := b.formatTrackingUpdate(&update)
+
+	opts := &tele.SendOptions{ThreadID: threadID, ParseMode: tele.ModeHTML, ReplyMarkup: updateMarkup(&update)}
+	// updates with whole new tracking infos may be too long for a message
+	sent, err := b.sendChunked(tele.ChatID(chatID), msg, opts)
+	for _, m := range sent {
+		b.rememberMessage(m, update.UserID, update.TrackingNumber)
+	}
+	if err != nil {
+		b.sends.Inc("error")
+		b.logger.Error("failed to send message", append(fields, core.ChatIDField(chatID), zaperr.ToField(err))...)
 		return
 	}
+	b.sends.Inc("ok")
+	if len(update.Milestones) > 0 && b.maps.Enabled() {
+		b.handlers.Add(1)
+		go func() {
+			defer b.handlers.Done()
+			b.sendMilestoneRouteMap(chatID, threadID, &update)
+		}()
+	}
+	b.markUpdatesDelivered(merged)
+}
+
+// recipientOf is the topic the tracking of the update is bound to or the chat of its user,
+// threadID is 0 for the latter, chatID is 0 if the user is unknown
+func (b *Bot) recipientOf(update *core.TrackingUpdate) (chatID int64, threadID int, err error) {
+	topic, err := b.storage.TrackingTopic(context.Background(), update.UserID, update.TrackingNumber)
+	if err != nil {
+		return 0, 0, err
+	}
+	if topic != nil {
+		return topic.ChatID, topic.ThreadID, nil
+	}
+	chatID, err = b.storage.UserChatID(context.Background(), update.UserID)
+	return chatID, 0, err
+}
+
+func (b *Bot) markUpdatesDelivered(updates []core.TrackingUpdate) {
+	for i := range updates {
+		b.markUpdateDelivered(&updates[i])
+	}
+}
+
+func (b *Bot) markUpdateDelivered(update *core.TrackingUpdate) {
+	if err := b.service.MarkUpdateDelivered(context.Background(), update); err != nil {
+		b.logger.Error("failed to mark update delivered", append(core.UpdateFields(update), zaperr.ToField(err))...)
+	}
+}
 
-	title := fmt.Sprintf("<code>%s</code>", update.TrackingNumber)
+func (b *Bot) formatTrackingUpdate(update *core.TrackingUpdate) string {
+	title := fmt.Sprintf("<code>%s</code>", html.EscapeString(update.TrackingNumber))
 	if update.DisplayName != "" {
-		title = fmt.Sprintf("%s - %s", title, update.DisplayName)
+		title = fmt.Sprintf("%s - %s", title, html.EscapeString(update.DisplayName))
 	}
 
 	var lines []string
 	lines = append(lines, title)
-	for _, e := range update.NewTrackingEvents {
-		l := fmt.Sprintf("%s - %s", e.Time, e.Description)
+	for _, milestone := range update.Milestones {
+		lines = append(lines, formatMilestone(milestone))
+	}
+	if update.StuckSince != nil {
+		lines = append(lines, fmt.Sprintf("⚠️ The parcel appears stuck: there's been no news of it since %s. "+
+			"It may be worth contacting the seller or the carrier", update.StuckSince.Format("Jan 2")))
+	}
+	if update.ExpectedAt != nil {
+		lines = append(lines, fmt.Sprintf("⏰ The parcel was expected on %s, has it arrived? "+
+			"/delete it once it has, or /attach a tracking number if you've got one", update.ExpectedAt.Format("Jan 2")))
+	}
+	for _, e := range update.Events() {
+		l := fmt.Sprintf("%s - %s", html.EscapeString(e.Time), html.EscapeString(e.Description))
 		lines = append(lines, l)
 	}
+	if update.ETA != nil {
+		lines = append(lines, "", formatETA(update.ETA))
+	}
+	if len(update.FailedProviders) > 0 {
+		lines = append(lines, "", "⚠️ Couldn't check "+html.EscapeString(strings.Join(update.FailedProviders, ", "))+" this time")
+	}
 
-	msg := strings.Join(lines, "\n")
+	return strings.Join(lines, "\n")
+}
 
-	if _, err := b.bot.Send(tele.ChatID(chatID), msg, tele.ModeHTML); err != nil {
-		zapFields := append(fields, zap.Int64("chat_id", chatID))
-		b.logger.Error("failed to send message", zapFields...)
+// formatMilestone highlights the milestone, telling the user what to expect of it
+func formatMilestone(milestone core.Status) string {
+	switch milestone {
+	case core.StatusCustoms:
+		return "🛃 <b>The parcel is at customs</b>\n" +
+			"Customs may ask you for documents or to pay duties and taxes, keep an eye on messages from the carrier"
+	case core.StatusArrivedDestination:
+		return "🛬 <b>The parcel has arrived in the destination country</b>\n" +
+			"The rest of the way is up to the local carrier"
 	}
+	return ""
 }
 
-func (b *Bot) handleTrackCmd(c tele.Context) error {
-	args := c.Args()
-	if len(args) == 0 {
-		return c.Send(TRACK_CMD_HELP, "Markdown")
+func formatETA(eta *core.ETA) string {
+	const layout = "Jan 2"
+	from, to := eta.From.Format(layout), eta.To.Format(layout)
+	if from == to {
+		return fmt.Sprintf("Expected delivery: %s", from)
 	}
+	return fmt.Sprintf("Expected delivery: %s - %s", from, to)
+}
 
-	userID := c.Message().Sender.ID
-	trackingNumber := args[0]
-	displayName := strings.Join(args[1:], " ")
+// trackingErrorMessage explains to the user what went wrong with fetching tracking info
+// and whether we are going to keep trying
+func trackingErrorMessage(trackingNumber string, code core.ErrorCode) string {
+	switch code {
+	case core.ErrorCodeNotFound:
+		return "Tracking info not found at the moment, but we will keep trying to find it and will update of any changes"
+	case core.ErrorCodeUpstreamDown:
+		return "Tracking service is unavailable at the moment, but we will keep trying and will update of any changes"
+	case core.ErrorCodeRateLimited:
+		return "Tracking service is overloaded at the moment, but we will keep trying and will update of any changes"
+	case core.ErrorCodeInvalidNumber:
+		return trackingNumber + " doesn't look like a valid tracking number, please check it and /delete it if it's wrong"
+	}
+	return "Failed to get tracking info"
+}
 
-	if err := b.service.Track(context.Background(), userID, trackingNumber, displayName); err == nil {
-		return c.Send("Started tracking " + trackingNumber)
-	} else {
-		b.logger.Error("failed to track parcel", zaperr.ToField(err))
+func refreshMarkup(trackingNumber string) *tele.ReplyMarkup {
+	markup := &tele.ReplyMarkup{}
+	markup.Inline(refreshRow(markup, trackingNumber))
+	return markup
+}
+
+func refreshRow(markup *tele.ReplyMarkup, trackingNumber string) tele.Row {
+	row := markup.Row(markup.Data("🔄 Refresh", refreshBtn.Unique, trackingNumber))
+	// per-tracking levels can't be set if the longest of their buttons doesn't fit into callback data
+	if btn := markup.Data("", trackingLevelBtn.Unique, string(core.NotificationLevelMilestones), trackingNumber); len(btn.Unique)+len(btn.Data)+2 <= 64 {
+		row = append(row, markup.Data("🔔 Notifications", trackingLevelsBtn.Unique, trackingNumber))
 	}
-	return nil
+	return row
 }
 
-func (b *Bot) handleInfoCmd(c tele.Context) error {
+// updateMarkup is refreshMarkup, preceded by buttons asking whether the parcel has actually been received
+// if the update is about its delivery
+func updateMarkup(update *core.TrackingUpdate) *tele.ReplyMarkup {
+	// reminders are about manual parcels, which have nothing to refresh
+	if update.ExpectedAt != nil {
+		return nil
+	}
+	delivered := false
+	for _, status := range update.Statuses {
+		delivered = delivered || status == core.StatusDelivered
+	}
+	if !delivered {
+		return refreshMarkup(update.TrackingNumber)
+	}
+	markup := &tele.ReplyMarkup{}
+	markup.Inline(
+		markup.Row(
+			markup.Data("✅ Received", confirmDeliveryBtn.Unique, "yes", update.TrackingNumber),
+			markup.Data("❌ Not received", confirmDeliveryBtn.Unique, "no", update.TrackingNumber),
+		),
+		refreshRow(markup, update.TrackingNumber),
+	)
+	return markup
+}
+
+func (b *Bot) handleTrackCmd(c tele.Context) error {
+	// telebot's Args splits on spaces only, while pasted numbers are often on separate lines
+	trackingNumbers, displayName, tags, carrier := parseTrackArgs(strings.Fields(c.Message().Payload))
+	if len(trackingNumbers) == 0 {
+		return c.Send(TRACK_CMD_HELP, "Markdown")
+	}
+	if carrier != "" && core.CarrierByCode(carrier) == nil {
+		return c.Send(unknownCarrierMessage(carrier))
+	}
+	if len(trackingNumbers) > maxTrackBatch {
+		return c.Send(fmt.Sprintf("Please track at most %d parcels at once", maxTrackBatch))
+	}
+
 	userID := c.Message().Sender.ID
-	args := c.Args()
-	if len(args) == 0 {
-		return c.Send(INFO_CMD_HELP, tele.ModeMarkdown)
+	if len(trackingNumbers) > 1 {
+		return b.trackMany(c, userID, trackingNumbers, displayName, tags, carrier)
 	}
+	trackingNumber := trackingNumbers[0]
 
-	trackingNumber := args[0]
-	tracking, err := b.service.GetTracking(context.Background(), userID, trackingNumber)
-	if err != nil {
-		return c.Send("Failed to get tracking info")
+	err := core.ValidateTrackingNumber(trackingNumber)
+	if errors.Is(err, core.ErrInvalidTrackingNumber) {
+		return c.Send(fmt.Sprintf("%s doesn't look like a tracking number (%s), please check it",
+			trackingNumber, strings.TrimPrefix(err.Error(), core.ErrInvalidTrackingNumber.Error()+": ")))
+	}
+	if errors.Is(err, core.ErrChecksumMismatch) {
+		b.uncheckedMu.Lock()
+		b.unchecked[userID] = uncheckedTrack{trackingNumber: trackingNumber, displayName: displayName, tags: tags, carrier: carrier}
+		b.uncheckedMu.Unlock()
+		markup := &tele.ReplyMarkup{}
+		markup.Inline(markup.Row(markup.Data("Track anyway", trackAnywayBtn.Unique, trackingNumber)))
+		return c.Send(trackingNumber+" fails its checksum, there may be a typo. Track anyway?", markup)
 	}
+	if markup := pickCarrierMarkup(trackingNumber); carrier == "" && markup != nil {
+		b.uncheckedMu.Lock()
+		b.unchecked[userID] = uncheckedTrack{trackingNumber: trackingNumber, displayName: displayName, tags: tags}
+		b.uncheckedMu.Unlock()
+		return c.Send(trackingNumber+" may be handled by several carriers, which one is it?", markup)
+	}
+	return b.track(c, userID, trackingNumber, displayName, tags, core.TrackingParams{Carrier: carrier})
+}
 
-	title := fmt.Sprintf("<code>%s</code>", tracking.TrackingNumber)
-	if tracking.DisplayName != "" {
-		title = fmt.Sprintf("%s - %s", title, tracking.DisplayName)
+// pickCarrierMarkup is nil if the number format fits a single carrier, or if the number doesn't fit into callback data
+func pickCarrierMarkup(trackingNumber string) *tele.ReplyMarkup {
+	candidates := core.CandidateCarriers(trackingNumber)
+	if len(candidates) < 2 {
+		return nil
+	}
+	markup := &tele.ReplyMarkup{}
+	var row tele.Row
+	for _, carrier := range candidates {
+		btn := markup.Data(carrier.Name, pickCarrierBtn.Unique, carrier.Code, trackingNumber)
+		if len(btn.Unique)+len(btn.Data)+2 > 64 {
+			return nil
+		}
+		row = append(row, btn)
 	}
+	markup.Inline(row, markup.Row(markup.Data("Don't know", pickCarrierBtn.Unique, "", trackingNumber)))
+	return markup
+}
 
-	lines := []string{title}
-	events := b.collectAllEvents(tracking)
-	for _, e := range events {
-		l := fmt.Sprintf("%s - %s", e.Time, e.Description)
-		lines = append(lines, l)
+func (b *Bot) handlePickCarrierBtn(c tele.Context) error {
+	if err := c.Respond(); err != nil {
+		b.contextLogger(c).Error("failed to respond to callback", zaperr.ToField(err))
+	}
+	carrier, trackingNumber, ok := strings.Cut(c.Data(), "|")
+	if !ok {
+		return nil
+	}
+	userID := c.Sender().ID
+	b.uncheckedMu.Lock()
+	unchecked, ok := b.unchecked[userID]
+	delete(b.unchecked, userID)
+	b.uncheckedMu.Unlock()
+	// the name and tags are lost if the bot has restarted or the user has tracked something else since
+	if !ok || unchecked.trackingNumber != trackingNumber {
+		unchecked = uncheckedTrack{trackingNumber: trackingNumber}
+	}
+	msg := trackingNumber + " may be handled by several carriers, guessing which one"
+	if picked := core.CarrierByCode(carrier); picked != nil {
+		msg = trackingNumber + " is handled by " + picked.Name
+	}
+	if err := c.Edit(msg); err != nil {
+		b.contextLogger(c).Error("failed to edit message", zaperr.ToField(err))
 	}
+	return b.track(c, userID, unchecked.trackingNumber, unchecked.displayName, unchecked.tags, core.TrackingParams{Carrier: carrier})
+}
 
-	return c.Send(strings.Join(lines, "\n"), tele.ModeHTML)
+// unknownCarrierMessage lists the carriers that can be given instead of the unknown one
+func unknownCarrierMessage(carrier string) string {
+	codes := core.CarrierCodes()
+	for i, code := range codes {
+		codes[i] = "@" + code
+	}
+	return fmt.Sprintf("I don't know the carrier %s, please give one of %s, or none to have it guessed",
+		carrier, strings.Join(codes, ", "))
 }
 
-func (b *Bot) handleListCmd(c tele.Context) error {
-	userID := c.Message().Sender.ID
-	trackings, err := b.service.ListTrackings(context.Background(), userID)
-	if err != nil {
-		return c.Send("Failed to list trackings")
+func (b *Bot) handleTrackAnywayBtn(c tele.Context) error {
+	if err := c.Respond(); err != nil {
+		b.contextLogger(c).Error("failed to respond to callback", zaperr.ToField(err))
+	}
+	userID := c.Sender().ID
+	trackingNumber := c.Data()
+	b.uncheckedMu.Lock()
+	unchecked, ok := b.unchecked[userID]
+	delete(b.unchecked, userID)
+	b.uncheckedMu.Unlock()
+	// the name and tags are lost if the bot has restarted or the user has tracked something else since
+	if !ok || unchecked.trackingNumber != trackingNumber {
+		unchecked = uncheckedTrack{trackingNumber: trackingNumber}
 	}
+	if err := c.Edit(trackingNumber + " fails its checksum, tracking it anyway"); err != nil {
+		b.contextLogger(c).Error("failed to edit message", zaperr.ToField(err))
+	}
+	return b.track(c, userID, unchecked.trackingNumber, unchecked.displayName, unchecked.tags, core.TrackingParams{Carrier: unchecked.carrier})
+}
 
-	var lines []string
-	for _, tracking := range trackings {
-		l := fmt.Sprintf("<code>%s</code>", tracking.TrackingNumber)
-		if tracking.DisplayName != "" {
-			l = fmt.Sprintf("%s - %s", l, tracking.DisplayName)
+func (b *Bot) track(c tele.Context, userID int64, trackingNumber string, displayName string, tags []string, params core.TrackingParams) error {
+	// before tracking, since the first update may come right away
+	b.bindToTopic(c, userID, trackingNumber)
+	err := b.service.Track(context.Background(), userID, trackingNumber, displayName, params)
+	if err == nil || errors.Is(err, core.ErrTrackingExists) {
+		if err := b.addTags(userID, trackingNumber, tags); errors.Is(err, core.ErrTooManyTags) {
+			_ = c.Send(fmt.Sprintf("Couldn't tag %s, parcels can have at most %d tags", trackingNumber, core.MaxTagsPerTracking))
+		} else if err != nil {
+			b.contextLogger(c).Error("failed to tag parcel", core.TrackingNumberField(trackingNumber), zaperr.ToField(err))
 		}
-		lines = append(lines, l)
+	}
+	if err == nil {
+		msg := "Started tracking " + trackingNumber
+		if carrier := core.CarrierByCode(params.Carrier); carrier != nil {
+			msg += " (" + carrier.Name + ")"
+		} else if carrier := core.DetectCarrier(trackingNumber); carrier != nil {
+			msg += " (looks like " + carrier.Name + ")"
+		}
+		return b.sendAbout(c, trackingNumber, msg)
+	}
 
-		events := b.collectAllEvents(tracking)
-		if len(events) > 0 {
-			e := events[len(events)-1]
-			l := fmt.Sprintf("%s - %s", e.Time, e.Description)
-			lines = append(lines, l)
+	if errors.Is(err, core.ErrTrackingExists) {
+		return b.sendAlreadyTracking(c, userID, trackingNumber)
+	}
+
+	var quotaErr *core.TrackingQuotaExceededError
+	if errors.As(err, &quotaErr) {
+		return c.Send(fmt.Sprintf("You're tracking %d parcels, which is the limit. Please /delete some first", quotaErr.Limit))
+	}
+
+	if errors.Is(err, core.ErrUnknownCarrier) {
+		return c.Send(unknownCarrierMessage(params.Carrier))
+	}
+
+	unchecked := uncheckedTrack{trackingNumber: trackingNumber, displayName: displayName, tags: tags, carrier: params.Carrier}
+	if errors.Is(err, core.ErrPostalCodeRequired) {
+		msg := trackingNumber + " needs the recipient's postal code to be tracked"
+		if carrier := (&core.Tracking{TrackingNumber: trackingNumber, Params: params}).Carrier(); carrier != nil {
+			msg = carrier.Name + " needs the recipient's postal code to track " + trackingNumber
 		}
+		return b.askPostalCode(c, unchecked, msg+". Send it to me, or any command to cancel")
+	}
+	if errors.Is(err, core.ErrInvalidPostalCode) {
+		return b.askPostalCode(c, unchecked, params.PostalCode+" doesn't look like a postal code, please send it again, or any command to cancel")
+	}
 
-		lines = append(lines, "")
+	b.contextLogger(c).Error("failed to track parcel", core.TrackingNumberField(trackingNumber), zaperr.ToField(err))
+	return nil
+}
+
+// trackMany tracks several numbers at once, reporting the result for each of them in a single message.
+// Numbers failing their checksums are tracked without asking, but with a warning, and so are numbers of ambiguous
+// formats, their carriers guessed unless the carrier is given
+func (b *Bot) trackMany(c tele.Context, userID int64, trackingNumbers []string, displayName string, tags []string, carrier string) error {
+	requests := make([]*core.TrackRequest, len(trackingNumbers))
+	for i, trackingNumber := range trackingNumbers {
+		requests[i] = &core.TrackRequest{TrackingNumber: trackingNumber, Params: core.TrackingParams{Carrier: carrier}}
+		if displayName != "" {
+			requests[i].DisplayName = fmt.Sprintf("%s (%d/%d)", displayName, i+1, len(trackingNumbers))
+		}
 	}
+	b.bindToTopic(c, userID, trackingNumbers...)
+	errs := b.service.TrackMany(context.Background(), userID, requests)
 
+	lines := make([]string, 0, len(requests))
+	for i, r := range requests {
+		if errs[i] == nil || errors.Is(errs[i], core.ErrTrackingExists) {
+			if err := b.addTags(userID, r.TrackingNumber, tags); err != nil {
+				b.contextLogger(c).Error("failed to tag parcel", core.TrackingNumberField(r.TrackingNumber), zaperr.ToField(err))
+			}
+		}
+		var quotaErr *core.TrackingQuotaExceededError
+		line := "<code>" + html.EscapeString(r.TrackingNumber) + "</code> - "
+		switch err := errs[i]; {
+		case err == nil:
+			line += "started tracking"
+			if given := core.CarrierByCode(carrier); given != nil {
+				line += " (" + html.EscapeString(given.Name) + ")"
+			} else if carrier := core.DetectCarrier(r.TrackingNumber); carrier != nil {
+				line += " (looks like " + html.EscapeString(carrier.Name) + ")"
+			}
+			if errors.Is(core.ValidateTrackingNumber(r.TrackingNumber), core.ErrChecksumMismatch) {
+				line += ", but it fails its checksum, there may be a typo"
+			}
+		case errors.Is(err, core.ErrTrackingExists):
+			line += "already tracking"
+		case errors.Is(err, core.ErrInvalidTrackingNumber):
+			line += "doesn't look like a tracking number"
+		case errors.Is(err, core.ErrPostalCodeRequired):
+			line += "needs the recipient's postal code, please /track it on its own"
+		case errors.As(err, &quotaErr):
+			line += fmt.Sprintf("not tracked, you're tracking %d parcels, which is the limit", quotaErr.Limit)
+		default:
+			b.contextLogger(c).Error("failed to track parcel", core.TrackingNumberField(r.TrackingNumber), zaperr.ToField(err))
+			line += "failed to track, please try again later"
+		}
+		lines = append(lines, line)
+	}
 	return c.Send(strings.Join(lines, "\n"), tele.ModeHTML)
 }
 
-func (b *Bot) handleDeleteCmd(c tele.Context) error {
-	args := c.Args()
+// parseTrackArgs splits /track arguments into tracking numbers, a name, tags and a carrier.
+// The first argument is always a tracking number, the following ones are too as long as they look like tracking numbers,
+// and the rest is the name, except for hashtags, which are tags, and an @-prefixed carrier code, the last one if several
+func parseTrackArgs(args []string) (trackingNumbers []string, displayName string, tags []string, carrier string) {
 	if len(args) == 0 {
-		return c.Send("Please specify tracking number")
+		return nil, "", nil, ""
+	}
+	seen := map[string]bool{args[0]: true}
+	trackingNumbers = []string{args[0]}
+	i := 1
+	for ; i < len(args) && looksLikeTrackingNumber(args[i]); i++ {
+		if !seen[args[i]] {
+			seen[args[i]] = true
+			trackingNumbers = append(trackingNumbers, args[i])
+		}
 	}
+	var words []string
+	for _, arg := range args[i:] {
+		if _, err := core.NormalizeTag(arg); err == nil && strings.HasPrefix(arg, "#") {
+			tags = append(tags, arg)
+		} else if strings.HasPrefix(arg, "@") && len(arg) > 1 {
+			carrier = strings.ToLower(arg[1:])
+		} else {
+			words = append(words, arg)
+		}
+	}
+	return trackingNumbers, strings.Join(words, " "), tags, carrier
+}
 
-	userID := c.Message().Sender.ID
+// addTags adds the tags to the ones the tracking already has
+func (b *Bot) addTags(userID int64, trackingNumber string, tags []string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+	tracking, err := b.service.GetTracking(context.Background(), userID, trackingNumber)
+	if err != nil {
+		return err
+	}
+	return b.service.SetTags(context.Background(), userID, trackingNumber, append(tracking.Tags, tags...))
+}
+
+func (b *Bot) handleTagCmd(c tele.Context) error {
+	args := strings.Fields(c.Message().Payload)
+	if len(args) < 2 {
+		return c.Send(TAG_CMD_HELP, tele.ModeMarkdown)
+	}
 	trackingNumber := args[0]
+	err := b.addTags(c.Sender().ID, trackingNumber, args[1:])
+	if err == nil {
+		return c.Send("Tagged " + trackingNumber)
+	}
+	return b.sendTagsError(c, trackingNumber, err)
+}
 
-	if err := b.service.DeleteTracking(context.Background(), userID, trackingNumber); err == nil {
-		return c.Send("Stopped tracking " + trackingNumber)
-	} else {
-		b.logger.Error("failed to stop tracking", zaperr.ToField(err))
+func (b *Bot) handleUntagCmd(c tele.Context) error {
+	args := strings.Fields(c.Message().Payload)
+	if len(args) == 0 {
+		return c.Send(UNTAG_CMD_HELP, tele.ModeMarkdown)
 	}
-	return nil
+	userID := c.Sender().ID
+	trackingNumber := args[0]
+	tracking, err := b.service.GetTracking(context.Background(), userID, trackingNumber)
+	if err != nil {
+		return b.sendTagsError(c, trackingNumber, err)
+	}
+
+	removed := make(map[string]bool)
+	for _, tag := range args[1:] {
+		normalized, err := core.NormalizeTag(tag)
+		if err != nil {
+			return b.sendTagsError(c, trackingNumber, err)
+		}
+		removed[normalized] = true
+	}
+	var kept []string
+	for _, tag := range tracking.Tags {
+		if len(args) > 1 && !removed[tag] {
+			kept = append(kept, tag)
+		}
+	}
+	if err := b.service.SetTags(context.Background(), userID, trackingNumber, kept); err != nil {
+		return b.sendTagsError(c, trackingNumber, err)
+	}
+	return c.Send("Untagged " + trackingNumber)
 }
 
-func (b *Bot) handleHelpCmd(c tele.Context) error {
-	return c.Send(HELP, "Markdown")
+func (b *Bot) sendTagsError(c tele.Context, trackingNumber string, err error) error {
+	switch {
+	case errors.Is(err, core.ErrTrackingNotFound):
+		return c.Send("You're not tracking " + trackingNumber)
+	case errors.Is(err, core.ErrInvalidTag):
+		return c.Send("Tags may only have letters, digits and underscores, and not only digits")
+	case errors.Is(err, core.ErrTooManyTags):
+		return c.Send(fmt.Sprintf("Parcels can have at most %d tags", core.MaxTagsPerTracking))
+	}
+	b.contextLogger(c).Error("failed to set tags", core.TrackingNumberField(trackingNumber), zaperr.ToField(err))
+	return c.Send("Failed to save tags")
 }
 
-func (b *Bot) saveChatIDMiddleware(next tele.HandlerFunc) tele.HandlerFunc {
-	return func(c tele.Context) error {
-		chatID := c.Message().Chat.ID
-		userID := c.Message().Sender.ID
+// formatTags renders tags as hashtags
+func formatTags(tags []string) string {
+	hashtags := make([]string, len(tags))
+	for i, tag := range tags {
+		hashtags[i] = "#" + tag
+	}
+	return strings.Join(hashtags, " ")
+}
 
-		zapFields := []zap.Field{
-			zap.Int64("chat_id", chatID),
-			zap.Int64("user_id", userID),
+// looksLikeTrackingNumber tells tracking numbers from words of names, which rarely have several digits in them
+func looksLikeTrackingNumber(s string) bool {
+	if errors.Is(core.ValidateTrackingNumber(s), core.ErrInvalidTrackingNumber) {
+		return false
+	}
+	digits := 0
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			digits++
 		}
-		b.logger.Debug("saving chat id", zapFields...)
+	}
+	return digits >= 4
+}
 
-		if err := b.storage.SaveUserChatID(context.Background(), userID, chatID); err != nil {
-			zapFields := append(zapFields, zaperr.ToField(err))
-			b.logger.Error("failed to save chat id", zapFields...)
-		}
-		return next(c)
+// sendAlreadyTracking reminds the user of the tracking's current status instead of confirming it again
+func (b *Bot) sendAlreadyTracking(c tele.Context, userID int64, trackingNumber string) error {
+	tracking, err := b.service.GetTracking(context.Background(), userID, trackingNumber)
+	if err != nil {
+		b.contextLogger(c).Error("failed to get tracking", core.TrackingNumberField(trackingNumber), zaperr.ToField(err))
+		return c.Send("You're already tracking " + trackingNumber)
+	}
+
+	title := fmt.Sprintf("You're already tracking <code>%s</code>", html.EscapeString(tracking.TrackingNumber))
+	if tracking.DisplayName != "" {
+		title = fmt.Sprintf("%s - %s", title, html.EscapeString(tracking.DisplayName))
 	}
+	lines := []string{title}
+	if events := b.collectAllEvents(tracking); len(events) > 0 {
+		e := events[len(events)-1]
+		lines = append(lines, fmt.Sprintf("%s - %s", html.EscapeString(e.Time), html.EscapeString(e.Description)))
+	} else {
+		lines = append(lines, "No tracking info yet")
+	}
+
+	return b.sendAbout(c, trackingNumber, strings.Join(lines, "\n"), tele.ModeHTML, refreshMarkup(trackingNumber))
 }
 
-func (b *Bot) collectAllEvents(tracking *core.Tracking) []parcels_api.TrackingEvent {
-	var events []parcels_api.TrackingEvent
-	for _, info := range tracking.TrackingInfos {
-		events = append(events, info.Events...)
+func (b *Bot) handleInfoCmd(c tele.Context) error {
+	userID := c.Message().Sender.ID
+	args := c.Args()
+	if len(args) == 0 {
+		return c.Send(INFO_CMD_HELP, tele.ModeMarkdown)
+	}
+
+	trackingNumber := args[0]
+	tracking, err := b.service.GetTracking(context.Background(), userID, trackingNumber)
+	if err != nil {
+		return c.Send("Failed to get tracking info")
+	}
+
+	title := fmt.Sprintf("<code>%s</code>", html.EscapeString(tracking.TrackingNumber))
+	if tracking.DisplayName != "" {
+		title = fmt.Sprintf("%s - %s", title, html.EscapeString(tracking.DisplayName))
+	}
+	if len(tracking.Tags) > 0 {
+		title += " " + formatTags(tracking.Tags)
+	}
+
+	lines := []string{title}
+	if tracking.DeliveryDisputedAt != nil {
+		lines = append(lines, "❌ Reported as delivered, but you haven't received it")
+	}
+	if tracking.Manual {
+		lines = append(lines, formatManual(tracking))
 	}
-	return events
+	if carrier := core.CarrierByCode(tracking.Params.Carrier); carrier != nil {
+		lines = append(lines, "Carrier: "+html.EscapeString(carrier.Name))
+	}
+	if tracking.Params.PostalCode != "" {
+		lines = append(lines, "Postal code: "+html.EscapeString(tracking.Params.PostalCode))
+	}
+	if tracking.Note != "" {
+		lines = append(lines, formatNote(tracking.Note))
+	}
+	events := b.collectAllEvents(tracking)
+	for _, e := range events {
+		l := fmt.Sprintf("%s - %s", html.EscapeString(e.Time), html.EscapeString(e.Description))
+		lines = append(lines, l)
+	}
+
+	eta, err := b.service.EstimateDelivery(context.Background(), tracking)
+	if err != nil {
+		b.logger.Error("failed to estimate delivery", append(core.TrackingFields(tracking), zaperr.ToField(err))...)
+	}
+	if eta != nil {
+		lines = append(lines, "", formatETA(eta))
+	}
+
+	if err := b.sendAbout(c, trackingNumber, strings.Join(lines, "\n"), tele.ModeHTML, renameMarkup(trackingNumber)); err != nil {
+		return err
+	}
+	b.sendRouteMap(c.Chat().ID, 0, tracking)
+	return nil
+}
+
+// routeMapTimeout limits drawing a route map, which may take a while when places have to be geocoded
+const routeMapTimeout = 30 * time.Second
+
+// sendRouteMap sends the map of the route of the tracking, unless maps are disabled or no place of the route is known
+func (b *Bot) sendRouteMap(chatID int64, threadID int, tracking *core.Tracking) {
+	if !b.maps.Enabled() {
+		return
+	}
+	fields := append(core.TrackingFields(tracking), core.ChatIDField(chatID))
+	ctx, cancel := context.WithTimeout(context.Background(), routeMapTimeout)
+	defer cancel()
+	image, err := b.maps.Render(ctx, tracking)
+	if errors.Is(err, routemap.ErrNoRoute) {
+		return
+	}
+	if err != nil {
+		b.logger.Error("failed to render route map", append(fields, zaperr.ToField(err))...)
+		return
+	}
+	photo := &tele.Photo{File: tele.FromReader(bytes.NewReader(image)), Caption: "Route of " + tracking.TrackingNumber}
+	sent, err := b.bot.Send(tele.ChatID(chatID), photo, &tele.SendOptions{ThreadID: threadID})
+	if err != nil {
+		b.logger.Error("failed to send route map", append(fields, zaperr.ToField(err))...)
+		return
+	}
+	b.rememberMessage(sent, tracking.UserID, tracking.TrackingNumber)
+}
+
+// sendMilestoneRouteMap follows a notification of a milestone with the route map
+func (b *Bot) sendMilestoneRouteMap(chatID int64, threadID int, update *core.TrackingUpdate) {
+	tracking, err := b.service.GetTracking(context.Background(), update.UserID, update.TrackingNumber)
+	if errors.Is(err, core.ErrTrackingNotFound) {
+		return
+	}
+	if err != nil {
+		b.logger.Error("failed to get tracking", append(core.UpdateFields(update), zaperr.ToField(err))...)
+		return
+	}
+	b.sendRouteMap(chatID, threadID, tracking)
+}
+
+// sendAbout sends a message about the tracking, remembering it so that replies to it can refer to the tracking.
+// Texts too long for a message are sent in several, see sendChunked
+func (b *Bot) sendAbout(c tele.Context, trackingNumber string, what interface{}, opts ...interface{}) error {
+	text, ok := what.(string)
+	if !ok {
+		sent, err := b.bot.Send(c.Recipient(), what, opts...)
+		if err != nil {
+			return err
+		}
+		b.rememberMessage(sent, c.Sender().ID, trackingNumber)
+		return nil
+	}
+	sent, err := b.sendChunked(c.Recipient(), text, opts...)
+	for _, msg := range sent {
+		b.rememberMessage(msg, c.Sender().ID, trackingNumber)
+	}
+	return err
+}
+
+func (b *Bot) rememberMessage(sent *tele.Message, userID int64, trackingNumber string) {
+	if err := b.storage.SaveMessageTracking(context.Background(), sent.Chat.ID, sent.ID, userID, trackingNumber); err != nil {
+		b.logger.Error("failed to save message tracking",
+			core.UserIDField(userID), core.TrackingNumberField(trackingNumber), zaperr.ToField(err))
+	}
+}
+
+// handleNameCmd renames the parcel the replied message is about
+func (b *Bot) handleNameCmd(c tele.Context) error {
+	name := strings.TrimSpace(c.Message().Payload)
+	if c.Message().ReplyTo == nil || name == "" {
+		return c.Reply(NAME_CMD_HELP)
+	}
+	trackingNumber, err := b.repliedTracking(c)
+	if err != nil {
+		b.contextLogger(c).Error("failed to get message tracking", zaperr.ToField(err))
+		return c.Reply("Failed to rename the parcel, please try again later")
+	}
+	if trackingNumber == "" {
+		return c.Reply("I don't know which of your parcels that message is about, use /track <tracking number> <name> instead")
+	}
+	return b.rename(c, trackingNumber, name)
+}
+
+// handleText renames the parcel a rename was started for with renameBtn, if the text answers the bot's prompt
+// for the new name, and tracks the number waiting for a postal code if the text answers the prompt for it.
+// A reply to a message about a parcel recorded with SaveMessageTracking renames the parcel as well,
+// other texts, including replies to any other messages, are ignored
+func (b *Bot) handleText(c tele.Context) error {
+	name := strings.TrimSpace(c.Text())
+	// unknown commands end up here too
+	if strings.HasPrefix(name, "/") {
+		return b.handleUnknownCmd(c)
+	}
+	if replyTo := c.Message().ReplyTo; replyTo == nil || b.isPendingPostalCodePrompt(c, replyTo.ID) {
+		if pending, ok := b.takePendingPostalCode(c); ok && name != "" {
+			return b.track(c, c.Sender().ID, pending.track.trackingNumber, pending.track.displayName, pending.track.tags,
+				core.TrackingParams{PostalCode: name, Carrier: pending.track.carrier})
+		}
+	}
+	if replyTo := c.Message().ReplyTo; replyTo != nil && !b.isPendingRenamePrompt(c, replyTo.ID) {
+		return b.renameReplied(c, name)
+	}
+	pending, ok := b.takePendingRename(c)
+	if !ok || name == "" {
+		return nil
+	}
+	return b.rename(c, pending.trackingNumber, name)
+}
+
+// handleRenameBtn asks for the new name of the parcel, the next text the user sends to the chat is taken for it
+func (b *Bot) handleRenameBtn(c tele.Context) error {
+	if err := c.Respond(); err != nil {
+		b.contextLogger(c).Error("failed to respond to callback", zaperr.ToField(err))
+	}
+	trackingNumber := c.Data()
+	prompt, err := b.bot.Send(c.Recipient(), "Send me the new name of "+trackingNumber+", or any command to cancel",
+		&tele.ReplyMarkup{ForceReply: true, Placeholder: "New name"})
+	if err != nil {
+		return err
+	}
+	b.renamesMu.Lock()
+	b.renames[c.Sender().ID] = pendingRename{trackingNumber: trackingNumber, chatID: c.Chat().ID, promptID: prompt.ID, askedAt: time.Now()}
+	b.renamesMu.Unlock()
+	return nil
+}
+
+// isPendingRenamePrompt tells whether the message is the prompt for the new name of the rename the user has started
+func (b *Bot) isPendingRenamePrompt(c tele.Context, messageID int) bool {
+	b.renamesMu.Lock()
+	defer b.renamesMu.Unlock()
+	pending, ok := b.renames[c.Sender().ID]
+	return ok && pending.chatID == c.Chat().ID && pending.promptID == messageID
+}
+
+// takePendingRename returns the rename the user has started in the chat, if it hasn't timed out, and forgets it
+func (b *Bot) takePendingRename(c tele.Context) (pendingRename, bool) {
+	b.renamesMu.Lock()
+	defer b.renamesMu.Unlock()
+	pending, ok := b.renames[c.Sender().ID]
+	if !ok || pending.chatID != c.Chat().ID {
+		return pendingRename{}, false
+	}
+	delete(b.renames, c.Sender().ID)
+	return pending, time.Since(pending.askedAt) < renameTimeout
+}
+
+// askPostalCode asks for the postal code the number needs, the next text the user sends to the chat is taken for it
+func (b *Bot) askPostalCode(c tele.Context, track uncheckedTrack, msg string) error {
+	prompt, err := b.bot.Send(c.Recipient(), msg, &tele.ReplyMarkup{ForceReply: true, Placeholder: "Postal code"})
+	if err != nil {
+		return err
+	}
+	b.postalCodesMu.Lock()
+	b.postalCodes[c.Sender().ID] = pendingPostalCode{track: track, chatID: c.Chat().ID, promptID: prompt.ID, askedAt: time.Now()}
+	b.postalCodesMu.Unlock()
+	return nil
+}
+
+// isPendingPostalCodePrompt tells whether the message is the prompt for the postal code the user is asked for
+func (b *Bot) isPendingPostalCodePrompt(c tele.Context, messageID int) bool {
+	b.postalCodesMu.Lock()
+	defer b.postalCodesMu.Unlock()
+	pending, ok := b.postalCodes[c.Sender().ID]
+	return ok && pending.chatID == c.Chat().ID && pending.promptID == messageID
+}
+
+// takePendingPostalCode is takePendingRename for postal codes, they are waited for just as long as new names
+func (b *Bot) takePendingPostalCode(c tele.Context) (pendingPostalCode, bool) {
+	b.postalCodesMu.Lock()
+	defer b.postalCodesMu.Unlock()
+	pending, ok := b.postalCodes[c.Sender().ID]
+	if !ok || pending.chatID != c.Chat().ID {
+		return pendingPostalCode{}, false
+	}
+	delete(b.postalCodes, c.Sender().ID)
+	return pending, time.Since(pending.askedAt) < renameTimeout
+}
+
+// dialogMiddleware cancels renames and postal code prompts of users who send commands instead of answers
+func (b *Bot) dialogMiddleware(next tele.HandlerFunc) tele.HandlerFunc {
+	return func(c tele.Context) error {
+		if c.Callback() == nil && c.Sender() != nil && strings.HasPrefix(c.Text(), "/") {
+			b.renamesMu.Lock()
+			delete(b.renames, c.Sender().ID)
+			b.renamesMu.Unlock()
+			b.postalCodesMu.Lock()
+			delete(b.postalCodes, c.Sender().ID)
+			b.postalCodesMu.Unlock()
+		}
+		return next(c)
+	}
+}
+
+// renameMarkup is nil if the tracking number is too long to fit into callback data
+func renameMarkup(trackingNumber string) *tele.ReplyMarkup {
+	markup := &tele.ReplyMarkup{}
+	btn := markup.Data("✏️ Rename", renameBtn.Unique, trackingNumber)
+	if len(btn.Unique)+len(btn.Data)+2 > 64 {
+		return nil
+	}
+	markup.Inline(markup.Row(btn))
+	return markup
+}
+
+// handleUnknownCmd suggests the command the user most likely meant. In groups, commands that aren't close
+// to any of ours are left alone, they may well be meant for other bots
+func (b *Bot) handleUnknownCmd(c tele.Context) error {
+	if suggestion := suggestCommand(c.Text()); suggestion != "" {
+		return c.Send("Did you mean " + suggestion + "?")
+	}
+	if c.Chat().Type == tele.ChatPrivate {
+		return c.Send("I don't know this command, see /help for the ones I know")
+	}
+	return nil
+}
+
+// renameReplied renames the parcel the replied message is about, if the bot knows of one
+func (b *Bot) renameReplied(c tele.Context, name string) error {
+	if name == "" {
+		return nil
+	}
+	trackingNumber, err := b.repliedTracking(c)
+	if err != nil {
+		b.contextLogger(c).Error("failed to get message tracking", zaperr.ToField(err))
+		return nil
+	}
+	if trackingNumber == "" {
+		return nil
+	}
+	return b.rename(c, trackingNumber, name)
+}
+
+// repliedTracking is the tracking of the sender the replied message is about, empty if there is none
+func (b *Bot) repliedTracking(c tele.Context) (string, error) {
+	replyTo := c.Message().ReplyTo
+	userID, trackingNumber, err := b.storage.MessageTracking(context.Background(), replyTo.Chat.ID, replyTo.ID)
+	if err != nil || userID != c.Sender().ID {
+		// in groups, parcels of others can't be renamed
+		return "", err
+	}
+	return trackingNumber, nil
+}
+
+func (b *Bot) rename(c tele.Context, trackingNumber string, name string) error {
+	err := b.service.Rename(context.Background(), c.Sender().ID, trackingNumber, name)
+	if errors.Is(err, core.ErrTrackingNotFound) {
+		return c.Reply("You are not tracking " + trackingNumber + " anymore")
+	}
+	if err != nil {
+		b.contextLogger(c).Error("failed to rename tracking", core.TrackingNumberField(trackingNumber), zaperr.ToField(err))
+		return c.Reply("Failed to rename " + trackingNumber + ", please try again later")
+	}
+	return c.Reply("Renamed " + trackingNumber + " to " + name)
+}
+
+func (b *Bot) handleNoteCmd(c tele.Context) error {
+	args := strings.Fields(c.Message().Payload)
+	if len(args) == 0 {
+		return c.Send(NOTE_CMD_HELP, tele.ModeMarkdown)
+	}
+
+	userID := c.Message().Sender.ID
+	trackingNumber := args[0]
+	// the note keeps its line breaks, only the tracking number is cut off
+	note := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(c.Message().Payload), trackingNumber))
+	err := b.service.SetNote(context.Background(), userID, trackingNumber, note)
+	switch {
+	case err == nil && note == "":
+		return c.Send("Removed the note of " + trackingNumber)
+	case err == nil:
+		return c.Send("Saved the note of " + trackingNumber)
+	case errors.Is(err, core.ErrTrackingNotFound):
+		return c.Send("You're not tracking " + trackingNumber)
+	case errors.Is(err, core.ErrNoteTooLong):
+		return c.Send(fmt.Sprintf("Please keep notes under %d characters", core.MaxNoteLength))
+	}
+	b.contextLogger(c).Error("failed to set note", core.TrackingNumberField(trackingNumber), zaperr.ToField(err))
+	return c.Send("Failed to save the note")
+}
+
+// formatNote renders the note for messages in HTML mode
+func formatNote(note string) string {
+	return "<i>" + html.EscapeString(note) + "</i>"
+}
+
+// manualDateLayout is how expected dates of manual parcels are given to /manual
+const manualDateLayout = "2006-01-02"
+
+func (b *Bot) handleManualCmd(c tele.Context) error {
+	args := strings.Fields(c.Message().Payload)
+	var expectedAt *time.Time
+	if len(args) > 0 {
+		if date, err := time.Parse(manualDateLayout, args[len(args)-1]); err == nil {
+			expectedAt = &date
+			args = args[:len(args)-1]
+		}
+	}
+	name := strings.Join(args, " ")
+	if name == "" {
+		return c.Send(MANUAL_CMD_HELP, tele.ModeMarkdown)
+	}
+
+	userID := c.Message().Sender.ID
+	tracking, err := b.service.TrackManual(context.Background(), userID, name, expectedAt)
+	var quotaErr *core.TrackingQuotaExceededError
+	if errors.As(err, &quotaErr) {
+		return c.Send(fmt.Sprintf("You're tracking %d parcels, which is the limit. Please /delete some first", quotaErr.Limit))
+	}
+	if err != nil {
+		b.contextLogger(c).Error("failed to add manual parcel", zaperr.ToField(err))
+		return c.Send("Failed to add the parcel, please try again later")
+	}
+	b.bindToTopic(c, userID, tracking.TrackingNumber)
+
+	msg := fmt.Sprintf("Added %s as <code>%s</code>", html.EscapeString(name), html.EscapeString(tracking.TrackingNumber))
+	if expectedAt != nil {
+		msg += fmt.Sprintf(", I'll remind you of it on %s", expectedAt.Format("Jan 2"))
+	}
+	msg += fmt.Sprintf("\nOnce you get a tracking number, /attach %s &lt;tracking number&gt;", html.EscapeString(tracking.TrackingNumber))
+	return b.sendAbout(c, tracking.TrackingNumber, msg, tele.ModeHTML)
+}
+
+func (b *Bot) handleAttachCmd(c tele.Context) error {
+	args := c.Args()
+	if len(args) != 2 {
+		return c.Send(ATTACH_CMD_HELP, tele.ModeMarkdown)
+	}
+
+	userID := c.Message().Sender.ID
+	manualNumber, trackingNumber := args[0], args[1]
+	err := b.service.AttachTrackingNumber(context.Background(), userID, manualNumber, trackingNumber)
+	switch {
+	case errors.Is(err, core.ErrInvalidTrackingNumber):
+		return c.Send(fmt.Sprintf("%s doesn't look like a tracking number (%s), please check it",
+			trackingNumber, strings.TrimPrefix(err.Error(), core.ErrInvalidTrackingNumber.Error()+": ")))
+	case errors.Is(err, core.ErrTrackingNotFound):
+		return c.Send("You're not tracking " + manualNumber)
+	case errors.Is(err, core.ErrNotManual):
+		return c.Send(manualNumber + " already has a tracking number")
+	case errors.Is(err, core.ErrTrackingExists):
+		return c.Send("You're already tracking " + trackingNumber + ", /delete " + manualNumber + " if it's the same parcel")
+	case errors.Is(err, core.ErrPostalCodeRequired):
+		return c.Send(trackingNumber + " needs the recipient's postal code to be tracked, please /track it and /delete " + manualNumber)
+	case err != nil:
+		b.contextLogger(c).Error("failed to attach tracking number", core.TrackingNumberField(trackingNumber), zaperr.ToField(err))
+		return c.Send("Failed to attach the tracking number, please try again later")
+	}
+
+	// updates of the parcel keep going to the topic it was added in
+	topic, err := b.storage.TrackingTopic(context.Background(), userID, manualNumber)
+	if err != nil {
+		b.contextLogger(c).Error("failed to get tracking topic", core.TrackingNumberField(manualNumber), zaperr.ToField(err))
+	}
+	if topic != nil {
+		if err := b.storage.SaveTrackingTopic(context.Background(), userID, trackingNumber, topic); err != nil {
+			b.contextLogger(c).Error("failed to save tracking topic", core.TrackingNumberField(trackingNumber), zaperr.ToField(err))
+		}
+	}
+
+	msg := "Started tracking " + trackingNumber + " instead of " + manualNumber
+	if carrier := core.DetectCarrier(trackingNumber); carrier != nil {
+		msg += " (looks like " + carrier.Name + ")"
+	}
+	return b.sendAbout(c, trackingNumber, msg)
+}
+
+// formatManual tells that the parcel is manual, and when it is expected if the user knows
+func formatManual(tracking *core.Tracking) string {
+	if tracking.ExpectedAt == nil {
+		return "📝 No tracking number"
+	}
+	return "📝 No tracking number, expected on " + tracking.ExpectedAt.Format("Jan 2")
+}
+
+func (b *Bot) handleListCmd(c tele.Context) error {
+	tag := ""
+	if args := c.Args(); len(args) > 0 {
+		tag = args[0]
+	}
+	text, markup, err := b.listPage(c.Sender().ID, tag, 0)
+	if errors.Is(err, core.ErrInvalidTag) {
+		return c.Send(LIST_CMD_HELP, tele.ModeMarkdown)
+	}
+	if err != nil {
+		return c.Send("Failed to list trackings")
+	}
+	// notes and events of a page of trackings may be too long for a message
+	_, err = b.sendChunked(c.Recipient(), text, tele.ModeHTML, markup)
+	return err
+}
+
+func (b *Bot) handleListPageBtn(c tele.Context) error {
+	if err := c.Respond(); err != nil {
+		b.contextLogger(c).Error("failed to respond to callback", zaperr.ToField(err))
+	}
+	data, tag, _ := strings.Cut(c.Data(), "|")
+	cursor, err := strconv.ParseInt(data, 10, 64)
+	if err != nil {
+		return nil
+	}
+	text, markup, err := b.listPage(c.Sender().ID, tag, cursor)
+	if err != nil {
+		return c.Send("Failed to list trackings")
+	}
+	return b.editChunked(c, text, tele.ModeHTML, markup)
+}
+
+// listPage renders a page of user's trackings, all of them or only the ones tagged with the tag if it's not empty,
+// with a button leading to the next page if there is one
+func (b *Bot) listPage(userID int64, tag string, cursor int64) (string, *tele.ReplyMarkup, error) {
+	var page *core.TrackingsPage
+	var err error
+	if tag == "" {
+		page, err = b.service.ListTrackings(context.Background(), userID, cursor, listPageSize)
+	} else {
+		page, err = b.service.ListTrackingsByTag(context.Background(), userID, tag, cursor, listPageSize)
+	}
+	if errors.Is(err, core.ErrInvalidTag) {
+		return "", nil, err
+	}
+	if err != nil {
+		b.logger.Error("failed to list trackings", core.UserIDField(userID), zaperr.ToField(err))
+		return "", nil, err
+	}
+
+	var lines []string
+	for _, tracking := range page.Trackings {
+		l := fmt.Sprintf("<code>%s</code>", html.EscapeString(tracking.TrackingNumber))
+		if tracking.DisplayName != "" {
+			l = fmt.Sprintf("%s - %s", l, html.EscapeString(tracking.DisplayName))
+		}
+		if len(tracking.Tags) > 0 {
+			l += " " + formatTags(tracking.Tags)
+		}
+		lines = append(lines, l)
+		if tracking.Manual {
+			lines = append(lines, formatManual(tracking))
+		}
+		if tracking.Note != "" {
+			lines = append(lines, formatNote(tracking.Note))
+		}
+
+		events := b.collectAllEvents(tracking)
+		if len(events) > 0 {
+			e := events[len(events)-1]
+			l := fmt.Sprintf("%s - %s", html.EscapeString(e.Time), html.EscapeString(e.Description))
+			lines = append(lines, l)
+		}
+
+		lines = append(lines, "")
+	}
+	if len(page.Trackings) == 0 && tag != "" {
+		lines = append(lines, "You're not tracking any parcels tagged "+html.EscapeString(tag))
+	} else if len(page.Trackings) == 0 {
+		lines = append(lines, "You're not tracking any parcels")
+	}
+
+	markup := &tele.ReplyMarkup{}
+	var rows []tele.Row
+	for _, tracking := range page.Trackings {
+		btn := markup.Data("✏️ "+tracking.TrackingNumber, renameBtn.Unique, tracking.TrackingNumber)
+		if len(btn.Unique)+len(btn.Data)+2 <= 64 {
+			rows = append(rows, markup.Row(btn))
+		}
+	}
+	if page.NextCursor != 0 {
+		lines = append(lines, fmt.Sprintf("%d parcels in total", page.Total))
+		data := []string{strconv.FormatInt(page.NextCursor, 10)}
+		if tag != "" {
+			data = append(data, tag)
+		}
+		btn := markup.Data("Next ▶", listPageBtn.Unique, data...)
+		// Telegram limits callback data to 64 bytes, which long tags of non-latin letters may not fit into
+		if len(btn.Unique)+len(btn.Data)+2 <= 64 {
+			rows = append(rows, markup.Row(btn))
+		}
+	}
+	markup.Inline(rows...)
+
+	return strings.Join(lines, "\n"), markup, nil
+}
+
+func (b *Bot) handleDeleteCmd(c tele.Context) error {
+	args := c.Args()
+	if len(args) == 0 {
+		return c.Send("Please specify tracking number")
+	}
+
+	userID := c.Message().Sender.ID
+	trackingNumber := args[0]
+
+	if err := b.service.DeleteTracking(context.Background(), userID, trackingNumber); err == nil {
+		deletedAt := time.Now()
+		b.deletionsMu.Lock()
+		b.deletions[userID] = deletion{trackingNumber: trackingNumber, deletedAt: deletedAt}
+		b.deletionsMu.Unlock()
+		return c.Send("Stopped tracking "+trackingNumber+", use /restore to undo", undoMarkup(trackingNumber, deletedAt))
+	} else {
+		b.contextLogger(c).Error("failed to stop tracking", core.TrackingNumberField(trackingNumber), zaperr.ToField(err))
+	}
+	return nil
+}
+
+// handleCleanupCmd stops tracking delivered and returned parcels. They can be restored all at once
+// with the undo button within undoWindow, and one by one with /restore like deleted ones
+func (b *Bot) handleCleanupCmd(c tele.Context) error {
+	tag := ""
+	if args := c.Args(); len(args) > 0 {
+		tag = args[0]
+	}
+	userID := c.Sender().ID
+	deleted, err := b.service.DeleteFinishedTrackings(context.Background(), userID, tag)
+	if errors.Is(err, core.ErrInvalidTag) {
+		return c.Send(CLEANUP_CMD_HELP, tele.ModeMarkdown)
+	}
+	if err != nil {
+		b.contextLogger(c).Error("failed to delete finished trackings", zaperr.ToField(err))
+		if len(deleted) == 0 {
+			return c.Send("Failed to clean up, please try again later")
+		}
+	}
+	if len(deleted) == 0 {
+		return c.Send("There are no delivered or returned parcels to clean up")
+	}
+
+	lines := []string{fmt.Sprintf("Stopped tracking %d delivered and returned parcels:", len(deleted))}
+	for _, trackingNumber := range deleted {
+		lines = append(lines, "<code>"+html.EscapeString(trackingNumber)+"</code>")
+	}
+	lines = append(lines, "", "Use /restore &lt;tracking number&gt; to resume receiving updates about any of them")
+
+	deletedAt := time.Now()
+	b.cleanupsMu.Lock()
+	b.cleanups[userID] = cleanup{trackingNumbers: deleted, deletedAt: deletedAt}
+	b.cleanupsMu.Unlock()
+	markup := &tele.ReplyMarkup{}
+	markup.Inline(markup.Row(markup.Data("↩️ Undo", undoCleanupBtn.Unique, strconv.FormatInt(deletedAt.Unix(), 10))))
+	return c.Send(strings.Join(lines, "\n"), tele.ModeHTML, markup)
+}
+
+// handleUndoCleanupBtn restores all trackings stopped by the user's latest /cleanup, if it was within undoWindow
+func (b *Bot) handleUndoCleanupBtn(c tele.Context) error {
+	if err := c.Respond(); err != nil {
+		b.contextLogger(c).Error("failed to respond to callback", zaperr.ToField(err))
+	}
+	deletedAt, err := strconv.ParseInt(c.Data(), 10, 64)
+	if err != nil {
+		return nil
+	}
+	userID := c.Sender().ID
+	b.cleanupsMu.Lock()
+	last, ok := b.cleanups[userID]
+	// older cleanups are forgotten, and so are the ones before restart
+	ok = ok && last.deletedAt.Unix() == deletedAt && time.Since(last.deletedAt) <= undoWindow
+	if ok {
+		delete(b.cleanups, userID)
+	}
+	b.cleanupsMu.Unlock()
+	if !ok {
+		return c.Send("It's too late to undo the cleanup, use /restore &lt;tracking number&gt; to resume tracking of any of the parcels", tele.ModeHTML)
+	}
+
+	restored := 0
+	var failed []string
+	for _, trackingNumber := range last.trackingNumbers {
+		err := b.service.RestoreTracking(context.Background(), userID, trackingNumber)
+		if errors.Is(err, core.ErrTrackingNotFound) {
+			// restored with /restore or tracked anew in the meantime
+			continue
+		}
+		if err != nil {
+			b.contextLogger(c).Error("failed to undo cleanup", core.TrackingNumberField(trackingNumber), zaperr.ToField(err))
+			failed = append(failed, trackingNumber)
+			continue
+		}
+		restored++
+	}
+
+	text := fmt.Sprintf("Resumed tracking %d parcels with all their history", restored)
+	if len(failed) > 0 {
+		text += "\nFailed to restore " + strings.Join(failed, ", ") + ", please try /restore for them"
+	}
+	return c.Edit(text)
+}
+
+// undoMarkup is nil if the tracking number is too long to fit into callback data
+func undoMarkup(trackingNumber string, deletedAt time.Time) *tele.ReplyMarkup {
+	markup := &tele.ReplyMarkup{}
+	btn := markup.Data("↩️ Undo", undoBtn.Unique, strconv.FormatInt(deletedAt.Unix(), 10), trackingNumber)
+	// Telegram limits callback data to 64 bytes
+	if len(btn.Unique)+len(btn.Data)+2 > 64 {
+		return nil
+	}
+	markup.Inline(markup.Row(btn))
+	return markup
+}
+
+// handleUndoCmd restores the tracking the user deleted last, if that was within undoWindow
+func (b *Bot) handleUndoCmd(c tele.Context) error {
+	userID := c.Sender().ID
+	b.deletionsMu.Lock()
+	last, ok := b.deletions[userID]
+	b.deletionsMu.Unlock()
+	if !ok || time.Since(last.deletedAt) > undoWindow {
+		return c.Send("There is nothing to undo, use /restore <tracking number> to resume tracking of a parcel stopped earlier")
+	}
+	return b.undo(c, userID, last.trackingNumber, c.Send)
+}
+
+func (b *Bot) handleUndoBtn(c tele.Context) error {
+	if err := c.Respond(); err != nil {
+		b.contextLogger(c).Error("failed to respond to callback", zaperr.ToField(err))
+	}
+	deletedAtUnix, trackingNumber, ok := strings.Cut(c.Data(), "|")
+	if !ok {
+		return nil
+	}
+	deletedAt, err := strconv.ParseInt(deletedAtUnix, 10, 64)
+	if err != nil {
+		return nil
+	}
+	if time.Since(time.Unix(deletedAt, 0)) > undoWindow {
+		return c.Edit("Stopped tracking " + trackingNumber + ", it's too late to undo, use /restore " + trackingNumber + " instead")
+	}
+	return b.undo(c, c.Sender().ID, trackingNumber, func(what interface{}, opts ...interface{}) error {
+		return c.Edit(what, opts...)
+	})
+}
+
+// undo restores the tracking, answering with reply, which either sends a message or edits the one with the button
+func (b *Bot) undo(c tele.Context, userID int64, trackingNumber string, reply func(what interface{}, opts ...interface{}) error) error {
+	err := b.service.RestoreTracking(context.Background(), userID, trackingNumber)
+	var quotaErr *core.TrackingQuotaExceededError
+	switch {
+	case errors.Is(err, core.ErrTrackingNotFound):
+		// the button may be pressed twice, or the number tracked anew in the meantime
+		if _, err := b.service.GetTracking(context.Background(), userID, trackingNumber); err == nil {
+			return reply("You're tracking " + trackingNumber + " already")
+		}
+		return reply("No recently stopped tracking of " + trackingNumber + " found")
+	case errors.As(err, &quotaErr):
+		return c.Send(fmt.Sprintf("You're tracking %d parcels, which is the limit. Please /delete some first", quotaErr.Limit))
+	case err != nil:
+		b.contextLogger(c).Error("failed to undo deletion", core.TrackingNumberField(trackingNumber), zaperr.ToField(err))
+		return c.Send("Failed to restore " + trackingNumber)
+	}
+	b.deletionsMu.Lock()
+	if last, ok := b.deletions[userID]; ok && last.trackingNumber == trackingNumber {
+		delete(b.deletions, userID)
+	}
+	b.deletionsMu.Unlock()
+	return reply("Resumed tracking " + trackingNumber + " with all its history")
+}
+
+// handleConfirmDeliveryBtn records whether a delivered parcel has actually been received:
+// received ones stop being tracked, the rest keep being tracked and are flagged
+func (b *Bot) handleConfirmDeliveryBtn(c tele.Context) error {
+	answer, trackingNumber, ok := strings.Cut(c.Data(), "|")
+	if !ok || (answer != "yes" && answer != "no") {
+		return c.Respond()
+	}
+	received := answer == "yes"
+	userID := c.Sender().ID
+	err := b.service.ConfirmDelivery(context.Background(), userID, trackingNumber, received)
+	switch {
+	case errors.Is(err, core.ErrTrackingNotFound):
+		return c.Respond(&tele.CallbackResponse{Text: "You are not tracking " + trackingNumber + " anymore"})
+	case errors.Is(err, core.ErrNotDelivered):
+		return c.Respond(&tele.CallbackResponse{Text: trackingNumber + " isn't delivered according to the carrier anymore"})
+	case err != nil:
+		b.contextLogger(c).Error("failed to confirm delivery", core.TrackingNumberField(trackingNumber), zaperr.ToField(err))
+		return c.Respond(&tele.CallbackResponse{Text: "Failed to save the answer, please try again later"})
+	}
+	if err := c.Respond(); err != nil {
+		b.contextLogger(c).Error("failed to respond to callback", zaperr.ToField(err))
+	}
+	if err := c.Edit(refreshMarkup(trackingNumber)); err != nil {
+		b.contextLogger(c).Error("failed to remove delivery confirmation buttons", zaperr.ToField(err))
+	}
+	if !received {
+		return c.Reply("Sorry to hear that. I'll keep tracking " + trackingNumber + " and /cleanup won't remove it. " +
+			"It may be worth contacting the carrier: parcels get left with neighbours or at pickup points at times")
+	}
+	deletedAt := time.Now()
+	b.deletionsMu.Lock()
+	b.deletions[userID] = deletion{trackingNumber: trackingNumber, deletedAt: deletedAt}
+	b.deletionsMu.Unlock()
+	return c.Reply("Glad it has arrived! Stopped tracking "+trackingNumber+", use /restore to undo", undoMarkup(trackingNumber, deletedAt))
+}
+
+func (b *Bot) handleRestoreCmd(c tele.Context) error {
+	args := c.Args()
+	if len(args) == 0 {
+		return c.Send(RESTORE_CMD_HELP, tele.ModeMarkdown)
+	}
+
+	userID := c.Sender().ID
+	trackingNumber := args[0]
+	err := b.service.RestoreTracking(context.Background(), userID, trackingNumber)
+	var quotaErr *core.TrackingQuotaExceededError
+	if errors.Is(err, core.ErrTrackingNotFound) {
+		return c.Send("No recently stopped tracking of " + trackingNumber + " found")
+	} else if errors.As(err, &quotaErr) {
+		return c.Send(fmt.Sprintf("You're tracking %d parcels, which is the limit. Please /delete some first", quotaErr.Limit))
+	} else if err != nil {
+		b.contextLogger(c).Error("failed to restore tracking", core.TrackingNumberField(trackingNumber), zaperr.ToField(err))
+		return c.Send("Failed to restore " + trackingNumber)
+	}
+	return c.Send("Resumed tracking " + trackingNumber)
+}
+
+func (b *Bot) handleRefreshCmd(c tele.Context) error {
+	args := c.Args()
+	if len(args) == 0 {
+		return c.Send(REFRESH_CMD_HELP, tele.ModeMarkdown)
+	}
+	return b.refresh(c, args[0])
+}
+
+func (b *Bot) handleRefreshBtn(c tele.Context) error {
+	if err := c.Respond(); err != nil {
+		b.contextLogger(c).Error("failed to respond to callback", zaperr.ToField(err))
+	}
+	return b.refresh(c, c.Data())
+}
+
+func (b *Bot) refresh(c tele.Context, trackingNumber string) error {
+	userID := c.Sender().ID
+	update, err := b.service.ForceRefresh(context.Background(), userID, trackingNumber)
+	if errors.Is(err, core.ErrManualTracking) {
+		return c.Send(trackingNumber + " has no tracking number to check, /attach one once you get it")
+	}
+	if err != nil {
+		code := core.ErrorCodeOf(err)
+		switch code {
+		case core.ErrorCodeNotFound:
+			return c.Send("Tracking info for " + trackingNumber + " is not found yet")
+		case core.ErrorCodeInternal:
+			b.contextLogger(c).Error("failed to refresh tracking", core.TrackingNumberField(trackingNumber), zaperr.ToField(err))
+			return c.Send("Failed to refresh " + trackingNumber)
+		}
+		return c.Send(trackingErrorMessage(trackingNumber, code))
+	}
+	if update == nil {
+		return b.sendAbout(c, trackingNumber, "No changes for "+trackingNumber, refreshMarkup(trackingNumber))
+	}
+	if err := b.sendAbout(c, trackingNumber, b.formatTrackingUpdate(update), tele.ModeHTML, updateMarkup(update)); err != nil {
+		return err
+	}
+	b.markUpdateDelivered(update)
+	return nil
+}
+
+func (b *Bot) handleHistoryCmd(c tele.Context) error {
+	args := c.Args()
+	if len(args) == 0 {
+		return c.Send(HISTORY_CMD_HELP, tele.ModeMarkdown)
+	}
+
+	userID := c.Sender().ID
+	trackingNumber := args[0]
+	records, err := b.service.TrackingHistory(context.Background(), userID, trackingNumber)
+	if err != nil {
+		b.contextLogger(c).Error("failed to get tracking history", core.TrackingNumberField(trackingNumber), zaperr.ToField(err))
+		return c.Send("Failed to get history of " + trackingNumber)
+	}
+	if len(records) == 0 {
+		return c.Send("No history for " + trackingNumber)
+	}
+
+	lines := []string{fmt.Sprintf("<code>%s</code>", trackingNumber)}
+	for _, r := range records {
+		l := fmt.Sprintf("%s - %s", r.CreatedAt.UTC().Format(time.RFC3339), r.Action)
+		if r.Details != "" {
+			l = fmt.Sprintf("%s %s", l, html.EscapeString(r.Details))
+		}
+		lines = append(lines, l)
+	}
+
+	return c.Send(strings.Join(lines, "\n"), tele.ModeHTML)
+}
+
+func (b *Bot) handleLastUpdateCmd(c tele.Context) error {
+	args := c.Args()
+	if len(args) == 0 {
+		return c.Send(LASTUPDATE_CMD_HELP, tele.ModeMarkdown)
+	}
+
+	userID := c.Sender().ID
+	trackingNumber := args[0]
+	record, err := b.service.LastUpdate(context.Background(), userID, trackingNumber)
+	if errors.Is(err, core.ErrTrackingNotFound) {
+		return c.Send("You're not tracking " + trackingNumber)
+	}
+	if err != nil {
+		b.contextLogger(c).Error("failed to get last update", core.TrackingNumberField(trackingNumber), zaperr.ToField(err))
+		return c.Send("Failed to get the last update of " + trackingNumber)
+	}
+	if record == nil {
+		return b.sendAbout(c, trackingNumber, "No updates of "+trackingNumber+" yet", refreshMarkup(trackingNumber))
+	}
+
+	// the record tells whether the update has reached the user, which is what "I never got notified" comes down to
+	header := fmt.Sprintf("<i>Last update, %s UTC", record.CreatedAt.UTC().Format("2006-01-02 15:04"))
+	if record.DeliveredAt == nil {
+		header += ", not delivered yet"
+	}
+	header += "</i>"
+	text := header + "\n" + b.formatTrackingUpdate(record.Update)
+	return b.sendAbout(c, trackingNumber, text, tele.ModeHTML, updateMarkup(record.Update))
+}
+
+func (b *Bot) handleDeleteMyDataCmd(c tele.Context) error {
+	markup := &tele.ReplyMarkup{}
+	markup.Inline(markup.Row(
+		markup.Data("Yes, delete everything", deleteMyDataBtn.Unique, "yes"),
+		markup.Data("Cancel", deleteMyDataBtn.Unique, "no"),
+	))
+	return c.Send(
+		"This will stop tracking all your parcels and delete everything I know about you, including history. "+
+			"It can't be undone. Are you sure?",
+		markup,
+	)
+}
+
+func (b *Bot) handleDeleteMyDataBtn(c tele.Context) error {
+	if err := c.Respond(); err != nil {
+		b.contextLogger(c).Error("failed to respond to callback", zaperr.ToField(err))
+	}
+	if c.Data() != "yes" {
+		return c.Edit("Cancelled, nothing was deleted")
+	}
+
+	userID := c.Sender().ID
+	if err := b.service.DeleteUserData(context.Background(), userID); err != nil {
+		b.contextLogger(c).Error("failed to delete user data", zaperr.ToField(err))
+		return c.Edit("Failed to delete your data, please try again later")
+	}
+	if err := b.channels.DeleteUserData(context.Background(), userID); err != nil {
+		b.contextLogger(c).Error("failed to delete notification channels", zaperr.ToField(err))
+		return c.Edit("Failed to delete your data, please try again later")
+	}
+	if err := b.feeds.Revoke(context.Background(), userID); err != nil {
+		b.contextLogger(c).Error("failed to revoke feed", zaperr.ToField(err))
+		return c.Edit("Failed to delete your data, please try again later")
+	}
+	// chat mapping goes last, so that a failure above leaves a way to reach the user
+	if err := b.storage.DeleteUserData(context.Background(), userID); err != nil {
+		b.contextLogger(c).Error("failed to delete user chat", zaperr.ToField(err))
+		return c.Edit("Failed to delete your data, please try again later")
+	}
+	b.deletionsMu.Lock()
+	delete(b.deletions, userID)
+	b.deletionsMu.Unlock()
+	return c.Edit("All your data has been deleted. Goodbye!")
+}
+
+func (b *Bot) handleNotificationsCmd(c tele.Context) error {
+	level, err := b.service.NotificationLevel(context.Background(), c.Sender().ID)
+	if err != nil {
+		b.contextLogger(c).Error("failed to get notification level", zaperr.ToField(err))
+		return c.Send("Failed to get your notification settings, please try again later")
+	}
+	return c.Send(notificationLevelText, notificationLevelMarkup(level))
+}
+
+func (b *Bot) handleNotificationLevelBtn(c tele.Context) error {
+	if err := c.Respond(); err != nil {
+		b.contextLogger(c).Error("failed to respond to callback", zaperr.ToField(err))
+	}
+	level := core.NotificationLevel(c.Data())
+	err := b.service.SetNotificationLevel(context.Background(), c.Sender().ID, level)
+	if errors.Is(err, core.ErrInvalidNotificationLevel) {
+		return nil
+	}
+	if err != nil {
+		b.contextLogger(c).Error("failed to set notification level", zaperr.ToField(err))
+		return c.Send("Failed to save your notification settings, please try again later")
+	}
+	return c.Edit(notificationLevelText, notificationLevelMarkup(level))
+}
+
+const notificationLevelText = "What should I notify you of? Updates you aren't notified of can still be seen with /info"
+
+// notificationLevelMarkup offers all the levels, marking the current one
+func notificationLevelMarkup(current core.NotificationLevel) *tele.ReplyMarkup {
+	markup := &tele.ReplyMarkup{}
+	var rows []tele.Row
+	for _, level := range core.NotificationLevels {
+		text := notificationLevelNames[level]
+		if level == current {
+			text = "✅ " + text
+		}
+		rows = append(rows, markup.Row(markup.Data(text, notificationLevelBtn.Unique, string(level))))
+	}
+	markup.Inline(rows...)
+	return markup
+}
+
+// handleSettingsCmd sets up notification channels:
+//
+//	/settings                          shows channels
+//	/settings <channel> <destination>  sends updates to the destination too, once it is verified
+//	/settings verify <channel> <code>  verifies the destination with the code sent there
+//	/settings <channel> off            stops sending updates through the channel
+//
+// handleTrackingLevelsBtn replaces buttons of an update message with per-tracking notification levels
+func (b *Bot) handleTrackingLevelsBtn(c tele.Context) error {
+	if err := c.Respond(); err != nil {
+		b.contextLogger(c).Error("failed to respond to callback", zaperr.ToField(err))
+	}
+	trackingNumber := c.Data()
+	tracking, err := b.service.GetTracking(context.Background(), c.Sender().ID, trackingNumber)
+	if errors.Is(err, core.ErrTrackingNotFound) {
+		return c.Send("You are not tracking " + trackingNumber + " anymore")
+	}
+	if err != nil {
+		b.contextLogger(c).Error("failed to get tracking", core.TrackingNumberField(trackingNumber), zaperr.ToField(err))
+		return c.Send("Failed to get notification settings of " + trackingNumber + ", please try again later")
+	}
+	return c.Edit(trackingLevelMarkup(trackingNumber, tracking.NotificationLevel))
+}
+
+func (b *Bot) handleTrackingLevelBtn(c tele.Context) error {
+	data := strings.SplitN(c.Data(), "|", 2)
+	if len(data) != 2 {
+		return c.Respond()
+	}
+	level, trackingNumber := core.NotificationLevel(data[0]), data[1]
+	err := b.service.SetTrackingNotificationLevel(context.Background(), c.Sender().ID, trackingNumber, level)
+	if errors.Is(err, core.ErrInvalidNotificationLevel) {
+		return c.Respond()
+	}
+	if errors.Is(err, core.ErrTrackingNotFound) {
+		return c.Respond(&tele.CallbackResponse{Text: "You are not tracking " + trackingNumber + " anymore"})
+	}
+	if err != nil {
+		b.contextLogger(c).Error("failed to set tracking notification level", core.TrackingNumberField(trackingNumber), zaperr.ToField(err))
+		return c.Respond(&tele.CallbackResponse{Text: "Failed to save notification settings, please try again later"})
+	}
+	text := "Notifications of " + trackingNumber + " follow /notifications"
+	if level != "" {
+		text = "Notifications of " + trackingNumber + ": " + notificationLevelNames[level]
+	}
+	if err := c.Respond(&tele.CallbackResponse{Text: text}); err != nil {
+		b.contextLogger(c).Error("failed to respond to callback", zaperr.ToField(err))
+	}
+	return c.Edit(refreshMarkup(trackingNumber))
+}
+
+// trackingLevelMarkup offers all the levels for the tracking, marking the current one
+func trackingLevelMarkup(trackingNumber string, current core.NotificationLevel) *tele.ReplyMarkup {
+	markup := &tele.ReplyMarkup{}
+	levels := append([]core.NotificationLevel{""}, core.NotificationLevels...)
+	var rows []tele.Row
+	for _, level := range levels {
+		text := notificationLevelNames[level]
+		if level == "" {
+			text = "As in /notifications"
+		}
+		if level == current {
+			text = "✅ " + text
+		}
+		rows = append(rows, markup.Row(markup.Data(text, trackingLevelBtn.Unique, string(level), trackingNumber)))
+	}
+	markup.Inline(rows...)
+	return markup
+}
+
+// handleTopicCmd binds a tracking to the topic the command is sent in, or unbinds it outside of topics
+func (b *Bot) handleTopicCmd(c tele.Context) error {
+	args := c.Args()
+	if len(args) == 0 {
+		return c.Reply(TOPIC_CMD_HELP)
+	}
+	trackingNumber := args[0]
+	userID := c.Sender().ID
+	_, err := b.service.GetTracking(context.Background(), userID, trackingNumber)
+	if errors.Is(err, core.ErrTrackingNotFound) {
+		return c.Reply("You are not tracking " + trackingNumber)
+	}
+	if err != nil {
+		b.contextLogger(c).Error("failed to get tracking", core.TrackingNumberField(trackingNumber), zaperr.ToField(err))
+		return c.Reply("Failed to get " + trackingNumber + ", please try again later")
+	}
+
+	topic := topicOf(c.Message())
+	if err := b.storage.SaveTrackingTopic(context.Background(), userID, trackingNumber, topic); err != nil {
+		b.contextLogger(c).Error("failed to save tracking topic", core.TrackingNumberField(trackingNumber), zaperr.ToField(err))
+		return c.Reply("Failed to save the topic, please try again later")
+	}
+	if topic == nil {
+		return c.Reply("Updates about " + trackingNumber + " will be sent to you")
+	}
+	return c.Reply("Updates about " + trackingNumber + " will be sent to this topic")
+}
+
+// bindToTopic binds the trackings to the topic the command is sent in, if any
+func (b *Bot) bindToTopic(c tele.Context, userID int64, trackingNumbers ...string) {
+	topic := topicOf(c.Message())
+	if topic == nil {
+		return
+	}
+	for _, trackingNumber := range trackingNumbers {
+		if err := b.storage.SaveTrackingTopic(context.Background(), userID, trackingNumber, topic); err != nil {
+			b.contextLogger(c).Error("failed to save tracking topic", core.TrackingNumberField(trackingNumber), zaperr.ToField(err))
+		}
+	}
+}
+
+// topicOf is the forum topic the message is in, nil for messages outside of topics
+func topicOf(m *tele.Message) *Topic {
+	if m == nil || !m.TopicMessage || m.Chat == nil {
+		return nil
+	}
+	return &Topic{ChatID: m.Chat.ID, ThreadID: m.ThreadID}
+}
+
+func (b *Bot) handleSettingsCmd(c tele.Context) error {
+	available := b.channels.Available()
+	if len(available) == 0 {
+		return c.Send("Updates can only be received in Telegram at the moment")
+	}
+	userID := c.Sender().ID
+	args := c.Args()
+
+	switch {
+	case len(args) == 0:
+		return b.sendSettings(c, available)
+	case args[0] == "verify" && len(args) == 3:
+		channel, code := args[1], args[2]
+		err := b.channels.Verify(context.Background(), userID, channel, code)
+		switch {
+		case errors.Is(err, notify.ErrUnknownChannel):
+			return c.Send("Unknown channel " + channel + ", available ones are " + strings.Join(available, ", "))
+		case errors.Is(err, notify.ErrChannelNotFound):
+			return c.Send("Set up " + channel + " first with /settings " + channel + " <destination>")
+		case errors.Is(err, notify.ErrAlreadyVerified):
+			return c.Send(channel + " is already verified")
+		case errors.Is(err, notify.ErrWrongCode):
+			return c.Send("Wrong code, please check it and try again")
+		case errors.Is(err, notify.ErrTooManyAttempts):
+			return c.Send("Too many wrong codes, please set up " + channel + " again to get a new one")
+		case err != nil:
+			b.contextLogger(c).Error("failed to verify notification channel", zap.String("channel", channel), zaperr.ToField(err))
+			return c.Send("Failed to verify " + channel + ", please try again later")
+		}
+		return c.Send("Done! Updates will be sent to " + channel + " as well")
+	case len(args) == 2 && args[1] == "off":
+		channel := args[0]
+		err := b.channels.Remove(context.Background(), userID, channel)
+		switch {
+		case errors.Is(err, notify.ErrUnknownChannel):
+			return c.Send("Unknown channel " + channel + ", available ones are " + strings.Join(available, ", "))
+		case errors.Is(err, notify.ErrChannelNotFound):
+			return c.Send(channel + " is not set up")
+		case err != nil:
+			b.contextLogger(c).Error("failed to remove notification channel", zap.String("channel", channel), zaperr.ToField(err))
+			return c.Send("Failed to turn " + channel + " off, please try again later")
+		}
+		return c.Send("Updates won't be sent to " + channel + " anymore")
+	case len(args) >= 2:
+		channel, destination := args[0], strings.Join(args[1:], " ")
+		set, err := b.channels.Set(context.Background(), userID, channel, destination)
+		switch {
+		case errors.Is(err, notify.ErrUnknownChannel):
+			return c.Send("Unknown channel " + channel + ", available ones are " + strings.Join(available, ", "))
+		case errors.Is(err, notify.ErrInvalidDestination):
+			return c.Send(strings.TrimPrefix(err.Error(), notify.ErrInvalidDestination.Error()+": "))
+		case err != nil:
+			b.contextLogger(c).Error("failed to set notification channel", zap.String("channel", channel), zaperr.ToField(err))
+			return c.Send("Failed to set up " + channel + ", please try again later")
+		}
+		if !set.Verified {
+			return c.Send(fmt.Sprintf("A code has been sent to %s, send /settings verify %s <code> to confirm it's yours", set.Destination, channel))
+		}
+		msg := "Done! Updates will be sent to " + set.Destination + " as well"
+		if set.Secret != "" {
+			msg += fmt.Sprintf("\n\nSecret: %s\n%s", set.Secret, b.channels.SigningInstructions(channel))
+		}
+		return c.Send(msg)
+	}
+	return b.sendSettings(c, available)
+}
+
+func (b *Bot) sendSettings(c tele.Context, available []string) error {
+	channels, err := b.channels.List(context.Background(), c.Sender().ID)
+	if err != nil {
+		b.contextLogger(c).Error("failed to list notification channels", zaperr.ToField(err))
+		return c.Send("Failed to get your settings, please try again later")
+	}
+
+	lines := []string{"Updates are sent to Telegram"}
+	for _, channel := range channels {
+		status := ""
+		if !channel.Verified {
+			status = " (not verified yet)"
+		}
+		lines = append(lines, fmt.Sprintf("and to %s %s%s", channel.Name, channel.Destination, status))
+	}
+	lines = append(lines,
+		"",
+		"Available channels: "+strings.Join(available, ", "),
+		"/settings <channel> <destination> - send updates there too, e.g. /settings email me@example.com",
+		"/settings verify <channel> <code> - confirm the destination with the code sent there",
+		"/settings <channel> off - stop sending updates there",
+	)
+	return c.Send(strings.Join(lines, "\n"))
+}
+
+// handleStatusCmd tells when the user's parcels were checked and will be checked next, and whether polling is degraded
+func (b *Bot) handleStatusCmd(c tele.Context) error {
+	status, err := b.service.PollingStatus(context.Background(), c.Sender().ID)
+	if err != nil {
+		b.contextLogger(c).Error("failed to get polling status", zaperr.ToField(err))
+		return c.Send("Failed to get the status, please try again later")
+	}
+
+	var lines []string
+	now := time.Now()
+	if status.LastPolledAt != nil {
+		lines = append(lines, "Your parcels were last checked "+formatDuration(now.Sub(*status.LastPolledAt))+" ago")
+	} else {
+		lines = append(lines, "Your parcels haven't been checked yet")
+	}
+	switch {
+	case status.NextPollAt == nil:
+		lines = append(lines, "There's nothing to check, /track a parcel to start")
+	case status.NextPollAt.After(now):
+		lines = append(lines, "The next check is due in "+formatDuration(status.NextPollAt.Sub(now)))
+	default:
+		lines = append(lines, "The next check is due any moment now")
+	}
+	if status.Degraded {
+		lines = append(lines, "", "⚠️ The tracking service is having trouble at the moment, so updates may come late. "+
+			"Nothing will be missed: parcels are checked again once it recovers")
+	} else {
+		lines = append(lines, "", "✅ Everything works fine")
+	}
+	return c.Send(strings.Join(lines, "\n"))
+}
+
+func (b *Bot) handleMyStatsCmd(c tele.Context) error {
+	stats, err := b.service.UserStats(context.Background(), c.Sender().ID)
+	if err != nil {
+		b.contextLogger(c).Error("failed to get user stats", zaperr.ToField(err))
+		return c.Send("Failed to get your stats, please try again later")
+	}
+	if stats.Tracked == 0 {
+		return c.Send("You're not tracking any parcels yet, send /track <tracking number> to start")
+	}
+
+	lines := []string{
+		"📊 <b>Your parcels</b>",
+		fmt.Sprintf("Tracked: %d", stats.Tracked),
+		fmt.Sprintf("In transit: %d", stats.InTransit),
+		fmt.Sprintf("Delivered: %d", stats.Delivered),
+	}
+	if stats.AverageDeliveryTime > 0 {
+		lines = append(lines, "Average delivery time: "+formatDuration(stats.AverageDeliveryTime))
+	}
+	formatCarrier := func(carrier core.CarrierStats) string {
+		parcels := fmt.Sprintf("%d parcels", carrier.Delivered)
+		if carrier.Delivered == 1 {
+			parcels = "1 parcel"
+		}
+		return fmt.Sprintf("%s, %s on average over %s", html.EscapeString(carrier.Name), formatDuration(carrier.AverageDeliveryTime), parcels)
+	}
+	switch n := len(stats.Carriers); {
+	case n == 1:
+		lines = append(lines, "Carrier: "+formatCarrier(stats.Carriers[0]))
+	case n > 1:
+		lines = append(lines,
+			"Fastest carrier: "+formatCarrier(stats.Carriers[0]),
+			"Slowest carrier: "+formatCarrier(stats.Carriers[n-1]),
+		)
+	}
+	return c.Send(strings.Join(lines, "\n"), tele.ModeHTML)
+}
+
+// compareLimit is how many carriers /compare lists at most
+const compareLimit = 15
+
+// countryCodeRe matches ISO 3166-1 alpha-2 country codes
+var countryCodeRe = regexp.MustCompile(`^[A-Za-z]{2}$`)
+
+func (b *Bot) handleCompareCmd(c tele.Context) error {
+	args := c.Args()
+	country := ""
+	if len(args) > 0 {
+		country = strings.ToUpper(args[0])
+	}
+	if len(args) > 1 || (country != "" && !countryCodeRe.MatchString(country)) {
+		return c.Send(COMPARE_CMD_HELP)
+	}
+	carriers, err := b.service.CompareCarriers(context.Background(), country)
+	if err != nil {
+		b.contextLogger(c).Error("failed to compare carriers", zaperr.ToField(err))
+		return c.Send("Failed to compare carriers, please try again later")
+	}
+	if len(carriers) == 0 && country != "" {
+		return c.Send("Not enough parcels from " + country + " have been delivered yet to tell how long they take")
+	}
+	if len(carriers) == 0 {
+		return c.Send("Not enough parcels have been delivered yet to compare carriers")
+	}
+
+	lines := []string{"⏱ Average time from pickup to delivery, fastest first:"}
+	for i, carrier := range carriers {
+		if i == compareLimit {
+			lines = append(lines, fmt.Sprintf("and %d more", len(carriers)-i))
+			break
+		}
+		name := carrier.Carrier
+		if carrier.Country != "" {
+			name += " from " + carrier.Country
+		}
+		line := fmt.Sprintf("%s - %s (%d parcels)", name, formatDuration(carrier.Average), carrier.SamplesCount)
+		if confirmed := carrier.Received + carrier.NotReceived; confirmed > 0 {
+			line += fmt.Sprintf(", %d%% of %d confirmed received", carrier.Received*100/confirmed, confirmed)
+		}
+		lines = append(lines, line)
+	}
+	lines = append(lines, "", "Based on parcels of everyone using the bot, anonymously. Where parcels go isn't known, "+
+		"so routes are told apart by carriers and, for parcels sent by post, countries they come from")
+	return c.Send(strings.Join(lines, "\n"))
+}
+
+// formatDuration rounds the duration to whole minutes, hours or days
+func formatDuration(d time.Duration) string {
+	plural := func(n int, unit string) string {
+		if n == 1 {
+			return "1 " + unit
+		}
+		return fmt.Sprintf("%d %ss", n, unit)
+	}
+	switch {
+	case d < time.Minute:
+		return "less than a minute"
+	case d < time.Hour:
+		return plural(int(d/time.Minute), "minute")
+	case d < 48*time.Hour:
+		return plural(int(d/time.Hour), "hour")
+	}
+	return plural(int(d/(24*time.Hour)), "day")
+}
+
+const (
+	// summaryPeriod is what weekly summaries are about: the week before and the week after
+	summaryPeriod = 7 * 24 * time.Hour
+	// summaryCheckInterval is how often due summaries are looked for, so summaries may come late by as much
+	summaryCheckInterval = 5 * time.Minute
+	// summaryBatchSize is how many due summaries are loaded at once
+	summaryBatchSize = 100
+	// summarySectionLimit limits how many parcels each section of a summary lists, so that it fits into a message
+	summarySectionLimit = 15
+	// defaultSummaryHour is when summaries are sent unless the user picks another hour, in UTC
+	defaultSummaryHour = 9
+)
+
+// summaryWeekdays are what users may call days in /summary
+var summaryWeekdays = map[string]time.Weekday{
+	"sunday": time.Sunday, "sun": time.Sunday,
+	"monday": time.Monday, "mon": time.Monday,
+	"tuesday": time.Tuesday, "tue": time.Tuesday,
+	"wednesday": time.Wednesday, "wed": time.Wednesday,
+	"thursday": time.Thursday, "thu": time.Thursday,
+	"friday": time.Friday, "fri": time.Friday,
+	"saturday": time.Saturday, "sat": time.Saturday,
+}
+
+// handleSummaryCmd shows or changes the schedule of the user's weekly summaries, /summary now sends one right away
+func (b *Bot) handleSummaryCmd(c tele.Context) error {
+	userID := c.Sender().ID
+	args := c.Args()
+	if len(args) == 0 {
+		schedule, err := b.storage.SummarySchedule(context.Background(), userID)
+		if err != nil {
+			b.contextLogger(c).Error("failed to get summary schedule", zaperr.ToField(err))
+			return c.Send("Failed to get your summary settings, please try again later")
+		}
+		if schedule != nil {
+			return c.Send(fmt.Sprintf("You get weekly summaries on %ss at %02d:00 UTC, the next one on %s. /summary off stops them",
+				schedule.Weekday, schedule.Hour, schedule.NextAt.UTC().Format("Jan 2")))
+		}
+		return b.saveSummarySchedule(c, time.Monday, defaultSummaryHour)
+	}
+
+	switch strings.ToLower(args[0]) {
+	case "now":
+		summary, err := b.service.Summary(context.Background(), userID, summaryPeriod)
+		if err != nil {
+			b.contextLogger(c).Error("failed to summarize trackings", zaperr.ToField(err))
+			return c.Send("Failed to summarize your parcels, please try again later")
+		}
+		if summary.IsEmpty() {
+			return c.Send("You're not tracking any parcels")
+		}
+		return c.Send(b.formatSummary(summary), tele.ModeHTML)
+	case "off":
+		if err := b.storage.SaveSummarySchedule(context.Background(), userID, nil); err != nil {
+			b.contextLogger(c).Error("failed to remove summary schedule", zaperr.ToField(err))
+			return c.Send("Failed to turn summaries off, please try again later")
+		}
+		return c.Send("You won't get weekly summaries anymore")
+	}
+
+	weekday, ok := summaryWeekdays[strings.ToLower(args[0])]
+	hour := defaultSummaryHour
+	if len(args) > 1 {
+		var err error
+		hour, err = strconv.Atoi(strings.TrimSuffix(args[1], ":00"))
+		ok = ok && err == nil && hour >= 0 && hour < 24
+	}
+	if !ok || len(args) > 2 {
+		return c.Send(SUMMARY_CMD_HELP + "\nE.g. /summary friday 18")
+	}
+	return b.saveSummarySchedule(c, weekday, hour)
+}
+
+func (b *Bot) saveSummarySchedule(c tele.Context, weekday time.Weekday, hour int) error {
+	schedule := &SummarySchedule{Weekday: weekday, Hour: hour, NextAt: nextSummaryAt(weekday, hour, time.Now())}
+	if err := b.storage.SaveSummarySchedule(context.Background(), c.Sender().ID, schedule); err != nil {
+		b.contextLogger(c).Error("failed to save summary schedule", zaperr.ToField(err))
+		return c.Send("Failed to schedule summaries, please try again later")
+	}
+	return c.Send(fmt.Sprintf("Done! You'll get a summary of your parcels on %ss at %02d:00 UTC, starting %s. "+
+		"/summary <day> [hour] picks another time, /summary off stops them",
+		weekday, hour, schedule.NextAt.Format("Jan 2")))
+}
+
+// nextSummaryAt is the first time after t the weekday and hour (in UTC) come
+func nextSummaryAt(weekday time.Weekday, hour int, t time.Time) time.Time {
+	t = t.UTC()
+	next := time.Date(t.Year(), t.Month(), t.Day(), hour, 0, 0, 0, time.UTC)
+	next = next.AddDate(0, 0, (int(weekday)-int(next.Weekday())+7)%7)
+	if !next.After(t) {
+		next = next.AddDate(0, 0, 7)
+	}
+	return next
+}
+
+// runSummaries sends due summaries until ctx is done
+func (b *Bot) runSummaries(ctx context.Context) {
+	t := time.NewTicker(summaryCheckInterval)
+	defer t.Stop()
+	for {
+		b.sendDueSummaries(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// sendDueSummaries sends summaries that are due. Every summary is rescheduled before it is sent,
+// so one that fails is skipped rather than sent twice: a missed summary is not worth sending late,
+// and summaries missed while the bot was down are not piled up
+func (b *Bot) sendDueSummaries(ctx context.Context) {
+	now := time.Now()
+	for ctx.Err() == nil {
+		schedules, err := b.storage.ListDueSummarySchedules(ctx, now, summaryBatchSize)
+		if err != nil {
+			b.logger.Error("failed to list due summaries", zaperr.ToField(err))
+			return
+		}
+		for _, schedule := range schedules {
+			fields := []zap.Field{core.UserIDField(schedule.UserID)}
+			schedule.NextAt = nextSummaryAt(schedule.Weekday, schedule.Hour, now)
+			if err := b.storage.SetSummaryNextAt(ctx, schedule.UserID, schedule.NextAt); err != nil {
+				// the schedule would be listed again and again otherwise
+				b.logger.Error("failed to reschedule summary", append(fields, zaperr.ToField(err))...)
+				return
+			}
+			b.sendSummary(ctx, schedule.UserID, fields)
+		}
+		if len(schedules) < summaryBatchSize {
+			return
+		}
+	}
+}
+
+func (b *Bot) sendSummary(ctx context.Context, userID int64, fields []zap.Field) {
+	summary, err := b.service.Summary(ctx, userID, summaryPeriod)
+	if err != nil {
+		b.logger.Error("failed to summarize trackings", append(fields, zaperr.ToField(err))...)
+		return
+	}
+	if summary.IsEmpty() {
+		return
+	}
+	chatID, err := b.storage.UserChatID(ctx, userID)
+	if err != nil {
+		b.logger.Error("failed to get chat id", append(fields, zaperr.ToField(err))...)
+		return
+	}
+	if chatID == 0 {
+		return
+	}
+	if _, err := b.sendChunked(tele.ChatID(chatID), b.formatSummary(summary), tele.ModeHTML); err != nil {
+		b.sends.Inc("error")
+		b.logger.Error("failed to send summary", append(fields, core.ChatIDField(chatID), zaperr.ToField(err))...)
+		return
+	}
+	b.sends.Inc("ok")
+}
+
+func (b *Bot) formatSummary(summary *core.Summary) string {
+	lines := []string{"📋 <b>Your parcels this week</b>"}
+	section := func(title string, trackings []*core.Tracking, describe func(t *core.Tracking) string) {
+		if len(trackings) == 0 {
+			return
+		}
+		lines = append(lines, "", fmt.Sprintf("%s (%d)", title, len(trackings)))
+		for i, t := range trackings {
+			if i == summarySectionLimit {
+				lines = append(lines, fmt.Sprintf("and %d more", len(trackings)-i))
+				break
+			}
+			l := fmt.Sprintf("<code>%s</code>", t.TrackingNumber)
+			if t.DisplayName != "" {
+				l += " - " + html.EscapeString(t.DisplayName)
+			}
+			if d := describe(t); d != "" {
+				l += ": " + d
+			}
+			lines = append(lines, l)
+		}
+	}
+
+	section("✅ Delivered", summary.Delivered, func(t *core.Tracking) string {
+		at, _ := t.DeliveredAt()
+		return at.Format("Jan 2")
+	})
+	section("🚚 On the move", summary.Moved, func(t *core.Tracking) string {
+		events := b.collectAllEvents(t)
+		if len(events) == 0 {
+			return ""
+		}
+		return html.EscapeString(events[len(events)-1].Description)
+	})
+	section("⚠️ Stuck", summary.Stuck, func(t *core.Tracking) string {
+		return "no news for a while, it may be worth contacting the seller or the carrier"
+	})
+	expected := make([]*core.Tracking, len(summary.Expected))
+	etas := make(map[*core.Tracking]*core.ETA, len(summary.Expected))
+	for i, e := range summary.Expected {
+		expected[i] = e.Tracking
+		etas[e.Tracking] = e.ETA
+	}
+	section("📅 Expected next week", expected, func(t *core.Tracking) string {
+		return strings.TrimPrefix(formatETA(etas[t]), "Expected delivery: ")
+	})
+
+	if quiet := summary.ActiveCount - len(summary.Moved) - len(summary.Stuck); quiet > 0 {
+		lines = append(lines, "", fmt.Sprintf("%d more parcels in transit had no news this week, see /list", quiet))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// handleFeedCmd issues a new feed URL, revoking the previous one, or just revokes it with /feed off
+func (b *Bot) handleFeedCmd(c tele.Context) error {
+	if !b.feeds.Enabled() {
+		return c.Send("Feeds are not available at the moment")
+	}
+	userID := c.Sender().ID
+	if args := c.Args(); len(args) == 1 && args[0] == "off" {
+		if err := b.feeds.Revoke(context.Background(), userID); err != nil {
+			b.contextLogger(c).Error("failed to revoke feed", zaperr.ToField(err))
+			return c.Send("Failed to revoke your feed, please try again later")
+		}
+		return c.Send("Your feed links don't work anymore")
+	}
+	feedURL, calendarURL, err := b.feeds.Rotate(context.Background(), userID)
+	if err != nil {
+		b.contextLogger(c).Error("failed to issue feed", zaperr.ToField(err))
+		return c.Send("Failed to create your feed, please try again later")
+	}
+	return c.Send(
+		"Here is your personal feed, add it to a feed reader:\n" + feedURL + "\n\n" +
+			"And a calendar of expected deliveries, add it to Google or Apple Calendar by URL:\n" + calendarURL + "\n\n" +
+			"Keep them secret, anyone with the links can see your parcels. " +
+			"Send /feed again to get new links, the previous ones will stop working, or /feed off to just revoke them",
+	)
+}
+
+func (b *Bot) handleHelpCmd(c tele.Context) error {
+	return c.Send(HELP, "Markdown")
+}
+
+// contextLogger annotates logs with the user and the chat the handled update came from
+func (b *Bot) contextLogger(c tele.Context) *zap.Logger {
+	logger := b.logger
+	if c.Sender() != nil {
+		logger = logger.With(core.UserIDField(c.Sender().ID))
+	}
+	if c.Chat() != nil {
+		logger = logger.With(core.ChatIDField(c.Chat().ID))
+	}
+	return logger
+}
+
+// inFlightMiddleware counts handlers in flight, so that shutdown can wait for them
+func (b *Bot) inFlightMiddleware(next tele.HandlerFunc) tele.HandlerFunc {
+	return func(c tele.Context) error {
+		b.handlers.Add(1)
+		b.handlersInFlight.Add(1)
+		defer b.handlers.Done()
+		defer b.handlersInFlight.Add(-1)
+		return next(c)
+	}
+}
+
+// recoverMiddleware logs panics of handlers (which reports them, if error reporting is enabled)
+// instead of letting them crash the whole bot
+func (b *Bot) recoverMiddleware(next tele.HandlerFunc) tele.HandlerFunc {
+	return func(c tele.Context) (err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				b.contextLogger(c).Error("handler panicked", zap.Any("panic", p), zap.Stack("stack"))
+				err = nil
+			}
+		}()
+		return next(c)
+	}
+}
+
+func (b *Bot) saveChatIDMiddleware(next tele.HandlerFunc) tele.HandlerFunc {
+	return func(c tele.Context) error {
+		if c.Chat() == nil || c.Sender() == nil {
+			return next(c)
+		}
+		chatID := c.Chat().ID
+		userID := c.Sender().ID
+
+		logger := b.contextLogger(c)
+		logger.Debug("saving chat id")
+
+		if err := b.storage.SaveUserChatID(context.Background(), userID, chatID, c.Sender().LanguageCode); err != nil {
+			logger.Error("failed to save chat id", zaperr.ToField(err))
+		}
+		return next(c)
+	}
+}
+
+// collectAllEvents is the timeline of the tracking, events of all its tracking infos merged, see core.MergeEvents
+func (b *Bot) collectAllEvents(tracking *core.Tracking) []parcels_api.TrackingEvent {
+	return core.MergeEvents(tracking.TrackingInfos)
 }
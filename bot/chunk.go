@@ -0,0 +1,247 @@
+package bot
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	tele "gopkg.in/telebot.v3"
+)
+
+// maxMessageLength is how many UTF-16 code units of text Telegram takes in a message, which is how it counts
+// characters, so an emoji outside of the Basic Multilingual Plane counts as two. Chunks are measured with their HTML
+// markup, which Telegram doesn't count, so they only end up shorter than they could be
+const maxMessageLength = 4096
+
+// splitMessage splits the text into chunks of at most limit UTF-16 code units, between lines where it can.
+// Every line the bot writes opens and closes its HTML tags itself, so chunks made of whole lines are valid on their own.
+// Lines longer than limit are split by splitLine
+func splitMessage(text string, limit int) []string {
+	if utf16Len(text) <= limit {
+		return []string{text}
+	}
+	var chunks []string
+	var chunk []string
+	chunkLen := 0
+	flush := func() {
+		if len(chunk) > 0 {
+			chunks = append(chunks, strings.Join(chunk, "\n"))
+		}
+		chunk, chunkLen = nil, 0
+	}
+	for _, line := range strings.Split(text, "\n") {
+		lineLen := utf16Len(line)
+		if lineLen > limit {
+			flush()
+			chunks = append(chunks, splitLine(line, limit)...)
+			continue
+		}
+		// lines are joined with newlines, which count too
+		if len(chunk) > 0 && chunkLen+1+lineLen > limit {
+			flush()
+		}
+		if len(chunk) > 0 {
+			chunkLen++
+		}
+		chunk = append(chunk, line)
+		chunkLen += lineLen
+	}
+	flush()
+	// blank lines separating parts of the text are of no use at the edges of chunks
+	trimmed := chunks[:0]
+	for _, chunk := range chunks {
+		if chunk = strings.Trim(chunk, "\n"); chunk != "" {
+			trimmed = append(trimmed, chunk)
+		}
+	}
+	return trimmed
+}
+
+// splitLine splits a line longer than limit between words if it has any, but never inside a tag or an escape.
+// Tags open at a cut are closed at the end of the part and opened again at the start of the next one,
+// so that every part is valid HTML on its own
+func splitLine(line string, limit int) []string {
+	var parts []string
+	tokens := tokenizeHTML(line)
+	for len(tokens) > 0 {
+		var part string
+		part, tokens = cutLine(tokens, limit)
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
+
+// htmlToken is a tag, an escape or a single character of text
+type htmlToken struct {
+	text string
+	// tag is the name of the tag, empty unless the token is one
+	tag     string
+	closing bool
+}
+
+func tokenizeHTML(text string) []htmlToken {
+	var tokens []htmlToken
+	for text != "" {
+		switch {
+		case text[0] == '<' && strings.IndexByte(text, '>') > 0:
+			end := strings.IndexByte(text, '>') + 1
+			token := htmlToken{text: text[:end]}
+			name := strings.TrimPrefix(strings.Trim(text[:end], "<>"), "/")
+			token.closing = strings.HasPrefix(text, "</")
+			token.tag, _, _ = strings.Cut(name, " ")
+			tokens = append(tokens, token)
+			text = text[end:]
+		case text[0] == '&' && escapeLen(text) > 0:
+			end := escapeLen(text)
+			tokens = append(tokens, htmlToken{text: text[:end]})
+			text = text[end:]
+		default:
+			_, size := utf8.DecodeRuneInString(text)
+			tokens = append(tokens, htmlToken{text: text[:size]})
+			text = text[size:]
+		}
+	}
+	return tokens
+}
+
+// cutLine cuts a part of at most limit UTF-16 code units off the tokens, tags included, see splitLine.
+// It returns the part and the tokens left, the latter start with tags reopened after the cut
+func cutLine(tokens []htmlToken, limit int) (string, []htmlToken) {
+	type cut struct {
+		at   int
+		open []htmlToken
+	}
+	var open []htmlToken
+	var lastSpace *cut
+	length := 0
+	// text is whether the part has more than the tags reopened, it is never cut before it has
+	text := false
+	for i, token := range tokens {
+		if token.text == " " && text {
+			lastSpace = &cut{at: i, open: append([]htmlToken(nil), open...)}
+		}
+		opened := open
+		switch {
+		case token.tag != "" && token.closing && len(open) > 0:
+			opened = open[:len(open)-1]
+		case token.tag != "" && !token.closing:
+			opened = append(append([]htmlToken(nil), open...), token)
+		}
+		if text && length+utf16Len(token.text)+closingLen(opened) > limit {
+			c := cut{at: i, open: open}
+			rest := tokens[i:]
+			if lastSpace != nil {
+				c = *lastSpace
+				rest = tokens[c.at+1:]
+			}
+			part := joinTokens(tokens[:c.at]) + closingTags(c.open)
+			return part, append(append([]htmlToken(nil), c.open...), rest...)
+		}
+		open = opened
+		length += utf16Len(token.text)
+		text = text || token.tag == ""
+	}
+	return joinTokens(tokens) + closingTags(open), nil
+}
+
+// escapeLen is the length of the escape text starts with, like &amp; or &#39;, 0 if it doesn't start with one
+func escapeLen(text string) int {
+	for i := 1; i < len(text) && i <= 10; i++ {
+		c := text[i]
+		switch {
+		case c == ';':
+			if i == 1 {
+				return 0
+			}
+			return i + 1
+		case c == '#' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9':
+		default:
+			return 0
+		}
+	}
+	return 0
+}
+
+func joinTokens(tokens []htmlToken) string {
+	var b strings.Builder
+	for _, token := range tokens {
+		b.WriteString(token.text)
+	}
+	return b.String()
+}
+
+// closingTags closes the open tags, innermost first
+func closingTags(open []htmlToken) string {
+	var b strings.Builder
+	for i := len(open) - 1; i >= 0; i-- {
+		b.WriteString("</" + open[i].tag + ">")
+	}
+	return b.String()
+}
+
+func closingLen(open []htmlToken) int {
+	return utf16Len(closingTags(open))
+}
+
+// utf16Len is the length of s the way Telegram counts it
+func utf16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		if r >= 0x10000 {
+			n += 2
+		} else {
+			n++
+		}
+	}
+	return n
+}
+
+// sendChunked sends the text in as many messages as it takes, see splitMessage. Reply markup only goes along with
+// the last message, other options go along with all of them. It returns the messages sent, even if it fails halfway
+func (b *Bot) sendChunked(to tele.Recipient, text string, opts ...interface{}) ([]*tele.Message, error) {
+	chunks := splitMessage(text, maxMessageLength)
+	var sent []*tele.Message
+	for i, chunk := range chunks {
+		chunkOpts := opts
+		if i < len(chunks)-1 {
+			chunkOpts = withoutReplyMarkup(opts)
+		}
+		msg, err := b.bot.Send(to, chunk, chunkOpts...)
+		if err != nil {
+			return sent, err
+		}
+		sent = append(sent, msg)
+	}
+	return sent, nil
+}
+
+// editChunked edits the message to be the first chunk of the text and sends the rest of it, just like sendChunked
+func (b *Bot) editChunked(c tele.Context, text string, opts ...interface{}) error {
+	chunks := splitMessage(text, maxMessageLength)
+	if len(chunks) == 1 {
+		return c.Edit(text, opts...)
+	}
+	if err := c.Edit(chunks[0], withoutReplyMarkup(opts)...); err != nil {
+		return err
+	}
+	_, err := b.sendChunked(c.Recipient(), strings.Join(chunks[1:], "\n"), opts...)
+	return err
+}
+
+func withoutReplyMarkup(opts []interface{}) []interface{} {
+	var without []interface{}
+	for _, opt := range opts {
+		switch o := opt.(type) {
+		case *tele.ReplyMarkup:
+			continue
+		case *tele.SendOptions:
+			withoutMarkup := *o
+			withoutMarkup.ReplyMarkup = nil
+			without = append(without, &withoutMarkup)
+		default:
+			without = append(without, opt)
+		}
+	}
+	return without
+}
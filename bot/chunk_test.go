@@ -0,0 +1,71 @@
+package bot
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitMessage(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		limit int
+		want  []string
+	}{
+		{"short", "one\ntwo", 10, []string{"one\ntwo"}},
+		{"exactly at limit", strings.Repeat("a", 10), 10, []string{strings.Repeat("a", 10)}},
+		{"lines exactly at limit", "aaaa\nbbbbb\ncc", 10, []string{"aaaa\nbbbbb", "cc"}},
+		{"between lines", "one\ntwo\nthree", 8, []string{"one\ntwo", "three"}},
+		{"blank lines at edges", "one\n\ntwo", 4, []string{"one", "two"}},
+		{"long tagged line", "<b>one two three</b>", 14, []string{"<b>one two</b>", "<b>three</b>"}},
+		{"long line among lines", "x\n<code>aaa bbb</code>", 16, []string{"x", "<code>aaa</code>", "<code>bbb</code>"}},
+		// emoji outside of the BMP are two UTF-16 code units
+		{"emoji", "😀😀😀 😀😀", 6, []string{"😀😀😀", "😀😀"}},
+		{"emoji exactly at limit", "😀😀😀", 6, []string{"😀😀😀"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := splitMessage(tt.text, tt.limit)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitMessage(%q, %d) = %q, want %q", tt.text, tt.limit, got, tt.want)
+			}
+			for _, chunk := range got {
+				if utf16Len(chunk) > tt.limit {
+					t.Errorf("chunk %q is longer than %d", chunk, tt.limit)
+				}
+			}
+		})
+	}
+}
+
+func TestSplitLine(t *testing.T) {
+	tests := []struct {
+		name  string
+		line  string
+		limit int
+		want  []string
+	}{
+		{"between words", "one two three", 8, []string{"one two", "three"}},
+		{"no words", "abcdefgh", 3, []string{"abc", "def", "gh"}},
+		{"tag closed and reopened", "<b>aaa bbb ccc</b>", 14, []string{"<b>aaa bbb</b>", "<b>ccc</b>"}},
+		{"nested tags", "<b><i>aa bb</i> cc</b>", 19, []string{"<b><i>aa bb</i></b>", "<b>cc</b>"}},
+		{"tag with attributes", `<a href="x">aa bb</a>`, 18, []string{`<a href="x">aa</a>`, `<a href="x">bb</a>`}},
+		{"escape kept whole", "a&amp;b&amp;c", 6, []string{"a&amp;", "b&amp;", "c"}},
+		{"not an escape", "a & b", 3, []string{"a &", "b"}},
+		{"emoji", "😀😀😀😀😀", 4, []string{"😀😀", "😀😀", "😀"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := splitLine(tt.line, tt.limit)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitLine(%q, %d) = %q, want %q", tt.line, tt.limit, got, tt.want)
+			}
+			for _, part := range got {
+				if utf16Len(part) > tt.limit {
+					t.Errorf("part %q is longer than %d", part, tt.limit)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package bot
+
+import (
+	"sync"
+	"time"
+
+	"github.com/dir01/tg-parcels/core"
+)
+
+// debouncer holds tracking updates back for a while to send updates of the same tracking
+// that arrive close together, e.g. a few scans made within minutes, as one message
+type debouncer struct {
+	// window is how long the first update of a tracking waits for more, 0 sends every update right away
+	window time.Duration
+	send   func(update core.TrackingUpdate, merged []core.TrackingUpdate)
+	mu     sync.Mutex
+	// pending are updates waiting for the window of their tracking to close
+	pending map[debounceKey]*pendingUpdates
+	// flushing tracks sends of windows that have closed, so that flushAll can wait for them
+	flushing sync.WaitGroup
+}
+
+type debounceKey struct {
+	userID         int64
+	trackingNumber string
+}
+
+type pendingUpdates struct {
+	updates []core.TrackingUpdate
+	timer   *time.Timer
+}
+
+// newDebouncer makes a debouncer sending merged updates with send, along with the updates that were merged
+func newDebouncer(window time.Duration, send func(update core.TrackingUpdate, merged []core.TrackingUpdate)) *debouncer {
+	return &debouncer{window: window, send: send, pending: make(map[debounceKey]*pendingUpdates)}
+}
+
+// add sends the update once the window of its tracking closes. Errors, stuck alerts and reminders aren't about
+// new events, so they are sent right away
+func (d *debouncer) add(update core.TrackingUpdate) {
+	if d.window <= 0 || update.TrackingError != nil || update.StuckSince != nil || update.ExpectedAt != nil {
+		d.send(update, []core.TrackingUpdate{update})
+		return
+	}
+	key := debounceKey{userID: update.UserID, trackingNumber: update.TrackingNumber}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if p, ok := d.pending[key]; ok {
+		p.updates = append(p.updates, update)
+		return
+	}
+	p := &pendingUpdates{updates: []core.TrackingUpdate{update}}
+	d.flushing.Add(1)
+	p.timer = time.AfterFunc(d.window, func() {
+		defer d.flushing.Done()
+		d.mu.Lock()
+		// flushAll may have taken the updates already
+		if d.pending[key] != p {
+			d.mu.Unlock()
+			return
+		}
+		delete(d.pending, key)
+		d.mu.Unlock()
+		d.send(core.MergeTrackingUpdates(p.updates), p.updates)
+	})
+	d.pending[key] = p
+}
+
+// flushAll sends all pending updates without waiting for their windows to close,
+// and waits for sends of windows that have closed already
+func (d *debouncer) flushAll() {
+	d.mu.Lock()
+	pending := d.pending
+	d.pending = make(map[debounceKey]*pendingUpdates)
+	d.mu.Unlock()
+	for _, p := range pending {
+		if p.timer.Stop() {
+			d.flushing.Done()
+		}
+		d.send(core.MergeTrackingUpdates(p.updates), p.updates)
+	}
+	d.flushing.Wait()
+}
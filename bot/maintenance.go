@@ -0,0 +1,73 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dir01/tg-parcels/core"
+	"github.com/hori-ryota/zaperr"
+	tele "gopkg.in/telebot.v3"
+)
+
+// Maintenance is how the bot runs core.Service.Cleanup
+type Maintenance struct {
+	// Interval is how often cleanup runs, the first run is right on start
+	Interval time.Duration
+	// AdminChatIDs are sent reports of what was cleaned up, reports are only logged if there are none
+	AdminChatIDs []int64
+	// DeleteOrphanedUsers deletes data of users who have trackings but have never started the bot
+	// or have deleted their data since, e.g. with /deletemydata failing halfway. Users of the gRPC API
+	// only ever start the bot by chance, so it is only safe if there are none
+	DeleteOrphanedUsers bool
+}
+
+// runMaintenance cleans up until ctx is done, see Maintenance
+func (b *Bot) runMaintenance(ctx context.Context) {
+	t := time.NewTicker(b.maintenance.Interval)
+	defer t.Stop()
+	for {
+		b.cleanup(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func (b *Bot) cleanup(ctx context.Context) {
+	var isKnownUser func(ctx context.Context, userID int64) (bool, error)
+	if b.maintenance.DeleteOrphanedUsers {
+		isKnownUser = b.storage.HasUser
+	}
+	report := b.service.Cleanup(ctx, isKnownUser)
+	if report.IsEmpty() || ctx.Err() != nil {
+		return
+	}
+	text := formatCleanupReport(report)
+	for _, chatID := range b.maintenance.AdminChatIDs {
+		if _, err := b.bot.Send(tele.ChatID(chatID), text, tele.ModeHTML); err != nil {
+			b.logger.Error("failed to send cleanup report", core.ChatIDField(chatID), zaperr.ToField(err))
+		}
+	}
+}
+
+func formatCleanupReport(report *core.CleanupReport) string {
+	lines := []string{"🧹 <b>Cleanup</b>"}
+	count := func(n int64, what string) {
+		if n > 0 {
+			lines = append(lines, fmt.Sprintf("%d %s", n, what))
+		}
+	}
+	count(int64(report.OrphanedUsers), "users unknown to the bot deleted")
+	count(int64(report.DeletedDeliveredTrackings), "delivered trackings past retention deleted")
+	count(report.PurgedDeletedTrackings, "deleted trackings purged")
+	count(report.PurgedUpdateRecords, "update records purged")
+	count(report.PurgedAuditRecords, "audit records purged")
+	if len(report.Failed) > 0 {
+		lines = append(lines, "⚠️ Failed to clean up "+strings.Join(report.Failed, ", ")+", see the logs")
+	}
+	return strings.Join(lines, "\n")
+}
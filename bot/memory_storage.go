@@ -0,0 +1,216 @@
+package bot
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dir01/tg-parcels/core"
+)
+
+// NewMemoryStorage creates Storage that keeps chat IDs in memory, for tests and demos
+func NewMemoryStorage(clock core.Clock) Storage {
+	return &MemoryStorage{
+		clock:     clock,
+		chatIDs:   make(map[int64]int64),
+		createdAt: make(map[int64]time.Time),
+		languages: make(map[int64]string),
+		topics:    make(map[trackingKey]Topic),
+		messages:  make(map[messageKey]trackingKey),
+		summaries: make(map[int64]SummarySchedule),
+	}
+}
+
+type MemoryStorage struct {
+	mu sync.Mutex
+	// clock is what users' first contact is recorded by, just like the database storage does
+	clock     core.Clock
+	chatIDs   map[int64]int64
+	createdAt map[int64]time.Time
+	languages map[int64]string
+	topics    map[trackingKey]Topic
+	// messages are the trackings sent messages are about
+	messages  map[messageKey]trackingKey
+	summaries map[int64]SummarySchedule
+}
+
+// trackingKey identifies a tracking of a user
+type trackingKey struct {
+	userID         int64
+	trackingNumber string
+}
+
+type messageKey struct {
+	chatID    int64
+	messageID int
+}
+
+func (s *MemoryStorage) SaveUserChatID(_ context.Context, userID int64, chatID int64, languageCode string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.chatIDs[userID]; !ok {
+		s.createdAt[userID] = s.clock.Now()
+	}
+	s.chatIDs[userID] = chatID
+	if s.languages[userID] == "" {
+		s.languages[userID] = languageCode
+	}
+	return nil
+}
+
+func (s *MemoryStorage) DeleteUserData(_ context.Context, userID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.chatIDs, userID)
+	delete(s.createdAt, userID)
+	delete(s.languages, userID)
+	delete(s.summaries, userID)
+	for key := range s.topics {
+		if key.userID == userID {
+			delete(s.topics, key)
+		}
+	}
+	for key, tracking := range s.messages {
+		if tracking.userID == userID {
+			delete(s.messages, key)
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStorage) CountNewUsersByDay(_ context.Context, since time.Time) ([]core.DailyCount, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	byDay := make(map[time.Time]int)
+	for _, createdAt := range s.createdAt {
+		if !createdAt.Before(since.Truncate(time.Second)) {
+			byDay[createdAt.UTC().Truncate(24*time.Hour)]++
+		}
+	}
+	counts := make([]core.DailyCount, 0, len(byDay))
+	for day, count := range byDay {
+		counts = append(counts, core.DailyCount{Day: day, Count: count})
+	}
+	sort.Slice(counts, func(i, j int) bool { return counts[i].Day.Before(counts[j].Day) })
+	return counts, nil
+}
+
+func (s *MemoryStorage) ListUsers(_ context.Context, afterUserID int64, limit int) ([]*User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	users := make([]*User, 0, len(s.chatIDs))
+	for userID, chatID := range s.chatIDs {
+		if userID > afterUserID {
+			createdAt := s.createdAt[userID]
+			users = append(users, &User{ID: userID, ChatID: chatID, CreatedAt: &createdAt, LanguageCode: s.languages[userID]})
+		}
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	if len(users) > limit {
+		users = users[:limit]
+	}
+	return users, nil
+}
+
+func (s *MemoryStorage) HasUser(_ context.Context, userID int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.chatIDs[userID]
+	return ok, nil
+}
+
+// UserChatID returns 0 for unknown users
+func (s *MemoryStorage) UserChatID(_ context.Context, userID int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.chatIDs[userID], nil
+}
+
+func (s *MemoryStorage) SaveTrackingTopic(_ context.Context, userID int64, trackingNumber string, topic *Topic) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := trackingKey{userID: userID, trackingNumber: trackingNumber}
+	if topic == nil {
+		delete(s.topics, key)
+	} else {
+		s.topics[key] = *topic
+	}
+	return nil
+}
+
+func (s *MemoryStorage) TrackingTopic(_ context.Context, userID int64, trackingNumber string) (*Topic, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	topic, ok := s.topics[trackingKey{userID: userID, trackingNumber: trackingNumber}]
+	if !ok {
+		return nil, nil
+	}
+	return &topic, nil
+}
+
+func (s *MemoryStorage) SaveMessageTracking(_ context.Context, chatID int64, messageID int, userID int64, trackingNumber string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages[messageKey{chatID: chatID, messageID: messageID}] = trackingKey{userID: userID, trackingNumber: trackingNumber}
+	return nil
+}
+
+func (s *MemoryStorage) MessageTracking(_ context.Context, chatID int64, messageID int) (int64, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tracking := s.messages[messageKey{chatID: chatID, messageID: messageID}]
+	return tracking.userID, tracking.trackingNumber, nil
+}
+
+func (s *MemoryStorage) SaveSummarySchedule(_ context.Context, userID int64, schedule *SummarySchedule) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if schedule == nil {
+		delete(s.summaries, userID)
+	} else {
+		saved := *schedule
+		saved.UserID = userID
+		s.summaries[userID] = saved
+	}
+	return nil
+}
+
+func (s *MemoryStorage) SummarySchedule(_ context.Context, userID int64) (*SummarySchedule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	schedule, ok := s.summaries[userID]
+	if !ok {
+		return nil, nil
+	}
+	return &schedule, nil
+}
+
+func (s *MemoryStorage) ListDueSummarySchedules(_ context.Context, now time.Time, limit int) ([]*SummarySchedule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var schedules []*SummarySchedule
+	for _, schedule := range s.summaries {
+		if !schedule.NextAt.After(now) {
+			schedule := schedule
+			schedules = append(schedules, &schedule)
+		}
+	}
+	sort.Slice(schedules, func(i, j int) bool { return schedules[i].NextAt.Before(schedules[j].NextAt) })
+	if len(schedules) > limit {
+		schedules = schedules[:limit]
+	}
+	return schedules, nil
+}
+
+func (s *MemoryStorage) SetSummaryNextAt(_ context.Context, userID int64, nextAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if schedule, ok := s.summaries[userID]; ok {
+		schedule.NextAt = nextAt
+		s.summaries[userID] = schedule
+	}
+	return nil
+}
@@ -2,33 +2,284 @@ package bot
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"time"
 
+	"github.com/dir01/tg-parcels/core"
+	"github.com/dir01/tg-parcels/core/storage"
 	"github.com/jmoiron/sqlx"
 )
 
-func NewStorage(db *sqlx.DB) Storage {
-	return &SqliteStorage{db: db}
+func NewStorage(db, readDB *sqlx.DB, queryTimeout time.Duration, clock core.Clock, metrics *storage.QueryMetrics) Storage {
+	return &SqliteStorage{db: db, readDB: readDB, queryTimeout: queryTimeout, clock: clock, metrics: metrics}
 }
 
 type SqliteStorage struct {
 	db *sqlx.DB
+	// readDB runs reads outside of transactions, it is db itself unless reads are split, see storage.ReadOnlyDSN
+	readDB       *sqlx.DB
+	queryTimeout time.Duration
+	// clock is what users' first contact is recorded by
+	clock   core.Clock
+	metrics *storage.QueryMetrics
 }
 
-func (s *SqliteStorage) SaveUserChatID(ctx context.Context, userID int64, chatID int64) error {
-	_, err := s.db.ExecContext(ctx, `
-		INSERT INTO users_chats (user_id, chat_id) VALUES (?, ?) 
-		ON CONFLICT DO UPDATE SET chat_id = ?`, userID, chatID, chatID)
+func (s *SqliteStorage) SaveUserChatID(ctx context.Context, userID int64, chatID int64, languageCode string) (err error) {
+	defer s.metrics.Observe("save_user_chat_id", time.Now(), &err)
+	ctx, cancel := storage.WithQueryTimeout(ctx, s.queryTimeout)
+	defer cancel()
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO users_chats (user_id, chat_id, created_at, language_code) VALUES (?, ?, ?, ?)
+		ON CONFLICT DO UPDATE SET chat_id = ?,
+			language_code = CASE WHEN language_code = '' THEN ? ELSE language_code END`,
+		userID, chatID, s.clock.Now().Unix(), languageCode, chatID, languageCode)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (s *SqliteStorage) UserChatID(ctx context.Context, userID int64) (int64, error) {
+func (s *SqliteStorage) DeleteUserData(ctx context.Context, userID int64) (err error) {
+	defer s.metrics.Observe("delete_user_data", time.Now(), &err)
+	ctx, cancel := storage.WithQueryTimeout(ctx, s.queryTimeout)
+	defer cancel()
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	if _, err := tx.ExecContext(ctx, `DELETE FROM tracking_topics WHERE user_id = ?`, userID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM message_trackings WHERE user_id = ?`, userID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM summary_schedules WHERE user_id = ?`, userID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM users_chats WHERE user_id = ?`, userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SqliteStorage) CountNewUsersByDay(ctx context.Context, since time.Time) (_ []core.DailyCount, err error) {
+	defer s.metrics.Observe("count_new_users_by_day", time.Now(), &err)
+	ctx, cancel := storage.WithQueryTimeout(ctx, s.queryTimeout)
+	defer cancel()
+	var rows []struct {
+		Day   string `db:"day"`
+		Count int    `db:"count"`
+	}
+	err = s.readDB.SelectContext(ctx, &rows, `
+		SELECT date(created_at, 'unixepoch') AS day, COUNT(*) AS count FROM users_chats
+		WHERE created_at >= ? GROUP BY day ORDER BY day`, since.Unix(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make([]core.DailyCount, 0, len(rows))
+	for _, r := range rows {
+		day, err := time.Parse("2006-01-02", r.Day)
+		if err != nil {
+			return nil, err
+		}
+		counts = append(counts, core.DailyCount{Day: day, Count: r.Count})
+	}
+	return counts, nil
+}
+
+func (s *SqliteStorage) ListUsers(ctx context.Context, afterUserID int64, limit int) (_ []*User, err error) {
+	defer s.metrics.Observe("list_users", time.Now(), &err)
+	ctx, cancel := storage.WithQueryTimeout(ctx, s.queryTimeout)
+	defer cancel()
+	var rows []struct {
+		UserID       int64         `db:"user_id"`
+		ChatID       int64         `db:"chat_id"`
+		CreatedAt    sql.NullInt64 `db:"created_at"`
+		LanguageCode string        `db:"language_code"`
+	}
+	err = s.readDB.SelectContext(ctx, &rows, `
+		SELECT user_id, chat_id, created_at, language_code FROM users_chats
+		WHERE user_id > ? ORDER BY user_id LIMIT ?`, afterUserID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	users := make([]*User, 0, len(rows))
+	for _, r := range rows {
+		user := &User{ID: r.UserID, ChatID: r.ChatID, LanguageCode: r.LanguageCode}
+		if r.CreatedAt.Valid {
+			createdAt := time.Unix(r.CreatedAt.Int64, 0)
+			user.CreatedAt = &createdAt
+		}
+		users = append(users, user)
+	}
+	return users, nil
+}
+
+func (s *SqliteStorage) UserChatID(ctx context.Context, userID int64) (_ int64, err error) {
+	defer s.metrics.Observe("user_chat_id", time.Now(), &err)
+	ctx, cancel := storage.WithQueryTimeout(ctx, s.queryTimeout)
+	defer cancel()
 	var chatID int64
-	err := s.db.GetContext(ctx, &chatID, `SELECT chat_id FROM users_chats WHERE user_id = ?`, userID)
+	err = s.readDB.GetContext(ctx, &chatID, `SELECT chat_id FROM users_chats WHERE user_id = ?`, userID)
 	if err != nil {
 		return 0, err
 	}
 	return chatID, nil
 }
+
+func (s *SqliteStorage) HasUser(ctx context.Context, userID int64) (_ bool, err error) {
+	defer s.metrics.Observe("has_user", time.Now(), &err)
+	ctx, cancel := storage.WithQueryTimeout(ctx, s.queryTimeout)
+	defer cancel()
+	var count int
+	err = s.readDB.GetContext(ctx, &count, `SELECT COUNT(*) FROM users_chats WHERE user_id = ?`, userID)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (s *SqliteStorage) SaveTrackingTopic(ctx context.Context, userID int64, trackingNumber string, topic *Topic) (err error) {
+	defer s.metrics.Observe("save_tracking_topic", time.Now(), &err)
+	ctx, cancel := storage.WithQueryTimeout(ctx, s.queryTimeout)
+	defer cancel()
+	if topic == nil {
+		_, err = s.db.ExecContext(ctx, `
+			DELETE FROM tracking_topics WHERE user_id = ? AND tracking_number = ?`, userID, trackingNumber)
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO tracking_topics (user_id, tracking_number, chat_id, thread_id) VALUES (?, ?, ?, ?)
+		ON CONFLICT DO UPDATE SET chat_id = ?, thread_id = ?`,
+		userID, trackingNumber, topic.ChatID, topic.ThreadID, topic.ChatID, topic.ThreadID)
+	return err
+}
+
+func (s *SqliteStorage) TrackingTopic(ctx context.Context, userID int64, trackingNumber string) (_ *Topic, err error) {
+	defer s.metrics.Observe("tracking_topic", time.Now(), &err)
+	ctx, cancel := storage.WithQueryTimeout(ctx, s.queryTimeout)
+	defer cancel()
+	var row struct {
+		ChatID   int64 `db:"chat_id"`
+		ThreadID int   `db:"thread_id"`
+	}
+	err = s.readDB.GetContext(ctx, &row, `
+		SELECT chat_id, thread_id FROM tracking_topics WHERE user_id = ? AND tracking_number = ?`, userID, trackingNumber)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Topic{ChatID: row.ChatID, ThreadID: row.ThreadID}, nil
+}
+
+func (s *SqliteStorage) SaveMessageTracking(ctx context.Context, chatID int64, messageID int, userID int64, trackingNumber string) (err error) {
+	defer s.metrics.Observe("save_message_tracking", time.Now(), &err)
+	ctx, cancel := storage.WithQueryTimeout(ctx, s.queryTimeout)
+	defer cancel()
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO message_trackings (chat_id, message_id, user_id, tracking_number) VALUES (?, ?, ?, ?)
+		ON CONFLICT DO UPDATE SET user_id = ?, tracking_number = ?`,
+		chatID, messageID, userID, trackingNumber, userID, trackingNumber)
+	return err
+}
+
+func (s *SqliteStorage) MessageTracking(ctx context.Context, chatID int64, messageID int) (userID int64, trackingNumber string, err error) {
+	defer s.metrics.Observe("message_tracking", time.Now(), &err)
+	ctx, cancel := storage.WithQueryTimeout(ctx, s.queryTimeout)
+	defer cancel()
+	var row struct {
+		UserID         int64  `db:"user_id"`
+		TrackingNumber string `db:"tracking_number"`
+	}
+	err = s.readDB.GetContext(ctx, &row, `
+		SELECT user_id, tracking_number FROM message_trackings WHERE chat_id = ? AND message_id = ?`, chatID, messageID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, "", nil
+	}
+	if err != nil {
+		return 0, "", err
+	}
+	return row.UserID, row.TrackingNumber, nil
+}
+
+func (s *SqliteStorage) SaveSummarySchedule(ctx context.Context, userID int64, schedule *SummarySchedule) (err error) {
+	defer s.metrics.Observe("save_summary_schedule", time.Now(), &err)
+	ctx, cancel := storage.WithQueryTimeout(ctx, s.queryTimeout)
+	defer cancel()
+	if schedule == nil {
+		_, err = s.db.ExecContext(ctx, `DELETE FROM summary_schedules WHERE user_id = ?`, userID)
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO summary_schedules (user_id, weekday, hour, next_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT DO UPDATE SET weekday = excluded.weekday, hour = excluded.hour, next_at = excluded.next_at`,
+		userID, int(schedule.Weekday), schedule.Hour, schedule.NextAt.Unix())
+	return err
+}
+
+// summaryScheduleRow is a row of summary_schedules
+type summaryScheduleRow struct {
+	UserID  int64 `db:"user_id"`
+	Weekday int   `db:"weekday"`
+	Hour    int   `db:"hour"`
+	NextAt  int64 `db:"next_at"`
+}
+
+func (r *summaryScheduleRow) toSchedule() *SummarySchedule {
+	return &SummarySchedule{
+		UserID:  r.UserID,
+		Weekday: time.Weekday(r.Weekday),
+		Hour:    r.Hour,
+		NextAt:  time.Unix(r.NextAt, 0),
+	}
+}
+
+func (s *SqliteStorage) SummarySchedule(ctx context.Context, userID int64) (_ *SummarySchedule, err error) {
+	defer s.metrics.Observe("summary_schedule", time.Now(), &err)
+	ctx, cancel := storage.WithQueryTimeout(ctx, s.queryTimeout)
+	defer cancel()
+	var row summaryScheduleRow
+	err = s.readDB.GetContext(ctx, &row, `
+		SELECT user_id, weekday, hour, next_at FROM summary_schedules WHERE user_id = ?`, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return row.toSchedule(), nil
+}
+
+func (s *SqliteStorage) ListDueSummarySchedules(ctx context.Context, now time.Time, limit int) (_ []*SummarySchedule, err error) {
+	defer s.metrics.Observe("list_due_summary_schedules", time.Now(), &err)
+	ctx, cancel := storage.WithQueryTimeout(ctx, s.queryTimeout)
+	defer cancel()
+	var rows []summaryScheduleRow
+	err = s.readDB.SelectContext(ctx, &rows, `
+		SELECT user_id, weekday, hour, next_at FROM summary_schedules
+		WHERE next_at <= ? ORDER BY next_at LIMIT ?`, now.Unix(), limit)
+	if err != nil {
+		return nil, err
+	}
+	schedules := make([]*SummarySchedule, len(rows))
+	for i := range rows {
+		schedules[i] = rows[i].toSchedule()
+	}
+	return schedules, nil
+}
+
+func (s *SqliteStorage) SetSummaryNextAt(ctx context.Context, userID int64, nextAt time.Time) (err error) {
+	defer s.metrics.Observe("set_summary_next_at", time.Now(), &err)
+	ctx, cancel := storage.WithQueryTimeout(ctx, s.queryTimeout)
+	defer cancel()
+	_, err = s.db.ExecContext(ctx, `UPDATE summary_schedules SET next_at = ? WHERE user_id = ?`, nextAt.Unix(), userID)
+	return err
+}
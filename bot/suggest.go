@@ -0,0 +1,99 @@
+package bot
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// commands are the commands handled by the bot, unknown commands are matched against them for suggestions
+var commands = []string{
+	"/track", "/list", "/info", "/delete", "/refresh", "/note", "/tag", "/untag", "/cleanup", "/history", "/lastupdate",
+	"/restore", "/undo", "/status", "/summary", "/mystats", "/compare", "/name", "/topic", "/notifications", "/settings", "/feed", "/deletemydata",
+	"/manual", "/attach",
+	"/help", "/start",
+}
+
+// commandAliases are commands users try for the ones we have, e.g. /help has long advertised /stop
+var commandAliases = map[string]string{
+	"/stop":   "/delete",
+	"/remove": "/delete",
+	"/add":    "/track",
+	"/rename": "/name",
+}
+
+// suggestCommand finds the command the unknown one is most likely a typo of, it returns "" if none is close enough.
+// Arguments and the bot mention are ignored, e.g. "/trak@bot RR123" suggests "/track"
+func suggestCommand(text string) string {
+	command := strings.ToLower(strings.Fields(text)[0])
+	command, _, _ = strings.Cut(command, "@")
+	if len(command) < 2 {
+		return ""
+	}
+	if alias, ok := commandAliases[command]; ok {
+		return alias
+	}
+
+	best, bestDistance := "", 0
+	for _, known := range commands {
+		d := editDistance(command, known)
+		// a single typo in short commands, two in longer ones
+		maxDistance := 1
+		if utf8.RuneCountInString(known) > 5 {
+			maxDistance = 2
+		}
+		if d <= maxDistance && (best == "" || d < bestDistance) {
+			best, bestDistance = known, d
+		}
+	}
+	if best != "" {
+		return best
+	}
+
+	// an abbreviation, as long as it is unambiguous
+	for _, known := range commands {
+		if len(command) >= 4 && strings.HasPrefix(known, command) {
+			if best != "" {
+				return ""
+			}
+			best = known
+		}
+	}
+	return best
+}
+
+// editDistance is optimal string alignment distance between the strings in runes:
+// Levenshtein distance that also counts swapped adjacent letters as a single typo
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	d := make([][]int, len(ra)+1)
+	for i := range d {
+		d[i] = make([]int, len(rb)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(ra)][len(rb)]
+}
+
+func min(values ...int) int {
+	result := values[0]
+	for _, v := range values[1:] {
+		if v < result {
+			result = v
+		}
+	}
+	return result
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/dir01/tg-parcels/bot"
+	"github.com/dir01/tg-parcels/core/storage"
+	"github.com/dir01/tg-parcels/db/migrations"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// healthCheckTimeout limits every check of /healthz and the healthcheck command
+const healthCheckTimeout = 5 * time.Second
+
+// healthHandler serves /healthz, it responds 200 as long as the database is reachable and 503 otherwise.
+// db is nil with in-memory storage
+func healthHandler(db *sqlx.DB, logger *zap.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if db != nil {
+			ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+			defer cancel()
+			if err := db.PingContext(ctx); err != nil {
+				logger.Warn("health check failed", zap.Error(err))
+				http.Error(w, "database is unreachable", http.StatusServiceUnavailable)
+				return
+			}
+		}
+		_, _ = w.Write([]byte("ok\n"))
+	})
+}
+
+// healthcheck asks /healthz of the running bot if listeners.health_addr is set.
+// Otherwise it checks the database and Telegram directly, which can't tell whether the bot itself is up
+func healthcheck(cfg *Config) error {
+	if cfg.Listeners.HealthAddr != "" {
+		return checkHealthz(cfg.Listeners.HealthAddr)
+	}
+	if cfg.DB.Path != memoryDBPath {
+		// opening a missing database would create it
+		if _, err := os.Stat(cfg.DB.Path); err != nil {
+			return fmt.Errorf("database is missing: %w", err)
+		}
+		db, err := sqlx.Open("sqlite3", storage.DSN(cfg.DB.Path, cfg.DB.BusyTimeout))
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), healthCheckTimeout)
+		defer cancel()
+		if err := migrations.Check(ctx, db.DB); err != nil {
+			return fmt.Errorf("database is unhealthy: %w", err)
+		}
+	}
+	if err := bot.CheckToken(cfg.BotToken); err != nil {
+		return fmt.Errorf("telegram is unreachable: %w", err)
+	}
+	return nil
+}
+
+func checkHealthz(addr string) error {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("listeners.health_addr (HEALTH_ADDR) is invalid: %w", err)
+	}
+	// listening on all interfaces includes the loopback one
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "localhost"
+	}
+	client := &http.Client{Timeout: healthCheckTimeout}
+	resp, err := client.Get("http://" + net.JoinHostPort(host, port) + "/healthz")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("bot is unhealthy: %s", resp.Status)
+	}
+	return nil
+}
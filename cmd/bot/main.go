@@ -2,56 +2,350 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
+	// time zones of poll schedules work wherever the bot runs, even without time zone data installed
+	_ "time/tzdata"
 
 	"github.com/dir01/tg-parcels/bot"
 	"github.com/dir01/tg-parcels/core"
 	"github.com/dir01/tg-parcels/core/storage"
+	"github.com/dir01/tg-parcels/core/storage/memory"
+	"github.com/dir01/tg-parcels/db/migrations"
+	"github.com/dir01/tg-parcels/feed"
+	"github.com/dir01/tg-parcels/grpcapi"
+	"github.com/dir01/tg-parcels/homeassistant"
+	"github.com/dir01/tg-parcels/httpapi"
+	"github.com/dir01/tg-parcels/metrics"
+	"github.com/dir01/tg-parcels/notify"
+	"github.com/dir01/tg-parcels/routemap"
+	"github.com/hori-ryota/zaperr"
 	"github.com/jmoiron/sqlx"
 	"github.com/joho/godotenv"
 	_ "github.com/mattn/go-sqlite3"
+	"github.com/spf13/cobra"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc"
 )
 
+// memoryDBPath is db.path that makes the bot use in-memory storage instead of SQLite
+const memoryDBPath = ":memory:"
+
 func main() {
 	_ = godotenv.Load()
-
-	token := os.Getenv("BOT_TOKEN")
-	if token == "" {
-		panic("BOT_TOKEN is not set")
+	if err := newRootCmd().Execute(); err != nil {
+		os.Exit(1)
 	}
+}
 
-	dbPath := os.Getenv("DB_PATH")
-	if dbPath == "" {
-		panic("DB_PATH is not set")
+// flags override the config file and environment variables
+type flags struct {
+	configPath string
+	dbPath     string
+	logLevel   string
+	dryRun     bool
+}
+
+func newRootCmd() *cobra.Command {
+	f := &flags{}
+	root := &cobra.Command{
+		Use:          "bot",
+		Short:        "Telegram bot that tracks parcels and notifies users of their updates",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			cfg, logger, err := f.load(cmd)
+			if err != nil {
+				return err
+			}
+			defer logger.Sync()
+			defer reportPanic(logger)
+			if err := run(cfg, f.dryRun, logger); err != nil {
+				logger.Error("bot failed", zaperr.ToField(err))
+				return err
+			}
+			return nil
+		},
 	}
+	// the config file is optional, everything can be configured with environment variables alone
+	root.PersistentFlags().StringVar(&f.configPath, "config", os.Getenv("CONFIG_PATH"), "path of YAML config file, see config.example.yaml (env CONFIG_PATH)")
+	root.PersistentFlags().StringVar(&f.dbPath, "db", "", "path of SQLite database, \""+memoryDBPath+"\" keeps everything in memory (db.path, env DB_PATH)")
+	root.PersistentFlags().StringVar(&f.logLevel, "log-level", "", "one of debug, info, warn and error (log.level, env LOG_LEVEL)")
+	root.Flags().BoolVar(&f.dryRun, "dry-run", false, "validate the config and check the database, then exit without starting the bot or migrating the database")
+
+	root.AddCommand(&cobra.Command{
+		Use:   "migrate",
+		Short: "Migrate the database to the latest version and exit",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			cfg, logger, err := f.load(cmd)
+			if err != nil {
+				return err
+			}
+			defer logger.Sync()
+			defer reportPanic(logger)
+			if err := migrate(cfg, logger); err != nil {
+				logger.Error("migration failed", zaperr.ToField(err))
+				return err
+			}
+			return nil
+		},
+	})
 
-	parcelsAPIURL := os.Getenv("PARCELS_SERVICE_URL")
-	if parcelsAPIURL == "" {
-		panic("PARCELS_SERVICE_URL is not set")
+	root.AddCommand(&cobra.Command{
+		Use:   "healthcheck",
+		Short: "Exit with 0 if the bot is healthy and with 1 otherwise, e.g. for HEALTHCHECK of Docker",
+		Long: "Ask /healthz of the running bot if listeners.health_addr (HEALTH_ADDR) is set,\n" +
+			"otherwise check the database and Telegram directly.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			cfg, logger, err := f.load(cmd)
+			if err != nil {
+				return err
+			}
+			defer logger.Sync()
+			return healthcheck(cfg)
+		},
+	})
+	return root
+}
+
+// load reads the config and applies flags that were set on top of it
+func (f *flags) load(cmd *cobra.Command) (*Config, *zap.Logger, error) {
+	cfg, err := LoadConfig(f.configPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("config is invalid: %w", err)
+	}
+	if cmd.Flags().Changed("db") {
+		cfg.DB.Path = f.dbPath
+	}
+	if cmd.Flags().Changed("log-level") {
+		cfg.Log.Level = f.logLevel
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, fmt.Errorf("config is invalid: %w", err)
 	}
+	logger, err := cfg.Logger()
+	if err != nil {
+		return nil, nil, err
+	}
+	return cfg, logger, nil
+}
 
-	pollingDurationStr := os.Getenv("POLLING_DURATION")
-	if pollingDurationStr == "" {
-		pollingDurationStr = "10m"
+func migrate(cfg *Config, logger *zap.Logger) error {
+	if cfg.DB.Path == memoryDBPath {
+		return errors.New("in-memory storage needs no migrations")
 	}
-	pollingDuration, err := time.ParseDuration(pollingDurationStr)
+	db, err := sqlx.Open("sqlite3", storage.DSN(cfg.DB.Path, cfg.DB.BusyTimeout))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	appliedMigrations, err := migrations.Up(context.Background(), db.DB, logger)
+	if err != nil {
+		return fmt.Errorf("failed to migrate database: %w", err)
+	}
+	schemaVersion, err := migrations.Version(context.Background(), db.DB)
+	if err != nil {
+		return fmt.Errorf("failed to get schema version: %w", err)
+	}
+	logger.Info("database migrated", zap.Int("applied_migrations", appliedMigrations), zap.String("schema_version", schemaVersion))
+	return nil
+}
 
-	logger, err := zap.NewDevelopment()
+// run starts the bot and blocks until it is stopped with SIGINT or SIGTERM and shuts down,
+// a second signal makes it exit immediately.
+// With dryRun it returns once everything is wired, without starting anything
+func run(cfg *Config, dryRun bool, logger *zap.Logger) error {
+	cipher, err := cfg.Cipher()
+	if err != nil {
+		return fmt.Errorf("DB_ENCRYPTION_KEY is invalid: %w", err)
+	}
+	pollSchedule, err := cfg.PollSchedule()
 	if err != nil {
-		panic(err)
+		return fmt.Errorf("POLL_SCHEDULE is invalid: %w", err)
+	}
+
+	var registry *metrics.Registry
+	if cfg.Listeners.MetricsAddr != "" {
+		registry = metrics.NewRegistry()
 	}
 
-	db := sqlx.MustOpen("sqlite3", dbPath)
-	stor := storage.NewStorage(db)
-	svc := core.NewService(stor, parcelsAPIURL, pollingDuration, logger)
-	botStor := bot.NewStorage(db)
-	b, err := bot.New(svc, botStor, token, logger)
+	var errorLog *httpapi.ErrorLog
+	if cfg.AdminAPI.Token != "" {
+		errorLog = httpapi.NewErrorLog(cfg.AdminAPI.RecentErrors)
+		logger = logger.WithOptions(zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+			return zapcore.NewTee(c, errorLog)
+		}))
+	}
+
+	var db *sqlx.DB
+	var stor core.Storage
+	var botStor bot.Storage
+	var notifyStor notify.Storage
+	var feedStor feed.Storage
+	var mapStor routemap.Storage
+	if cfg.DB.Path == memoryDBPath {
+		logger.Warn("using in-memory storage, everything will be lost on exit")
+		stor = memory.NewStorage(core.SystemClock)
+		botStor = bot.NewMemoryStorage(core.SystemClock)
+		notifyStor = notify.NewMemoryStorage()
+		feedStor = feed.NewMemoryStorage()
+		mapStor = routemap.NewMemoryStorage()
+	} else {
+		db, err = sqlx.Open("sqlite3", storage.DSN(cfg.DB.Path, cfg.DB.BusyTimeout))
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+		readDB := db
+		if cfg.DB.SplitReads {
+			readDB, err = sqlx.Open("sqlite3", storage.ReadOnlyDSN(cfg.DB.Path, cfg.DB.BusyTimeout))
+			if err != nil {
+				return err
+			}
+			defer readDB.Close()
+			cfg.DBPool().Apply(readDB.DB)
+			cfg.DBPool().Writer().Apply(db.DB)
+			logger.Info("reads are split from writes")
+		} else {
+			cfg.DBPool().Apply(db.DB)
+		}
+		appliedMigrations := 0
+		if cfg.DB.AutoMigrate && !dryRun {
+			if appliedMigrations, err = migrations.Up(context.Background(), db.DB, logger); err != nil {
+				return fmt.Errorf("failed to migrate database: %w", err)
+			}
+		}
+		// fail fast instead of failing with cryptic SQL errors on the first command
+		err = migrations.Check(context.Background(), db.DB)
+		if dryRun && cfg.DB.AutoMigrate && errors.Is(err, migrations.ErrSchemaOutdated) {
+			logger.Info("database would be migrated on start")
+		} else if err != nil {
+			return fmt.Errorf("database schema is incompatible: %w", err)
+		}
+		schemaVersion, err := migrations.Version(context.Background(), db.DB)
+		if err != nil {
+			return fmt.Errorf("failed to get schema version: %w", err)
+		}
+		logger.Info("database is checked", zap.Int("applied_migrations", appliedMigrations), zap.String("schema_version", schemaVersion))
+		queryMetrics := storage.NewQueryMetrics(cfg.DB.SlowQueryThreshold, logger)
+		queryMetrics.Register(registry)
+		stor = storage.NewStorage(db, readDB, cipher, cfg.DB.QueryTimeout, core.SystemClock, queryMetrics)
+		botStor = bot.NewStorage(db, readDB, cfg.DB.QueryTimeout, core.SystemClock, queryMetrics)
+		notifyStor = notify.NewStorage(db, readDB, cipher, cfg.DB.QueryTimeout, queryMetrics)
+		feedStor = feed.NewStorage(db, readDB, cfg.DB.QueryTimeout, queryMetrics)
+		mapStor = routemap.NewStorage(db, readDB, cfg.DB.QueryTimeout, queryMetrics)
+	}
+	coreMetrics := core.NewMetrics(registry)
+	httpClient := core.NewHTTPClient(cfg.HTTPTimeouts(), coreMetrics)
+	var providers []core.TrackingInfoProvider
+	if cfg.ParcelsAPI.URL != "" {
+		circuitBreaker := core.NewCircuitBreaker("parcels_api.tracking_info", cfg.ParcelsAPI.CircuitBreakerThreshold, cfg.ParcelsAPI.CircuitBreakerCooldown, logger)
+		// unlimited unless the rate limit is set
+		rateLimit := rate.Inf
+		if cfg.ParcelsAPI.RateLimit > 0 {
+			rateLimit = rate.Limit(cfg.ParcelsAPI.RateLimit)
+		}
+		rateLimiter := rate.NewLimiter(rateLimit, cfg.ParcelsAPI.RateBurst)
+		providers = append(providers, core.NewParcelsAPI(cfg.ParcelsAPI.URL, httpClient, cfg.RetryPolicy(), circuitBreaker, rateLimiter, logger))
+	}
+	var seventeenTrackAPI *core.SeventeenTrackAPI
+	if cfg.SeventeenTrack.APIKey != "" {
+		// 17track allows 3 requests per second
+		rateLimiter := rate.NewLimiter(3, 1)
+		seventeenTrackAPI = core.NewSeventeenTrackAPI(cfg.SeventeenTrack.APIKey, httpClient, rateLimiter, logger)
+		providers = append(providers, seventeenTrackAPI)
+	}
+	provider := core.NewFetchCoordinator(core.NewMultiProvider(logger, providers...), cfg.Polling.FetchResultTTL)
+	var pushSubscriber core.PushSubscriber
+	if cfg.Listeners.WebhookAddr != "" {
+		pushSubscriber = provider
+	}
+	svc := core.NewService(
+		stor, provider, pushSubscriber,
+		cfg.Polling.Interval, cfg.Polling.CarrierIntervals, pollSchedule, cfg.Polling.UpdatesBufferSize, cfg.Limits.MaxTrackingsPerUser, cfg.Limits.DeletedTrackingsRetention,
+		cfg.Limits.DeliveredTrackingsRetention, cfg.Limits.UpdateRecordsRetention, cfg.Limits.AuditRecordsRetention,
+		cfg.Alerts.StuckAfter, core.SystemClock, coreMetrics, logger,
+	)
+	registry.NewCounterFunc("tg_parcels_dropped_updates_total", "Tracking updates dropped because a subscriber's buffer was full.", func() float64 {
+		return float64(svc.DroppedUpdates())
+	})
+	var notifiers []notify.Notifier
+	if cfg.SMTP.Host != "" {
+		notifiers = append(notifiers, notify.NewEmailNotifier(notify.SMTPConfig{
+			Host:     cfg.SMTP.Host,
+			Port:     cfg.SMTP.Port,
+			Username: cfg.SMTP.Username,
+			Password: cfg.SMTP.Password,
+			From:     cfg.SMTP.From,
+		}))
+	}
+	if cfg.Webhooks.Enabled {
+		notifiers = append(notifiers, notify.NewWebhookNotifier(core.DefaultRetryPolicy(), cfg.Webhooks.AllowPrivateAddresses))
+	}
+	if cfg.Discord.BotToken != "" {
+		notifiers = append(notifiers, notify.NewDiscordNotifier(notify.DefaultDiscordURL, cfg.Discord.BotToken, core.DefaultRetryPolicy()))
+	}
+	if cfg.Slack.Enabled {
+		notifiers = append(notifiers, notify.NewSlackNotifier(notify.DefaultSlackAPIURL, notify.DefaultSlackWebhooksURL, cfg.Slack.BotToken, core.DefaultRetryPolicy()))
+	}
+	if cfg.Matrix.HomeserverURL != "" {
+		notifiers = append(notifiers, notify.NewMatrixNotifier(cfg.Matrix.HomeserverURL, cfg.Matrix.AccessToken, core.DefaultRetryPolicy()))
+	}
+	if cfg.Ntfy.Enabled {
+		notifiers = append(notifiers, notify.NewNtfyNotifier(cfg.Ntfy.DefaultServer, core.DefaultRetryPolicy(), cfg.Ntfy.AllowPrivateAddresses))
+	}
+	if len(cfg.Shoutrrr.Services) > 0 {
+		notifiers = append(notifiers, notify.NewShoutrrrNotifier(cfg.Shoutrrr.Services))
+	}
+	var channels *notify.Channels
+	if len(notifiers) > 0 {
+		channels = notify.NewChannels(notifyStor, registry, logger, notifiers...)
+	}
+	var publisher *homeassistant.Publisher
+	if cfg.MQTT.BrokerURL != "" {
+		publisher = homeassistant.NewPublisher(homeassistant.Options{
+			BrokerURL:       cfg.MQTT.BrokerURL,
+			Username:        cfg.MQTT.Username,
+			Password:        cfg.MQTT.Password,
+			ClientID:        cfg.MQTT.ClientID,
+			TopicPrefix:     cfg.MQTT.TopicPrefix,
+			DiscoveryPrefix: cfg.MQTT.DiscoveryPrefix,
+			UserIDs:         cfg.MQTT.UserIDs,
+		}, registry, logger)
+	}
+	var feeds *feed.Feeds
+	if cfg.Feeds.BaseURL != "" {
+		feeds = feed.New(svc, feedStor, cfg.Feeds.BaseURL, logger)
+	}
+	var maps *routemap.Maps
+	if cfg.RouteMap.StaticMapURL != "" {
+		geocoder := routemap.NewNominatim(cfg.RouteMap.GeocoderURL, cfg.RouteMap.UserAgent, httpClient)
+		maps = routemap.New(geocoder, mapStor, cfg.RouteMap.StaticMapURL, httpClient, logger)
+	}
+	if dryRun {
+		logger.Info("dry run, exiting")
+		return nil
+	}
+	maintenance := bot.Maintenance{
+		Interval:            cfg.Maintenance.Interval,
+		AdminChatIDs:        cfg.Maintenance.AdminChatIDs,
+		DeleteOrphanedUsers: cfg.Maintenance.DeleteOrphanedUsers,
+	}
+	b, err := bot.New(svc, botStor, channels, feeds, maps, cfg.BotToken, cfg.Alerts.DebounceWindow, maintenance, registry, logger)
 	if err != nil {
-		panic(err)
+		return err
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
@@ -60,7 +354,174 @@ func main() {
 		signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
 		<-sig
 		cancel()
+		if err := notifySystemd("STOPPING=1"); err != nil {
+			logger.Warn("failed to notify systemd", zap.Error(err))
+		}
+		// impatient operators can skip the graceful shutdown
+		<-sig
+		logger.Warn("second signal received, exiting immediately")
+		_ = logger.Sync()
+		os.Exit(1)
 	}()
 
-	b.Start(ctx)
+	if cfg.Listeners.WebhookAddr != "" {
+		mux := http.NewServeMux()
+		if seventeenTrackAPI != nil {
+			mux.Handle("/webhooks/17track", seventeenTrackAPI.WebhookHandler(svc.HandlePushedTrackingInfos))
+		}
+		if feeds != nil {
+			mux.Handle(feed.Prefix, feeds)
+		}
+		serve(ctx, "webhook", cfg.Listeners.WebhookAddr, mux, logger)
+	}
+
+	if cfg.Listeners.MetricsAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", registry.Handler())
+		if cfg.AdminAPI.Token != "" {
+			mux.Handle(httpapi.Prefix, httpapi.New(svc, botStor, channels, errorLog, cfg.AdminAPI.Token, logger))
+		}
+		serve(ctx, "metrics", cfg.Listeners.MetricsAddr, mux, logger)
+	}
+
+	if cfg.Listeners.PprofAddr != "" {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+		serve(ctx, "pprof", cfg.Listeners.PprofAddr, mux, logger)
+	}
+
+	if cfg.Listeners.HealthAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/healthz", healthHandler(db, logger))
+		serve(ctx, "health", cfg.Listeners.HealthAddr, mux, logger)
+	}
+
+	if cfg.Listeners.GRPCAddr != "" {
+		auth, err := cfg.GRPCAuth()
+		if err != nil {
+			return err
+		}
+		server, err := grpcapi.NewServer(svc, auth, logger)
+		if err != nil {
+			return err
+		}
+		if err := serveGRPC(ctx, cfg.GRPCListenAddr(), server, logger); err != nil {
+			return err
+		}
+	}
+
+	// subscribers outside the bot subscribe before the service starts, so that they get redelivered updates too
+	subscribers := newSubscribers(svc)
+	if channels != nil {
+		subscribers.Go(channels.Run)
+	}
+	if publisher != nil {
+		subscribers.Go(func(ctx context.Context, updates <-chan core.TrackingUpdate) {
+			publisher.Run(ctx, svc, updates)
+		})
+	}
+
+	// the bot has connected to Telegram by now, since New checks the token
+	if err := notifySystemd("READY=1"); err != nil {
+		logger.Warn("failed to notify systemd", zap.Error(err))
+	}
+	if interval := watchdogInterval(); interval > 0 {
+		go runWatchdog(ctx, interval, svc.PollStalledFor, logger)
+	}
+
+	// the database is closed by the deferred Close once the bot and the rest of subscribers have shut down
+	if err := b.Start(ctx, cfg.ShutdownTimeout); err != nil {
+		subscribers.Stop(0)
+		return err
+	}
+	return subscribers.Stop(cfg.ShutdownTimeout)
+}
+
+func newSubscribers(svc core.Service) *subscribers {
+	s := &subscribers{svc: svc}
+	s.ctx, s.abort = context.WithCancel(context.Background())
+	return s
+}
+
+// subscribers runs subscribers to tracking updates outside the bot, e.g. notification channels.
+// They aren't stopped along with the rest on a signal, but once the service stops publishing, see Stop,
+// so that they deliver what's left in their buffers
+type subscribers struct {
+	svc           core.Service
+	ctx           context.Context
+	abort         context.CancelFunc
+	wg            sync.WaitGroup
+	subscriptions []<-chan core.TrackingUpdate
+}
+
+// Go subscribes right away and runs the subscriber in background until its updates are unsubscribed
+func (s *subscribers) Go(run func(ctx context.Context, updates <-chan core.TrackingUpdate)) {
+	updates := s.svc.Subscribe()
+	s.subscriptions = append(s.subscriptions, updates)
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		run(s.ctx, updates)
+	}()
+}
+
+// Stop unsubscribes subscribers and waits for them to return, deliveries still in flight after timeout are aborted.
+// It has to be called once the service has stopped publishing
+func (s *subscribers) Stop(timeout time.Duration) error {
+	for _, updates := range s.subscriptions {
+		s.svc.Unsubscribe(updates)
+	}
+	defer s.abort()
+	stopped := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(stopped)
+	}()
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+	select {
+	case <-stopped:
+		return nil
+	case <-t.C:
+		return zaperr.Wrap(core.ErrShutdownTimedOut, "subscribers didn't stop in time")
+	}
+}
+
+// serveGRPC starts the gRPC listener in background, it is stopped once ctx is done
+func serveGRPC(ctx context.Context, addr string, server *grpc.Server, logger *zap.Logger) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to start gRPC listener: %w", err)
+	}
+	go func() {
+		<-ctx.Done()
+		// streams of updates never end on their own, so there's no point in waiting for them
+		server.Stop()
+	}()
+	go func() {
+		logger.Info("gRPC listener started", zap.String("addr", addr))
+		if err := server.Serve(listener); err != nil {
+			logger.Error("gRPC listener failed", zap.Error(err))
+		}
+	}()
+	return nil
+}
+
+// serve starts an HTTP listener in background, it is closed once ctx is done
+func serve(ctx context.Context, name string, addr string, handler http.Handler, logger *zap.Logger) {
+	server := &http.Server{Addr: addr, Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		_ = server.Close()
+	}()
+	go func() {
+		logger.Info(name+" listener started", zap.String("addr", addr))
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error(name+" listener failed", zap.Error(err))
+		}
+	}()
 }
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// sentryFlushTimeout limits how long pending events are sent on exit
+const sentryFlushTimeout = 2 * time.Second
+
+// withSentry makes the logger report error-level entries to Sentry (or a compatible service) at dsn
+func withSentry(logger *zap.Logger, dsn string, environment string) (*zap.Logger, error) {
+	client, err := sentry.NewClient(sentry.ClientOptions{Dsn: dsn, Environment: environment})
+	if err != nil {
+		return nil, err
+	}
+	core := &sentryCore{LevelEnabler: zapcore.ErrorLevel, hub: sentry.NewHub(client, sentry.NewScope())}
+	return logger.WithOptions(zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+		return zapcore.NewTee(c, core)
+	})), nil
+}
+
+// sentryCore sends log entries to Sentry as events.
+// User and parcel fields (see core.UserIDField and friends) become the user and tags of the event,
+// so that events can be searched by them
+type sentryCore struct {
+	zapcore.LevelEnabler
+	hub    *sentry.Hub
+	fields []zapcore.Field
+}
+
+func (c *sentryCore) With(fields []zapcore.Field) zapcore.Core {
+	return &sentryCore{
+		LevelEnabler: c.LevelEnabler,
+		hub:          c.hub,
+		fields:       append(c.fields[:len(c.fields):len(c.fields)], fields...),
+	}
+}
+
+func (c *sentryCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+func (c *sentryCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	fields = append(c.fields[:len(c.fields):len(c.fields)], fields...)
+	enc := zapcore.NewMapObjectEncoder()
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+
+	event := sentry.NewEvent()
+	event.Level = sentryLevel(entry.Level)
+	event.Message = entry.Message
+	event.Logger = entry.LoggerName
+	event.Timestamp = entry.Time
+	event.Extra = enc.Fields
+	if entry.Caller.Defined {
+		event.Tags["caller"] = entry.Caller.TrimmedPath()
+	}
+	if userID, ok := findField(enc.Fields, "user_id"); ok {
+		event.User.ID = fmt.Sprint(userID)
+	}
+	for _, key := range []string{"chat_id", "tracking_number", "tracking_id"} {
+		if value, ok := findField(enc.Fields, key); ok {
+			event.Tags[key] = fmt.Sprint(value)
+		}
+	}
+	if err := findError(fields); err != nil {
+		event.Exception = []sentry.Exception{{
+			Type:       entry.Message,
+			Value:      err.Error(),
+			Stacktrace: sentry.ExtractStacktrace(err),
+		}}
+	}
+
+	c.hub.CaptureEvent(event)
+	return nil
+}
+
+func (c *sentryCore) Sync() error {
+	c.hub.Flush(sentryFlushTimeout)
+	return nil
+}
+
+func sentryLevel(level zapcore.Level) sentry.Level {
+	switch level {
+	case zapcore.DebugLevel:
+		return sentry.LevelDebug
+	case zapcore.InfoLevel:
+		return sentry.LevelInfo
+	case zapcore.WarnLevel:
+		return sentry.LevelWarning
+	case zapcore.ErrorLevel:
+		return sentry.LevelError
+	default:
+		return sentry.LevelFatal
+	}
+}
+
+// findField looks for the field at the top level and in errors, since zaperr errors carry fields of their own
+func findField(fields map[string]interface{}, key string) (interface{}, bool) {
+	if value, ok := fields[key]; ok {
+		return value, true
+	}
+	if errFields, ok := fields["error"].(map[string]interface{}); ok {
+		return findField(errFields, key)
+	}
+	return nil, false
+}
+
+func findError(fields []zapcore.Field) error {
+	for _, f := range fields {
+		if err, ok := f.Interface.(error); ok && (f.Type == zapcore.ErrorType || f.Type == zapcore.ObjectMarshalerType) {
+			return err
+		}
+	}
+	return nil
+}
+
+// reportPanic logs the panic, so that it is reported before the process dies, and panics again.
+// It is meant to be deferred
+func reportPanic(logger *zap.Logger) {
+	if p := recover(); p != nil {
+		err, ok := p.(error)
+		if !ok {
+			err = errors.New(fmt.Sprint(p))
+		}
+		logger.Error("panic", zap.Error(err), zap.Stack("stack"))
+		_ = logger.Sync()
+		panic(p)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// notifySystemd sends the state (see sd_notify(3)) to systemd when the bot runs as a Type=notify service,
+// it does nothing unless NOTIFY_SOCKET is set
+func notifySystemd(state string) error {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+	// sockets starting with @ are in the abstract namespace
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+// watchdogInterval is how often systemd expects to be pinged (WatchdogSec), 0 means the watchdog is disabled
+func watchdogInterval() time.Duration {
+	if os.Getenv("NOTIFY_SOCKET") == "" {
+		return 0
+	}
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond
+}
+
+// runWatchdog pings systemd twice per interval until ctx is done, unless the poller has been stalled for longer
+// than the interval, so that systemd restarts a wedged bot. WatchdogSec has to be longer than the slowest fetch
+// (with all of its retries) for that not to happen to a healthy one
+func runWatchdog(ctx context.Context, interval time.Duration, pollStalledFor func() time.Duration, logger *zap.Logger) {
+	logger.Info("systemd watchdog enabled", zap.Duration("interval", interval))
+	t := time.NewTicker(interval / 2)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if stalledFor := pollStalledFor(); stalledFor > interval {
+				logger.Warn("poller is stalled, skipping watchdog ping", zap.Duration("stalled_for", stalledFor))
+				continue
+			}
+			if err := notifySystemd("WATCHDOG=1"); err != nil {
+				logger.Warn("failed to ping systemd watchdog", zap.Error(err))
+			}
+		}
+	}
+}
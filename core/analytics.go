@@ -0,0 +1,44 @@
+package core
+
+import (
+	"context"
+	"time"
+)
+
+// TrackingCounts are counts of trackings of all users
+type TrackingCounts struct {
+	// Active are trackings that are not delivered yet
+	Active int
+	// Delivered are trackings some provider reported as delivered
+	Delivered int
+	// Deleted are soft-deleted trackings that weren't purged yet
+	Deleted int
+}
+
+// UserTrackingsCount is the number of trackings of a user
+type UserTrackingsCount struct {
+	UserID int64
+	Count  int
+}
+
+// APIEventsCount is the number of tracking events reported by an API (i.e. a carrier or an aggregator)
+type APIEventsCount struct {
+	ApiName string
+	Count   int
+}
+
+// DailyCount is a count of something that happened on a day (in UTC)
+type DailyCount struct {
+	Day   time.Time
+	Count int
+}
+
+// AnalyticsStorage answers aggregate questions about all users, for admin commands and metrics.
+// Deleted trackings are excluded unless stated otherwise
+type AnalyticsStorage interface {
+	CountTrackings(ctx context.Context) (*TrackingCounts, error)
+	// ListTrackingsPerUser lists up to limit users with the most trackings, most first
+	ListTrackingsPerUser(ctx context.Context, limit int) ([]UserTrackingsCount, error)
+	// CountEventsByAPI counts events of all trackings by the API that reported them, most first
+	CountEventsByAPI(ctx context.Context) ([]APIEventsCount, error)
+}
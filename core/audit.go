@@ -0,0 +1,30 @@
+package core
+
+import "time"
+
+// AuditAction is a lifecycle change of a tracking
+type AuditAction string
+
+const (
+	AuditActionCreated  AuditAction = "created"
+	AuditActionRenamed  AuditAction = "renamed"
+	AuditActionDeleted  AuditAction = "deleted"
+	AuditActionRestored AuditAction = "restored"
+	// AuditActionDeliveryDisputed is the user saying a parcel reported as delivered hasn't reached them
+	AuditActionDeliveryDisputed AuditAction = "delivery_disputed"
+	// AuditActionNumberAttached is a manual parcel getting a real tracking number, see AttachTrackingNumber
+	AuditActionNumberAttached AuditAction = "number_attached"
+)
+
+// AuditRecord is an entry of the tracking history.
+// Records are kept after the tracking itself is deleted, so they refer to it by user and tracking number
+type AuditRecord struct {
+	ID             int64
+	TrackingID     int64
+	UserID         int64
+	TrackingNumber string
+	Action         AuditAction
+	// Details is a human-readable description of the change, e.g. old and new name
+	Details   string
+	CreatedAt time.Time
+}
@@ -0,0 +1,198 @@
+package core
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	CarrierUPU   = "upu" // national postal operators using UPU S10 numbers
+	CarrierUPS   = "ups"
+	CarrierDHL   = "dhl"
+	CarrierFedEx = "fedex"
+	CarrierUSPS  = "usps"
+	// CarrierPostNL is PostNL with its domestic barcodes, international parcels of PostNL have UPU S10 numbers
+	CarrierPostNL = "postnl"
+)
+
+// ErrUnknownCarrier is returned by Track for carrier hints that aren't codes of carriers we know, see CarrierByCode
+var ErrUnknownCarrier = errors.New("unknown carrier")
+
+// Carrier is a guess of who handles a parcel, made from the format of its tracking number
+type Carrier struct {
+	Code    string
+	Name    string
+	Country string // ISO 3166-1 alpha-2 code, only known for postal operators
+	// NeedsPostalCode tells that the carrier only tells anything about the parcel given the postal code of the recipient,
+	// see TrackingParams
+	NeedsPostalCode bool
+}
+
+var (
+	upuS10Re      = regexp.MustCompile(`^[A-Z]{2}\d{9}[A-Z]{2}$`)
+	upsRe         = regexp.MustCompile(`^1Z[0-9A-Z]{16}$`)
+	dhlExpressRe  = regexp.MustCompile(`^\d{10}$`)
+	dhlEcommRe    = regexp.MustCompile(`^(GM|LX|RX)\d{16,18}$`)
+	dhlParcelRe   = regexp.MustCompile(`^JJD\d{16,20}$`)
+	uspsRe        = regexp.MustCompile(`^(9[1-5]\d{18,20}|82\d{8})$`)
+	fedExRe       = regexp.MustCompile(`^(\d{12}|\d{15}|\d{20}|\d{22})$`)
+	postNLRe      = regexp.MustCompile(`^3S[A-Z]{4}\d{6,11}$`)
+	nonAlphanumRe = regexp.MustCompile(`[^0-9A-Z]`)
+)
+
+// postalOperators maps UPU S10 suffix country codes to the names of national postal operators
+var postalOperators = map[string]string{
+	"AT": "Austrian Post",
+	"AU": "Australia Post",
+	"BE": "bpost",
+	"BY": "Belpost",
+	"CA": "Canada Post",
+	"CH": "Swiss Post",
+	"CN": "China Post",
+	"CZ": "Czech Post",
+	"DE": "Deutsche Post",
+	"DK": "PostNord Denmark",
+	"EE": "Omniva",
+	"ES": "Correos",
+	"FI": "Posti",
+	"FR": "La Poste",
+	"GB": "Royal Mail",
+	"HK": "Hongkong Post",
+	"IL": "Israel Post",
+	"IN": "India Post",
+	"IT": "Poste Italiane",
+	"JP": "Japan Post",
+	"KR": "Korea Post",
+	"KZ": "Kazpost",
+	"LT": "Lithuania Post",
+	"LV": "Latvijas Pasts",
+	"NL": "PostNL",
+	"NO": "Posten Norge",
+	"PL": "Poczta Polska",
+	"PT": "CTT",
+	"RU": "Russian Post",
+	"SE": "PostNord Sweden",
+	"SG": "Singapore Post",
+	"TR": "PTT",
+	"TW": "Chunghwa Post",
+	"UA": "Ukrposhta",
+	"US": "USPS",
+}
+
+// carrierNames are names of carriers other than postal operators, see postalOperatorName for those
+var carrierNames = map[string]string{
+	CarrierUPS:    "UPS",
+	CarrierDHL:    "DHL",
+	CarrierFedEx:  "FedEx",
+	CarrierUSPS:   "USPS",
+	CarrierPostNL: "PostNL",
+}
+
+// postalOperatorName is the name of the national postal operator of the country
+func postalOperatorName(country string) string {
+	if name, ok := postalOperators[country]; ok {
+		return name
+	}
+	return "Postal service (" + country + ")"
+}
+
+// normalizeTrackingNumber uppercases a tracking number and strips spaces and dashes users tend to copy along with it
+func normalizeTrackingNumber(trackingNumber string) string {
+	return nonAlphanumRe.ReplaceAllString(strings.ToUpper(trackingNumber), "")
+}
+
+// CarrierCodes are the codes users can give as carrier hints (see TrackingParams), sorted.
+// UPU isn't among them, since it isn't a carrier, but a format national postal operators share
+func CarrierCodes() []string {
+	codes := make([]string, 0, len(carrierNames))
+	for code := range carrierNames {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// CarrierByCode is the carrier with the code, one of CarrierCodes, or nil for unknown codes.
+// The carrier of a number of its own format is given by DetectCarrier, since e.g. only it knows whether PostNL needs a postal code
+func CarrierByCode(code string) *Carrier {
+	code = strings.ToLower(code)
+	name, ok := carrierNames[code]
+	if !ok {
+		return nil
+	}
+	carrier := &Carrier{Code: code, Name: name}
+	if code == CarrierUSPS {
+		carrier.Country = "US"
+	}
+	if code == CarrierPostNL {
+		carrier.Country = "NL"
+	}
+	return carrier
+}
+
+// Carrier is the carrier the user has told the tracking is handled by (see TrackingParams), or the one guessed by
+// DetectCarrier otherwise. It is nil if neither is known
+func (t *Tracking) Carrier() *Carrier {
+	if t.Params.Carrier == "" {
+		return DetectCarrier(t.TrackingNumber)
+	}
+	if detected := DetectCarrier(t.TrackingNumber); detected != nil && detected.Code == t.Params.Carrier {
+		return detected
+	}
+	return CarrierByCode(t.Params.Carrier)
+}
+
+// CandidateCarriers are all carriers the tracking number format fits, DetectCarrier's guess first.
+// More than one candidate means the format is ambiguous, e.g. 10 digits fit both DHL Express and USPS
+func CandidateCarriers(trackingNumber string) []*Carrier {
+	detected := DetectCarrier(trackingNumber)
+	if detected == nil {
+		return nil
+	}
+	candidates := []*Carrier{detected}
+	n := normalizeTrackingNumber(trackingNumber)
+	for _, f := range []struct {
+		re   *regexp.Regexp
+		code string
+	}{
+		{uspsRe, CarrierUSPS},
+		{dhlExpressRe, CarrierDHL},
+		{fedExRe, CarrierFedEx},
+	} {
+		if f.code != detected.Code && f.re.MatchString(n) {
+			candidates = append(candidates, CarrierByCode(f.code))
+		}
+	}
+	return candidates
+}
+
+// DetectCarrier guesses the carrier from the tracking number format.
+// It returns nil if the format is not recognized.
+// Note that some formats are ambiguous (e.g. plain digits), so this is only a hint
+func DetectCarrier(trackingNumber string) *Carrier {
+	n := normalizeTrackingNumber(trackingNumber)
+
+	switch {
+	case upuS10Re.MatchString(n):
+		country := n[len(n)-2:]
+		return &Carrier{Code: CarrierUPU, Name: postalOperatorName(country), Country: country}
+	case upsRe.MatchString(n):
+		return &Carrier{Code: CarrierUPS, Name: carrierNames[CarrierUPS]}
+	// USPS goes before DHL, since 10-digit USPS numbers would match the DHL Express format too
+	case uspsRe.MatchString(n):
+		return &Carrier{Code: CarrierUSPS, Name: carrierNames[CarrierUSPS], Country: "US"}
+	// DHL Parcel only shows the details of parcels to those who know where they go
+	case dhlParcelRe.MatchString(n):
+		return &Carrier{Code: CarrierDHL, Name: carrierNames[CarrierDHL], NeedsPostalCode: true}
+	case dhlEcommRe.MatchString(n), dhlExpressRe.MatchString(n):
+		return &Carrier{Code: CarrierDHL, Name: carrierNames[CarrierDHL]}
+	case postNLRe.MatchString(n):
+		return &Carrier{Code: CarrierPostNL, Name: carrierNames[CarrierPostNL], Country: "NL", NeedsPostalCode: true}
+	case fedExRe.MatchString(n):
+		return &Carrier{Code: CarrierFedEx, Name: carrierNames[CarrierFedEx]}
+	default:
+		return nil
+	}
+}
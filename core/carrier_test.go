@@ -0,0 +1,88 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDetectCarrier(t *testing.T) {
+	tests := []struct {
+		trackingNumber  string
+		wantCode        string
+		wantCountry     string
+		needsPostalCode bool
+	}{
+		{"RR123456785US", CarrierUPU, "US", false},
+		{"rr 123-456-785 gb", CarrierUPU, "GB", false},
+		{"1Z999AA10123456784", CarrierUPS, "", false},
+		// 82-prefixed 10 digits are USPS, though any 10 digits fit DHL Express
+		{"8212345678", CarrierUSPS, "US", false},
+		{"1234567890", CarrierDHL, "", false},
+		// 20 and 22 digits are USPS if they start with 91-95, FedEx otherwise
+		{"92055901649173127510", CarrierUSPS, "US", false},
+		{"9205590164917312751089", CarrierUSPS, "US", false},
+		{"12345678901234567890", CarrierFedEx, "", false},
+		{"9605590164917312751089", CarrierFedEx, "", false},
+		{"123456789012", CarrierFedEx, "", false},
+		{"123456789012345", CarrierFedEx, "", false},
+		{"GM1234567890123456", CarrierDHL, "", false},
+		{"JJD01234567890123456", CarrierDHL, "", true},
+		{"3SABCD1234567", CarrierPostNL, "NL", true},
+		{"12345", "", "", false},
+		{"ABCDEFGHIJ", "", "", false},
+	}
+	for _, tt := range tests {
+		got := DetectCarrier(tt.trackingNumber)
+		if tt.wantCode == "" {
+			if got != nil {
+				t.Errorf("DetectCarrier(%q) = %+v, want nil", tt.trackingNumber, got)
+			}
+			continue
+		}
+		if got == nil || got.Code != tt.wantCode || got.Country != tt.wantCountry || got.NeedsPostalCode != tt.needsPostalCode {
+			t.Errorf("DetectCarrier(%q) = %+v, want %s in %q, needing postal code: %v",
+				tt.trackingNumber, got, tt.wantCode, tt.wantCountry, tt.needsPostalCode)
+		}
+	}
+}
+
+func TestDetectCarrierNames(t *testing.T) {
+	tests := []struct {
+		trackingNumber string
+		want           string
+	}{
+		{"RR123456785GB", "Royal Mail"},
+		{"RR123456785XX", "Postal service (XX)"},
+		{"1Z999AA10123456784", "UPS"},
+	}
+	for _, tt := range tests {
+		if got := DetectCarrier(tt.trackingNumber); got == nil || got.Name != tt.want {
+			t.Errorf("DetectCarrier(%q) = %+v, want %s", tt.trackingNumber, got, tt.want)
+		}
+	}
+}
+
+func TestCandidateCarriers(t *testing.T) {
+	tests := []struct {
+		trackingNumber string
+		want           []string
+	}{
+		{"8212345678", []string{CarrierUSPS, CarrierDHL}},
+		{"1234567890", []string{CarrierDHL}},
+		{"92055901649173127510", []string{CarrierUSPS, CarrierFedEx}},
+		{"9205590164917312751089", []string{CarrierUSPS, CarrierFedEx}},
+		{"12345678901234567890", []string{CarrierFedEx}},
+		{"RR123456785US", []string{CarrierUPU}},
+		{"1Z999AA10123456784", []string{CarrierUPS}},
+		{"12345", nil},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, carrier := range CandidateCarriers(tt.trackingNumber) {
+			got = append(got, carrier.Code)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("CandidateCarriers(%q) = %v, want %v", tt.trackingNumber, got, tt.want)
+		}
+	}
+}
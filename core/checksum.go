@@ -0,0 +1,105 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+const (
+	minTrackingNumberLength = 6
+	maxTrackingNumberLength = 40
+	// maxRawTrackingNumberLength leaves room for spaces and dashes users copy along with numbers
+	maxRawTrackingNumberLength = 50
+)
+
+// ErrChecksumMismatch means the number matches a format with a check digit, but the digit is wrong.
+// It's likely a typo, but carriers do issue numbers that break their own rules, so it's only a warning
+var ErrChecksumMismatch = errors.New("tracking number fails its checksum")
+
+// trackingNumberCharsRe is what tracking numbers are made of, along with separators
+var trackingNumberCharsRe = regexp.MustCompile(`^[0-9A-Za-z -]+$`)
+
+// ValidateTrackingNumber returns ErrInvalidTrackingNumber for input that can't be a tracking number of any carrier,
+// and ErrChecksumMismatch for numbers of formats with check digits (UPU S10, UPS, USPS) that fail the check.
+// Check digits are checked on the number as it is given, which is what Track stores and asks providers about,
+// so a number only matches a format with check digits if it is written the way the carrier writes it
+func ValidateTrackingNumber(trackingNumber string) error {
+	if !trackingNumberCharsRe.MatchString(trackingNumber) {
+		return fmt.Errorf("%w: only latin letters and digits are allowed", ErrInvalidTrackingNumber)
+	}
+	n := normalizeTrackingNumber(trackingNumber)
+	if len(n) < minTrackingNumberLength {
+		return fmt.Errorf("%w: too short", ErrInvalidTrackingNumber)
+	}
+	if len(n) > maxTrackingNumberLength || len(trackingNumber) > maxRawTrackingNumberLength {
+		return fmt.Errorf("%w: too long", ErrInvalidTrackingNumber)
+	}
+
+	var valid bool
+	switch {
+	case upuS10Re.MatchString(trackingNumber):
+		valid = s10CheckDigit(trackingNumber[2:10]) == int(trackingNumber[10]-'0')
+	case upsRe.MatchString(trackingNumber):
+		valid = upsCheckDigit(trackingNumber[2:17]) == digitValue(trackingNumber[17])
+	case uspsRe.MatchString(trackingNumber) && len(trackingNumber) >= 20:
+		valid = mod10CheckDigit(trackingNumber[:len(trackingNumber)-1]) == int(trackingNumber[len(trackingNumber)-1]-'0')
+	default:
+		return nil
+	}
+	if !valid {
+		return ErrChecksumMismatch
+	}
+	return nil
+}
+
+// s10CheckDigit is the check digit of the serial number of UPU S10 numbers
+func s10CheckDigit(serial string) int {
+	weights := [8]int{8, 6, 4, 2, 3, 5, 9, 7}
+	sum := 0
+	for i := range weights {
+		sum += int(serial[i]-'0') * weights[i]
+	}
+	switch check := 11 - sum%11; check {
+	case 10:
+		return 0
+	case 11:
+		return 5
+	default:
+		return check
+	}
+}
+
+// upsCheckDigit is the check digit of the 15 characters following "1Z"
+func upsCheckDigit(s string) int {
+	sum := 0
+	for i := 0; i < len(s); i++ {
+		v := digitValue(s[i])
+		if i%2 == 1 {
+			v *= 2
+		}
+		sum += v
+	}
+	return (10 - sum%10) % 10
+}
+
+// mod10CheckDigit is the GS1 check digit USPS uses: digits are weighted 3 and 1 alternately from the right
+func mod10CheckDigit(digits string) int {
+	sum := 0
+	for i := len(digits) - 1; i >= 0; i-- {
+		v := int(digits[i] - '0')
+		if (len(digits)-1-i)%2 == 0 {
+			v *= 3
+		}
+		sum += v
+	}
+	return (10 - sum%10) % 10
+}
+
+// digitValue of letters is how UPS maps them to digits: A is 2, B is 3 and so on, modulo 10
+func digitValue(c byte) int {
+	if c >= '0' && c <= '9' {
+		return int(c - '0')
+	}
+	return int(c-'A'+2) % 10
+}
@@ -0,0 +1,97 @@
+package core
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidateTrackingNumber(t *testing.T) {
+	tests := []struct {
+		trackingNumber string
+		want           error
+	}{
+		// UPU S10
+		{"RR123456785US", nil},
+		{"LZ987654326CN", nil},
+		{"RR123456784US", ErrChecksumMismatch},
+		{"LZ987654321CN", ErrChecksumMismatch},
+		// UPS 1Z
+		{"1Z999AA10123456784", nil},
+		{"1Z999AA10123456785", ErrChecksumMismatch},
+		// USPS
+		{"9205590164917312751089", nil},
+		{"9205590164917312751088", ErrChecksumMismatch},
+		// 82-prefixed USPS numbers have no check digit
+		{"8212345678", nil},
+		// formats without check digits
+		{"JD014600006251544585", nil},
+		{"123456789012", nil},
+		// check digits are only checked on numbers written the way carriers write them
+		{"rr123456784us", nil},
+		{"RR 123 456 784 US", nil},
+
+		{"RR12345€785US", ErrInvalidTrackingNumber},
+		{"RR-12", ErrInvalidTrackingNumber},
+		{strings.Repeat("A", maxTrackingNumberLength+1), ErrInvalidTrackingNumber},
+		{strings.Repeat("A-", maxRawTrackingNumberLength/2+1), ErrInvalidTrackingNumber},
+	}
+	for _, tt := range tests {
+		if got := ValidateTrackingNumber(tt.trackingNumber); !errors.Is(got, tt.want) {
+			t.Errorf("ValidateTrackingNumber(%q) = %v, want %v", tt.trackingNumber, got, tt.want)
+		}
+	}
+}
+
+func TestS10CheckDigit(t *testing.T) {
+	tests := []struct {
+		serial string
+		want   int
+	}{
+		{"12345678", 5},
+		{"98765432", 6},
+		// 11 - sum%11 is 10 and 11 for these, which map to 0 and 5
+		{"00060000", 0},
+		{"00000000", 5},
+	}
+	for _, tt := range tests {
+		if got := s10CheckDigit(tt.serial); got != tt.want {
+			t.Errorf("s10CheckDigit(%q) = %d, want %d", tt.serial, got, tt.want)
+		}
+	}
+}
+
+func TestUPSCheckDigit(t *testing.T) {
+	tests := []struct {
+		s    string
+		want int
+	}{
+		{"999AA1012345678", 4},
+		{"000000000000000", 0},
+		// letters count as digits: A is 2, so it weighs 4 in an even position
+		{"0A0000000000000", 6},
+	}
+	for _, tt := range tests {
+		if got := upsCheckDigit(tt.s); got != tt.want {
+			t.Errorf("upsCheckDigit(%q) = %d, want %d", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestMod10CheckDigit(t *testing.T) {
+	tests := []struct {
+		digits string
+		want   int
+	}{
+		{"920559016491731275108", 9},
+		{"000000000000000000000", 0},
+		// the rightmost digit weighs 3
+		{"000000000000000000001", 7},
+		{"000000000000000000010", 9},
+	}
+	for _, tt := range tests {
+		if got := mod10CheckDigit(tt.digits); got != tt.want {
+			t.Errorf("mod10CheckDigit(%q) = %d, want %d", tt.digits, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package core
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+type CircuitState string
+
+const (
+	CircuitClosed   CircuitState = "closed"
+	CircuitOpen     CircuitState = "open"
+	CircuitHalfOpen CircuitState = "half_open"
+)
+
+func NewCircuitBreaker(name string, failureThreshold int, cooldown time.Duration, logger *zap.Logger) *CircuitBreaker {
+	return &CircuitBreaker{
+		name:             name,
+		failureThreshold: failureThreshold,
+		cooldown:         cooldown,
+		logger:           logger,
+		state:            CircuitClosed,
+		now:              time.Now,
+	}
+}
+
+// CircuitBreaker stops calling a failing endpoint for a while.
+// After failureThreshold consecutive failures it opens and rejects all calls with ErrCircuitOpen.
+// Once cooldown has passed, a single trial call is let through (half-open state):
+// if it succeeds, the circuit closes, otherwise it opens for another cooldown period.
+type CircuitBreaker struct {
+	name             string
+	failureThreshold int
+	cooldown         time.Duration
+	logger           *zap.Logger
+	now              func() time.Time
+
+	// OnStateChange, if set, is called on every state transition, e.g. to report metrics
+	OnStateChange func(name string, from, to CircuitState)
+
+	mu                  sync.Mutex
+	state               CircuitState
+	consecutiveFailures int
+	openedAt            time.Time
+	trialInFlight       bool
+}
+
+// Call executes fn unless the circuit is open.
+// isFailure decides which errors should count towards opening the circuit:
+// e.g. "not found" is a perfectly valid answer and should not trip the breaker
+func (cb *CircuitBreaker) Call(fn func() error, isFailure func(error) bool) error {
+	if err := cb.before(); err != nil {
+		return err
+	}
+	err := fn()
+	cb.after(err != nil && isFailure(err))
+	return err
+}
+
+func (cb *CircuitBreaker) State() CircuitState {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	return cb.state
+}
+
+func (cb *CircuitBreaker) before() error {
+	if cb.failureThreshold <= 0 {
+		return nil // circuit breaker disabled
+	}
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	switch cb.state {
+	case CircuitOpen:
+		if cb.now().Sub(cb.openedAt) < cb.cooldown {
+			return ErrCircuitOpen
+		}
+		cb.setState(CircuitHalfOpen)
+		cb.trialInFlight = true
+		return nil
+	case CircuitHalfOpen:
+		if cb.trialInFlight {
+			return ErrCircuitOpen
+		}
+		cb.trialInFlight = true
+		return nil
+	default:
+		return nil
+	}
+}
+
+func (cb *CircuitBreaker) after(failed bool) {
+	if cb.failureThreshold <= 0 {
+		return
+	}
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.state == CircuitHalfOpen {
+		cb.trialInFlight = false
+		if failed {
+			cb.openedAt = cb.now()
+			cb.setState(CircuitOpen)
+		} else {
+			cb.consecutiveFailures = 0
+			cb.setState(CircuitClosed)
+		}
+		return
+	}
+
+	if !failed {
+		cb.consecutiveFailures = 0
+		return
+	}
+
+	cb.consecutiveFailures++
+	if cb.state == CircuitClosed && cb.consecutiveFailures >= cb.failureThreshold {
+		cb.openedAt = cb.now()
+		cb.setState(CircuitOpen)
+	}
+}
+
+// setState must be called with mu held
+func (cb *CircuitBreaker) setState(state CircuitState) {
+	from := cb.state
+	if from == state {
+		return
+	}
+	cb.state = state
+
+	cb.logger.Warn(
+		"circuit breaker state changed",
+		zap.String("circuit_breaker", cb.name),
+		zap.String("from", string(from)),
+		zap.String("to", string(state)),
+		zap.Int("consecutive_failures", cb.consecutiveFailures),
+	)
+	if cb.OnStateChange != nil {
+		cb.OnStateChange(cb.name, from, state)
+	}
+}
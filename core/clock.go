@@ -0,0 +1,44 @@
+package core
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells the time the service and storages go by, so that poll scheduling, expiry and retention can be tested
+// by advancing FakeClock instead of waiting. Timers and latency metrics go by real time regardless
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock is the Clock of real time
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// FakeClock is a Clock for tests, it stands still until it is advanced
+type FakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance moves the clock d forward
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
@@ -0,0 +1,68 @@
+package core
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hori-ryota/zaperr"
+)
+
+// compareStage is the stage transit times are compared from: parcels get registered long before
+// they are handed over to the carrier at times, which says nothing of the carrier
+const compareStage = StatusInTransit
+
+// CarrierTransitTime is how long parcels of a carrier take to be delivered once they are on the way,
+// averaged across all users
+type CarrierTransitTime struct {
+	Carrier string
+	// Country is where parcels come from, it is only known for postal operators
+	Country      string
+	SamplesCount int
+	Average      time.Duration
+	// Received and NotReceived count what users said of parcels of the carrier reported as delivered,
+	// see ConfirmDelivery
+	Received    int
+	NotReceived int
+}
+
+// CompareCarriers compares carriers by transit times of parcels delivered before, fastest first.
+// Only routes with enough samples for the average to mean something (and for no parcel to stand out) are compared.
+// Non-empty country limits the comparison to postal operators of the country, the only ones origins are known of
+func (s *ServiceImpl) CompareCarriers(ctx context.Context, country string) ([]*CarrierTransitTime, error) {
+	routes, err := s.storage.ListRouteTransitTimes(ctx, compareStage, etaMinSamples)
+	if err != nil {
+		return nil, zaperr.Wrap(err, "failed to list route transit times")
+	}
+	confirmations, err := s.storage.ListRouteDeliveryConfirmations(ctx)
+	if err != nil {
+		return nil, zaperr.Wrap(err, "failed to list delivery confirmations")
+	}
+	confirmationsByRoute := make(map[string]*RouteDeliveryConfirmations, len(confirmations))
+	for _, c := range confirmations {
+		confirmationsByRoute[c.Route] = c
+	}
+	country = strings.ToUpper(country)
+	var result []*CarrierTransitTime
+	for _, r := range routes {
+		code, routeCountry, _ := strings.Cut(r.Route, ":")
+		if country != "" && routeCountry != country {
+			continue
+		}
+		var name string
+		if code == CarrierUPU {
+			name = postalOperatorName(routeCountry)
+		} else if name = carrierNames[code]; name == "" {
+			// numbers of unknown formats may be of any carrier
+			continue
+		}
+		carrier := &CarrierTransitTime{Carrier: name, Country: routeCountry, SamplesCount: r.SamplesCount, Average: r.Average}
+		if c, ok := confirmationsByRoute[r.Route]; ok {
+			carrier.Received, carrier.NotReceived = c.Received, c.NotReceived
+		}
+		result = append(result, carrier)
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].Average < result[j].Average })
+	return result, nil
+}
@@ -0,0 +1,36 @@
+package core
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNotModified is returned by providers for conditional fetches (see WithValidators)
+// when tracking infos haven't changed since the version the validators identify
+var ErrNotModified = errors.New("not modified")
+
+// Validators identify the version of tracking infos a provider returned last time, see WithValidators.
+// They are opaque values of ETag and Last-Modified HTTP headers, empty ones aren't sent
+type Validators struct {
+	ETag         string
+	LastModified string
+}
+
+func (v Validators) IsZero() bool {
+	return v.ETag == "" && v.LastModified == ""
+}
+
+type validatorsKey struct{}
+
+// WithValidators makes fetches with the context conditional: providers supporting it return ErrNotModified
+// if tracking infos haven't changed since the version validators identify, and set validators
+// to the ones of the fetched tracking infos otherwise. nil validators make fetches unconditional
+func WithValidators(ctx context.Context, validators *Validators) context.Context {
+	return context.WithValue(ctx, validatorsKey{}, validators)
+}
+
+// validatorsOf returns validators of a conditional fetch, nil for unconditional ones
+func validatorsOf(ctx context.Context) *Validators {
+	validators, _ := ctx.Value(validatorsKey{}).(*Validators)
+	return validators
+}
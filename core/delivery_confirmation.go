@@ -0,0 +1,76 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/hori-ryota/zaperr"
+	"go.uber.org/zap"
+)
+
+// ErrNotDelivered is returned by ConfirmDelivery for trackings no provider has reported as delivered
+var ErrNotDelivered = errors.New("tracking is not delivered")
+
+// DeliveryConfirmation is what a user said of a parcel reported as delivered: whether it actually reached them.
+// Confirmations are anonymous just like transit samples, they are aggregated by route (see routeOf)
+type DeliveryConfirmation struct {
+	Route    string
+	Received bool
+}
+
+// RouteDeliveryConfirmations counts confirmations of parcels delivered on a route
+type RouteDeliveryConfirmations struct {
+	Route       string
+	Received    int
+	NotReceived int
+}
+
+// DeliveryConfirmationStorage persists delivery confirmations
+type DeliveryConfirmationStorage interface {
+	SaveDeliveryConfirmation(ctx context.Context, confirmation *DeliveryConfirmation) error
+	// ListRouteDeliveryConfirmations counts confirmations on every route that has any
+	ListRouteDeliveryConfirmations(ctx context.Context) ([]*RouteDeliveryConfirmations, error)
+	// SetDeliveryDisputedAt flags the tracking as not received despite being delivered, nil clears the flag
+	SetDeliveryDisputedAt(ctx context.Context, trackingID int64, disputedAt *time.Time) error
+}
+
+// ConfirmDelivery records whether the parcel reported as delivered has actually reached the user.
+// A received parcel is deleted, it can be restored just like the ones deleted with DeleteTracking.
+// A parcel that hasn't been received is flagged and kept tracked, it isn't deleted by DeleteFinishedTrackings.
+// It returns ErrNotDelivered if no provider has reported the parcel as delivered
+func (s *ServiceImpl) ConfirmDelivery(ctx context.Context, userID int64, trackingNumber string, received bool) error {
+	tracking, err := s.storage.GetTracking(ctx, userID, trackingNumber)
+	if err != nil {
+		return err
+	}
+	if tracking.Status() != StatusDelivered {
+		return ErrNotDelivered
+	}
+	// answering again that the parcel hasn't been received doesn't make the carrier any less reliable
+	if received || tracking.DeliveryDisputedAt == nil {
+		confirmation := &DeliveryConfirmation{Route: routeOf(trackingNumber), Received: received}
+		if err := s.storage.SaveDeliveryConfirmation(ctx, confirmation); err != nil {
+			return zaperr.Wrap(err, "failed to save delivery confirmation", TrackingFields(tracking)...)
+		}
+	}
+	s.logger.Info("delivery confirmed", append(TrackingFields(tracking), zap.Bool("received", received))...)
+
+	if received {
+		if tracking.DeliveryDisputedAt != nil {
+			if err := s.storage.SetDeliveryDisputedAt(ctx, tracking.ID, nil); err != nil {
+				return zaperr.Wrap(err, "failed to clear delivery dispute", TrackingFields(tracking)...)
+			}
+		}
+		return s.DeleteTracking(ctx, userID, trackingNumber)
+	}
+	if tracking.DeliveryDisputedAt != nil {
+		return nil
+	}
+	now := s.clock.Now()
+	if err := s.storage.SetDeliveryDisputedAt(ctx, tracking.ID, &now); err != nil {
+		return zaperr.Wrap(err, "failed to flag delivery dispute", TrackingFields(tracking)...)
+	}
+	s.audit(ctx, tracking, AuditActionDeliveryDisputed, "")
+	return nil
+}
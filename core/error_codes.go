@@ -0,0 +1,55 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+)
+
+var ErrInvalidTrackingNumber = errors.New("invalid tracking number")
+
+// ErrorCode is a category of tracking errors, telling clients what went wrong without them inspecting errors
+type ErrorCode string
+
+const (
+	ErrorCodeNotFound      ErrorCode = "not_found"
+	ErrorCodeUpstreamDown  ErrorCode = "upstream_down"
+	ErrorCodeRateLimited   ErrorCode = "rate_limited"
+	ErrorCodeInvalidNumber ErrorCode = "invalid_number"
+	ErrorCodeInternal      ErrorCode = "internal"
+)
+
+// ErrorCodeOf categorizes the error, nil error has no code
+func ErrorCodeOf(err error) ErrorCode {
+	if err == nil {
+		return ""
+	}
+	if errors.Is(err, ErrNoTrackingInfo) {
+		return ErrorCodeNotFound
+	}
+	if errors.Is(err, ErrInvalidTrackingNumber) {
+		return ErrorCodeInvalidNumber
+	}
+	if errors.Is(err, ErrCircuitOpen) || errors.Is(err, context.DeadlineExceeded) {
+		return ErrorCodeUpstreamDown
+	}
+
+	var upstreamErr *UpstreamError
+	if errors.As(err, &upstreamErr) {
+		switch {
+		case upstreamErr.StatusCode == http.StatusTooManyRequests:
+			return ErrorCodeRateLimited
+		case upstreamErr.StatusCode >= 500:
+			return ErrorCodeUpstreamDown
+		}
+		return ErrorCodeInternal
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return ErrorCodeUpstreamDown
+	}
+
+	return ErrorCodeInternal
+}
@@ -0,0 +1,159 @@
+package core
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/hori-ryota/zaperr"
+	"go.uber.org/zap"
+)
+
+const (
+	// etaMinSamples is how many delivered parcels we need to have seen before estimating anything
+	etaMinSamples = 5
+	// etaMaxSamples limits how many of the latest samples are used, so that estimates follow changes in transit times
+	etaMaxSamples = 1000
+)
+
+// ETA is a predicted delivery window
+type ETA struct {
+	From time.Time
+	To   time.Time
+	// SamplesCount is the number of delivered parcels the estimate is based on
+	SamplesCount int
+}
+
+// TransitSample is how long it took a delivered parcel to get from some stage to delivery.
+// Samples are anonymous: they are aggregated across all users and know nothing of the tracking they came from
+type TransitSample struct {
+	// Route is what we know of the way the parcel went, see routeOf
+	Route    string
+	Stage    Status
+	Duration time.Duration
+}
+
+// ETAStorage persists transit samples
+type ETAStorage interface {
+	SaveTransitSamples(ctx context.Context, samples []*TransitSample) error
+	// ListTransitDurations returns up to limit latest durations for the stage on the route, empty route means any route
+	ListTransitDurations(ctx context.Context, route string, stage Status, limit int) ([]time.Duration, error)
+	// ListRouteTransitTimes averages durations for the stage on every route with at least minSamples samples
+	ListRouteTransitTimes(ctx context.Context, stage Status, minSamples int) ([]*RouteTransitTime, error)
+}
+
+// RouteTransitTime is the average time it took parcels on a route (see routeOf) to get from some stage to delivery
+type RouteTransitTime struct {
+	Route        string
+	SamplesCount int
+	Average      time.Duration
+}
+
+func NewETAEstimator(storage ETAStorage, logger *zap.Logger) *ETAEstimator {
+	return &ETAEstimator{storage: storage, logger: logger}
+}
+
+// ETAEstimator predicts delivery windows from transit times of parcels delivered before.
+// The window is the interquartile range of the times it took parcels on the same route
+// to get from the current stage to delivery, falling back to parcels on any route
+// when there aren't enough samples for the route
+type ETAEstimator struct {
+	storage ETAStorage
+	logger  *zap.Logger
+}
+
+// Estimate returns nil ETA if the tracking is already delivered, its stage is unknown
+// or there is not enough data to make a prediction
+func (e *ETAEstimator) Estimate(ctx context.Context, tracking *Tracking) (*ETA, error) {
+	stage := tracking.Status()
+	if stage == StatusUnknown || stage.IsTerminal() {
+		return nil, nil
+	}
+	reachedAt, ok := stagesReachedAt(tracking)[stage]
+	if !ok {
+		return nil, nil
+	}
+
+	for _, route := range []string{routeOf(tracking.TrackingNumber), ""} {
+		durations, err := e.storage.ListTransitDurations(ctx, route, stage, etaMaxSamples)
+		if err != nil {
+			return nil, zaperr.Wrap(err, "failed to list transit durations", zap.String("route", route), zap.String("stage", string(stage)))
+		}
+		if len(durations) < etaMinSamples {
+			continue
+		}
+		sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+		return &ETA{
+			From:         reachedAt.Add(percentile(durations, 0.25)),
+			To:           reachedAt.Add(percentile(durations, 0.75)),
+			SamplesCount: len(durations),
+		}, nil
+	}
+
+	return nil, nil
+}
+
+// RecordDelivery saves how long it took the tracking to get from each of the stages it went through to delivery.
+// It should be called once, when the tracking becomes delivered
+func (e *ETAEstimator) RecordDelivery(ctx context.Context, tracking *Tracking) error {
+	reachedAt := stagesReachedAt(tracking)
+	deliveredAt, ok := reachedAt[StatusDelivered]
+	if !ok {
+		return nil
+	}
+
+	route := routeOf(tracking.TrackingNumber)
+	var samples []*TransitSample
+	for stage, t := range reachedAt {
+		if stage.IsTerminal() || stage == StatusUnknown || t.After(deliveredAt) {
+			continue
+		}
+		samples = append(samples, &TransitSample{Route: route, Stage: stage, Duration: deliveredAt.Sub(t)})
+	}
+	if len(samples) == 0 {
+		return nil
+	}
+
+	e.logger.Debug("recording transit samples", TrackingIDField(tracking.ID), zap.Int("samples_count", len(samples)))
+	return e.storage.SaveTransitSamples(ctx, samples)
+}
+
+// stagesReachedAt finds when the tracking first reached each of the stages, according to any of the APIs.
+// Events with unparsable time are ignored
+func stagesReachedAt(tracking *Tracking) map[Status]time.Time {
+	result := make(map[Status]time.Time)
+	for _, info := range tracking.TrackingInfos {
+		for _, e := range info.Events {
+			status := NormalizeStatus(info.ApiName, e.Status)
+			if status == StatusUnknown {
+				continue
+			}
+			t, ok := ParseEventTime(e.Time)
+			if !ok {
+				continue
+			}
+			if existing, ok := result[status]; !ok || t.Before(existing) {
+				result[status] = t
+			}
+		}
+	}
+	return result
+}
+
+// routeOf describes the way a parcel goes as precisely as we can tell from its tracking number:
+// the carrier and, for postal operators, the origin country
+func routeOf(trackingNumber string) string {
+	carrier := DetectCarrier(trackingNumber)
+	if carrier == nil {
+		return "unknown"
+	}
+	if carrier.Country != "" {
+		return carrier.Code + ":" + carrier.Country
+	}
+	return carrier.Code
+}
+
+// percentile expects durations to be sorted
+func percentile(durations []time.Duration, p float64) time.Duration {
+	return durations[int(p*float64(len(durations)-1))]
+}
@@ -0,0 +1,37 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"github.com/dir01/parcels/parcels_api"
+)
+
+// EventHash returns a stable fingerprint of an event's content.
+// Providers report the location as part of the description, so it's covered as well.
+// Unlike comparing events by position or by status and time only,
+// this survives events being reordered, and tells apart events that share time and status
+func EventHash(e parcels_api.TrackingEvent) string {
+	h := sha256.New()
+	for _, part := range []string{e.Time, e.Status, e.Description} {
+		h.Write([]byte(strings.TrimSpace(part)))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
+
+// seenEventHashes returns the set of hashes of all events of the tracking we have notified about.
+// Trackings stored before hashes were introduced don't have them, so they are derived from stored events
+func seenEventHashes(tracking *Tracking) map[string]bool {
+	seen := make(map[string]bool, len(tracking.SeenEventHashes))
+	for _, h := range tracking.SeenEventHashes {
+		seen[h] = true
+	}
+	for _, info := range tracking.TrackingInfos {
+		for _, e := range info.Events {
+			seen[EventHash(e)] = true
+		}
+	}
+	return seen
+}
@@ -0,0 +1,71 @@
+package core
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dir01/parcels/parcels_api"
+)
+
+// eventKey identifies an event regardless of the source reporting it. Sources format times their own way
+// and report their own statuses, so unlike EventHash it is the parsed time and the description with case
+// and spacing ignored. Events whose times can't be parsed are told apart by EventHash
+func eventKey(e parcels_api.TrackingEvent) string {
+	t, ok := ParseEventTime(e.Time)
+	if !ok {
+		return EventHash(e)
+	}
+	description := strings.ToLower(strings.Join(strings.Fields(e.Description), " "))
+	return strconv.FormatInt(t.Unix(), 10) + "|" + description
+}
+
+// MergeEvents merges events of the tracking infos into one timeline, oldest first. An event reported by several
+// sources is only kept as the first of them reports it. Events whose times can't be parsed stay right after
+// the event preceding them in their source
+func MergeEvents(infos []*parcels_api.TrackingInfo) []parcels_api.TrackingEvent {
+	type timedEvent struct {
+		event parcels_api.TrackingEvent
+		at    time.Time
+	}
+	var timed []timedEvent
+	seen := make(map[string]bool)
+	for _, info := range infos {
+		var at time.Time
+		for _, e := range info.Events {
+			if t, ok := ParseEventTime(e.Time); ok {
+				at = t
+			}
+			key := eventKey(e)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			timed = append(timed, timedEvent{event: e, at: at})
+		}
+	}
+	sort.SliceStable(timed, func(i, j int) bool {
+		return timed[i].at.Before(timed[j].at)
+	})
+
+	events := make([]parcels_api.TrackingEvent, len(timed))
+	for i, t := range timed {
+		events[i] = t.event
+	}
+	return events
+}
+
+// Events are the events of the update's new tracking infos and its new events merged into one timeline,
+// see MergeEvents
+func (u *TrackingUpdate) Events() []parcels_api.TrackingEvent {
+	infos := append([]*parcels_api.TrackingInfo(nil), u.NewTrackingInfos...)
+	if len(u.NewTrackingEvents) > 0 {
+		newEvents := &parcels_api.TrackingInfo{}
+		for _, e := range u.NewTrackingEvents {
+			newEvents.Events = append(newEvents.Events, *e)
+		}
+		infos = append(infos, newEvents)
+	}
+	return MergeEvents(infos)
+}
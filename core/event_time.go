@@ -0,0 +1,40 @@
+package core
+
+import (
+	"strings"
+	"time"
+)
+
+// eventTimeLayouts are the formats of event times reported by the APIs we know of, most common first.
+// Times without a zone are taken as UTC, which is what the parcels service reports them in
+var eventTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006/01/02 15:04:05",
+	"2006/01/02 15:04",
+	"02.01.2006 15:04:05",
+	"02.01.2006 15:04",
+	time.RFC1123Z,
+	time.RFC1123,
+	"2006-01-02",
+	"02.01.2006",
+}
+
+// ParseEventTime parses event times in formats of the APIs we know of and normalizes them to UTC,
+// ok is false for anything else
+func ParseEventTime(s string) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, false
+	}
+	for _, layout := range eventTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC(), true
+		}
+	}
+	return time.Time{}, false
+}
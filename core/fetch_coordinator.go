@@ -0,0 +1,187 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/dir01/parcels/parcels_api"
+	"golang.org/x/sync/singleflight"
+)
+
+// sharedFetchTimeout limits upstream fetches shared by several callers, since they outlive the callers' contexts
+const sharedFetchTimeout = 2 * time.Minute
+
+func NewFetchCoordinator(provider TrackingInfoProvider, resultTTL time.Duration) *FetchCoordinator {
+	fc := &FetchCoordinator{
+		provider:  provider,
+		resultTTL: resultTTL,
+		results:   make(map[string]fetchResult),
+		now:       time.Now,
+	}
+	var _ BatchTrackingInfoProvider = fc
+	var _ PushSubscriber = fc
+	return fc
+}
+
+// FetchCoordinator makes sure that the same tracking number is fetched from upstream only once,
+// no matter how many users track it: concurrent calls share a single upstream request,
+// and results are shared with calls made within resultTTL after it
+// (e.g. by other users' trackings of the same number polled in the same cycle).
+// Every user's tracking is still diffed and updated separately.
+// A shared fetch doesn't depend on any of its callers: a caller giving up doesn't fail the fetch for the others.
+// Fetches bypassing caches (see WithSkipCache) never get results of the ones that don't, but results of fetches
+// bypassing caches are shared with all calls made within resultTTL, so that users refreshing a parcel over and over
+// (or several users refreshing the same one) don't hit upstream every time
+type FetchCoordinator struct {
+	provider  TrackingInfoProvider
+	resultTTL time.Duration
+	group     singleflight.Group
+	now       func() time.Time
+
+	mu      sync.Mutex
+	results map[string]fetchResult
+}
+
+type fetchResult struct {
+	trackingInfos []*parcels_api.TrackingInfo
+	err           error
+	fetchedAt     time.Time
+	// skippedCache tells that upstream was asked to bypass its caches, so the result is as fresh as it gets
+	skippedCache bool
+}
+
+func (fc *FetchCoordinator) GetTrackingInfo(ctx context.Context, trackingNumber string, carrier *Carrier, params TrackingParams) ([]*parcels_api.TrackingInfo, error) {
+	key := trackingNumber
+	if carrier != nil {
+		key = carrier.Code + ":" + trackingNumber
+	}
+	// what a carrier tells given a postal code may differ from what it tells without one, and providers querying
+	// only the carrier the user has told may tell less than the ones guessing
+	if !params.IsZero() {
+		key += "|" + params.PostalCode + "|" + params.Carrier
+	}
+
+	skipCache := shouldSkipCache(ctx)
+	if r, ok := fc.cachedResult(key, skipCache); ok {
+		return r.trackingInfos, r.err
+	}
+
+	// a forced fetch must not get the result of a concurrent regular one, which may be served from upstream caches
+	flightKey := key
+	if skipCache {
+		flightKey = "skip-cache:" + key
+	}
+	// conditional fetches are only shared by callers having the same version of tracking infos,
+	// and each of them gets its own copy of validators of the result
+	callerValidators := validatorsOf(ctx)
+	var requestValidators *Validators
+	if callerValidators != nil {
+		validators := *callerValidators
+		requestValidators = &validators
+		flightKey += "|" + validators.ETag + "|" + validators.LastModified
+	}
+	results := fc.group.DoChan(flightKey, func() (interface{}, error) {
+		fetchCtx, cancel := context.WithTimeout(WithValidators(detach(ctx), requestValidators), sharedFetchTimeout)
+		defer cancel()
+		trackingInfos, err := fc.provider.GetTrackingInfo(fetchCtx, trackingNumber, carrier, params)
+		// transient errors are not shared beyond this flight, so that the next caller gets a chance to retry,
+		// and neither is ErrNotModified, which only means something to callers having the same version,
+		// nor are partial results, which failed providers may complete the next time
+		if err == nil || errors.Is(err, ErrNoTrackingInfo) {
+			fc.storeResult(key, fetchResult{trackingInfos: trackingInfos, err: err, fetchedAt: fc.now(), skippedCache: skipCache})
+		}
+		return sharedFetch{trackingInfos: trackingInfos, validators: requestValidators}, err
+	})
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case r := <-results:
+		var partialErr *PartialFetchError
+		if r.Err != nil && !errors.As(r.Err, &partialErr) {
+			return nil, r.Err
+		}
+		fetch := r.Val.(sharedFetch)
+		if callerValidators != nil && fetch.validators != nil {
+			*callerValidators = *fetch.validators
+		}
+		return fetch.trackingInfos, r.Err
+	}
+}
+
+// sharedFetch is the result of a fetch shared by callers, validators are nil for unconditional fetches
+type sharedFetch struct {
+	trackingInfos []*parcels_api.TrackingInfo
+	validators    *Validators
+}
+
+// GetTrackingInfoBatch fetches the batch with the provider if it supports batches, results are shared
+// with calls made within resultTTL just like results of GetTrackingInfo
+func (fc *FetchCoordinator) GetTrackingInfoBatch(ctx context.Context, trackingNumbers []string) (map[string][]*parcels_api.TrackingInfo, error) {
+	provider, ok := fc.provider.(BatchTrackingInfoProvider)
+	if !ok {
+		return nil, ErrBatchNotSupported
+	}
+	results, err := provider.GetTrackingInfoBatch(ctx, trackingNumbers)
+	if err != nil {
+		return nil, err
+	}
+	skipCache := shouldSkipCache(ctx)
+	for _, trackingNumber := range trackingNumbers {
+		key := trackingNumber
+		if carrier := DetectCarrier(trackingNumber); carrier != nil {
+			key = carrier.Code + ":" + trackingNumber
+		}
+		r := fetchResult{trackingInfos: results[trackingNumber], fetchedAt: fc.now(), skippedCache: skipCache}
+		if len(r.trackingInfos) == 0 {
+			r.err = ErrNoTrackingInfo
+		}
+		fc.storeResult(key, r)
+	}
+	return results, nil
+}
+
+// cachedResult is the result of a fetch made within resultTTL, skipCache limits it to fetches that bypassed caches
+func (fc *FetchCoordinator) cachedResult(key string, skipCache bool) (fetchResult, bool) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	r, ok := fc.results[key]
+	if !ok || fc.now().Sub(r.fetchedAt) > fc.resultTTL || (skipCache && !r.skippedCache) {
+		return fetchResult{}, false
+	}
+	return r, true
+}
+
+func (fc *FetchCoordinator) storeResult(key string, r fetchResult) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	// a result possibly served from upstream caches mustn't replace a fresher one
+	existing, ok := fc.results[key]
+	if !ok || r.skippedCache || !existing.skippedCache || fc.now().Sub(existing.fetchedAt) > fc.resultTTL {
+		fc.results[key] = r
+	}
+	// results are short-lived, so cleaning up on every write keeps the map small without a separate janitor
+	for k, existing := range fc.results {
+		if fc.now().Sub(existing.fetchedAt) > fc.resultTTL {
+			delete(fc.results, k)
+		}
+	}
+}
+
+// detachedContext keeps values of the parent context, but is never cancelled along with it
+type detachedContext struct {
+	parent context.Context
+}
+
+func detach(ctx context.Context) context.Context {
+	return detachedContext{parent: ctx}
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}       { return nil }
+func (detachedContext) Err() error                  { return nil }
+
+func (c detachedContext) Value(key any) any {
+	return c.parent.Value(key)
+}
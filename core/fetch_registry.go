@@ -0,0 +1,74 @@
+package core
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// fetchRegistry keeps track of refreshes in flight, so that deleting a tracking can cancel them.
+// Otherwise a refresh that has started before the deletion would save the tracking after it
+type fetchRegistry struct {
+	mu sync.Mutex
+	// fetches are keyed by tracking ID, a tracking can be refreshed by polling and by its user at the same time
+	fetches map[int64]map[*inFlightFetch]struct{}
+}
+
+// inFlightFetch is a refresh of a tracking, its context is cancelled once the tracking is deleted
+type inFlightFetch struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	userID  int64
+	deleted atomic.Bool
+}
+
+func newFetchRegistry() *fetchRegistry {
+	return &fetchRegistry{fetches: make(map[int64]map[*inFlightFetch]struct{})}
+}
+
+// start registers a refresh of the tracking, finish must be called once it is over
+func (r *fetchRegistry) start(ctx context.Context, tracking *Tracking) *inFlightFetch {
+	f := &inFlightFetch{userID: tracking.UserID}
+	f.ctx, f.cancel = context.WithCancel(ctx)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.fetches[tracking.ID] == nil {
+		r.fetches[tracking.ID] = make(map[*inFlightFetch]struct{})
+	}
+	r.fetches[tracking.ID][f] = struct{}{}
+	return f
+}
+
+func (r *fetchRegistry) finish(trackingID int64, f *inFlightFetch) {
+	f.cancel()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.fetches[trackingID], f)
+	if len(r.fetches[trackingID]) == 0 {
+		delete(r.fetches, trackingID)
+	}
+}
+
+// cancelTracking cancels refreshes of the tracking in flight, marking them deleted
+func (r *fetchRegistry) cancelTracking(trackingID int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for f := range r.fetches[trackingID] {
+		f.deleted.Store(true)
+		f.cancel()
+	}
+}
+
+// cancelUser cancels refreshes of all trackings of the user in flight, marking them deleted
+func (r *fetchRegistry) cancelUser(userID int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, fetches := range r.fetches {
+		for f := range fetches {
+			if f.userID == userID {
+				f.deleted.Store(true)
+				f.cancel()
+			}
+		}
+	}
+}
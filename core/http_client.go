@@ -0,0 +1,44 @@
+package core
+
+import (
+	"net"
+	"net/http"
+	"time"
+)
+
+// HTTPTimeouts limit how long a single call to an upstream API may take,
+// so that a hung upstream can't stall polling
+type HTTPTimeouts struct {
+	// Connect limits establishing a connection, including TLS handshake
+	Connect time.Duration
+	// Read limits waiting for the response and reading it
+	Read time.Duration
+}
+
+func DefaultHTTPTimeouts() HTTPTimeouts {
+	return HTTPTimeouts{
+		Connect: 5 * time.Second,
+		Read:    30 * time.Second,
+	}
+}
+
+// NewHTTPClient creates a client enforcing the timeouts on every request.
+// Context deadlines of requests are honored as well, whichever comes first.
+// Requests are observed by metrics unless they are nil
+func NewHTTPClient(timeouts HTTPTimeouts, metrics *Metrics) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{
+		Timeout:   timeouts.Connect,
+		KeepAlive: 30 * time.Second,
+	}).DialContext
+	transport.TLSHandshakeTimeout = timeouts.Connect
+	transport.ResponseHeaderTimeout = timeouts.Read
+	var roundTripper http.RoundTripper = transport
+	if metrics != nil {
+		roundTripper = &instrumentedTransport{next: transport, metrics: metrics}
+	}
+	return &http.Client{
+		Transport: roundTripper,
+		Timeout:   timeouts.Connect + timeouts.Read,
+	}
+}
@@ -0,0 +1,45 @@
+package core
+
+import (
+	"go.uber.org/zap"
+)
+
+// Fields identifying users and parcels are named the same in logs of all packages,
+// so that everything about a user or a parcel can be found with a single query in a log aggregation system
+
+func UserIDField(userID int64) zap.Field {
+	return zap.Int64("user_id", userID)
+}
+
+func ChatIDField(chatID int64) zap.Field {
+	return zap.Int64("chat_id", chatID)
+}
+
+func TrackingNumberField(trackingNumber string) zap.Field {
+	return zap.String("tracking_number", trackingNumber)
+}
+
+func TrackingIDField(trackingID int64) zap.Field {
+	return zap.Int64("tracking_id", trackingID)
+}
+
+// TrackingFields identify the tracking without logging personal data like its display name
+func TrackingFields(tracking *Tracking) []zap.Field {
+	return []zap.Field{
+		TrackingIDField(tracking.ID),
+		UserIDField(tracking.UserID),
+		TrackingNumberField(tracking.TrackingNumber),
+	}
+}
+
+// UpdateFields identify the tracking update without logging personal data like its display name
+func UpdateFields(update *TrackingUpdate) []zap.Field {
+	fields := []zap.Field{
+		UserIDField(update.UserID),
+		TrackingNumberField(update.TrackingNumber),
+	}
+	if update.NotificationID != 0 {
+		fields = append(fields, zap.Int64("notification_id", update.NotificationID))
+	}
+	return fields
+}
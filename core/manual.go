@@ -0,0 +1,175 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/hori-ryota/zaperr"
+	"go.uber.org/zap"
+)
+
+const (
+	// manualNumberPrefix starts numbers of manual parcels, a colon is never a part of a real tracking number
+	// (see ValidateTrackingNumber), so manual numbers can't clash with real ones
+	manualNumberPrefix = "M:"
+	// manualNumberAlphabet leaves out letters and digits easily mistaken for one another
+	manualNumberAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	manualNumberLength   = 6
+	// manualNumberAttempts is how many times a new manual number is generated if the user already has the previous one
+	manualNumberAttempts = 5
+	// reminderScanInterval is how often manual parcels are checked for being expected by now, expected dates are days
+	reminderScanInterval = time.Hour
+)
+
+// ErrManualTracking is returned for operations that need a real tracking number, e.g. ForceRefresh of a manual parcel
+var ErrManualTracking = errors.New("tracking is manual")
+
+// ErrNotManual is returned by AttachTrackingNumber for trackings that already have a real tracking number
+var ErrNotManual = errors.New("tracking is not manual")
+
+// ManualTrackingStorage persists what is specific to manual parcels, see TrackManual
+type ManualTrackingStorage interface {
+	// AttachTrackingNumber turns the manual tracking into an ordinary one with the tracking number, due for poll at
+	// nextPollAt. A deleted tracking of the same user with the number is purged for good, since it is replaced
+	AttachTrackingNumber(ctx context.Context, trackingID int64, trackingNumber string, nextPollAt time.Time) error
+	SetRemindedAt(ctx context.Context, trackingID int64, remindedAt time.Time) error
+}
+
+// IsManualTrackingNumber tells whether the number was generated for a manual parcel by TrackManual
+func IsManualTrackingNumber(trackingNumber string) bool {
+	return strings.HasPrefix(trackingNumber, manualNumberPrefix)
+}
+
+func newManualTrackingNumber() string {
+	b := make([]byte, manualNumberLength)
+	for i := range b {
+		b[i] = manualNumberAlphabet[rand.Intn(len(manualNumberAlphabet))]
+	}
+	return manualNumberPrefix + string(b)
+}
+
+// TrackManual adds a parcel without a tracking number, e.g. one brought by a local courier. Such parcels are never
+// polled, but are listed along with the rest, can have notes and tags, and the user is reminded of them once
+// expectedAt comes, unless it is nil. The parcel gets a generated number (see IsManualTrackingNumber) to refer to it by,
+// until a real one is attached with AttachTrackingNumber
+func (s *ServiceImpl) TrackManual(ctx context.Context, userID int64, displayName string, expectedAt *time.Time) (*Tracking, error) {
+	zapFields := []zap.Field{UserIDField(userID)}
+	s.logger.Info("got manual track command", zapFields...)
+
+	if err := s.checkTrackingQuota(ctx, userID, ""); err != nil {
+		return nil, err
+	}
+	for i := 0; i < manualNumberAttempts; i++ {
+		tracking := &Tracking{
+			UserID:         userID,
+			TrackingNumber: newManualTrackingNumber(),
+			DisplayName:    displayName,
+			Manual:         true,
+			ExpectedAt:     expectedAt,
+		}
+		saved, created, err := s.storage.SaveTracking(ctx, tracking)
+		if err != nil {
+			return nil, zaperr.Wrap(err, "failed to add manual tracking", zapFields...)
+		}
+		if !created {
+			continue
+		}
+		s.logger.Info("manual tracking added", TrackingFields(saved)...)
+		s.audit(ctx, saved, AuditActionCreated, "manual")
+		return saved, nil
+	}
+	return nil, zaperr.Wrap(errors.New("failed to generate a unique manual tracking number"), "failed to add manual tracking", zapFields...)
+}
+
+// AttachTrackingNumber gives the manual parcel a real tracking number, after that the parcel is tracked just like
+// the ones added with Track, its name, note and tags kept. It returns ErrNotManual if the parcel already has a number,
+// ErrTrackingExists if the user already tracks the number, and ErrInvalidTrackingNumber if it can't be a tracking number.
+// Numbers of carriers needing a postal code can't be attached, ErrPostalCodeRequired is returned for them
+func (s *ServiceImpl) AttachTrackingNumber(ctx context.Context, userID int64, manualNumber string, trackingNumber string) error {
+	zapFields := []zap.Field{UserIDField(userID), TrackingNumberField(trackingNumber), zap.String("manual_number", manualNumber)}
+	s.logger.Info("got attach tracking number command", zapFields...)
+
+	if err := ValidateTrackingNumber(trackingNumber); errors.Is(err, ErrInvalidTrackingNumber) {
+		return err
+	}
+	if carrier := DetectCarrier(trackingNumber); carrier != nil && carrier.NeedsPostalCode {
+		return ErrPostalCodeRequired
+	}
+	tracking, err := s.storage.GetTracking(ctx, userID, manualNumber)
+	if err != nil {
+		return err
+	}
+	if !tracking.Manual {
+		return ErrNotManual
+	}
+	_, err = s.storage.GetTracking(ctx, userID, trackingNumber)
+	if err == nil {
+		return ErrTrackingExists
+	}
+	if !errors.Is(err, ErrTrackingNotFound) {
+		return zaperr.Wrap(err, "failed to get tracking", zapFields...)
+	}
+
+	// the tracking is fetched right below, so polling must not pick it up at the same time
+	nextPollAt := s.nextPollAt(s.clock.Now(), &Tracking{})
+	if err := s.storage.AttachTrackingNumber(ctx, tracking.ID, trackingNumber, nextPollAt); err != nil {
+		return zaperr.Wrap(err, "failed to attach tracking number", zapFields...)
+	}
+	tracking.TrackingNumber, tracking.Manual, tracking.NextPollAt = trackingNumber, false, &nextPollAt
+	s.logger.Info("tracking number attached", TrackingFields(tracking)...)
+	s.audit(ctx, tracking, AuditActionNumberAttached, fmt.Sprintf("%s -> %s", manualNumber, trackingNumber))
+
+	s.background.Add(1)
+	go func() {
+		defer s.background.Done()
+		s.fetchTrackingInfo(s.fetchCtx, tracking, true)
+		s.subscribeToPushUpdates(s.fetchCtx, tracking)
+	}()
+	return nil
+}
+
+// scanManualReminders reminds users of their manual parcels that are expected by now. Every parcel is reminded of
+// once, reminders aren't persisted just like stuck alerts (see scanStuckTrackings)
+func (s *ServiceImpl) scanManualReminders(ctx context.Context) {
+	now := s.clock.Now()
+	reminded := 0
+	var afterID int64
+	for {
+		trackings, err := s.storage.ListTrackings(ctx, afterID, pollBatchSize)
+		if err != nil {
+			s.logger.Error("failed to list trackings", zaperr.ToField(err))
+			return
+		}
+		for _, tracking := range trackings {
+			if ctx.Err() != nil {
+				return
+			}
+			if !tracking.Manual || tracking.ExpectedAt == nil || tracking.RemindedAt != nil || tracking.ExpectedAt.After(now) {
+				continue
+			}
+			if err := s.storage.SetRemindedAt(ctx, tracking.ID, now); err != nil {
+				s.logger.Error("failed to save reminder", append(TrackingFields(tracking), zaperr.ToField(err))...)
+				continue
+			}
+			expectedAt := *tracking.ExpectedAt
+			s.publishTrackingUpdate(ctx, TrackingUpdate{
+				TrackingNumber: tracking.TrackingNumber,
+				UserID:         tracking.UserID,
+				DisplayName:    tracking.DisplayName,
+				ExpectedAt:     &expectedAt,
+			})
+			reminded++
+		}
+		if len(trackings) < pollBatchSize {
+			break
+		}
+		afterID = trackings[len(trackings)-1].ID
+	}
+	if reminded > 0 {
+		s.logger.Info("reminded of manual trackings", zap.Int("trackings_count", reminded))
+	}
+}
@@ -0,0 +1,37 @@
+package core
+
+import "sort"
+
+// MergeTrackingUpdates combines updates of the same tracking, in the order they were made, into one update
+// telling about all of them at once. It is meant for updates with new events, not for errors or stuck alerts.
+// NotificationID of the result is 0: each merged update still has to be marked delivered on its own
+func MergeTrackingUpdates(updates []TrackingUpdate) TrackingUpdate {
+	if len(updates) == 1 {
+		return updates[0]
+	}
+	merged := TrackingUpdate{}
+	seenStatuses := make(map[Status]bool)
+	for _, u := range updates {
+		merged.TrackingNumber = u.TrackingNumber
+		merged.UserID = u.UserID
+		merged.DisplayName = u.DisplayName
+		merged.NewTrackingInfos = append(merged.NewTrackingInfos, u.NewTrackingInfos...)
+		merged.NewTrackingEvents = append(merged.NewTrackingEvents, u.NewTrackingEvents...)
+		if u.ETA != nil {
+			merged.ETA = u.ETA
+		}
+		// only the latest fetch tells whether the providers are still failing
+		merged.FailedProviders = u.FailedProviders
+		for _, status := range u.Statuses {
+			if !seenStatuses[status] {
+				seenStatuses[status] = true
+				merged.Statuses = append(merged.Statuses, status)
+			}
+		}
+	}
+	sort.SliceStable(merged.Statuses, func(i, j int) bool {
+		return statusProgress[merged.Statuses[i]] < statusProgress[merged.Statuses[j]]
+	})
+	merged.Milestones = milestonesOf(merged.Statuses)
+	return merged
+}
@@ -0,0 +1,92 @@
+package core
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dir01/tg-parcels/metrics"
+)
+
+// NewMetrics registers metrics of the service and of upstream APIs calls
+func NewMetrics(registry *metrics.Registry) *Metrics {
+	return &Metrics{
+		pollCycles: registry.NewCounterVec(
+			"tg_parcels_poll_cycles_total", "Poll cycles by result.", "result",
+		),
+		pollDuration: registry.NewHistogramVec(
+			"tg_parcels_poll_duration_seconds", "Duration of poll cycles.", metrics.DurationBuckets,
+		),
+		polledTrackings: registry.NewCounterVec(
+			"tg_parcels_polled_trackings_total", "Trackings polled.",
+		),
+		upstreamRequests: registry.NewCounterVec(
+			"tg_parcels_upstream_requests_total", "Requests to upstream APIs by host and status code, code is \"error\" if there was no response.", "host", "code",
+		),
+		upstreamDuration: registry.NewHistogramVec(
+			"tg_parcels_upstream_request_duration_seconds", "Latency of upstream APIs by host.", metrics.DurationBuckets, "host",
+		),
+		updatesEmitted: registry.NewCounterVec(
+			"tg_parcels_updates_emitted_total", "Tracking updates emitted to subscribers by kind.", "kind",
+		),
+	}
+}
+
+// Metrics are counters and histograms of the service, nil Metrics is valid and records nothing
+type Metrics struct {
+	pollCycles       *metrics.CounterVec
+	pollDuration     *metrics.HistogramVec
+	polledTrackings  *metrics.CounterVec
+	upstreamRequests *metrics.CounterVec
+	upstreamDuration *metrics.HistogramVec
+	updatesEmitted   *metrics.CounterVec
+}
+
+func (m *Metrics) observePoll(startedAt time.Time, polled int, err error) {
+	if m == nil {
+		return
+	}
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	m.pollCycles.Inc(result)
+	m.pollDuration.ObserveSince(startedAt)
+	m.polledTrackings.Add(float64(polled))
+}
+
+func (m *Metrics) observeUpdateEmitted(update TrackingUpdate) {
+	if m == nil {
+		return
+	}
+	kind := "tracking_info"
+	if update.TrackingError != nil {
+		kind = "error"
+	}
+	if update.StuckSince != nil {
+		kind = "stuck"
+	}
+	if update.ExpectedAt != nil {
+		kind = "reminder"
+	}
+	m.updatesEmitted.Inc(kind)
+}
+
+// instrumentedTransport counts and times requests to upstream APIs,
+// every attempt is observed separately, retries included
+type instrumentedTransport struct {
+	next    http.RoundTripper
+	metrics *Metrics
+}
+
+func (t *instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	startedAt := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(resp.StatusCode)
+	}
+	t.metrics.upstreamRequests.Inc(req.URL.Host, code)
+	t.metrics.upstreamDuration.ObserveSince(startedAt, req.URL.Host)
+	return resp, err
+}
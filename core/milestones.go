@@ -0,0 +1,66 @@
+package core
+
+import (
+	"regexp"
+	"sort"
+
+	"github.com/dir01/parcels/parcels_api"
+)
+
+// milestoneStatuses are the statuses users are told about specially when their parcels reach them,
+// in the order of progress
+var milestoneStatuses = []Status{
+	// customs usually means the user may need to act: provide documents or pay fees
+	StatusCustoms,
+	// cross-border parcels are mostly waited for at this point, the rest of the way is the local carrier's
+	StatusArrivedDestination,
+}
+
+// arrivedDestinationRe recognizes arrival events by their descriptions, for events with raw statuses we can't map
+var arrivedDestinationRe = regexp.MustCompile(`(?i)arriv\w*\s+(in|at|to)\s+(the\s+)?(destination|receiving)\s+(country|region)`)
+
+// newStatuses lists canonical statuses the new tracking infos have events of, while the old ones didn't,
+// in the order of progress. Events are looked at regardless of their order, so statuses passed within a single update
+// are reported too
+func newStatuses(old []*parcels_api.TrackingInfo, new []*parcels_api.TrackingInfo) []Status {
+	had, has := reachedStatuses(old), reachedStatuses(new)
+	var result []Status
+	for status := range has {
+		if !had[status] && status != StatusUnknown {
+			result = append(result, status)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return statusProgress[result[i]] < statusProgress[result[j]] })
+	return result
+}
+
+// milestonesOf picks milestone statuses out of the statuses
+func milestonesOf(statuses []Status) []Status {
+	var milestones []Status
+	for _, milestone := range milestoneStatuses {
+		for _, status := range statuses {
+			if status == milestone {
+				milestones = append(milestones, milestone)
+			}
+		}
+	}
+	return milestones
+}
+
+// reachedStatuses collects statuses of all events of the infos, infos flagged as delivered count as delivered
+func reachedStatuses(infos []*parcels_api.TrackingInfo) map[Status]bool {
+	result := make(map[Status]bool)
+	for _, info := range infos {
+		if info.IsDelivered {
+			result[StatusDelivered] = true
+		}
+		for _, e := range info.Events {
+			status := NormalizeStatus(info.ApiName, e.Status)
+			if status == StatusUnknown && arrivedDestinationRe.MatchString(e.Description) {
+				status = StatusArrivedDestination
+			}
+			result[status] = true
+		}
+	}
+	return result
+}
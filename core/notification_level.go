@@ -0,0 +1,137 @@
+package core
+
+import (
+	"context"
+	"errors"
+
+	"github.com/hori-ryota/zaperr"
+	"go.uber.org/zap"
+)
+
+// NotificationLevel is how much of the tracking updates the user wants to be notified of.
+// Updates the user isn't notified of are still recorded, so they show up in /info and the like
+type NotificationLevel string
+
+const (
+	NotificationLevelAll NotificationLevel = "all"
+	// NotificationLevelMilestones notifies of milestones (see milestoneStatuses), parcels being out for delivery
+	// and delivered, and of parcels being stuck
+	NotificationLevelMilestones NotificationLevel = "milestones"
+	// NotificationLevelDelivery notifies of parcels being out for delivery and delivered only
+	NotificationLevelDelivery NotificationLevel = "delivery"
+	// NotificationLevelDelivered notifies of parcels being delivered only
+	NotificationLevelDelivered NotificationLevel = "delivered"
+	NotificationLevelMuted     NotificationLevel = "muted"
+)
+
+// NotificationLevels are all the levels, from the most verbose one
+var NotificationLevels = []NotificationLevel{
+	NotificationLevelAll,
+	NotificationLevelMilestones,
+	NotificationLevelDelivery,
+	NotificationLevelDelivered,
+	NotificationLevelMuted,
+}
+
+var ErrInvalidNotificationLevel = errors.New("invalid notification level")
+
+func (l NotificationLevel) valid() bool {
+	for _, level := range NotificationLevels {
+		if l == level {
+			return true
+		}
+	}
+	return false
+}
+
+// allows tells whether the user should be notified of the update. Errors are answers to commands, so they always are.
+// Reminders of manual parcels are what the user has asked for, so only muting silences them
+func (l NotificationLevel) allows(update *TrackingUpdate) bool {
+	if update.TrackingError != nil {
+		return true
+	}
+	if update.ExpectedAt != nil {
+		return l != NotificationLevelMuted
+	}
+	switch l {
+	case NotificationLevelMilestones:
+		return len(update.Milestones) > 0 || update.StuckSince != nil ||
+			hasStatus(update, StatusOutForDelivery) || hasStatus(update, StatusDelivered)
+	case NotificationLevelDelivery:
+		return hasStatus(update, StatusOutForDelivery) || hasStatus(update, StatusDelivered)
+	case NotificationLevelDelivered:
+		return hasStatus(update, StatusDelivered)
+	case NotificationLevelMuted:
+		return false
+	}
+	return true
+}
+
+func hasStatus(update *TrackingUpdate, status Status) bool {
+	for _, s := range update.Statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *ServiceImpl) SetNotificationLevel(ctx context.Context, userID int64, level NotificationLevel) error {
+	if !level.valid() {
+		return ErrInvalidNotificationLevel
+	}
+	if err := s.storage.SetNotificationLevel(ctx, userID, level); err != nil {
+		return zaperr.Wrap(err, "failed to set notification level", UserIDField(userID))
+	}
+	return nil
+}
+
+func (s *ServiceImpl) NotificationLevel(ctx context.Context, userID int64) (NotificationLevel, error) {
+	return s.storage.GetNotificationLevel(ctx, userID)
+}
+
+func (s *ServiceImpl) SetTrackingNotificationLevel(ctx context.Context, userID int64, trackingNumber string, level NotificationLevel) error {
+	if level != "" && !level.valid() {
+		return ErrInvalidNotificationLevel
+	}
+	tracking, err := s.storage.GetTracking(ctx, userID, trackingNumber)
+	if err != nil {
+		return err
+	}
+	if err := s.storage.SetTrackingNotificationLevel(ctx, tracking.ID, level); err != nil {
+		return zaperr.Wrap(err, "failed to set notification level", TrackingFields(tracking)...)
+	}
+	return nil
+}
+
+// notificationLevel is the level of the tracking, falling back to the level of its user
+func (s *ServiceImpl) notificationLevel(ctx context.Context, update *TrackingUpdate) (NotificationLevel, error) {
+	tracking, err := s.storage.GetTracking(ctx, update.UserID, update.TrackingNumber)
+	if err != nil && !errors.Is(err, ErrTrackingNotFound) {
+		return "", err
+	}
+	if tracking != nil && tracking.NotificationLevel != "" {
+		return tracking.NotificationLevel, nil
+	}
+	return s.storage.GetNotificationLevel(ctx, update.UserID)
+}
+
+// publishTrackingUpdate publishes the update unless the user doesn't want to be notified of it,
+// in which case the update is only recorded: its pending notification is dropped right away
+func (s *ServiceImpl) publishTrackingUpdate(ctx context.Context, update TrackingUpdate) {
+	level, err := s.notificationLevel(ctx, &update)
+	if err != nil {
+		// better notify too much than miss something
+		s.logger.Error("failed to get notification level", append(UpdateFields(&update), zaperr.ToField(err))...)
+		level = NotificationLevelAll
+	}
+	if level.allows(&update) {
+		s.publishUpdate(update)
+		return
+	}
+
+	s.logger.Debug("update is below notification level", append(UpdateFields(&update), zap.String("level", string(level)))...)
+	if err := s.MarkUpdateDelivered(ctx, &update); err != nil {
+		s.logger.Error("failed to drop notification", append(UpdateFields(&update), zaperr.ToField(err))...)
+	}
+}
@@ -1,40 +1,157 @@
 package core
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"io"
 	"net/http"
+	"net/url"
+	"sync/atomic"
+	"time"
 
 	"github.com/dir01/parcels/parcels_api"
 	"github.com/hori-ryota/zaperr"
 	"go.uber.org/zap"
+	"golang.org/x/time/rate"
 )
 
 var ErrNoTrackingInfo = errors.New("not found")
 
+// batchRecheckInterval is how long ParcelsAPI takes parcels service for not having a batch endpoint
+// before trying it again, so that the endpoint is picked up without a restart once it is deployed
+const batchRecheckInterval = time.Hour
+
+func NewParcelsAPI(
+	apiURL string,
+	httpClient *http.Client,
+	retryPolicy RetryPolicy,
+	trackingInfoBreaker *CircuitBreaker,
+	rateLimiter *rate.Limiter,
+	logger *zap.Logger,
+) *ParcelsAPI {
+	api := &ParcelsAPI{
+		apiURL:              apiURL,
+		httpClient:          httpClient,
+		retryPolicy:         retryPolicy,
+		trackingInfoBreaker: trackingInfoBreaker,
+		rateLimiter:         rateLimiter,
+		logger:              logger,
+	}
+	var _ BatchTrackingInfoProvider = api
+	var _ NamedTrackingInfoProvider = api
+	return api
+}
+
 type ParcelsAPI struct {
-	apiURL string
+	apiURL              string
+	httpClient          *http.Client // limits every attempt separately, see NewHTTPClient
+	retryPolicy         RetryPolicy
+	trackingInfoBreaker *CircuitBreaker
+	rateLimiter         *rate.Limiter // shared by all callers, including retries
+	logger              *zap.Logger
+	// batchUnsupportedAt is unix nanos of when parcels service was found to have no batch endpoint, 0 if it has one
+	batchUnsupportedAt atomic.Int64
+}
+
+// Name is what users know parcels service by, see NamedTrackingInfoProvider
+func (api *ParcelsAPI) Name() string {
+	return "parcels service"
+}
+
+// GetTrackingInfo fetches tracking info from parcels service,
+// retrying transient failures according to the retry policy.
+// While the parcels service keeps failing, the circuit breaker
+// makes calls fail fast with ErrCircuitOpen instead of hammering it.
+// Parcels service figures out the carrier on its own, so the carrier guessed from the number format is not used,
+// but the carrier the user has told is (see TrackingParams), so that only that carrier is queried.
+// Fetches are conditional if the context has validators, see WithValidators
+func (api *ParcelsAPI) GetTrackingInfo(ctx context.Context, trackingNumber string, _ *Carrier, params TrackingParams) ([]*parcels_api.TrackingInfo, error) {
+	for attempt := 1; ; attempt++ {
+		var trackingInfos []*parcels_api.TrackingInfo
+		err := api.trackingInfoBreaker.Call(func() (err error) {
+			trackingInfos, err = api.getTrackingInfo(ctx, trackingNumber, params)
+			return err
+		}, isRetryable)
+		if err == nil {
+			return trackingInfos, nil
+		}
+		if attempt >= api.retryPolicy.MaxAttempts || !isRetryable(err) {
+			return nil, err
+		}
+
+		backoff := api.retryPolicy.Backoff(attempt)
+		api.logger.Warn(
+			"failed to get tracking info, retrying",
+			TrackingNumberField(trackingNumber),
+			zap.Int("attempt", attempt),
+			zap.Duration("backoff", backoff),
+			zaperr.ToField(err),
+		)
+
+		t := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return nil, err
+		case <-t.C:
+		}
+	}
 }
 
-func (api *ParcelsAPI) GetTrackingInfo(ctx context.Context, trackingNumber string) ([]*parcels_api.TrackingInfo, error) {
-	url := api.apiURL + "/trackingInfo/" + "?trackingNumber=" + trackingNumber
+func (api *ParcelsAPI) getTrackingInfo(ctx context.Context, trackingNumber string, params TrackingParams) ([]*parcels_api.TrackingInfo, error) {
+	if err := api.rateLimiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+
+	query := url.Values{"trackingNumber": {trackingNumber}}
+	if shouldSkipCache(ctx) {
+		query.Set("skipCache", "true")
+	}
+	if params.PostalCode != "" {
+		query.Set("postalCode", params.PostalCode)
+	}
+	if params.Carrier != "" {
+		query.Set("carrier", params.Carrier)
+	}
 
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", api.apiURL+"/trackingInfo/?"+query.Encode(), nil)
 	if err != nil {
 		return nil, err
 	}
+	validators := validatorsOf(ctx)
+	if validators != nil {
+		if validators.ETag != "" {
+			req.Header.Set("If-None-Match", validators.ETag)
+		}
+		if validators.LastModified != "" {
+			req.Header.Set("If-Modified-Since", validators.LastModified)
+		}
+	}
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := api.httpClient.Do(req)
 	if err != nil {
 		return nil, err
 	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && validators != nil && !validators.IsZero() {
+		return nil, ErrNotModified
+	}
 
 	if resp.StatusCode == http.StatusNotFound {
 		return nil, ErrNoTrackingInfo
 	}
 
+	if resp.StatusCode == http.StatusBadRequest {
+		return nil, ErrInvalidTrackingNumber
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &UpstreamError{StatusCode: resp.StatusCode}
+	}
+
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return nil, err
@@ -44,6 +161,75 @@ func (api *ParcelsAPI) GetTrackingInfo(ctx context.Context, trackingNumber strin
 	if err := json.Unmarshal(body, &trackingInfos); err != nil {
 		return nil, zaperr.Wrap(err, "failed to unmarshal tracking info", zap.String("body", string(body)))
 	}
+	if validators != nil {
+		*validators = Validators{ETag: resp.Header.Get("ETag"), LastModified: resp.Header.Get("Last-Modified")}
+	}
 
 	return trackingInfos, nil
 }
+
+// GetTrackingInfoBatch fetches tracking infos of many numbers with a single request to parcels service.
+// It returns ErrBatchNotSupported if parcels service has no batch endpoint, without asking it again
+// for batchRecheckInterval. Failed batches aren't retried, the numbers are better fetched one by one then
+func (api *ParcelsAPI) GetTrackingInfoBatch(ctx context.Context, trackingNumbers []string) (map[string][]*parcels_api.TrackingInfo, error) {
+	if at := api.batchUnsupportedAt.Load(); at != 0 && time.Since(time.Unix(0, at)) < batchRecheckInterval {
+		return nil, ErrBatchNotSupported
+	}
+	var results map[string][]*parcels_api.TrackingInfo
+	err := api.trackingInfoBreaker.Call(func() (err error) {
+		results, err = api.getTrackingInfoBatch(ctx, trackingNumbers)
+		return err
+	}, isRetryable)
+	if errors.Is(err, ErrBatchNotSupported) {
+		api.logger.Info("parcels service has no batch endpoint, fetching tracking numbers one by one")
+		api.batchUnsupportedAt.Store(time.Now().UnixNano())
+	}
+	return results, err
+}
+
+func (api *ParcelsAPI) getTrackingInfoBatch(ctx context.Context, trackingNumbers []string) (map[string][]*parcels_api.TrackingInfo, error) {
+	if err := api.rateLimiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+
+	url := api.apiURL + "/trackingInfo/batch"
+	if shouldSkipCache(ctx) {
+		url += "?skipCache=true"
+	}
+	reqBody, err := json.Marshal(map[string][]string{"trackingNumbers": trackingNumbers})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := api.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return nil, ErrBatchNotSupported
+	default:
+		return nil, &UpstreamError{StatusCode: resp.StatusCode}
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	// tracking infos keyed by tracking number, numbers parcels service knows nothing about are left out
+	var results map[string][]*parcels_api.TrackingInfo
+	if err := json.Unmarshal(body, &results); err != nil {
+		return nil, zaperr.Wrap(err, "failed to unmarshal tracking info batch", zap.Int("body_length", len(body)))
+	}
+	return results, nil
+}
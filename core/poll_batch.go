@@ -0,0 +1,93 @@
+package core
+
+import (
+	"context"
+
+	"github.com/hori-ryota/zaperr"
+	"go.uber.org/zap"
+)
+
+// TrackingSave is a write of a polled tracking, see Storage.SaveTrackings
+type TrackingSave struct {
+	Tracking *Tracking
+	// Update is saved along with the tracking just like with SaveTrackingUpdate,
+	// without it only the poll schedule of the tracking is saved just like with UpdatePollSchedule
+	Update *TrackingUpdate
+	// Deleted is set by SaveTrackings for trackings deleted since they were polled, nothing is saved for them
+	Deleted bool
+}
+
+type pollBatchKey struct{}
+
+// pollBatch collects writes of trackings polled from a page of due trackings, so that they are saved
+// with a single SaveTrackings rather than one by one. It is only used by the polling goroutine
+type pollBatch struct {
+	entries []*pollBatchEntry
+	// byUpdate are entries having updates, for afterSave
+	byUpdate map[*TrackingUpdate]*pollBatchEntry
+}
+
+type pollBatchEntry struct {
+	save *TrackingSave
+	// saved are run once the write is saved, unless the tracking has been deleted meanwhile
+	saved []func(ctx context.Context)
+}
+
+func newPollBatch() *pollBatch {
+	return &pollBatch{byUpdate: make(map[*TrackingUpdate]*pollBatchEntry)}
+}
+
+// withPollBatch makes refreshes with the context queue their writes to the batch instead of saving them
+func withPollBatch(ctx context.Context, batch *pollBatch) context.Context {
+	return context.WithValue(ctx, pollBatchKey{}, batch)
+}
+
+// pollBatchOf returns the batch writes are queued to, nil if they are saved right away
+func pollBatchOf(ctx context.Context) *pollBatch {
+	batch, _ := ctx.Value(pollBatchKey{}).(*pollBatch)
+	return batch
+}
+
+// add queues the write, saved is run once it is saved, it may be nil
+func (b *pollBatch) add(save *TrackingSave, saved func(ctx context.Context)) {
+	entry := &pollBatchEntry{save: save}
+	if saved != nil {
+		entry.saved = append(entry.saved, saved)
+	}
+	b.entries = append(b.entries, entry)
+	if save.Update != nil {
+		b.byUpdate[save.Update] = entry
+	}
+}
+
+// afterSave runs fn once the update queued with add is saved, e.g. to publish it once it has NotificationID
+func (b *pollBatch) afterSave(update *TrackingUpdate, fn func(ctx context.Context)) {
+	if entry, ok := b.byUpdate[update]; ok {
+		entry.saved = append(entry.saved, fn)
+	}
+}
+
+// savePollBatch saves writes queued to the batch and runs what waits for them. If saving fails, nothing is saved,
+// so the trackings are polled again next time and their updates are found again
+func (s *ServiceImpl) savePollBatch(ctx context.Context, batch *pollBatch) {
+	if len(batch.entries) == 0 {
+		return
+	}
+	saves := make([]*TrackingSave, 0, len(batch.entries))
+	for _, entry := range batch.entries {
+		saves = append(saves, entry.save)
+	}
+	if err := s.storage.SaveTrackings(ctx, saves); err != nil {
+		s.logger.Error("failed to save polled trackings", zap.Int("trackings_count", len(saves)), zaperr.ToField(err))
+		return
+	}
+	for _, entry := range batch.entries {
+		if entry.save.Deleted {
+			s.logger.Debug("tracking deleted before its update is saved", TrackingFields(entry.save.Tracking)...)
+			continue
+		}
+		for _, fn := range entry.saved {
+			fn(ctx)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package core
+
+import (
+	"context"
+	"time"
+
+	"github.com/hori-ryota/zaperr"
+)
+
+// degradedAfterFailures is how many fetches in a row have to fail because of providers for polling to be degraded
+const degradedAfterFailures = 3
+
+// PollingStatus tells a user how polling of their trackings goes
+type PollingStatus struct {
+	// LastPolledAt is when any of the user's trackings was last polled, nil if none has been yet
+	LastPolledAt *time.Time
+	// NextPollAt is when the next of the user's trackings is due, nil if there's nothing to poll
+	NextPollAt *time.Time
+	// Degraded tells whether providers keep failing or the poller is stalled, so updates may come late
+	Degraded bool
+}
+
+func (s *ServiceImpl) PollingStatus(ctx context.Context, userID int64) (*PollingStatus, error) {
+	status := &PollingStatus{
+		Degraded: s.providerFailures.Load() >= degradedAfterFailures || s.PollStalledFor() > s.shortestPollingDuration(),
+	}
+	now := s.clock.Now()
+	var cursor int64
+	for {
+		page, err := s.storage.ListTrackingsByUserID(ctx, userID, cursor, pollingStatusPageSize)
+		if err != nil {
+			return nil, zaperr.Wrap(err, "failed to list trackings", UserIDField(userID))
+		}
+		for _, t := range page.Trackings {
+			if t.LastPolledAt != nil && (status.LastPolledAt == nil || t.LastPolledAt.After(*status.LastPolledAt)) {
+				status.LastPolledAt = t.LastPolledAt
+			}
+			// trackings that were never polled are due right away
+			nextPollAt := t.NextPollAt
+			if nextPollAt == nil {
+				nextPollAt = &now
+			}
+			if status.NextPollAt == nil || nextPollAt.Before(*status.NextPollAt) {
+				status.NextPollAt = nextPollAt
+			}
+		}
+		if page.NextCursor == 0 {
+			if status.NextPollAt != nil && s.pollSchedule != nil {
+				// due trackings wait for the poller to wake up
+				due := *status.NextPollAt
+				if due.Before(now) {
+					due = now
+				}
+				next := s.pollSchedule.Next(due.Add(-time.Nanosecond))
+				status.NextPollAt = &next
+			}
+			return status, nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+// observeFetch counts fetches failing in a row because of providers, answers like "not found" reset the count
+func (s *ServiceImpl) observeFetch(err error) {
+	switch ErrorCodeOf(err) {
+	case ErrorCodeUpstreamDown, ErrorCodeRateLimited:
+		s.providerFailures.Add(1)
+	default:
+		s.providerFailures.Store(0)
+	}
+}
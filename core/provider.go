@@ -0,0 +1,171 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/dir01/parcels/parcels_api"
+	"github.com/hori-ryota/zaperr"
+	"go.uber.org/zap"
+)
+
+// TrackingInfoProvider is a source of tracking infos, e.g. the parcels service.
+// It should return ErrNoTrackingInfo when it knows nothing about the tracking number.
+// carrier is a hint detected from tracking number format (see DetectCarrier), it may be nil.
+// params are what the user has told along with the tracking number, e.g. the postal code some carriers need.
+// Providers made of several ones may return tracking infos along with *PartialFetchError
+type TrackingInfoProvider interface {
+	GetTrackingInfo(ctx context.Context, trackingNumber string, carrier *Carrier, params TrackingParams) ([]*parcels_api.TrackingInfo, error)
+}
+
+// NamedTrackingInfoProvider is a provider with a name users know it by, e.g. from warnings about it failing
+type NamedTrackingInfoProvider interface {
+	TrackingInfoProvider
+	Name() string
+}
+
+// PartialFetchError is returned by MultiProvider along with the tracking infos of the providers that succeeded,
+// when others have failed. It doesn't unwrap to errors of the failed providers, since the fetch hasn't failed
+type PartialFetchError struct {
+	// Failed are names of the failed providers, see NamedTrackingInfoProvider
+	Failed []string
+	// Err is the error of the first failed provider
+	Err error
+}
+
+func (e *PartialFetchError) Error() string {
+	return fmt.Sprintf("failed to fetch tracking info from %s: %s", strings.Join(e.Failed, ", "), e.Err)
+}
+
+// ErrBatchNotSupported is returned by BatchTrackingInfoProvider when it can't fetch batches after all,
+// e.g. when the upstream service has no batch endpoint
+var ErrBatchNotSupported = errors.New("batch fetches are not supported")
+
+// BatchTrackingInfoProvider is a TrackingInfoProvider able to fetch many tracking numbers in a single round trip
+type BatchTrackingInfoProvider interface {
+	TrackingInfoProvider
+	// GetTrackingInfoBatch fetches tracking infos of the numbers, keyed by tracking number.
+	// Numbers the provider knows nothing about are left out. An error fails the whole batch.
+	// Batches have no TrackingParams, numbers having them are fetched with GetTrackingInfo
+	GetTrackingInfoBatch(ctx context.Context, trackingNumbers []string) (map[string][]*parcels_api.TrackingInfo, error)
+}
+
+type skipCacheKey struct{}
+
+// WithSkipCache marks the context so that providers bypass their caches
+// (or ask upstream services to do so) and fetch fresh data
+func WithSkipCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, skipCacheKey{}, true)
+}
+
+func shouldSkipCache(ctx context.Context) bool {
+	skip, _ := ctx.Value(skipCacheKey{}).(bool)
+	return skip
+}
+
+func NewMultiProvider(logger *zap.Logger, providers ...TrackingInfoProvider) *MultiProvider {
+	p := &MultiProvider{providers: providers, logger: logger}
+	var _ BatchTrackingInfoProvider = p
+	var _ PushSubscriber = p
+	return p
+}
+
+// MultiProvider queries several providers concurrently and merges their results.
+// Tracking infos are deduplicated by ApiName, providers registered earlier take precedence.
+// A failing provider does not fail the whole call as long as some other provider returned data,
+// *PartialFetchError tells about it along with the data then.
+// Fetches are only conditional (see WithValidators) with a single provider, since results can't be merged
+// with a version of tracking infos we don't have.
+type MultiProvider struct {
+	providers []TrackingInfoProvider
+	logger    *zap.Logger
+}
+
+// GetTrackingInfoBatch fetches batches with the only provider there is, batches of several providers
+// would have to be merged number by number, which is what GetTrackingInfo is for
+func (p *MultiProvider) GetTrackingInfoBatch(ctx context.Context, trackingNumbers []string) (map[string][]*parcels_api.TrackingInfo, error) {
+	if len(p.providers) != 1 {
+		return nil, ErrBatchNotSupported
+	}
+	provider, ok := p.providers[0].(BatchTrackingInfoProvider)
+	if !ok {
+		return nil, ErrBatchNotSupported
+	}
+	return provider.GetTrackingInfoBatch(ctx, trackingNumbers)
+}
+
+func (p *MultiProvider) GetTrackingInfo(ctx context.Context, trackingNumber string, carrier *Carrier, params TrackingParams) ([]*parcels_api.TrackingInfo, error) {
+	type result struct {
+		trackingInfos []*parcels_api.TrackingInfo
+		err           error
+	}
+	results := make([]result, len(p.providers))
+	if len(p.providers) > 1 {
+		ctx = WithValidators(ctx, nil)
+	}
+
+	var wg sync.WaitGroup
+	for i, provider := range p.providers {
+		wg.Add(1)
+		go func(i int, provider TrackingInfoProvider) {
+			defer wg.Done()
+			trackingInfos, err := provider.GetTrackingInfo(ctx, trackingNumber, carrier, params)
+			results[i] = result{trackingInfos: trackingInfos, err: err}
+		}(i, provider)
+	}
+	wg.Wait()
+	if len(results) == 1 && errors.Is(results[0].err, ErrNotModified) {
+		return nil, ErrNotModified
+	}
+
+	var merged []*parcels_api.TrackingInfo
+	seenApiNames := make(map[string]bool)
+	var firstErr error
+	var failed []string
+	for i, r := range results {
+		if r.err != nil {
+			if !errors.Is(r.err, ErrNoTrackingInfo) {
+				p.logger.Warn(
+					"tracking info provider failed",
+					TrackingNumberField(trackingNumber),
+					zap.Int("provider_index", i),
+					zaperr.ToField(r.err),
+				)
+				if firstErr == nil {
+					firstErr = r.err
+				}
+				failed = append(failed, providerName(p.providers[i], i))
+			}
+			continue
+		}
+		for _, ti := range r.trackingInfos {
+			if seenApiNames[ti.ApiName] {
+				continue
+			}
+			seenApiNames[ti.ApiName] = true
+			merged = append(merged, ti)
+		}
+	}
+
+	if len(merged) > 0 && firstErr != nil {
+		return merged, &PartialFetchError{Failed: failed, Err: firstErr}
+	}
+	if len(merged) > 0 {
+		return merged, nil
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return nil, ErrNoTrackingInfo
+}
+
+// providerName is the name of the provider, or its position among providers of MultiProvider if it has no name
+func providerName(provider TrackingInfoProvider, i int) string {
+	if named, ok := provider.(NamedTrackingInfoProvider); ok {
+		return named.Name()
+	}
+	return fmt.Sprintf("provider #%d", i+1)
+}
@@ -0,0 +1,73 @@
+package core
+
+import (
+	"context"
+	"errors"
+
+	"github.com/dir01/parcels/parcels_api"
+)
+
+var ErrPushNotSupported = errors.New("push updates are not supported")
+
+// PushSubscriber is implemented by providers that can push tracking infos to us instead of being polled.
+// Pushed tracking infos are expected to be handed over to Service.HandlePushedTrackingInfos,
+// usually by a webhook handler provided by the same provider.
+// Subscribe should return ErrPushNotSupported if it can't subscribe to the tracking number
+type PushSubscriber interface {
+	Subscribe(ctx context.Context, trackingNumber string, carrier *Carrier, params TrackingParams) error
+}
+
+// PushHandler receives tracking infos pushed by a provider
+type PushHandler func(ctx context.Context, trackingNumber string, trackingInfos []*parcels_api.TrackingInfo) error
+
+// Subscribe subscribes to the tracking number with every provider that supports push updates,
+// it only fails if none of them managed to subscribe
+func (p *MultiProvider) Subscribe(ctx context.Context, trackingNumber string, carrier *Carrier, params TrackingParams) error {
+	err := ErrPushNotSupported
+	for _, provider := range p.providers {
+		subscriber, ok := provider.(PushSubscriber)
+		if !ok {
+			continue
+		}
+		providerErr := subscriber.Subscribe(ctx, trackingNumber, carrier, params)
+		if providerErr == nil {
+			err = nil
+		} else if errors.Is(err, ErrPushNotSupported) && !errors.Is(providerErr, ErrPushNotSupported) {
+			err = providerErr
+		}
+	}
+	return err
+}
+
+func (fc *FetchCoordinator) Subscribe(ctx context.Context, trackingNumber string, carrier *Carrier, params TrackingParams) error {
+	subscriber, ok := fc.provider.(PushSubscriber)
+	if !ok {
+		return ErrPushNotSupported
+	}
+	return subscriber.Subscribe(ctx, trackingNumber, carrier, params)
+}
+
+// mergeTrackingInfos replaces existing tracking infos with pushed ones coming from the same API,
+// keeping infos from other APIs, since a push only ever carries infos of the provider that sent it
+func mergeTrackingInfos(existing []*parcels_api.TrackingInfo, pushed []*parcels_api.TrackingInfo) []*parcels_api.TrackingInfo {
+	pushedByApiName := make(map[string]*parcels_api.TrackingInfo, len(pushed))
+	for _, ti := range pushed {
+		pushedByApiName[ti.ApiName] = ti
+	}
+
+	merged := make([]*parcels_api.TrackingInfo, 0, len(existing)+len(pushed))
+	for _, ti := range existing {
+		if p, ok := pushedByApiName[ti.ApiName]; ok {
+			merged = append(merged, p)
+			delete(pushedByApiName, ti.ApiName)
+		} else {
+			merged = append(merged, ti)
+		}
+	}
+	for _, ti := range pushed {
+		if _, ok := pushedByApiName[ti.ApiName]; ok {
+			merged = append(merged, ti)
+		}
+	}
+	return merged
+}
@@ -0,0 +1,167 @@
+package core
+
+import (
+	"context"
+	"time"
+
+	"github.com/hori-ryota/zaperr"
+	"go.uber.org/zap"
+)
+
+// retentionPageSize is how many trackings are loaded at once while looking for ones past their retention
+const retentionPageSize = 100
+
+// orphansPageSize is how many users are loaded at once while looking for ones unknown to the caller of Cleanup
+const orphansPageSize = 100
+
+// MaintenanceStorage is what Cleanup needs besides purging deleted trackings
+type MaintenanceStorage interface {
+	// ListUserIDs lists IDs of users having trackings, deleted ones included, in order, starting after afterUserID
+	// (0 for the first page)
+	ListUserIDs(ctx context.Context, afterUserID int64, limit int) ([]int64, error)
+	// PurgeUpdateRecords permanently deletes update records created before createdBefore, returning how many
+	PurgeUpdateRecords(ctx context.Context, createdBefore time.Time) (int64, error)
+	// PurgeAuditRecords permanently deletes audit records created before createdBefore, returning how many
+	PurgeAuditRecords(ctx context.Context, createdBefore time.Time) (int64, error)
+}
+
+// CleanupReport is what a Cleanup run has removed
+type CleanupReport struct {
+	// OrphanedUsers is how many users unknown to the caller had their data deleted
+	OrphanedUsers int
+	// DeletedDeliveredTrackings is how many trackings delivered longer than their retention ago were deleted
+	DeletedDeliveredTrackings int
+	// PurgedDeletedTrackings is how many trackings deleted longer than their retention ago were purged for good
+	PurgedDeletedTrackings int64
+	PurgedUpdateRecords    int64
+	PurgedAuditRecords     int64
+	// Failed names the steps that failed, they are logged and tried again on the next run
+	Failed []string
+}
+
+// IsEmpty tells whether the run has neither removed anything nor failed
+func (r *CleanupReport) IsEmpty() bool {
+	return r.OrphanedUsers == 0 && r.DeletedDeliveredTrackings == 0 && r.PurgedDeletedTrackings == 0 &&
+		r.PurgedUpdateRecords == 0 && r.PurgedAuditRecords == 0 && len(r.Failed) == 0
+}
+
+// Cleanup deletes data of users isKnownUser doesn't know, unless it is nil, deletes delivered trackings past their
+// retention, if it is set, purges deleted trackings past theirs, and purges update and audit records past theirs.
+// Steps that fail don't stop the rest, see CleanupReport.Failed. It has to be run periodically, nothing is cleaned
+// up otherwise. Restoring deleted trackings doesn't depend on it, so it may run much less often than the retention
+func (s *ServiceImpl) Cleanup(ctx context.Context, isKnownUser func(ctx context.Context, userID int64) (bool, error)) *CleanupReport {
+	report := &CleanupReport{}
+	now := s.clock.Now()
+	if isKnownUser != nil {
+		s.deleteOrphanedUsers(ctx, isKnownUser, report)
+	}
+	if s.deliveredTrackingsRetention > 0 {
+		s.deleteExpiredDeliveredTrackings(ctx, report)
+	}
+
+	var err error
+	if report.PurgedDeletedTrackings, err = s.storage.PurgeDeletedTrackings(ctx, now.Add(-s.deletedTrackingsRetention)); err != nil {
+		s.logger.Error("failed to purge deleted trackings", zaperr.ToField(err))
+		report.Failed = append(report.Failed, "deleted trackings")
+	}
+	if s.updateRecordsRetention > 0 {
+		if report.PurgedUpdateRecords, err = s.storage.PurgeUpdateRecords(ctx, now.Add(-s.updateRecordsRetention)); err != nil {
+			s.logger.Error("failed to purge update records", zaperr.ToField(err))
+			report.Failed = append(report.Failed, "update records")
+		}
+	}
+	if s.auditRecordsRetention > 0 {
+		if report.PurgedAuditRecords, err = s.storage.PurgeAuditRecords(ctx, now.Add(-s.auditRecordsRetention)); err != nil {
+			s.logger.Error("failed to purge audit records", zaperr.ToField(err))
+			report.Failed = append(report.Failed, "audit records")
+		}
+	}
+
+	if !report.IsEmpty() {
+		s.logger.Info("cleaned up",
+			zap.Int("orphaned_users", report.OrphanedUsers),
+			zap.Int("deleted_delivered_trackings", report.DeletedDeliveredTrackings),
+			zap.Int64("purged_deleted_trackings", report.PurgedDeletedTrackings),
+			zap.Int64("purged_update_records", report.PurgedUpdateRecords),
+			zap.Int64("purged_audit_records", report.PurgedAuditRecords),
+			zap.Strings("failed", report.Failed),
+		)
+	}
+	return report
+}
+
+// deleteOrphanedUsers deletes data of users having trackings who are unknown to isKnownUser, just like DeleteUserData.
+// Users whose check fails are kept
+func (s *ServiceImpl) deleteOrphanedUsers(ctx context.Context, isKnownUser func(ctx context.Context, userID int64) (bool, error), report *CleanupReport) {
+	failed := false
+	for afterUserID := int64(0); ctx.Err() == nil; {
+		userIDs, err := s.storage.ListUserIDs(ctx, afterUserID, orphansPageSize)
+		if err != nil {
+			s.logger.Error("failed to list users for cleanup", zaperr.ToField(err))
+			failed = true
+			break
+		}
+		for _, userID := range userIDs {
+			known, err := isKnownUser(ctx, userID)
+			if err != nil {
+				s.logger.Error("failed to check whether user is known", UserIDField(userID), zaperr.ToField(err))
+				failed = true
+				continue
+			}
+			if known {
+				continue
+			}
+			if err := s.DeleteUserData(ctx, userID); err != nil {
+				s.logger.Error("failed to delete data of orphaned user", UserIDField(userID), zaperr.ToField(err))
+				failed = true
+				continue
+			}
+			report.OrphanedUsers++
+		}
+		if len(userIDs) < orphansPageSize {
+			break
+		}
+		afterUserID = userIDs[len(userIDs)-1]
+	}
+	if failed {
+		report.Failed = append(report.Failed, "orphaned users")
+	}
+}
+
+// deleteExpiredDeliveredTrackings deletes trackings of all users delivered more than deliveredTrackingsRetention ago.
+// They are soft-deleted just like with DeleteTracking, so they are purged for good once deletedTrackingsRetention
+// passes too. Trackings users haven't received (see ConfirmDelivery) are kept
+func (s *ServiceImpl) deleteExpiredDeliveredTrackings(ctx context.Context, report *CleanupReport) {
+	deliveredBefore := s.clock.Now().Add(-s.deliveredTrackingsRetention)
+	details := "delivered more than " + s.deliveredTrackingsRetention.String() + " ago"
+	failed := false
+	for afterID := int64(0); ctx.Err() == nil; {
+		trackings, err := s.storage.ListTrackings(ctx, afterID, retentionPageSize)
+		if err != nil {
+			s.logger.Error("failed to list trackings for retention", zaperr.ToField(err))
+			failed = true
+			break
+		}
+		for _, tracking := range trackings {
+			deliveredAt, ok := tracking.DeliveredAt()
+			if !ok || !deliveredAt.Before(deliveredBefore) || tracking.DeliveryDisputedAt != nil {
+				continue
+			}
+			if err := s.storage.DeleteTracking(ctx, tracking.UserID, tracking.TrackingNumber); err != nil {
+				s.logger.Error("failed to delete tracking past retention", append(TrackingFields(tracking), zaperr.ToField(err))...)
+				failed = true
+				continue
+			}
+			s.fetches.cancelTracking(tracking.ID)
+			s.audit(ctx, tracking, AuditActionDeleted, details)
+			report.DeletedDeliveredTrackings++
+		}
+		if len(trackings) < retentionPageSize {
+			break
+		}
+		afterID = trackings[len(trackings)-1].ID
+	}
+	if failed {
+		report.Failed = append(report.Failed, "delivered trackings")
+	}
+}
@@ -0,0 +1,48 @@
+package core_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dir01/tg-parcels/core"
+	"github.com/dir01/tg-parcels/core/storage/memory"
+	"go.uber.org/zap"
+)
+
+func TestCleanupPurgesDeletedTrackingsPastRetention(t *testing.T) {
+	ctx := context.Background()
+	clock := core.NewFakeClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	stor := memory.NewStorage(clock)
+	retention := 24 * time.Hour
+	service := core.NewService(stor, nil, nil, time.Hour, nil, nil, 1, 0, retention, 0, 0, 0, 0, clock, nil, zap.NewNop())
+
+	for _, trackingNumber := range []string{"RR123456785US", "LZ987654326CN"} {
+		if _, _, err := stor.SaveTracking(ctx, &core.Tracking{UserID: 1, TrackingNumber: trackingNumber}); err != nil {
+			t.Fatalf("SaveTracking(%q): %v", trackingNumber, err)
+		}
+	}
+	if err := service.DeleteTracking(ctx, 1, "RR123456785US"); err != nil {
+		t.Fatalf("DeleteTracking: %v", err)
+	}
+	clock.Advance(retention / 2)
+	if err := service.DeleteTracking(ctx, 1, "LZ987654326CN"); err != nil {
+		t.Fatalf("DeleteTracking: %v", err)
+	}
+
+	if report := service.Cleanup(ctx, nil); report.PurgedDeletedTrackings != 0 {
+		t.Errorf("purged %d trackings within retention, want none", report.PurgedDeletedTrackings)
+	}
+	clock.Advance(retention/2 + time.Minute)
+	if report := service.Cleanup(ctx, nil); report.PurgedDeletedTrackings != 1 {
+		t.Errorf("purged %d trackings, want only the one deleted past retention", report.PurgedDeletedTrackings)
+	}
+
+	if err := service.RestoreTracking(ctx, 1, "RR123456785US"); !errors.Is(err, core.ErrTrackingNotFound) {
+		t.Errorf("RestoreTracking of the purged tracking = %v, want %v", err, core.ErrTrackingNotFound)
+	}
+	if err := service.RestoreTracking(ctx, 1, "LZ987654326CN"); err != nil {
+		t.Errorf("RestoreTracking of the tracking within retention: %v", err)
+	}
+}
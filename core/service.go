@@ -3,35 +3,182 @@ package core
 import (
 	"context"
 	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
 	"github.com/dir01/parcels/parcels_api"
 	"github.com/hori-ryota/zaperr"
 	"go.uber.org/zap"
 )
 
+const (
+	// pollingJitter is the fraction of polling duration by which each poll is randomly shifted
+	pollingJitter = 0.2
+	// pollingTicksPerDuration is how many times per polling duration due trackings are checked
+	pollingTicksPerDuration = 20
+	// pollBatchSize is how many due trackings are loaded at once while polling
+	pollBatchSize = 100
+	// fetchBatchSize limits how many tracking numbers are fetched with a single request, see BatchTrackingInfoProvider
+	fetchBatchSize = 50
+	// pushPollingFactor slows down polling of trackings subscribed to push updates,
+	// they are still polled occasionally in case some push gets lost
+	pushPollingFactor = 6
+	// finishedTrackingsPageSize is how many trackings are loaded at once while looking for finished ones
+	finishedTrackingsPageSize = 100
+	// pollingStatusPageSize is how many trackings are loaded at once while figuring out PollingStatus
+	pollingStatusPageSize = 100
+	// summaryPageSize is how many trackings are loaded at once while summarizing them
+	summaryPageSize = 100
+	// userStatsPageSize is how many trackings are loaded at once while computing UserStats
+	userStatsPageSize = 100
+)
+
+//go:generate moq -pkg mocks -out mocks/core.go . Service Storage TrackingInfoProvider
+
 type Service interface {
+	// Start starts polling and other background work, which stops once ctx is done.
+	// Polling stops between trackings, fetches already in flight are let finish, see Wait
 	Start(ctx context.Context)
-	Updates() chan TrackingUpdate
-	Track(ctx context.Context, userID int64, trackingNumber string, displayName string) error
+	// Wait blocks until background work stops after the context passed to Start is done.
+	// If ctx is done first, fetches still in flight are cancelled and ErrShutdownTimedOut is returned
+	Wait(ctx context.Context) error
+	// Subscribe returns a new channel of tracking updates, every subscriber receives every update.
+	// Each subscriber has its own buffer, and if a subscriber falls behind far enough for its buffer to fill up,
+	// new updates are dropped for it (and counted, see DroppedUpdates) rather than blocking the fetching of tracking infos
+	// or other subscribers.
+	// Updates with new events are persisted before being published though, and are published again on next Start
+	// until some subscriber confirms them with MarkUpdateDelivered, so they are delivered at least once.
+	// Subscribe before calling Start to receive redelivered updates
+	Subscribe() <-chan TrackingUpdate
+	// Unsubscribe stops publishing updates to the channel and closes it
+	Unsubscribe(updates <-chan TrackingUpdate)
+	MarkUpdateDelivered(ctx context.Context, update *TrackingUpdate) error
+	// Track starts tracking the number, it returns ErrTrackingExists if the user already tracks it
+	// (renaming the tracking if a new display name is given, and updating its params if new ones are given),
+	// and ErrInvalidTrackingNumber if it can't be a tracking number at all. Numbers failing their checksums
+	// are tracked, see ValidateTrackingNumber. Numbers of carriers needing a postal code (see Carrier.NeedsPostalCode)
+	// are only tracked along with one, otherwise ErrPostalCodeRequired is returned. Carrier hints that aren't codes
+	// of known carriers are rejected with ErrUnknownCarrier
+	Track(ctx context.Context, userID int64, trackingNumber string, displayName string, params TrackingParams) error
+	// TrackMany tracks several numbers at once, returning Track's result for each of the requests, in the same order.
+	// The requests are independent: failing to track one number doesn't prevent tracking the others
+	TrackMany(ctx context.Context, userID int64, requests []*TrackRequest) []error
 	GetTracking(ctx context.Context, userID int64, trackingNumber string) (*Tracking, error)
-	ListTrackings(ctx context.Context, userID int64) ([]*Tracking, error)
+	// Rename changes the display name of the tracking, an empty name removes it
+	Rename(ctx context.Context, userID int64, trackingNumber string, displayName string) error
+	// ListTrackings lists user's trackings page by page, cursor is 0 for the first page and NextCursor of the previous page after that
+	ListTrackings(ctx context.Context, userID int64, cursor int64, limit int) (*TrackingsPage, error)
+	// ListTrackingsByTag is ListTrackings limited to trackings tagged with the tag, it returns ErrInvalidTag for invalid tags
+	ListTrackingsByTag(ctx context.Context, userID int64, tag string, cursor int64, limit int) (*TrackingsPage, error)
+	// DeleteFinishedTrackings deletes user's delivered and returned trackings, only the ones tagged with the tag
+	// unless it is empty, and returns their numbers. They can be restored just like the ones deleted with DeleteTracking
+	DeleteFinishedTrackings(ctx context.Context, userID int64, tag string) ([]string, error)
+	// DeleteTracking stops tracking, the tracking can be restored with RestoreTracking for a while
 	DeleteTracking(ctx context.Context, userID int64, trackingNumber string) error
+	RestoreTracking(ctx context.Context, userID int64, trackingNumber string) error
+	// SetNote attaches a free-form note to the tracking, replacing the previous one, an empty note removes it.
+	// It returns ErrNoteTooLong for notes longer than MaxNoteLength characters
+	SetNote(ctx context.Context, userID int64, trackingNumber string, note string) error
+	// SetTags replaces tags of the tracking, see NormalizeTag. It returns ErrInvalidTag if some of the tags are invalid
+	// and ErrTooManyTags if there are more than MaxTagsPerTracking of them
+	SetTags(ctx context.Context, userID int64, trackingNumber string, tags []string) error
+	// SetNotificationLevel sets how much of the updates the user is notified of,
+	// it returns ErrInvalidNotificationLevel for levels other than NotificationLevels
+	SetNotificationLevel(ctx context.Context, userID int64, level NotificationLevel) error
+	NotificationLevel(ctx context.Context, userID int64) (NotificationLevel, error)
+	// SetTrackingNotificationLevel overrides the user's notification level for the tracking, empty level removes the override
+	SetTrackingNotificationLevel(ctx context.Context, userID int64, trackingNumber string, level NotificationLevel) error
+	// DeleteUserData permanently deletes all user's trackings, including deleted ones, along with their history
+	// and pending notifications. Unlike DeleteTracking, it can't be undone
+	DeleteUserData(ctx context.Context, userID int64) error
+	// Cleanup removes what is past its retention, along with data of users isKnownUser doesn't know, unless it is nil
+	Cleanup(ctx context.Context, isKnownUser func(ctx context.Context, userID int64) (bool, error)) *CleanupReport
+	ForceRefresh(ctx context.Context, userID int64, trackingNumber string) (*TrackingUpdate, error)
+	// TrackingHistory lists lifecycle changes of the tracking, oldest first. It works for deleted trackings too
+	TrackingHistory(ctx context.Context, userID int64, trackingNumber string) ([]*AuditRecord, error)
+	// LastUpdate returns the latest update of the tracking, nil if there has been none
+	LastUpdate(ctx context.Context, userID int64, trackingNumber string) (*UpdateRecord, error)
+	// EstimateDelivery predicts when the parcel will be delivered, nil ETA means there's no prediction
+	EstimateDelivery(ctx context.Context, tracking *Tracking) (*ETA, error)
+	// HandlePushedTrackingInfos applies tracking infos pushed by a provider to all trackings of the tracking number,
+	// publishing updates just like polling does
+	HandlePushedTrackingInfos(ctx context.Context, trackingNumber string, trackingInfos []*parcels_api.TrackingInfo) error
+	// PollingStatus tells when the user's trackings were last polled, when the next poll is due
+	// and whether polling is degraded at the moment
+	PollingStatus(ctx context.Context, userID int64) (*PollingStatus, error)
+	// Summary summarizes the user's trackings: what moved or got delivered within the period before now,
+	// what's stuck, and what's expected to be delivered within the period after now
+	Summary(ctx context.Context, userID int64, period time.Duration) (*Summary, error)
+	// UserStats computes statistics of the user's trackings, deleted ones aside
+	UserStats(ctx context.Context, userID int64) (*UserStats, error)
+	// CompareCarriers compares carriers by transit times across all users, fastest first.
+	// Non-empty country (ISO 3166-1 alpha-2) limits it to parcels from the country
+	CompareCarriers(ctx context.Context, country string) ([]*CarrierTransitTime, error)
+	// ConfirmDelivery records whether a parcel reported as delivered has actually reached the user,
+	// received parcels are deleted and the rest are flagged. It returns ErrNotDelivered for parcels that aren't delivered
+	ConfirmDelivery(ctx context.Context, userID int64, trackingNumber string, received bool) error
+	// TrackManual adds a parcel without a tracking number, which is never polled, the user is reminded of it
+	// once expectedAt comes unless it is nil. The returned tracking has a generated number to refer to the parcel by
+	TrackManual(ctx context.Context, userID int64, displayName string, expectedAt *time.Time) (*Tracking, error)
+	// AttachTrackingNumber gives the manual parcel a real tracking number, it is tracked as usual from then on.
+	// It returns ErrNotManual for parcels that already have a number and ErrTrackingExists if the user tracks the number
+	AttachTrackingNumber(ctx context.Context, userID int64, manualNumber string, trackingNumber string) error
+}
+
+// PollSchedule is when the poller wakes up to poll due trackings, e.g. a cron schedule.
+// The poller also polls once on start, not to wait for the schedule with trackings that became due while it was down
+type PollSchedule interface {
+	// Next is the first time after t the poller wakes up
+	Next(t time.Time) time.Time
 }
 
 func NewService(
 	storage Storage,
-	parcelsAPIURL string,
+	provider TrackingInfoProvider,
+	pushSubscriber PushSubscriber,
 	pollingDuration time.Duration,
+	carrierPollingDurations map[string]time.Duration,
+	pollSchedule PollSchedule,
+	updatesBufferSize int,
+	maxTrackingsPerUser int,
+	deletedTrackingsRetention time.Duration,
+	deliveredTrackingsRetention time.Duration,
+	updateRecordsRetention time.Duration,
+	auditRecordsRetention time.Duration,
+	stuckAfter time.Duration,
+	clock Clock,
+	metrics *Metrics,
 	logger *zap.Logger,
 ) *ServiceImpl {
 	s := &ServiceImpl{
-		storage:         storage,
-		parcelsAPI:      &ParcelsAPI{apiURL: parcelsAPIURL},
-		pollingDuration: pollingDuration,
-		logger:          logger,
-		updatesChan:     make(chan TrackingUpdate),
+		storage:                     storage,
+		provider:                    provider,
+		pushSubscriber:              pushSubscriber,
+		pollingDuration:             pollingDuration,
+		carrierPollingDurations:     carrierPollingDurations,
+		pollSchedule:                pollSchedule,
+		maxTrackingsPerUser:         maxTrackingsPerUser,
+		deletedTrackingsRetention:   deletedTrackingsRetention,
+		deliveredTrackingsRetention: deliveredTrackingsRetention,
+		updateRecordsRetention:      updateRecordsRetention,
+		auditRecordsRetention:       auditRecordsRetention,
+		stuckAfter:                  stuckAfter,
+		clock:                       clock,
+		metrics:                     metrics,
+		logger:                      logger,
+		updatesBufferSize:           updatesBufferSize,
+		subscribers:                 make(map[<-chan TrackingUpdate]chan TrackingUpdate),
+		eta:                         NewETAEstimator(storage, logger),
+		fetches:                     newFetchRegistry(),
 	}
+	s.fetchCtx, s.cancelFetches = context.WithCancel(context.Background())
+	s.pollNow = make(chan struct{}, 1)
 	var _ Service = s
 	return s
 }
@@ -39,20 +186,122 @@ func NewService(
 type ServiceImpl struct {
 	storage         Storage
 	pollingDuration time.Duration
-	parcelsAPI      *ParcelsAPI
-	logger          *zap.Logger
-	updatesChan     chan TrackingUpdate
+	// carrierPollingDurations override pollingDuration for trackings of carriers, see pollingDurationOf
+	carrierPollingDurations map[string]time.Duration
+	// pollSchedule is when due trackings are polled, nil means they are polled as soon as they are due
+	pollSchedule PollSchedule
+	// maxTrackingsPerUser limits how many parcels a single user can track, 0 means no limit
+	maxTrackingsPerUser int
+	// deletedTrackingsRetention is how long deleted trackings can be restored before they are purged for good
+	deletedTrackingsRetention time.Duration
+	// deliveredTrackingsRetention is how long delivered trackings are kept before they are deleted, 0 keeps them forever
+	deliveredTrackingsRetention time.Duration
+	// updateRecordsRetention is how long update records (see UpdateRecord) are kept, 0 keeps them forever
+	updateRecordsRetention time.Duration
+	// auditRecordsRetention is how long audit records are kept, 0 keeps them forever
+	auditRecordsRetention time.Duration
+	// stuckAfter is how long a tracking can go without new events before the user is alerted, 0 disables the alerts
+	stuckAfter time.Duration
+	// clock is what polls are scheduled, and trackings are expired and purged by
+	clock    Clock
+	provider TrackingInfoProvider
+	// pushSubscriber subscribes new trackings to push updates, nil means push updates are disabled
+	pushSubscriber    PushSubscriber
+	metrics           *Metrics
+	logger            *zap.Logger
+	updatesBufferSize int
+	subscribersMu     sync.RWMutex
+	subscribers       map[<-chan TrackingUpdate]chan TrackingUpdate
+	droppedUpdates    atomic.Int64
+	eta               *ETAEstimator
+	// background tracks goroutines started by Start and Track, so that shutdown can wait for them
+	background sync.WaitGroup
+	// fetchCtx outlives the context passed to Start, so that stopping doesn't abort fetches half way
+	fetchCtx        context.Context
+	cancelFetches   context.CancelFunc
+	fetchesInFlight atomic.Int64
+	// fetches are refreshes in flight, which are cancelled once their trackings are deleted
+	fetches *fetchRegistry
+	// pollNow wakes the poller up before the next tick, see TriggerPoll
+	pollNow chan struct{}
+	// pollStepStartedAt is unix nanos of when the poller started its current step, 0 while it is idle
+	pollStepStartedAt atomic.Int64
+	// providerFailures is how many fetches in a row have failed because of providers, see observeFetch
+	providerFailures atomic.Int64
 }
 
 type Storage interface {
-	SaveTracking(ctx context.Context, tracking *Tracking) (*Tracking, error)
+	ETAStorage
+	DeliveryConfirmationStorage
+	ManualTrackingStorage
+	UpdateRecordStorage
+	MaintenanceStorage
+	// SaveTracking upserts the tracking, created tells whether the user wasn't tracking the number before
+	SaveTracking(ctx context.Context, tracking *Tracking) (saved *Tracking, created bool, err error)
 	GetTracking(ctx context.Context, userID int64, trackingNumber string) (*Tracking, error)
-	ListTrackingsLastPolledBefore(ctx context.Context, t time.Time) ([]*Tracking, error)
-	ListTrackingsByUserID(ctx context.Context, userID int64) ([]*Tracking, error)
+	// ListTrackingsDueForPoll lists up to limit trackings due for poll in priority order: never polled ones first,
+	// then the most recently active ones, trackings without known activity last. after is nil for the first page.
+	// Manual trackings are never due
+	ListTrackingsDueForPoll(ctx context.Context, now time.Time, after *PollCursor, limit int) ([]*Tracking, error)
+	// ListTrackings lists trackings of all users page by page, afterID is 0 for the first page
+	ListTrackings(ctx context.Context, afterID int64, limit int) ([]*Tracking, error)
+	ListTrackingsByUserID(ctx context.Context, userID int64, cursor int64, limit int) (*TrackingsPage, error)
+	// ListTrackingsByTag is ListTrackingsByUserID limited to trackings tagged with the tag
+	ListTrackingsByTag(ctx context.Context, userID int64, tag string, cursor int64, limit int) (*TrackingsPage, error)
+	ListTrackingsByTrackingNumber(ctx context.Context, trackingNumber string) ([]*Tracking, error)
+	CountTrackingsByUserID(ctx context.Context, userID int64) (int, error)
 	DeleteTracking(ctx context.Context, userID int64, trackingNumber string) error
+	RestoreTracking(ctx context.Context, userID int64, trackingNumber string, deletedAfter time.Time) error
+	PurgeDeletedTrackings(ctx context.Context, deletedBefore time.Time) (int64, error)
+	// DeleteUserData permanently deletes everything stored about the user
+	DeleteUserData(ctx context.Context, userID int64) error
+	// UpdatePollSchedule saves when the tracking was last polled and is due next, along with its Validators
+	UpdatePollSchedule(ctx context.Context, tracking *Tracking) error
+	SetPushSubscribed(ctx context.Context, trackingID int64, subscribed bool) error
+	SetTrackingNote(ctx context.Context, trackingID int64, note string) error
+	SetTrackingDisplayName(ctx context.Context, trackingID int64, displayName string) error
+	// SetTrackingTags replaces tags of the tracking
+	SetTrackingTags(ctx context.Context, trackingID int64, tags []string) error
+	SetStuckAlertedAt(ctx context.Context, trackingID int64, alertedAt time.Time) error
+	SetNotificationLevel(ctx context.Context, userID int64, level NotificationLevel) error
+	SetTrackingNotificationLevel(ctx context.Context, trackingID int64, level NotificationLevel) error
+	// GetNotificationLevel returns NotificationLevelAll for users who have never set their level
+	GetNotificationLevel(ctx context.Context, userID int64) (NotificationLevel, error)
+	// SaveTrackingUpdate saves the tracking, a pending notification about the update and its UpdateRecord atomically,
+	// setting update.NotificationID
+	SaveTrackingUpdate(ctx context.Context, tracking *Tracking, update *TrackingUpdate) (*Tracking, error)
+	// SaveTrackings saves polled trackings in a single transaction, see TrackingSave. Trackings deleted since they
+	// were polled are left alone and marked Deleted, the rest are saved all or none
+	SaveTrackings(ctx context.Context, saves []*TrackingSave) error
+	ListPendingNotifications(ctx context.Context) ([]*TrackingUpdate, error)
+	// DeleteNotification deletes the pending notification as delivered, marking its UpdateRecord delivered
+	DeleteNotification(ctx context.Context, notificationID int64) error
+	SaveAuditRecord(ctx context.Context, record *AuditRecord) error
+	ListAuditRecords(ctx context.Context, userID int64, trackingNumber string) ([]*AuditRecord, error)
 }
 
 var ErrTrackingExists = errors.New("tracking exists")
+var ErrNoteTooLong = errors.New("note is too long")
+var ErrTrackingNotFound = errors.New("tracking not found")
+
+// ErrShutdownTimedOut is returned by Wait when background work doesn't stop in time
+var ErrShutdownTimedOut = errors.New("shutdown timed out")
+
+// TrackingQuotaExceededError is returned by Track when the user already tracks as many parcels as allowed
+type TrackingQuotaExceededError struct {
+	Limit int
+}
+
+func (e *TrackingQuotaExceededError) Error() string {
+	return fmt.Sprintf("tracking quota of %d parcels exceeded", e.Limit)
+}
+
+// TrackRequest is a tracking number to track along with its display name, see Service.TrackMany
+type TrackRequest struct {
+	TrackingNumber string
+	DisplayName    string
+	Params         TrackingParams
+}
 
 type Tracking struct {
 	ID             int64
@@ -61,186 +310,1038 @@ type Tracking struct {
 	DisplayName    string
 	TrackingInfos  []*parcels_api.TrackingInfo
 	LastPolledAt   *time.Time
+	NextPollAt     *time.Time
+	// SeenEventHashes are hashes (see EventHash) of all the events we have ever notified the user about
+	SeenEventHashes []string
+	// PushSubscribed tells whether some provider pushes updates of the tracking to us, so it can be polled less often
+	PushSubscribed bool
+	// Note is what the user wants to remember about the parcel: what's inside, the order number, the seller
+	Note string
+	// Tags are normalized (see NormalizeTag) and sorted
+	Tags []string
+	// NotificationLevel overrides the user's level for the tracking unless it is empty
+	NotificationLevel NotificationLevel
+	// StuckAlertedAt is when the user was last alerted of the tracking being stuck, see scanStuckTrackings
+	StuckAlertedAt *time.Time
+	// LastActivityAt is when the tracking was added or last got new events, it is maintained by storage.
+	// It is nil for trackings that have had no activity since it is recorded
+	LastActivityAt *time.Time
+	// DeliveryDisputedAt is when the user said the parcel hadn't reached them even though it was reported as delivered,
+	// see ConfirmDelivery. It is nil for trackings nobody has disputed
+	DeliveryDisputedAt *time.Time
+	// Validators identify the version of TrackingInfos as last fetched, for conditional fetches (see WithValidators)
+	Validators Validators
+	// Params are passed to providers along with the tracking number, e.g. the postal code some carriers need
+	Params TrackingParams
+	// Manual parcels have no real tracking number and are never polled, see TrackManual
+	Manual bool
+	// ExpectedAt is when the user expects the manual parcel, nil if they don't know
+	ExpectedAt *time.Time
+	// RemindedAt is when the user was reminded of the manual parcel being expected, see scanManualReminders
+	RemindedAt *time.Time
+}
+
+// PollCursor is a position in the queue of trackings due for poll, see Storage.ListTrackingsDueForPoll
+type PollCursor struct {
+	Polled         bool
+	LastActivityAt *time.Time
+	ID             int64
+}
+
+// PollCursorOf is the position of the tracking in the queue of trackings due for poll
+func PollCursorOf(tracking *Tracking) *PollCursor {
+	cursor := &PollCursor{Polled: tracking.LastPolledAt != nil, ID: tracking.ID}
+	if tracking.LastActivityAt != nil {
+		lastActivityAt := *tracking.LastActivityAt
+		cursor.LastActivityAt = &lastActivityAt
+	}
+	return cursor
+}
+
+// MaxNoteLength is the maximum length of a tracking note in characters
+const MaxNoteLength = 500
+
+// TrackingsPage is a page of trackings ordered by ID
+type TrackingsPage struct {
+	Trackings []*Tracking
+	// NextCursor is the cursor of the next page, 0 if this is the last one
+	NextCursor int64
+	// Total is the number of trackings on all pages
+	Total int
 }
 
 type TrackingUpdate struct {
+	// NotificationID identifies the persisted notification about the update, it is 0 for updates that aren't persisted (e.g. errors)
+	NotificationID    int64
 	TrackingNumber    string
 	UserID            int64
 	DisplayName       string
 	NewTrackingInfos  []*parcels_api.TrackingInfo
 	NewTrackingEvents []*parcels_api.TrackingEvent
-	TrackingError     error
+	// ETA is the delivery prediction made with the new events taken into account, it may be nil
+	ETA *ETA
+	// Statuses are the canonical statuses the tracking has reached with the update, in the order of progress
+	Statuses []Status
+	// Milestones are the statuses worth a special mention among Statuses, see milestoneStatuses
+	Milestones    []Status
+	TrackingError error
+	// ErrorCode is the category of TrackingError, it is empty if there's no error
+	ErrorCode ErrorCode
+	// StuckSince is set for alerts about trackings that haven't moved for a while, it is the time of the latest event.
+	// Such alerts have no new events and aren't persisted
+	StuckSince *time.Time
+	// ExpectedAt is set for reminders about manual parcels expected by now (see TrackManual), it is when they were expected.
+	// Such reminders have no new events and aren't persisted
+	ExpectedAt *time.Time
+	// FailedProviders are names of providers that couldn't be checked while fetching the update's events,
+	// see PartialFetchError. What they would have told may be missing from the update
+	FailedProviders []string
+}
+
+func (s *ServiceImpl) Subscribe() <-chan TrackingUpdate {
+	ch := make(chan TrackingUpdate, s.updatesBufferSize)
+	s.subscribersMu.Lock()
+	defer s.subscribersMu.Unlock()
+	s.subscribers[ch] = ch
+	return ch
+}
+
+func (s *ServiceImpl) Unsubscribe(updates <-chan TrackingUpdate) {
+	s.subscribersMu.Lock()
+	defer s.subscribersMu.Unlock()
+	if ch, ok := s.subscribers[updates]; ok {
+		delete(s.subscribers, updates)
+		close(ch)
+	}
+}
+
+// DroppedUpdates returns the number of updates dropped because some subscriber's buffer was full
+func (s *ServiceImpl) DroppedUpdates() int64 {
+	return s.droppedUpdates.Load()
+}
+
+// TriggerPoll makes the poller poll trackings that are due right away instead of on the next tick.
+// It returns false if a poll is already triggered and hasn't started yet
+func (s *ServiceImpl) TriggerPoll() bool {
+	select {
+	case s.pollNow <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// PollStalledFor returns how long the poller has been busy with its current step (listing due trackings
+// or fetching one of them), 0 while it is idle. A step taking much longer than a fetch can means the poller is wedged
+func (s *ServiceImpl) PollStalledFor() time.Duration {
+	startedAt := s.pollStepStartedAt.Load()
+	if startedAt == 0 {
+		return 0
+	}
+	return time.Since(time.Unix(0, startedAt))
+}
+
+// publishUpdate never blocks: if a subscriber is stalled and its buffer is full, the update is dropped for it
+func (s *ServiceImpl) publishUpdate(update TrackingUpdate) {
+	s.metrics.observeUpdateEmitted(update)
+	s.subscribersMu.RLock()
+	defer s.subscribersMu.RUnlock()
+	for _, ch := range s.subscribers {
+		select {
+		case ch <- update:
+		default:
+			dropped := s.droppedUpdates.Add(1)
+			s.logger.Error(
+				"updates buffer is full, dropping update",
+				append(UpdateFields(&update),
+					zap.Int("subscribers_count", len(s.subscribers)),
+					zap.Int64("dropped_updates_total", dropped),
+				)...,
+			)
+		}
+	}
 }
 
-func (s *ServiceImpl) Updates() chan TrackingUpdate {
-	return s.updatesChan
+func (s *ServiceImpl) untilNextScheduledPoll() time.Duration {
+	now := s.clock.Now()
+	next := s.pollSchedule.Next(now)
+	s.logger.Debug("next poll is scheduled", zap.Time("at", next))
+	return next.Sub(now)
+}
+
+// MarkUpdateDelivered should be called once the update has been delivered to the user,
+// otherwise it will be delivered again after restart
+func (s *ServiceImpl) MarkUpdateDelivered(ctx context.Context, update *TrackingUpdate) error {
+	if update.NotificationID == 0 {
+		return nil
+	}
+	return s.storage.DeleteNotification(ctx, update.NotificationID)
 }
 
 func (s *ServiceImpl) Start(ctx context.Context) {
 	s.logger.Debug("service starting")
+	s.background.Add(1)
 	go func() {
-		s.poll(ctx)
+		defer s.background.Done()
+		s.redeliverPendingUpdates(ctx)
 
-		t := time.NewTicker(s.pollingDuration)
+		// trackings that became due while we were down are polled right away, with a schedule too
+		s.poll(ctx)
+		var tick <-chan time.Time
+		var scheduled *time.Timer
+		if s.pollSchedule == nil {
+			// every tracking has its own schedule, so we have to check for due trackings
+			// much more often than polling duration for the polls to actually be spread out
+			tickInterval := s.shortestPollingDuration() / pollingTicksPerDuration
+			if tickInterval < time.Second {
+				tickInterval = time.Second
+			}
+			t := time.NewTicker(tickInterval)
+			defer t.Stop()
+			tick = t.C
+		} else {
+			// trackings that become due in between wait for the next scheduled time
+			scheduled = time.NewTimer(s.untilNextScheduledPoll())
+			defer scheduled.Stop()
+			tick = scheduled.C
+		}
 		for {
 			select {
 			case <-ctx.Done():
 				s.logger.Debug("polling stopped")
 				return
-			case <-t.C:
+			case <-tick:
+				s.poll(ctx)
+				if scheduled != nil {
+					scheduled.Reset(s.untilNextScheduledPoll())
+				}
+			case <-s.pollNow:
 				s.poll(ctx)
 			}
 		}
 	}()
 	s.logger.Debug("polling started")
+
+	if s.stuckAfter > 0 {
+		s.background.Add(1)
+		go func() {
+			defer s.background.Done()
+			s.scanStuckTrackings(ctx)
+			t := time.NewTicker(stuckScanInterval)
+			defer t.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-t.C:
+					s.scanStuckTrackings(ctx)
+				}
+			}
+		}()
+	}
+
+	s.background.Add(1)
+	go func() {
+		defer s.background.Done()
+		s.scanManualReminders(ctx)
+		t := time.NewTicker(reminderScanInterval)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				s.scanManualReminders(ctx)
+			}
+		}
+	}()
 }
 
-// Track starts tracking a new tracking number for a user
-// if the tracking number is already being tracked by the user
-// it will just fetch the tracking info
-// Please note that the result of fetching the tracking info can be cached by parcels service
-func (s *ServiceImpl) Track(ctx context.Context, userID int64, trackingNumber string, displayName string) error {
-	zapFields := []zap.Field{
-		zap.Int64("user_id", userID),
-		zap.String("tracking_number", trackingNumber),
+func (s *ServiceImpl) Wait(ctx context.Context) error {
+	stopped := make(chan struct{})
+	go func() {
+		s.background.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		inFlight := s.fetchesInFlight.Load()
+		s.cancelFetches()
+		return zaperr.Wrap(ErrShutdownTimedOut, "background work didn't stop in time", zap.Int64("fetches_in_flight", inFlight))
 	}
+}
+
+// Track starts tracking a new tracking number for a user.
+// If the user is already tracking the number, it returns ErrTrackingExists,
+// renaming the tracking first if a different non-empty display name is given, and updating its params if any are given
+// Please note that the result of fetching the tracking info can be cached by parcels service
+func (s *ServiceImpl) Track(ctx context.Context, userID int64, trackingNumber string, displayName string, params TrackingParams) error {
+	zapFields := []zap.Field{UserIDField(userID), TrackingNumberField(trackingNumber)}
 	s.logger.Info("got track command", zapFields...)
 
-	tracking := &Tracking{
-		UserID:         userID,
-		TrackingNumber: trackingNumber,
-		DisplayName:    displayName,
+	if err := ValidateTrackingNumber(trackingNumber); errors.Is(err, ErrInvalidTrackingNumber) {
+		return err
 	}
-	if tracking, err := s.storage.SaveTracking(ctx, tracking); err == nil {
-		zapFields := append(zapFields, zap.Int64("tracking_id", tracking.ID), zaperr.ToField(err))
-		s.logger.Info("tracking added", zapFields...)
-		go s.fetchTrackingInfo(ctx, tracking, true)
-		return nil
-	} else {
+	postalCode, err := NormalizePostalCode(params.PostalCode)
+	if err != nil {
+		return err
+	}
+	params.PostalCode = postalCode
+	if params.Carrier != "" {
+		carrier := CarrierByCode(params.Carrier)
+		if carrier == nil {
+			return ErrUnknownCarrier
+		}
+		params.Carrier = carrier.Code
+	}
+	if err := s.checkTrackingQuota(ctx, userID, trackingNumber); err != nil {
+		return err
+	}
+
+	existing, err := s.storage.GetTracking(ctx, userID, trackingNumber)
+	if err != nil && !errors.Is(err, ErrTrackingNotFound) {
+		return zaperr.Wrap(err, "failed to get tracking", zapFields...)
+	}
+	if existing != nil && displayName == "" {
+		displayName = existing.DisplayName
+	}
+	if existing != nil && params.PostalCode == "" {
+		params.PostalCode = existing.Params.PostalCode
+	}
+	if existing != nil && params.Carrier == "" {
+		params.Carrier = existing.Params.Carrier
+	}
+	tracking := &Tracking{TrackingNumber: trackingNumber, Params: params}
+	if carrier := tracking.Carrier(); carrier != nil && carrier.NeedsPostalCode && params.PostalCode == "" {
+		return ErrPostalCodeRequired
+	}
+
+	// the tracking is fetched right below, so polling must not pick a new one up at the same time
+	nextPollAt := s.nextPollAt(s.clock.Now(), &Tracking{})
+	tracking.UserID, tracking.DisplayName, tracking.NextPollAt = userID, displayName, &nextPollAt
+	tracking, created, err := s.storage.SaveTracking(ctx, tracking)
+	if err != nil {
 		return zaperr.Wrap(err, "failed to add tracking", zapFields...)
 	}
+	zapFields = append(zapFields, TrackingIDField(tracking.ID))
+
+	if !created {
+		if existing != nil && existing.DisplayName != displayName {
+			s.audit(ctx, tracking, AuditActionRenamed, fmt.Sprintf("%q -> %q", existing.DisplayName, displayName))
+		}
+		return ErrTrackingExists
+	}
+
+	s.logger.Info("tracking added", zapFields...)
+	s.audit(ctx, tracking, AuditActionCreated, "")
+	s.background.Add(1)
+	go func() {
+		defer s.background.Done()
+		s.fetchTrackingInfo(s.fetchCtx, tracking, true)
+		s.subscribeToPushUpdates(s.fetchCtx, tracking)
+	}()
+	return nil
+}
+
+func (s *ServiceImpl) TrackMany(ctx context.Context, userID int64, requests []*TrackRequest) []error {
+	s.logger.Info("got batch track command", UserIDField(userID), zap.Int("requests_count", len(requests)))
+	errs := make([]error, len(requests))
+	for i, r := range requests {
+		// numbers are tracked one by one, so that the quota is checked against the ones tracked just before
+		errs[i] = s.Track(ctx, userID, r.TrackingNumber, r.DisplayName, r.Params)
+	}
+	return errs
+}
+
+// checkTrackingQuota makes sure user can track one more parcel.
+// Re-tracking an already tracked number (e.g. to rename it) is always allowed
+func (s *ServiceImpl) checkTrackingQuota(ctx context.Context, userID int64, trackingNumber string) error {
+	if s.maxTrackingsPerUser <= 0 {
+		return nil
+	}
+
+	count, err := s.storage.CountTrackingsByUserID(ctx, userID)
+	if err != nil {
+		return zaperr.Wrap(err, "failed to count trackings", UserIDField(userID))
+	}
+	if count < s.maxTrackingsPerUser {
+		return nil
+	}
+
+	_, err = s.storage.GetTracking(ctx, userID, trackingNumber)
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, ErrTrackingNotFound) {
+		return &TrackingQuotaExceededError{Limit: s.maxTrackingsPerUser}
+	}
+	return zaperr.Wrap(err, "failed to get tracking", UserIDField(userID), TrackingNumberField(trackingNumber))
 }
 
 func (s *ServiceImpl) GetTracking(ctx context.Context, userID int64, trackingNumber string) (*Tracking, error) {
 	return s.storage.GetTracking(ctx, userID, trackingNumber)
 }
 
-func (s *ServiceImpl) ListTrackings(ctx context.Context, userID int64) ([]*Tracking, error) {
-	return s.storage.ListTrackingsByUserID(ctx, userID)
+func (s *ServiceImpl) ListTrackings(ctx context.Context, userID int64, cursor int64, limit int) (*TrackingsPage, error) {
+	return s.storage.ListTrackingsByUserID(ctx, userID, cursor, limit)
+}
+
+func (s *ServiceImpl) ListTrackingsByTag(ctx context.Context, userID int64, tag string, cursor int64, limit int) (*TrackingsPage, error) {
+	tag, err := NormalizeTag(tag)
+	if err != nil {
+		return nil, err
+	}
+	return s.storage.ListTrackingsByTag(ctx, userID, tag, cursor, limit)
+}
+
+func (s *ServiceImpl) DeleteFinishedTrackings(ctx context.Context, userID int64, tag string) ([]string, error) {
+	list := s.ListTrackings
+	if tag != "" {
+		list = func(ctx context.Context, userID int64, cursor int64, limit int) (*TrackingsPage, error) {
+			return s.ListTrackingsByTag(ctx, userID, tag, cursor, limit)
+		}
+	}
+
+	var deleted []string
+	for cursor := int64(0); ; {
+		page, err := list(ctx, userID, cursor, finishedTrackingsPageSize)
+		if err != nil {
+			return deleted, err
+		}
+		for _, tracking := range page.Trackings {
+			// parcels that haven't been received despite being delivered are still of interest
+			if !tracking.Status().IsTerminal() || tracking.DeliveryDisputedAt != nil {
+				continue
+			}
+			if err := s.DeleteTracking(ctx, userID, tracking.TrackingNumber); err != nil {
+				return deleted, zaperr.Wrap(err, "failed to delete tracking", TrackingFields(tracking)...)
+			}
+			deleted = append(deleted, tracking.TrackingNumber)
+		}
+		if page.NextCursor == 0 {
+			break
+		}
+		cursor = page.NextCursor
+	}
+	if len(deleted) > 0 {
+		s.logger.Info("deleted finished trackings", UserIDField(userID), zap.Int("count", len(deleted)))
+	}
+	return deleted, nil
 }
 
 func (s *ServiceImpl) DeleteTracking(ctx context.Context, userID int64, trackingNumber string) error {
-	return s.storage.DeleteTracking(ctx, userID, trackingNumber)
+	tracking, err := s.storage.GetTracking(ctx, userID, trackingNumber)
+	if errors.Is(err, ErrTrackingNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := s.storage.DeleteTracking(ctx, userID, trackingNumber); err != nil {
+		return err
+	}
+	s.fetches.cancelTracking(tracking.ID)
+	s.audit(ctx, tracking, AuditActionDeleted, "")
+	return nil
 }
 
-// fetchTrackingInfo fetches the tracking info from parcels service
-// and updates the tracking in the storage if required
-// it also publishes any updates to the user
-func (s *ServiceImpl) fetchTrackingInfo(ctx context.Context, tracking *Tracking, reportErrors bool) {
-	zapFields := []zap.Field{
-		zap.Any("tracking", tracking),
+func (s *ServiceImpl) EstimateDelivery(ctx context.Context, tracking *Tracking) (*ETA, error) {
+	return s.eta.Estimate(ctx, tracking)
+}
+
+// RestoreTracking restores a tracking deleted within retention period,
+// it returns ErrTrackingNotFound if there's no such tracking
+func (s *ServiceImpl) RestoreTracking(ctx context.Context, userID int64, trackingNumber string) error {
+	if err := s.checkTrackingQuota(ctx, userID, trackingNumber); err != nil {
+		return err
 	}
-	s.logger.Debug("fetching tracking info", zapFields...)
+	if err := s.storage.RestoreTracking(ctx, userID, trackingNumber, s.clock.Now().Add(-s.deletedTrackingsRetention)); err != nil {
+		return err
+	}
+	tracking, err := s.storage.GetTracking(ctx, userID, trackingNumber)
+	if err != nil {
+		return err
+	}
+	s.audit(ctx, tracking, AuditActionRestored, "")
+	return nil
+}
 
-	fetchedTrackingInfos, err := s.parcelsAPI.GetTrackingInfo(ctx, tracking.TrackingNumber)
+func (s *ServiceImpl) Rename(ctx context.Context, userID int64, trackingNumber string, displayName string) error {
+	displayName = strings.TrimSpace(displayName)
+	tracking, err := s.storage.GetTracking(ctx, userID, trackingNumber)
 	if err != nil {
-		s.logger.Error("failed to fetch tracking info", append(zapFields, zaperr.ToField(err))...)
-		if reportErrors {
-			s.updatesChan <- TrackingUpdate{
+		return err
+	}
+	if tracking.DisplayName == displayName {
+		return nil
+	}
+	if err := s.storage.SetTrackingDisplayName(ctx, tracking.ID, displayName); err != nil {
+		return zaperr.Wrap(err, "failed to rename tracking", TrackingFields(tracking)...)
+	}
+	s.audit(ctx, tracking, AuditActionRenamed, fmt.Sprintf("%q -> %q", tracking.DisplayName, displayName))
+	return nil
+}
+
+// SetNote is not audited, since notes aren't lifecycle changes
+func (s *ServiceImpl) SetNote(ctx context.Context, userID int64, trackingNumber string, note string) error {
+	note = strings.TrimSpace(note)
+	if utf8.RuneCountInString(note) > MaxNoteLength {
+		return ErrNoteTooLong
+	}
+	tracking, err := s.storage.GetTracking(ctx, userID, trackingNumber)
+	if err != nil {
+		return err
+	}
+	if err := s.storage.SetTrackingNote(ctx, tracking.ID, note); err != nil {
+		return zaperr.Wrap(err, "failed to set note", TrackingFields(tracking)...)
+	}
+	return nil
+}
+
+func (s *ServiceImpl) SetTags(ctx context.Context, userID int64, trackingNumber string, tags []string) error {
+	normalized, err := normalizeTags(tags)
+	if err != nil {
+		return err
+	}
+	tracking, err := s.storage.GetTracking(ctx, userID, trackingNumber)
+	if err != nil {
+		return err
+	}
+	if err := s.storage.SetTrackingTags(ctx, tracking.ID, normalized); err != nil {
+		return zaperr.Wrap(err, "failed to set tags", TrackingFields(tracking)...)
+	}
+	return nil
+}
+
+// DeleteUserData is not audited, since audit records are personal data too
+func (s *ServiceImpl) DeleteUserData(ctx context.Context, userID int64) error {
+	if err := s.storage.DeleteUserData(ctx, userID); err != nil {
+		return err
+	}
+	s.fetches.cancelUser(userID)
+	s.logger.Info("deleted user data", UserIDField(userID))
+	return nil
+}
+
+func (s *ServiceImpl) TrackingHistory(ctx context.Context, userID int64, trackingNumber string) ([]*AuditRecord, error) {
+	return s.storage.ListAuditRecords(ctx, userID, trackingNumber)
+}
+
+// audit records a lifecycle change of the tracking.
+// History is a debugging aid, so failing to record it does not fail the change itself
+func (s *ServiceImpl) audit(ctx context.Context, tracking *Tracking, action AuditAction, details string) {
+	record := &AuditRecord{
+		TrackingID:     tracking.ID,
+		UserID:         tracking.UserID,
+		TrackingNumber: tracking.TrackingNumber,
+		Action:         action,
+		Details:        details,
+		CreatedAt:      s.clock.Now(),
+	}
+	if err := s.storage.SaveAuditRecord(ctx, record); err != nil {
+		s.logger.Error("failed to save audit record", append(TrackingFields(tracking), zap.String("action", string(action)), zaperr.ToField(err))...)
+	}
+}
+
+// ForceRefresh fetches the tracking info right away, regardless of when it was polled last time,
+// and asks providers to bypass their caches.
+// The update is returned to the caller instead of being published, nil update means nothing changed
+func (s *ServiceImpl) ForceRefresh(ctx context.Context, userID int64, trackingNumber string) (*TrackingUpdate, error) {
+	tracking, err := s.storage.GetTracking(ctx, userID, trackingNumber)
+	if err != nil {
+		return nil, err
+	}
+	if tracking.Manual {
+		return nil, ErrManualTracking
+	}
+	return s.refreshTracking(WithSkipCache(ctx), tracking)
+}
+
+// fetchTrackingInfo refreshes the tracking and publishes any updates to the user
+func (s *ServiceImpl) fetchTrackingInfo(ctx context.Context, tracking *Tracking, reportErrors bool) {
+	s.fetchesInFlight.Add(1)
+	defer s.fetchesInFlight.Add(-1)
+	trackingUpdate, err := s.refreshTracking(ctx, tracking)
+	s.publishRefresh(ctx, tracking, trackingUpdate, err, reportErrors)
+}
+
+// publishRefresh publishes the update of the refreshed tracking, and the error of the refresh if reportErrors is set.
+// Nothing is published about trackings deleted during the refresh
+func (s *ServiceImpl) publishRefresh(ctx context.Context, tracking *Tracking, trackingUpdate *TrackingUpdate, err error, reportErrors bool) {
+	if err != nil {
+		if reportErrors && !errors.Is(err, ErrTrackingNotFound) {
+			s.publishUpdate(TrackingUpdate{
 				TrackingNumber: tracking.TrackingNumber,
 				UserID:         tracking.UserID,
 				DisplayName:    tracking.DisplayName,
 				TrackingError:  err,
-			}
+				ErrorCode:      ErrorCodeOf(err),
+			})
 		}
 		return
 	}
+	if trackingUpdate == nil {
+		return
+	}
+	// updates of polls are published once they are saved, so that they have NotificationID
+	if batch := pollBatchOf(ctx); batch != nil {
+		batch.afterSave(trackingUpdate, func(ctx context.Context) { s.publishTrackingUpdate(ctx, *trackingUpdate) })
+		return
+	}
+	s.publishTrackingUpdate(ctx, *trackingUpdate)
+}
+
+// refreshTracking fetches the tracking info from the provider
+// and updates the tracking in the storage if required.
+// It returns nil update if tracking info is up to date, and ErrTrackingNotFound if the tracking is deleted meanwhile
+func (s *ServiceImpl) refreshTracking(ctx context.Context, tracking *Tracking) (*TrackingUpdate, error) {
+	zapFields := TrackingFields(tracking)
+	s.logger.Debug("fetching tracking info", zapFields...)
+	fetch := s.fetches.start(ctx, tracking)
+	defer s.fetches.finish(tracking.ID, fetch)
+
+	// validators are only worth anything along with the tracking infos they identify
+	validators := Validators{}
+	if len(tracking.TrackingInfos) > 0 {
+		validators = tracking.Validators
+	}
+	fetchCtx := WithValidators(fetch.ctx, &validators)
+	fetchedTrackingInfos, err := s.provider.GetTrackingInfo(fetchCtx, tracking.TrackingNumber, tracking.Carrier(), tracking.Params)
+	if err == nil {
+		tracking.Validators = validators
+	}
+	return s.handleFetchResultOf(fetch, tracking, fetchedTrackingInfos, err)
+}
+
+// handleFetchResultOf is handleFetchResult of the fetch registered with fetchRegistry.
+// Once the tracking is deleted, whatever has been fetched is dropped, and so is the error of saving it
+func (s *ServiceImpl) handleFetchResultOf(fetch *inFlightFetch, tracking *Tracking, fetchedTrackingInfos []*parcels_api.TrackingInfo, err error) (*TrackingUpdate, error) {
+	if !fetch.deleted.Load() {
+		var trackingUpdate *TrackingUpdate
+		trackingUpdate, err = s.handleFetchResult(fetch.ctx, tracking, fetchedTrackingInfos, err)
+		if !fetch.deleted.Load() {
+			return trackingUpdate, err
+		}
+	}
+	s.logger.Debug("tracking deleted while being refreshed", TrackingFields(tracking)...)
+	return nil, ErrTrackingNotFound
+}
+
+// handleFetchResult schedules the next poll of the tracking and applies tracking infos fetched for it,
+// unless the fetch has failed. Tracking infos fetched from some of the providers are applied too (see PartialFetchError),
+// the update telling which providers have failed
+func (s *ServiceImpl) handleFetchResult(ctx context.Context, tracking *Tracking, fetchedTrackingInfos []*parcels_api.TrackingInfo, err error) (*TrackingUpdate, error) {
+	zapFields := TrackingFields(tracking)
+	s.observeFetch(err)
+	now := s.clock.Now()
+	nextPollAt := s.nextPollAt(now, tracking)
+	tracking.LastPolledAt = &now
+	tracking.NextPollAt = &nextPollAt
+
+	if errors.Is(err, ErrNotModified) {
+		s.logger.Debug("tracking info is not modified", zapFields...)
+		s.updatePollSchedule(ctx, tracking)
+		return nil, nil
+	}
+	var partialErr *PartialFetchError
+	if errors.As(err, &partialErr) {
+		s.logger.Warn("failed to fetch tracking info from some providers", append(zapFields, zaperr.ToField(err))...)
+		// what the failed providers told before is all we know of them, until they are back
+		fetched := make(map[string]bool, len(fetchedTrackingInfos))
+		for _, info := range fetchedTrackingInfos {
+			fetched[info.ApiName] = true
+		}
+		for _, info := range tracking.TrackingInfos {
+			if !fetched[info.ApiName] {
+				fetchedTrackingInfos = append(fetchedTrackingInfos, info)
+			}
+		}
+		trackingUpdate, err := s.applyTrackingInfos(ctx, tracking, fetchedTrackingInfos)
+		if trackingUpdate != nil {
+			trackingUpdate.FailedProviders = partialErr.Failed
+		}
+		return trackingUpdate, err
+	}
+	if err != nil {
+		s.logger.Error("failed to fetch tracking info", append(zapFields, zaperr.ToField(err))...)
+		s.updatePollSchedule(ctx, tracking)
+		return nil, err
+	}
+
+	return s.applyTrackingInfos(ctx, tracking, fetchedTrackingInfos)
+}
+
+// applyTrackingInfos diffs fetched tracking infos against the tracking and saves them if anything changed.
+// It returns nil update if tracking info is up to date
+func (s *ServiceImpl) applyTrackingInfos(ctx context.Context, tracking *Tracking, fetchedTrackingInfos []*parcels_api.TrackingInfo) (*TrackingUpdate, error) {
+	zapFields := TrackingFields(tracking)
 
-	trackingUpdate := s.getTrackingUpdate(tracking.TrackingInfos, fetchedTrackingInfos)
+	var err error
+	existingTrackingInfos := tracking.TrackingInfos
+	trackingUpdate := s.getTrackingUpdate(tracking, fetchedTrackingInfos)
 	if trackingUpdate == nil {
 		s.logger.Debug("tracking info is up to date", zapFields...)
-		return
+		s.updatePollSchedule(ctx, tracking)
+		return nil, nil
 	}
 
 	s.logger.Debug("tracking infos changed", append([]zap.Field{
-		zap.Any("existing_tracking_infos", tracking.TrackingInfos),
+		zap.Any("existing_tracking_infos", existingTrackingInfos),
 		zap.Any("fetched_tracking_infos", fetchedTrackingInfos),
 	}, zapFields...)...)
+	wasDelivered := tracking.Status() == StatusDelivered
 	tracking.TrackingInfos = fetchedTrackingInfos
-	now := time.Now()
-	tracking.LastPolledAt = &now
-	if _, err := s.storage.SaveTracking(ctx, tracking); err != nil {
+	trackingUpdate.TrackingNumber = tracking.TrackingNumber
+	trackingUpdate.UserID = tracking.UserID
+	trackingUpdate.DisplayName = tracking.DisplayName
+	trackingUpdate.Statuses = newStatuses(existingTrackingInfos, fetchedTrackingInfos)
+	trackingUpdate.Milestones = milestonesOf(trackingUpdate.Statuses)
+	if trackingUpdate.ETA, err = s.eta.Estimate(ctx, tracking); err != nil {
+		s.logger.Error("failed to estimate delivery", append(zapFields, zaperr.ToField(err))...)
+	}
+	// the tracking may have been deleted since it was loaded, and saving it would bring it back
+	if err := s.checkTrackingExists(ctx, tracking); err != nil {
+		return nil, err
+	}
+	if batch := pollBatchOf(ctx); batch != nil {
+		batch.add(&TrackingSave{Tracking: tracking, Update: trackingUpdate}, func(ctx context.Context) {
+			s.recordDelivery(ctx, tracking, wasDelivered)
+		})
+		return trackingUpdate, nil
+	}
+	if _, err := s.storage.SaveTrackingUpdate(ctx, tracking, trackingUpdate); err != nil {
 		zapFields := append(zapFields, zaperr.ToField(err))
 		s.logger.Error("failed to update tracking", zapFields...)
+		return nil, zaperr.Wrap(err, "failed to update tracking", zapFields...)
+	}
+	s.recordDelivery(ctx, tracking, wasDelivered)
+
+	return trackingUpdate, nil
+}
+
+// recordDelivery records delivery of the saved tracking for ETA estimates, if it has just been delivered
+func (s *ServiceImpl) recordDelivery(ctx context.Context, tracking *Tracking, wasDelivered bool) {
+	if wasDelivered || tracking.Status() != StatusDelivered {
 		return
 	}
+	if err := s.eta.RecordDelivery(ctx, tracking); err != nil {
+		s.logger.Error("failed to record delivery", append(TrackingFields(tracking), zaperr.ToField(err))...)
+	}
+}
 
-	trackingUpdate.TrackingNumber = tracking.TrackingNumber
-	trackingUpdate.UserID = tracking.UserID
-	trackingUpdate.DisplayName = tracking.DisplayName
-	s.updatesChan <- *trackingUpdate
+// checkTrackingExists returns ErrTrackingNotFound if the tracking has been deleted, re-tracking a deleted number
+// may make a new tracking of it, which doesn't count
+func (s *ServiceImpl) checkTrackingExists(ctx context.Context, tracking *Tracking) error {
+	existing, err := s.storage.GetTracking(ctx, tracking.UserID, tracking.TrackingNumber)
+	if errors.Is(err, ErrTrackingNotFound) || (err == nil && existing.ID != tracking.ID) {
+		s.logger.Debug("tracking deleted before its update is saved", TrackingFields(tracking)...)
+		return ErrTrackingNotFound
+	}
+	if err != nil {
+		return zaperr.Wrap(err, "failed to check if tracking exists", TrackingFields(tracking)...)
+	}
+	return nil
 }
 
-// poll polls all trackings that were last polled before the polling duration
-func (s *ServiceImpl) poll(ctx context.Context) {
-	s.logger.Debug("polling")
-	trackings, err := s.storage.ListTrackingsLastPolledBefore(ctx, time.Now().Add(-s.pollingDuration))
+// redeliverPendingUpdates publishes updates that were saved, but never confirmed as delivered,
+// e.g. because the process died before the user was notified
+func (s *ServiceImpl) redeliverPendingUpdates(ctx context.Context) {
+	updates, err := s.storage.ListPendingNotifications(ctx)
 	if err != nil {
-		s.logger.Error("polling failed", zaperr.ToField(err))
+		s.logger.Error("failed to list pending notifications", zaperr.ToField(err))
 		return
 	}
-	s.logger.Info("polling", zap.Int("trackings_count", len(trackings)))
-	for _, tracking := range trackings {
-		s.fetchTrackingInfo(ctx, tracking, false)
+	if len(updates) > 0 {
+		s.logger.Info("redelivering pending updates", zap.Int("updates_count", len(updates)))
+	}
+	for _, update := range updates {
+		s.publishTrackingUpdate(ctx, *update)
+	}
+}
+
+func (s *ServiceImpl) updatePollSchedule(ctx context.Context, tracking *Tracking) {
+	if batch := pollBatchOf(ctx); batch != nil {
+		batch.add(&TrackingSave{Tracking: tracking}, nil)
+		return
+	}
+	if err := s.storage.UpdatePollSchedule(ctx, tracking); err != nil {
+		s.logger.Error("failed to update poll schedule", append(TrackingFields(tracking), zaperr.ToField(err))...)
 	}
 }
 
-func (s *ServiceImpl) getTrackingUpdate(
-	existing []*parcels_api.TrackingInfo,
-	fetched []*parcels_api.TrackingInfo,
-) *TrackingUpdate {
-	if len(existing) == 0 {
-		return &TrackingUpdate{
-			NewTrackingInfos: fetched,
+// nextPollAt schedules the next poll roughly polling duration of the tracking from now, see pollingDurationOf.
+// Every tracking gets its own random offset, so that polls are spread evenly over time
+// instead of happening all at once on every tick
+func (s *ServiceImpl) nextPollAt(now time.Time, tracking *Tracking) time.Time {
+	pollingDuration := s.pollingDurationOf(tracking)
+	if tracking.PushSubscribed {
+		pollingDuration *= pushPollingFactor
+	}
+	jitter := time.Duration(float64(pollingDuration) * pollingJitter * (2*rand.Float64() - 1))
+	return now.Add(pollingDuration + jitter)
+}
+
+// pollingDurationOf is how often the tracking is polled. Carriers update at their own pace, so polling durations
+// can be set per API name of the tracking infos (e.g. "cainiao") or per carrier of the tracking
+// (e.g. "upu", see Tracking.Carrier). The API names win, since they are what we actually get updates from,
+// and the shortest of their durations wins, not to miss updates of the most frequently updated one
+func (s *ServiceImpl) pollingDurationOf(tracking *Tracking) time.Duration {
+	if len(s.carrierPollingDurations) == 0 {
+		return s.pollingDuration
+	}
+	var shortest time.Duration
+	for _, info := range tracking.TrackingInfos {
+		if d, ok := s.carrierPollingDurations[info.ApiName]; ok && (shortest == 0 || d < shortest) {
+			shortest = d
 		}
 	}
+	if shortest > 0 {
+		return shortest
+	}
+	if carrier := tracking.Carrier(); carrier != nil {
+		if d, ok := s.carrierPollingDurations[carrier.Code]; ok {
+			return d
+		}
+	}
+	return s.pollingDuration
+}
 
-	result := &TrackingUpdate{}
+// shortestPollingDuration is the shortest of the polling durations of all carriers, the default one included
+func (s *ServiceImpl) shortestPollingDuration() time.Duration {
+	shortest := s.pollingDuration
+	for _, d := range s.carrierPollingDurations {
+		if d < shortest {
+			shortest = d
+		}
+	}
+	return shortest
+}
 
-	for _, fetchedTrackingInfo := range fetched {
-		for _, existingTrackingInfo := range existing { // yes, O(n^2) but we don't expect a lot of tracking infos
-			if fetchedTrackingInfo.ApiName != existingTrackingInfo.ApiName {
-				continue // not a matching existing tracking info, keep looking
+// subscribeToPushUpdates asks providers to push updates of the tracking to us, if push updates are enabled.
+// Trackings that couldn't be subscribed are just polled as usual
+func (s *ServiceImpl) subscribeToPushUpdates(ctx context.Context, tracking *Tracking) {
+	if s.pushSubscriber == nil || tracking.PushSubscribed {
+		return
+	}
+	zapFields := TrackingFields(tracking)
+
+	err := s.pushSubscriber.Subscribe(ctx, tracking.TrackingNumber, tracking.Carrier(), tracking.Params)
+	if errors.Is(err, ErrPushNotSupported) {
+		s.logger.Debug("push updates are not supported for tracking", zapFields...)
+		return
+	}
+	if err != nil {
+		s.logger.Error("failed to subscribe to push updates", append(zapFields, zaperr.ToField(err))...)
+		return
+	}
+
+	if err := s.storage.SetPushSubscribed(ctx, tracking.ID, true); err != nil {
+		s.logger.Error("failed to save push subscription", append(zapFields, zaperr.ToField(err))...)
+		return
+	}
+	s.logger.Info("subscribed to push updates", zapFields...)
+}
+
+// RefreshTrackingNumber fetches fresh tracking info for all trackings of the number, regardless of their users
+// and schedules, and publishes updates just like polling does. It returns how many trackings were refreshed
+func (s *ServiceImpl) RefreshTrackingNumber(ctx context.Context, trackingNumber string) (int, error) {
+	trackings, err := s.storage.ListTrackingsByTrackingNumber(ctx, trackingNumber)
+	if err != nil {
+		return 0, zaperr.Wrap(err, "failed to list trackings", TrackingNumberField(trackingNumber))
+	}
+	if len(trackings) == 0 {
+		return 0, ErrTrackingNotFound
+	}
+
+	for i, tracking := range trackings {
+		// only the first fetch bypasses caches, the rest get its result from them
+		fetchCtx := ctx
+		if i == 0 {
+			fetchCtx = WithSkipCache(ctx)
+		}
+		trackingUpdate, err := s.refreshTracking(fetchCtx, tracking)
+		if errors.Is(err, ErrTrackingNotFound) {
+			continue
+		}
+		if err != nil {
+			return i, err
+		}
+		if trackingUpdate != nil {
+			s.publishTrackingUpdate(ctx, *trackingUpdate)
+		}
+	}
+	return len(trackings), nil
+}
+
+func (s *ServiceImpl) HandlePushedTrackingInfos(ctx context.Context, trackingNumber string, trackingInfos []*parcels_api.TrackingInfo) error {
+	trackings, err := s.storage.ListTrackingsByTrackingNumber(ctx, trackingNumber)
+	if err != nil {
+		return zaperr.Wrap(err, "failed to list trackings", TrackingNumberField(trackingNumber))
+	}
+	s.logger.Info("got pushed tracking infos", TrackingNumberField(trackingNumber), zap.Int("trackings_count", len(trackings)))
+
+	for _, tracking := range trackings {
+		trackingUpdate, err := s.applyTrackingInfos(ctx, tracking, mergeTrackingInfos(tracking.TrackingInfos, trackingInfos))
+		if errors.Is(err, ErrTrackingNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if trackingUpdate != nil {
+			s.publishTrackingUpdate(ctx, *trackingUpdate)
+		}
+	}
+	return nil
+}
+
+// poll polls all trackings that are due according to their schedule, loading them in batches
+func (s *ServiceImpl) poll(ctx context.Context) {
+	s.logger.Debug("polling")
+	startedAt, now := time.Now(), s.clock.Now()
+	defer s.pollStepStartedAt.Store(0)
+	polled := 0
+	// the most interesting trackings go first, so that they are polled even if polling gets cut short
+	var after *PollCursor
+	for {
+		s.pollStepStartedAt.Store(time.Now().UnixNano())
+		trackings, err := s.storage.ListTrackingsDueForPoll(ctx, now, after, pollBatchSize)
+		if err != nil {
+			s.metrics.observePoll(startedAt, polled, err)
+			s.logger.Error("polling failed", zaperr.ToField(err))
+			return
+		}
+		if len(trackings) == 0 {
+			break
+		}
+		// polling updates LastPolledAt and LastActivityAt, the cursor must be where the tracking was in the queue
+		next := PollCursorOf(trackings[len(trackings)-1])
+		fetched := s.fetchTrackingInfoBatches(s.fetchCtx, trackings)
+		// writes of the whole page are saved at once, see pollBatch
+		batch := newPollBatch()
+		batchCtx := withPollBatch(s.fetchCtx, batch)
+		for _, tracking := range trackings {
+			if ctx.Err() != nil {
+				break
 			}
-			if len(fetchedTrackingInfo.Events) == len(existingTrackingInfo.Events) {
-				goto nextFetched // matching tracking info but no new events, go to next fetched tracking info
+			s.pollStepStartedAt.Store(time.Now().UnixNano())
+			if infos, ok := fetched[tracking.TrackingNumber]; ok {
+				s.applyBatchResult(batchCtx, tracking, infos)
+			} else {
+				s.fetchTrackingInfo(batchCtx, tracking, false)
 			}
+			polled++
+		}
+		s.savePollBatch(s.fetchCtx, batch)
+		if len(trackings) < pollBatchSize || ctx.Err() != nil {
+			break
+		}
+		after = next
+	}
+	s.metrics.observePoll(startedAt, polled, nil)
+	s.logger.Info("polled", zap.Int("trackings_count", polled))
+}
 
-			// if we made it here, we have a matching tracking info with new events
-			// we now have to find the new events
-			for _, fetchedTrackingEvent := range fetchedTrackingInfo.Events {
-				found := false
-				for _, existingTrackingEvent := range existingTrackingInfo.Events {
-					sameStatus := fetchedTrackingEvent.Status == existingTrackingEvent.Status
-					sameTime := fetchedTrackingEvent.Time == existingTrackingEvent.Time
-					if sameStatus && sameTime {
-						found = true
-						break
-					}
-				}
-				if !found {
-					result.NewTrackingEvents = append(result.NewTrackingEvents, &fetchedTrackingEvent)
-				}
-			}
-			goto nextFetched // we found a matching tracking info, go to next fetched tracking info
+// fetchTrackingInfoBatches fetches tracking infos of the trackings in batches if the provider supports them.
+// Numbers of batches that have failed are left out, just like all of them if batches aren't supported,
+// so those have to be fetched one by one. Numbers the provider knows nothing about have nil tracking infos
+func (s *ServiceImpl) fetchTrackingInfoBatches(ctx context.Context, trackings []*Tracking) map[string][]*parcels_api.TrackingInfo {
+	provider, ok := s.provider.(BatchTrackingInfoProvider)
+	if !ok {
+		return nil
+	}
+	var trackingNumbers []string
+	seen := make(map[string]bool, len(trackings))
+	for _, tracking := range trackings {
+		// batches have no params, so the ones having them are fetched one by one
+		if !tracking.Params.IsZero() {
+			continue
+		}
+		if !seen[tracking.TrackingNumber] {
+			seen[tracking.TrackingNumber] = true
+			trackingNumbers = append(trackingNumbers, tracking.TrackingNumber)
 		}
+	}
 
-		// if we made it here, it means we `continue`d through all the existing tracking infos
-		// without finding a matching one, so we have to store the fetched one as a new tracking info
-		result.NewTrackingInfos = append(result.NewTrackingInfos, fetchedTrackingInfo)
+	fetched := make(map[string][]*parcels_api.TrackingInfo, len(trackingNumbers))
+	for start := 0; start < len(trackingNumbers); start += fetchBatchSize {
+		end := start + fetchBatchSize
+		if end > len(trackingNumbers) {
+			end = len(trackingNumbers)
+		}
+		batch := trackingNumbers[start:end]
+		results, err := provider.GetTrackingInfoBatch(ctx, batch)
+		if errors.Is(err, ErrBatchNotSupported) {
+			break
+		}
+		if err != nil {
+			s.logger.Warn("failed to fetch tracking info batch, fetching one by one", zap.Int("batch_size", len(batch)), zaperr.ToField(err))
+			continue
+		}
+		for _, trackingNumber := range batch {
+			fetched[trackingNumber] = results[trackingNumber]
+		}
+	}
+	return fetched
+}
+
+// applyBatchResult is fetchTrackingInfo for tracking infos fetched in a batch
+func (s *ServiceImpl) applyBatchResult(ctx context.Context, tracking *Tracking, trackingInfos []*parcels_api.TrackingInfo) {
+	s.fetchesInFlight.Add(1)
+	defer s.fetchesInFlight.Add(-1)
+	var err error
+	if len(trackingInfos) == 0 {
+		err = ErrNoTrackingInfo
+	}
+	// batches aren't conditional, so validators of the tracking don't identify what it will have anymore
+	tracking.Validators = Validators{}
+	fetch := s.fetches.start(ctx, tracking)
+	defer s.fetches.finish(tracking.ID, fetch)
+	trackingUpdate, err := s.handleFetchResultOf(fetch, tracking, trackingInfos, err)
+	s.publishRefresh(ctx, tracking, trackingUpdate, err, false)
+}
+
+// getTrackingUpdate diffs fetched tracking infos against what we've already seen for the tracking.
+// Tracking infos from APIs we haven't heard from before are reported whole,
+// for the rest only events with unseen content hashes are reported, unless another API has already reported them
+// (see MergeEvents). It records hashes of all fetched events in tracking.SeenEventHashes
+func (s *ServiceImpl) getTrackingUpdate(tracking *Tracking, fetched []*parcels_api.TrackingInfo) *TrackingUpdate {
+	knownApiNames := make(map[string]bool, len(tracking.TrackingInfos))
+	knownEvents := make(map[string]bool)
+	for _, info := range tracking.TrackingInfos {
+		knownApiNames[info.ApiName] = true
+		for _, e := range info.Events {
+			knownEvents[eventKey(e)] = true
+		}
+	}
+	seen := seenEventHashes(tracking)
+
+	result := &TrackingUpdate{}
+	for _, fetchedTrackingInfo := range fetched {
+		isNewInfo := !knownApiNames[fetchedTrackingInfo.ApiName]
+		if isNewInfo {
+			result.NewTrackingInfos = append(result.NewTrackingInfos, fetchedTrackingInfo)
+		}
+		for _, e := range fetchedTrackingInfo.Events {
+			h := EventHash(e)
+			if seen[h] {
+				continue
+			}
+			seen[h] = true
+			if !isNewInfo && !knownEvents[eventKey(e)] {
+				e := e
+				result.NewTrackingEvents = append(result.NewTrackingEvents, &e)
+			}
+		}
+	}
 
-	nextFetched: // jump to the next fetched tracking info without executing the code above
+	tracking.SeenEventHashes = make([]string, 0, len(seen))
+	for h := range seen {
+		tracking.SeenEventHashes = append(tracking.SeenEventHashes, h)
 	}
+	sort.Strings(tracking.SeenEventHashes)
 
 	if len(result.NewTrackingInfos) == 0 && len(result.NewTrackingEvents) == 0 {
 		return nil
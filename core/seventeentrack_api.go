@@ -0,0 +1,234 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/dir01/parcels/parcels_api"
+	"github.com/hori-ryota/zaperr"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+)
+
+const seventeenTrackAPIURL = "https://api.17track.net/track/v2.2"
+
+// 17track answers with this code for numbers that were never registered with it
+const seventeenTrackNotRegisteredCode = -18019902
+
+func NewSeventeenTrackAPI(apiKey string, httpClient *http.Client, rateLimiter *rate.Limiter, logger *zap.Logger) *SeventeenTrackAPI {
+	api := &SeventeenTrackAPI{
+		apiURL:      seventeenTrackAPIURL,
+		apiKey:      apiKey,
+		httpClient:  httpClient,
+		rateLimiter: rateLimiter,
+		logger:      logger,
+	}
+	var _ NamedTrackingInfoProvider = api
+	var _ PushSubscriber = api
+	return api
+}
+
+// SeventeenTrackAPI is a provider backed by 17track.net aggregator API.
+// 17track only tracks numbers that were registered with it first,
+// so the first request for a number registers it and reports ErrNoTrackingInfo:
+// data becomes available on the following polls.
+type SeventeenTrackAPI struct {
+	apiURL      string
+	apiKey      string
+	httpClient  *http.Client
+	rateLimiter *rate.Limiter
+	logger      *zap.Logger
+}
+
+type seventeenTrackResponse struct {
+	Code int `json:"code"`
+	Data struct {
+		Accepted []seventeenTrackItem `json:"accepted"`
+		Rejected []struct {
+			Number string `json:"number"`
+			Error  struct {
+				Code    int    `json:"code"`
+				Message string `json:"message"`
+			} `json:"error"`
+		} `json:"rejected"`
+	} `json:"data"`
+}
+
+type seventeenTrackItem struct {
+	Number    string `json:"number"`
+	TrackInfo struct {
+		LatestStatus struct {
+			Status string `json:"status"`
+		} `json:"latest_status"`
+		Tracking struct {
+			Providers []struct {
+				Provider struct {
+					Key  int    `json:"key"`
+					Name string `json:"name"`
+				} `json:"provider"`
+				Events []struct {
+					TimeISO     string `json:"time_iso"`
+					Description string `json:"description"`
+					Location    string `json:"location"`
+					Stage       string `json:"stage"`
+				} `json:"events"`
+			} `json:"providers"`
+		} `json:"tracking"`
+	} `json:"track_info"`
+}
+
+// seventeenTrackCarrierKeys maps carrier codes to 17track carrier keys,
+// carriers missing here are auto-detected by 17track itself
+var seventeenTrackCarrierKeys = map[string]int{
+	CarrierDHL:   100001,
+	CarrierUPS:   100002,
+	CarrierFedEx: 100003,
+	CarrierUSPS:  21051,
+}
+
+// Name is what users know 17track by, see NamedTrackingInfoProvider
+func (api *SeventeenTrackAPI) Name() string {
+	return "17TRACK"
+}
+
+// GetTrackingInfo registers unknown numbers with 17track, the postal code of params goes along as the additional
+// parameter 17track asks for with numbers of some carriers
+func (api *SeventeenTrackAPI) GetTrackingInfo(ctx context.Context, trackingNumber string, carrier *Carrier, params TrackingParams) ([]*parcels_api.TrackingInfo, error) {
+	carrierKey := 0
+	if carrier != nil {
+		carrierKey = seventeenTrackCarrierKeys[carrier.Code]
+	}
+
+	resp, err := api.call(ctx, "/gettrackinfo", trackingNumber, carrierKey, params)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, rejected := range resp.Data.Rejected {
+		if rejected.Error.Code != seventeenTrackNotRegisteredCode {
+			return nil, zaperr.New(
+				"17track rejected tracking number",
+				TrackingNumberField(trackingNumber),
+				zap.Int("code", rejected.Error.Code),
+				zap.String("message", rejected.Error.Message),
+			)
+		}
+		api.logger.Info("registering tracking number with 17track", TrackingNumberField(trackingNumber))
+		if _, err := api.call(ctx, "/register", trackingNumber, carrierKey, params); err != nil {
+			return nil, zaperr.Wrap(err, "failed to register tracking number with 17track")
+		}
+		return nil, ErrNoTrackingInfo
+	}
+
+	var trackingInfos []*parcels_api.TrackingInfo
+	for _, accepted := range resp.Data.Accepted {
+		trackingInfos = append(trackingInfos, accepted.trackingInfos()...)
+	}
+
+	if len(trackingInfos) == 0 {
+		return nil, ErrNoTrackingInfo
+	}
+
+	return trackingInfos, nil
+}
+
+func (item *seventeenTrackItem) trackingInfos() []*parcels_api.TrackingInfo {
+	var trackingInfos []*parcels_api.TrackingInfo
+	isDelivered := item.TrackInfo.LatestStatus.Status == "Delivered"
+	for _, provider := range item.TrackInfo.Tracking.Providers {
+		ti := &parcels_api.TrackingInfo{
+			TrackingNumber: item.Number,
+			ApiName:        "17track:" + provider.Provider.Name,
+			IsDelivered:    isDelivered,
+		}
+		// 17track lists events newest first, while the rest of the bot expects them in chronological order
+		for i := len(provider.Events) - 1; i >= 0; i-- {
+			e := provider.Events[i]
+			description := e.Description
+			if e.Location != "" {
+				description = e.Location + eventLocationSeparator + description
+			}
+			ti.Events = append(ti.Events, parcels_api.TrackingEvent{
+				Time:        e.TimeISO,
+				Description: description,
+				Status:      e.Stage,
+			})
+		}
+		if len(ti.Events) > 0 {
+			ti.LastUpdatedAt = ti.Events[len(ti.Events)-1].Time
+		}
+		trackingInfos = append(trackingInfos, ti)
+	}
+	return trackingInfos
+}
+
+func (api *SeventeenTrackAPI) call(ctx context.Context, path string, trackingNumber string, carrierKey int, params TrackingParams) (*seventeenTrackResponse, error) {
+	if err := api.rateLimiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+
+	item := map[string]any{"number": trackingNumber}
+	if carrierKey != 0 {
+		item["carrier"] = carrierKey
+	}
+	if params.PostalCode != "" {
+		item["param"] = params.PostalCode
+	}
+	reqBody, err := json.Marshal([]map[string]any{item})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", api.apiURL+path, bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("17token", api.apiKey)
+
+	resp, err := api.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &UpstreamError{StatusCode: resp.StatusCode}
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var parsed seventeenTrackResponse
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return nil, zaperr.Wrap(err, "failed to unmarshal 17track response", zap.String("body", string(body)))
+	}
+	if parsed.Code != 0 {
+		return nil, zaperr.New("17track responded with error", zap.Int("code", parsed.Code), zap.String("body", string(body)))
+	}
+
+	return &parsed, nil
+}
+
+// eventLocationSeparator separates locations of events from their descriptions, see EventLocation
+const eventLocationSeparator = ": "
+
+// maxEventLocationLength tells locations from descriptions that merely happen to contain the separator
+const maxEventLocationLength = 64
+
+// EventLocation is the place the event happened at, e.g. "Shenzhen, CN", if the provider told it.
+// Descriptions of events of other providers may contain the separator as well, so it is a best guess
+func EventLocation(e parcels_api.TrackingEvent) string {
+	location, _, found := strings.Cut(e.Description, eventLocationSeparator)
+	location = strings.TrimSpace(location)
+	if !found || location == "" || len(location) > maxEventLocationLength {
+		return ""
+	}
+	return location
+}
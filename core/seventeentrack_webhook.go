@@ -0,0 +1,102 @@
+package core
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/hori-ryota/zaperr"
+	"go.uber.org/zap"
+)
+
+// 17track answers with this code when registering a number that is already registered
+const seventeenTrackAlreadyRegisteredCode = -18019901
+
+// Subscribe registers the tracking number with 17track: it pushes updates of registered numbers
+// to the webhook URL configured in 17track dashboard, see WebhookHandler
+func (api *SeventeenTrackAPI) Subscribe(ctx context.Context, trackingNumber string, carrier *Carrier, params TrackingParams) error {
+	carrierKey := 0
+	if carrier != nil {
+		carrierKey = seventeenTrackCarrierKeys[carrier.Code]
+	}
+
+	resp, err := api.call(ctx, "/register", trackingNumber, carrierKey, params)
+	if err != nil {
+		return zaperr.Wrap(err, "failed to register tracking number with 17track")
+	}
+	for _, rejected := range resp.Data.Rejected {
+		if rejected.Error.Code != seventeenTrackAlreadyRegisteredCode {
+			return zaperr.New(
+				"17track rejected registration",
+				TrackingNumberField(trackingNumber),
+				zap.Int("code", rejected.Error.Code),
+				zap.String("message", rejected.Error.Message),
+			)
+		}
+	}
+	return nil
+}
+
+type seventeenTrackWebhook struct {
+	Event string             `json:"event"`
+	Data  seventeenTrackItem `json:"data"`
+}
+
+// WebhookHandler handles 17track webhooks, passing pushed tracking infos to onPush.
+// Webhooks are authenticated by the signature 17track computes with the API key
+func (api *SeventeenTrackAPI) WebhookHandler(onPush PushHandler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if !api.isValidWebhookSignature(body, r.Header.Get("sign")) {
+			api.logger.Warn("17track webhook with invalid signature", zap.String("remote_addr", r.RemoteAddr))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		var webhook seventeenTrackWebhook
+		if err := json.Unmarshal(body, &webhook); err != nil {
+			api.logger.Error("failed to unmarshal 17track webhook", zap.String("body", string(body)), zaperr.ToField(err))
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if webhook.Event != "TRACKING_UPDATED" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		trackingInfos := webhook.Data.trackingInfos()
+		if len(trackingInfos) > 0 {
+			if err := onPush(r.Context(), webhook.Data.Number, trackingInfos); err != nil {
+				api.logger.Error(
+					"failed to handle 17track webhook",
+					TrackingNumberField(webhook.Data.Number),
+					zaperr.ToField(err),
+				)
+				// 17track retries failed webhooks
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+func (api *SeventeenTrackAPI) isValidWebhookSignature(body []byte, sign string) bool {
+	sum := sha256.Sum256([]byte(string(body) + "/" + api.apiKey))
+	return hmac.Equal([]byte(hex.EncodeToString(sum[:])), []byte(sign))
+}
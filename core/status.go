@@ -0,0 +1,145 @@
+package core
+
+import (
+	"strings"
+	"time"
+
+	"github.com/dir01/parcels/parcels_api"
+)
+
+// Status is a canonical parcel status, independent of the provider that reported it
+type Status string
+
+const (
+	StatusUnknown            Status = "unknown"
+	StatusRegistered         Status = "registered"
+	StatusInTransit          Status = "in_transit"
+	StatusCustoms            Status = "customs"
+	StatusArrivedDestination Status = "arrived_destination"
+	StatusOutForDelivery     Status = "out_for_delivery"
+	StatusDelivered          Status = "delivered"
+	StatusReturned           Status = "returned"
+	StatusException          Status = "exception"
+)
+
+// IsTerminal tells whether a parcel in this status is not expected to move anymore
+func (s Status) IsTerminal() bool {
+	return s == StatusDelivered || s == StatusReturned
+}
+
+// statusProgress orders statuses by how far along the way the parcel is,
+// it is used to pick the overall status of a tracking with several tracking infos
+var statusProgress = map[Status]int{
+	StatusUnknown:            0,
+	StatusRegistered:         1,
+	StatusInTransit:          2,
+	StatusException:          3,
+	StatusCustoms:            4,
+	StatusArrivedDestination: 5,
+	StatusOutForDelivery:     6,
+	StatusReturned:           7,
+	StatusDelivered:          8,
+}
+
+const (
+	providerParcels        = "parcels"
+	providerSeventeenTrack = "17track"
+)
+
+// statusMappings maps provider-specific raw statuses to canonical ones, per provider
+var statusMappings = map[string]map[string]Status{
+	providerParcels: {
+		"SHIPMENT_INFO_RECEIVED":                   StatusRegistered,
+		"PACKAGING_COMPLETE":                       StatusRegistered,
+		"WMS_CONFIRMED":                            StatusRegistered,
+		"DISPATCHED_FROM_WAREHOUSE":                StatusInTransit,
+		"ARRIVED_AT_SORTING_CENTER":                StatusInTransit,
+		"ACCEPTED_BY_CARRIER":                      StatusInTransit,
+		"DEPARTED_FROM_SORTING_CENTER":             StatusInTransit,
+		"ARRIVED_AT_DEPARTURE_TRANSPORT_HUB":       StatusInTransit,
+		"TRANSIT_PORT_REROUTE_CALLBACK":            StatusInTransit,
+		"EXPORT_CUSTOMS_CLEARANCE_STARTED":         StatusInTransit,
+		"EXPORT_CUSTOMS_CLEARANCE_SUCCESS":         StatusInTransit,
+		"LEAVING_FROM_DEPARTURE_COUNTRY_OR_REGION": StatusInTransit,
+		"DEPARTED_ORIGIN_COUNTRY_OR_REGION":        StatusInTransit,
+		"ARRIVED_AT_LINEHAUL_OFFICE":               StatusInTransit,
+		"ARRIVED_AT_CUSTOMS":                       StatusCustoms,
+		"IMPORT_CUSTOMS_CLEARANCE_STARTED":         StatusCustoms,
+		"DEPARTED_FROM_CUSTOMS":                    StatusArrivedDestination,
+		"IMPORT_CUSTOMS_CLEARANCE_SUCCESS":         StatusArrivedDestination,
+		"DELIVERED":                                StatusDelivered,
+	},
+	providerSeventeenTrack: {
+		"InfoReceived":       StatusRegistered,
+		"PickedUp":           StatusInTransit,
+		"Departure":          StatusInTransit,
+		"Arrival":            StatusArrivedDestination,
+		"AvailableForPickup": StatusOutForDelivery,
+		"OutForDelivery":     StatusOutForDelivery,
+		"Delivered":          StatusDelivered,
+		"Returning":          StatusReturned,
+		"Returned":           StatusReturned,
+		"DeliveryFailure":    StatusException,
+		"Exception":          StatusException,
+	},
+}
+
+// providerOf figures out which provider a tracking info came from by its ApiName:
+// 17track infos are prefixed with "17track:", everything else comes from parcels service
+func providerOf(apiName string) string {
+	if strings.HasPrefix(apiName, providerSeventeenTrack+":") {
+		return providerSeventeenTrack
+	}
+	return providerParcels
+}
+
+// NormalizeStatus maps a raw status reported by the API to a canonical status
+func NormalizeStatus(apiName string, rawStatus string) Status {
+	if status, ok := statusMappings[providerOf(apiName)][rawStatus]; ok {
+		return status
+	}
+	return StatusUnknown
+}
+
+// TrackingInfoStatus is the status of the latest event of the tracking info
+func TrackingInfoStatus(info *parcels_api.TrackingInfo) Status {
+	if info.IsDelivered {
+		return StatusDelivered
+	}
+	for i := len(info.Events) - 1; i >= 0; i-- {
+		if status := NormalizeStatus(info.ApiName, info.Events[i].Status); status != StatusUnknown {
+			return status
+		}
+	}
+	return StatusUnknown
+}
+
+// Status is the most advanced of statuses reported by all tracking infos of the tracking
+func (t *Tracking) Status() Status {
+	result := StatusUnknown
+	for _, info := range t.TrackingInfos {
+		if status := TrackingInfoStatus(info); statusProgress[status] > statusProgress[result] {
+			result = status
+		}
+	}
+	return result
+}
+
+// DeliveredAt is when the tracking was delivered, ok is false unless it is delivered and the time is known.
+// Providers that flag infos as delivered without a delivery event are taken at their latest event
+func (t *Tracking) DeliveredAt() (at time.Time, ok bool) {
+	if t.Status() != StatusDelivered {
+		return time.Time{}, false
+	}
+	if at, ok := stagesReachedAt(t)[StatusDelivered]; ok {
+		return at, true
+	}
+	for _, info := range t.TrackingInfos {
+		for _, e := range info.Events {
+			if eventTime, parsed := ParseEventTime(e.Time); parsed && eventTime.After(at) {
+				at, ok = eventTime, true
+			}
+		}
+	}
+	return at, ok
+}
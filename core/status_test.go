@@ -0,0 +1,104 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/dir01/parcels/parcels_api"
+)
+
+func TestNormalizeStatus(t *testing.T) {
+	tests := []struct {
+		apiName   string
+		rawStatus string
+		want      Status
+	}{
+		{"cainiao", "SHIPMENT_INFO_RECEIVED", StatusRegistered},
+		{"cainiao", "PACKAGING_COMPLETE", StatusRegistered},
+		{"cainiao", "WMS_CONFIRMED", StatusRegistered},
+		{"cainiao", "DISPATCHED_FROM_WAREHOUSE", StatusInTransit},
+		{"cainiao", "ARRIVED_AT_SORTING_CENTER", StatusInTransit},
+		{"cainiao", "ACCEPTED_BY_CARRIER", StatusInTransit},
+		{"cainiao", "DEPARTED_FROM_SORTING_CENTER", StatusInTransit},
+		{"cainiao", "ARRIVED_AT_DEPARTURE_TRANSPORT_HUB", StatusInTransit},
+		{"cainiao", "TRANSIT_PORT_REROUTE_CALLBACK", StatusInTransit},
+		{"cainiao", "EXPORT_CUSTOMS_CLEARANCE_STARTED", StatusInTransit},
+		{"cainiao", "EXPORT_CUSTOMS_CLEARANCE_SUCCESS", StatusInTransit},
+		{"cainiao", "LEAVING_FROM_DEPARTURE_COUNTRY_OR_REGION", StatusInTransit},
+		{"cainiao", "DEPARTED_ORIGIN_COUNTRY_OR_REGION", StatusInTransit},
+		{"cainiao", "ARRIVED_AT_LINEHAUL_OFFICE", StatusInTransit},
+		{"cainiao", "ARRIVED_AT_CUSTOMS", StatusCustoms},
+		{"cainiao", "IMPORT_CUSTOMS_CLEARANCE_STARTED", StatusCustoms},
+		{"cainiao", "DEPARTED_FROM_CUSTOMS", StatusArrivedDestination},
+		{"cainiao", "IMPORT_CUSTOMS_CLEARANCE_SUCCESS", StatusArrivedDestination},
+		{"cainiao", "DELIVERED", StatusDelivered},
+		{"cainiao", "SOMETHING_NEW", StatusUnknown},
+		{"cainiao", "", StatusUnknown},
+		// raw statuses are mapped per provider, so 17track statuses mean nothing to parcels service infos
+		{"cainiao", "Delivered", StatusUnknown},
+
+		{"17track:usps", "InfoReceived", StatusRegistered},
+		{"17track:usps", "PickedUp", StatusInTransit},
+		{"17track:usps", "Departure", StatusInTransit},
+		{"17track:usps", "Arrival", StatusArrivedDestination},
+		{"17track:usps", "AvailableForPickup", StatusOutForDelivery},
+		{"17track:usps", "OutForDelivery", StatusOutForDelivery},
+		{"17track:usps", "Delivered", StatusDelivered},
+		{"17track:usps", "Returning", StatusReturned},
+		{"17track:usps", "Returned", StatusReturned},
+		{"17track:usps", "DeliveryFailure", StatusException},
+		{"17track:usps", "Exception", StatusException},
+		{"17track:usps", "Expired", StatusUnknown},
+		{"17track:usps", "", StatusUnknown},
+		{"17track:usps", "DELIVERED", StatusUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.apiName+"/"+tt.rawStatus, func(t *testing.T) {
+			if got := NormalizeStatus(tt.apiName, tt.rawStatus); got != tt.want {
+				t.Errorf("NormalizeStatus(%q, %q) = %q, want %q", tt.apiName, tt.rawStatus, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrackingStatus(t *testing.T) {
+	tests := []struct {
+		name  string
+		infos []*parcels_api.TrackingInfo
+		want  Status
+	}{
+		{
+			name: "no tracking infos",
+			want: StatusUnknown,
+		},
+		{
+			name: "latest known status wins",
+			infos: []*parcels_api.TrackingInfo{{ApiName: "cainiao", Events: []parcels_api.TrackingEvent{
+				{Status: "ACCEPTED_BY_CARRIER"},
+				{Status: "ARRIVED_AT_CUSTOMS"},
+				{Status: "SOMETHING_NEW"},
+			}}},
+			want: StatusCustoms,
+		},
+		{
+			name:  "delivered flag wins over events",
+			infos: []*parcels_api.TrackingInfo{{ApiName: "cainiao", IsDelivered: true}},
+			want:  StatusDelivered,
+		},
+		{
+			name: "most advanced of tracking infos wins",
+			infos: []*parcels_api.TrackingInfo{
+				{ApiName: "cainiao", Events: []parcels_api.TrackingEvent{{Status: "ARRIVED_AT_CUSTOMS"}}},
+				{ApiName: "17track:usps", Events: []parcels_api.TrackingEvent{{Status: "OutForDelivery"}}},
+			},
+			want: StatusOutForDelivery,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracking := &Tracking{TrackingInfos: tt.infos}
+			if got := tracking.Status(); got != tt.want {
+				t.Errorf("Status() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/dir01/tg-parcels/core"
+)
+
+func (s *Storage) CountTrackings(ctx context.Context) (_ *core.TrackingCounts, err error) {
+	defer s.metrics.Observe("count_trackings", time.Now(), &err)
+	ctx, cancel := WithQueryTimeout(ctx, s.queryTimeout)
+	defer cancel()
+	counts := &core.TrackingCounts{}
+	err = s.readDB.QueryRowContext(ctx, `
+		SELECT
+			COALESCE(SUM(deleted_at IS NULL AND NOT delivered), 0),
+			COALESCE(SUM(deleted_at IS NULL AND delivered), 0),
+			COALESCE(SUM(deleted_at IS NOT NULL), 0)
+		FROM (
+			SELECT t.deleted_at, EXISTS (
+				SELECT 1 FROM tracking_infos i WHERE i.tracking_id = t.id AND i.is_delivered
+			) AS delivered
+			FROM trackings t
+		)`,
+	).Scan(&counts.Active, &counts.Delivered, &counts.Deleted)
+	if err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+func (s *Storage) ListTrackingsPerUser(ctx context.Context, limit int) (_ []core.UserTrackingsCount, err error) {
+	defer s.metrics.Observe("list_trackings_per_user", time.Now(), &err)
+	ctx, cancel := WithQueryTimeout(ctx, s.queryTimeout)
+	defer cancel()
+	var rows []struct {
+		UserID int64 `db:"user_id"`
+		Count  int   `db:"count"`
+	}
+	err = s.readDB.SelectContext(ctx, &rows, `
+		SELECT user_id, COUNT(*) AS count FROM trackings WHERE deleted_at IS NULL
+		GROUP BY user_id ORDER BY count DESC, user_id LIMIT ?`, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make([]core.UserTrackingsCount, 0, len(rows))
+	for _, r := range rows {
+		counts = append(counts, core.UserTrackingsCount{UserID: r.UserID, Count: r.Count})
+	}
+	return counts, nil
+}
+
+func (s *Storage) CountEventsByAPI(ctx context.Context) (_ []core.APIEventsCount, err error) {
+	defer s.metrics.Observe("count_events_by_api", time.Now(), &err)
+	ctx, cancel := WithQueryTimeout(ctx, s.queryTimeout)
+	defer cancel()
+	var rows []struct {
+		ApiName string `db:"api_name"`
+		Count   int    `db:"count"`
+	}
+	err = s.readDB.SelectContext(ctx, &rows, `
+		SELECT i.api_name, COUNT(*) AS count
+		FROM tracking_events e
+		JOIN tracking_infos i ON e.tracking_info_id = i.id
+		JOIN trackings t ON i.tracking_id = t.id
+		WHERE t.deleted_at IS NULL
+		GROUP BY i.api_name ORDER BY count DESC, i.api_name`,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make([]core.APIEventsCount, 0, len(rows))
+	for _, r := range rows {
+		counts = append(counts, core.APIEventsCount{ApiName: r.ApiName, Count: r.Count})
+	}
+	return counts, nil
+}
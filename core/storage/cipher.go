@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strings"
+
+	"github.com/hori-ryota/zaperr"
+)
+
+// encryptedPrefix marks encrypted values, values without it were written before encryption was enabled
+const encryptedPrefix = "enc1:"
+
+var ErrEncryptionKeyRequired = errors.New("database contains encrypted data, but no encryption key is configured")
+
+// NewCipher creates AES-GCM cipher, key must be 16, 24 or 32 bytes long
+func NewCipher(key []byte) (*Cipher, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, zaperr.Wrap(err, "failed to create AES cipher")
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, zaperr.Wrap(err, "failed to create GCM")
+	}
+	return &Cipher{aead: aead}, nil
+}
+
+// Cipher encrypts personal data (display names, event descriptions, notifications, audit details,
+// notification destinations) at rest.
+// Nil Cipher stores everything as is. Plaintext values are still read fine once encryption is enabled,
+// they get encrypted the next time they are written
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+// Encrypt and Decrypt are for storages of other packages, which keep personal data too
+func (c *Cipher) Encrypt(plaintext string) (string, error) {
+	return c.encrypt(plaintext)
+}
+
+func (c *Cipher) Decrypt(stored string) (string, error) {
+	return c.decrypt(stored)
+}
+
+func (c *Cipher) encrypt(plaintext string) (string, error) {
+	if c == nil || plaintext == "" {
+		return plaintext, nil
+	}
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", zaperr.Wrap(err, "failed to generate nonce")
+	}
+	sealed := c.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (c *Cipher) decrypt(stored string) (string, error) {
+	if !strings.HasPrefix(stored, encryptedPrefix) {
+		return stored, nil
+	}
+	if c == nil {
+		return "", ErrEncryptionKeyRequired
+	}
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(stored, encryptedPrefix))
+	if err != nil {
+		return "", zaperr.Wrap(err, "failed to decode encrypted value")
+	}
+	nonceSize := c.aead.NonceSize()
+	if len(sealed) < nonceSize {
+		return "", errors.New("encrypted value is too short")
+	}
+	plaintext, err := c.aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], nil)
+	if err != nil {
+		return "", zaperr.Wrap(err, "failed to decrypt value, is the encryption key right?")
+	}
+	return string(plaintext), nil
+}
+
+func (c *Cipher) encryptBytes(plaintext []byte) ([]byte, error) {
+	encrypted, err := c.encrypt(string(plaintext))
+	return []byte(encrypted), err
+}
+
+func (c *Cipher) decryptBytes(stored []byte) ([]byte, error) {
+	plaintext, err := c.decrypt(string(stored))
+	return []byte(plaintext), err
+}
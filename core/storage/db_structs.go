@@ -14,31 +14,101 @@ type dbStruct struct {
 	TrackingNumber string `db:"tracking_number"`
 	DisplayName    string `db:"display_name"`
 	LastPolledAt   *int64 `db:"last_polled_at"`
-	Payload        []byte `db:"payload"`
+	NextPollAt     *int64 `db:"next_poll_at"`
+	// SeenEventHashes is a JSON array of hashes
+	SeenEventHashes []byte `db:"seen_event_hashes"`
+	PushSubscribed  bool   `db:"push_subscribed"`
+	// FetchETag and FetchLastModified are core.Validators
+	FetchETag         string `db:"fetch_etag"`
+	FetchLastModified string `db:"fetch_last_modified"`
+	// Manual and ExpectedAt are written by SaveTracking for created trackings, Manual is cleared by AttachTrackingNumber
+	Manual     bool   `db:"manual"`
+	ExpectedAt *int64 `db:"expected_at"`
+	// PostalCode is core.TrackingParams, written by SaveTracking for created trackings and re-tracked ones.
+	// It is encrypted just like DisplayName, being personal data
+	PostalCode string `db:"postal_code"`
+	// Carrier is core.TrackingParams too, it is written just like PostalCode
+	Carrier string `db:"carrier"`
+	// RemindedAt is only written by SetRemindedAt
+	RemindedAt *int64 `db:"reminded_at"`
+	// Note is only written by SetTrackingNote, see saveTracking
+	Note string `db:"note"`
+	// StuckAlertedAt is only written by SetStuckAlertedAt
+	StuckAlertedAt *int64 `db:"stuck_alerted_at"`
+	// LastActivityAt is only written by SaveTracking, for created trackings, SaveTrackingUpdate and SaveTrackings
+	LastActivityAt *int64 `db:"last_activity_at"`
+	// DeliveryDisputedAt is only written by SetDeliveryDisputedAt
+	DeliveryDisputedAt *int64 `db:"delivery_disputed_at"`
+	// NotificationLevel is only written by SetTrackingNotificationLevel
+	NotificationLevel string `db:"notification_level"`
+	// DeletedAt is set for soft-deleted trackings, which are excluded from all queries but restoring
+	DeletedAt *int64 `db:"deleted_at"`
 }
 
-func (d dbStruct) fromBusinessStruct(t *core.Tracking) (*dbStruct, error) {
-	payload, err := json.Marshal(t.TrackingInfos)
-	if err != nil {
-		return nil, err
+// fromBusinessStruct converts the tracking itself, tracking infos are converted separately, see trackingInfoDBStruct
+func (d dbStruct) fromBusinessStruct(t *core.Tracking, c *Cipher) (*dbStruct, error) {
+	if len(t.SeenEventHashes) > 0 {
+		seenEventHashes, err := json.Marshal(t.SeenEventHashes)
+		if err != nil {
+			return nil, err
+		}
+		d.SeenEventHashes = seenEventHashes
 	}
-	d.Payload = payload
 	d.ID = t.ID
 	d.UserID = t.UserID
-	d.DisplayName = t.DisplayName
+	displayName, err := c.encrypt(t.DisplayName)
+	if err != nil {
+		return nil, err
+	}
+	d.DisplayName = displayName
+	postalCode, err := c.encrypt(t.Params.PostalCode)
+	if err != nil {
+		return nil, err
+	}
+	d.PostalCode = postalCode
+	d.Carrier = t.Params.Carrier
 	d.TrackingNumber = t.TrackingNumber
+	d.PushSubscribed = t.PushSubscribed
+	d.FetchETag = t.Validators.ETag
+	d.FetchLastModified = t.Validators.LastModified
+	d.Manual = t.Manual
+	if t.ExpectedAt != nil {
+		expectedAt := t.ExpectedAt.Unix()
+		d.ExpectedAt = &expectedAt
+	}
 	if t.LastPolledAt != nil {
 		lastPolledAt := t.LastPolledAt.Unix()
 		d.LastPolledAt = &lastPolledAt
 	}
+	if t.NextPollAt != nil {
+		nextPollAt := t.NextPollAt.Unix()
+		d.NextPollAt = &nextPollAt
+	}
 	return &d, nil
 }
 
-func (d dbStruct) toBusinessStruct() (*core.Tracking, error) {
-	var trackingInfos []*parcels_api.TrackingInfo
-	err := json.Unmarshal(d.Payload, &trackingInfos)
+// toBusinessStruct converts the tracking itself, tracking infos have to be loaded separately
+func (d dbStruct) toBusinessStruct(c *Cipher) (*core.Tracking, error) {
+	var seenEventHashes []string
+	if len(d.SeenEventHashes) > 0 {
+		if err := json.Unmarshal(d.SeenEventHashes, &seenEventHashes); err != nil {
+			return nil, err
+		}
+	}
+
+	displayName, err := c.decrypt(d.DisplayName)
 	if err != nil {
-		// this is OK, we just don't have any tracking infos
+		return nil, err
+	}
+
+	note, err := c.decrypt(d.Note)
+	if err != nil {
+		return nil, err
+	}
+
+	postalCode, err := c.decrypt(d.PostalCode)
+	if err != nil {
+		return nil, err
 	}
 
 	var t *time.Time = nil
@@ -47,12 +117,269 @@ func (d dbStruct) toBusinessStruct() (*core.Tracking, error) {
 		t = &nt
 	}
 
+	var nextPollAt *time.Time = nil
+	if d.NextPollAt != nil {
+		nt := time.Unix(*d.NextPollAt, 0)
+		nextPollAt = &nt
+	}
+
+	var stuckAlertedAt *time.Time = nil
+	if d.StuckAlertedAt != nil {
+		at := time.Unix(*d.StuckAlertedAt, 0)
+		stuckAlertedAt = &at
+	}
+
+	var lastActivityAt *time.Time = nil
+	if d.LastActivityAt != nil {
+		at := time.Unix(*d.LastActivityAt, 0)
+		lastActivityAt = &at
+	}
+
+	var deliveryDisputedAt *time.Time = nil
+	if d.DeliveryDisputedAt != nil {
+		at := time.Unix(*d.DeliveryDisputedAt, 0)
+		deliveryDisputedAt = &at
+	}
+
+	var expectedAt *time.Time = nil
+	if d.ExpectedAt != nil {
+		at := time.Unix(*d.ExpectedAt, 0)
+		expectedAt = &at
+	}
+
+	var remindedAt *time.Time = nil
+	if d.RemindedAt != nil {
+		at := time.Unix(*d.RemindedAt, 0)
+		remindedAt = &at
+	}
+
 	return &core.Tracking{
+		ID:                 d.ID,
+		UserID:             d.UserID,
+		TrackingNumber:     d.TrackingNumber,
+		DisplayName:        displayName,
+		LastPolledAt:       t,
+		NextPollAt:         nextPollAt,
+		SeenEventHashes:    seenEventHashes,
+		PushSubscribed:     d.PushSubscribed,
+		Note:               note,
+		StuckAlertedAt:     stuckAlertedAt,
+		NotificationLevel:  core.NotificationLevel(d.NotificationLevel),
+		LastActivityAt:     lastActivityAt,
+		DeliveryDisputedAt: deliveryDisputedAt,
+		Validators:         core.Validators{ETag: d.FetchETag, LastModified: d.FetchLastModified},
+		Manual:             d.Manual,
+		ExpectedAt:         expectedAt,
+		RemindedAt:         remindedAt,
+		Params:             core.TrackingParams{PostalCode: postalCode, Carrier: d.Carrier},
+	}, nil
+}
+
+type trackingInfoDBStruct struct {
+	ID             int64  `db:"id"`
+	TrackingID     int64  `db:"tracking_id"`
+	Position       int    `db:"position"`
+	ApiName        string `db:"api_name"`
+	TrackingNumber string `db:"tracking_number"`
+	IsDelivered    bool   `db:"is_delivered"`
+	LastCheckedAt  string `db:"last_checked_at"`
+	LastUpdatedAt  string `db:"last_updated_at"`
+}
+
+type trackingEventDBStruct struct {
+	ID             int64  `db:"id"`
+	TrackingInfoID int64  `db:"tracking_info_id"`
+	Position       int    `db:"position"`
+	Time           string `db:"time"`
+	// OccurredAt is Time parsed with core.ParseEventTime as unix seconds, nil if it couldn't be parsed
+	OccurredAt  *int64 `db:"occurred_at"`
+	Description string `db:"description"`
+	Status      string `db:"status"`
+}
+
+func (d trackingInfoDBStruct) fromBusinessStruct(trackingID int64, position int, ti *parcels_api.TrackingInfo) *trackingInfoDBStruct {
+	d.TrackingID = trackingID
+	d.Position = position
+	d.ApiName = ti.ApiName
+	d.TrackingNumber = ti.TrackingNumber
+	d.IsDelivered = ti.IsDelivered
+	d.LastCheckedAt = ti.LastCheckedAt
+	d.LastUpdatedAt = ti.LastUpdatedAt
+	return &d
+}
+
+func (d trackingInfoDBStruct) toBusinessStruct(events []*trackingEventDBStruct, c *Cipher) (*parcels_api.TrackingInfo, error) {
+	ti := &parcels_api.TrackingInfo{
+		TrackingNumber: d.TrackingNumber,
+		ApiName:        d.ApiName,
+		IsDelivered:    d.IsDelivered,
+		LastCheckedAt:  d.LastCheckedAt,
+		LastUpdatedAt:  d.LastUpdatedAt,
+	}
+	for _, e := range events {
+		description, err := c.decrypt(e.Description)
+		if err != nil {
+			return nil, err
+		}
+		ti.Events = append(ti.Events, parcels_api.TrackingEvent{
+			Time:        e.Time,
+			Description: description,
+			Status:      e.Status,
+		})
+	}
+	return ti, nil
+}
+
+func (d trackingEventDBStruct) fromBusinessStruct(trackingInfoID int64, position int, e parcels_api.TrackingEvent, c *Cipher) (*trackingEventDBStruct, error) {
+	description, err := c.encrypt(e.Description)
+	if err != nil {
+		return nil, err
+	}
+	d.TrackingInfoID = trackingInfoID
+	d.Position = position
+	d.Time = e.Time
+	if t, ok := core.ParseEventTime(e.Time); ok {
+		occurredAt := t.Unix()
+		d.OccurredAt = &occurredAt
+	}
+	d.Description = description
+	d.Status = e.Status
+	return &d, nil
+}
+
+type notificationDBStruct struct {
+	ID      int64  `db:"id"`
+	UserID  int64  `db:"user_id"`
+	Payload []byte `db:"payload"`
+}
+
+// notificationPayload is what gets persisted of a TrackingUpdate, errors are never persisted
+type notificationPayload struct {
+	TrackingNumber    string                       `json:"tracking_number"`
+	DisplayName       string                       `json:"display_name"`
+	NewTrackingInfos  []*parcels_api.TrackingInfo  `json:"new_tracking_infos"`
+	NewTrackingEvents []*parcels_api.TrackingEvent `json:"new_tracking_events"`
+	ETA               *core.ETA                    `json:"eta,omitempty"`
+	Statuses          []core.Status                `json:"statuses,omitempty"`
+	Milestones        []core.Status                `json:"milestones,omitempty"`
+	FailedProviders   []string                     `json:"failed_providers,omitempty"`
+}
+
+func (d notificationDBStruct) fromBusinessStruct(u *core.TrackingUpdate, c *Cipher) (*notificationDBStruct, error) {
+	payload, err := json.Marshal(notificationPayload{
+		TrackingNumber:    u.TrackingNumber,
+		DisplayName:       u.DisplayName,
+		NewTrackingInfos:  u.NewTrackingInfos,
+		NewTrackingEvents: u.NewTrackingEvents,
+		ETA:               u.ETA,
+		Statuses:          u.Statuses,
+		Milestones:        u.Milestones,
+		FailedProviders:   u.FailedProviders,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if payload, err = c.encryptBytes(payload); err != nil {
+		return nil, err
+	}
+	d.ID = u.NotificationID
+	d.UserID = u.UserID
+	d.Payload = payload
+	return &d, nil
+}
+
+func (d notificationDBStruct) toBusinessStruct(c *Cipher) (*core.TrackingUpdate, error) {
+	rawPayload, err := c.decryptBytes(d.Payload)
+	if err != nil {
+		return nil, err
+	}
+	var payload notificationPayload
+	if err := json.Unmarshal(rawPayload, &payload); err != nil {
+		return nil, err
+	}
+	return &core.TrackingUpdate{
+		NotificationID:    d.ID,
+		TrackingNumber:    payload.TrackingNumber,
+		UserID:            d.UserID,
+		DisplayName:       payload.DisplayName,
+		NewTrackingInfos:  payload.NewTrackingInfos,
+		NewTrackingEvents: payload.NewTrackingEvents,
+		ETA:               payload.ETA,
+		Statuses:          payload.Statuses,
+		Milestones:        payload.Milestones,
+		FailedProviders:   payload.FailedProviders,
+	}, nil
+}
+
+type auditRecordDBStruct struct {
+	ID             int64  `db:"id"`
+	TrackingID     int64  `db:"tracking_id"`
+	UserID         int64  `db:"user_id"`
+	TrackingNumber string `db:"tracking_number"`
+	Action         string `db:"action"`
+	Details        string `db:"details"`
+	CreatedAt      int64  `db:"created_at"`
+}
+
+func (d auditRecordDBStruct) fromBusinessStruct(r *core.AuditRecord, c *Cipher) (*auditRecordDBStruct, error) {
+	details, err := c.encrypt(r.Details)
+	if err != nil {
+		return nil, err
+	}
+	d.ID = r.ID
+	d.TrackingID = r.TrackingID
+	d.UserID = r.UserID
+	d.TrackingNumber = r.TrackingNumber
+	d.Action = string(r.Action)
+	d.Details = details
+	d.CreatedAt = r.CreatedAt.Unix()
+	return &d, nil
+}
+
+func (d auditRecordDBStruct) toBusinessStruct(c *Cipher) (*core.AuditRecord, error) {
+	details, err := c.decrypt(d.Details)
+	if err != nil {
+		return nil, err
+	}
+	return &core.AuditRecord{
 		ID:             d.ID,
+		TrackingID:     d.TrackingID,
 		UserID:         d.UserID,
 		TrackingNumber: d.TrackingNumber,
-		DisplayName:    d.DisplayName,
-		LastPolledAt:   t,
-		TrackingInfos:  trackingInfos,
+		Action:         core.AuditAction(d.Action),
+		Details:        details,
+		CreatedAt:      time.Unix(d.CreatedAt, 0),
 	}, nil
 }
+
+type updateRecordDBStruct struct {
+	ID             int64  `db:"id"`
+	NotificationID int64  `db:"notification_id"`
+	TrackingID     int64  `db:"tracking_id"`
+	UserID         int64  `db:"user_id"`
+	TrackingNumber string `db:"tracking_number"`
+	// Payload is the payload of the notification, see notificationDBStruct
+	Payload     []byte `db:"payload"`
+	CreatedAt   int64  `db:"created_at"`
+	DeliveredAt *int64 `db:"delivered_at"`
+}
+
+func (d updateRecordDBStruct) toBusinessStruct(c *Cipher) (*core.UpdateRecord, error) {
+	update, err := notificationDBStruct{UserID: d.UserID, Payload: d.Payload}.toBusinessStruct(c)
+	if err != nil {
+		return nil, err
+	}
+	record := &core.UpdateRecord{
+		ID:             d.ID,
+		TrackingID:     d.TrackingID,
+		UserID:         d.UserID,
+		TrackingNumber: d.TrackingNumber,
+		Update:         update,
+		CreatedAt:      time.Unix(d.CreatedAt, 0),
+	}
+	if d.DeliveredAt != nil {
+		deliveredAt := time.Unix(*d.DeliveredAt, 0)
+		record.DeliveredAt = &deliveredAt
+	}
+	return record, nil
+}
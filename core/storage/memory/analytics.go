@@ -0,0 +1,95 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"github.com/dir01/tg-parcels/core"
+)
+
+func (s *Storage) CountTrackings(_ context.Context) (*core.TrackingCounts, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	counts := &core.TrackingCounts{}
+	for id, t := range s.trackings {
+		switch {
+		case isDeleted(s, id):
+			counts.Deleted++
+		case isDelivered(t):
+			counts.Delivered++
+		default:
+			counts.Active++
+		}
+	}
+	return counts, nil
+}
+
+func (s *Storage) ListTrackingsPerUser(_ context.Context, limit int) ([]core.UserTrackingsCount, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	byUser := make(map[int64]int)
+	for id, t := range s.trackings {
+		if !isDeleted(s, id) {
+			byUser[t.UserID]++
+		}
+	}
+	counts := make([]core.UserTrackingsCount, 0, len(byUser))
+	for userID, count := range byUser {
+		counts = append(counts, core.UserTrackingsCount{UserID: userID, Count: count})
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		return counts[i].UserID < counts[j].UserID
+	})
+	if len(counts) > limit {
+		counts = counts[:limit]
+	}
+	return counts, nil
+}
+
+func (s *Storage) CountEventsByAPI(_ context.Context) ([]core.APIEventsCount, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	byAPI := make(map[string]int)
+	for id, t := range s.trackings {
+		if isDeleted(s, id) {
+			continue
+		}
+		for _, info := range t.TrackingInfos {
+			if len(info.Events) > 0 {
+				byAPI[info.ApiName] += len(info.Events)
+			}
+		}
+	}
+	counts := make([]core.APIEventsCount, 0, len(byAPI))
+	for apiName, count := range byAPI {
+		counts = append(counts, core.APIEventsCount{ApiName: apiName, Count: count})
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		return counts[i].ApiName < counts[j].ApiName
+	})
+	return counts, nil
+}
+
+func isDeleted(s *Storage, trackingID int64) bool {
+	_, deleted := s.deletedAt[trackingID]
+	return deleted
+}
+
+// isDelivered mirrors the SQL storage: a tracking is delivered once any provider says so
+func isDelivered(t *core.Tracking) bool {
+	for _, info := range t.TrackingInfos {
+		if info.IsDelivered {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,819 @@
+// Package memory implements core.Storage in memory, for tests and demos.
+// It mirrors the behavior of the SQLite storage, but everything is lost once the process exits
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dir01/parcels/parcels_api"
+	"github.com/dir01/tg-parcels/core"
+)
+
+func NewStorage(clock core.Clock) *Storage {
+	s := &Storage{
+		clock:         clock,
+		trackings:     make(map[int64]*core.Tracking),
+		deletedAt:     make(map[int64]time.Time),
+		notifications: make(map[int64]*notification),
+		levels:        make(map[int64]core.NotificationLevel),
+	}
+	var _ core.Storage = s
+	var _ core.AnalyticsStorage = s
+	return s
+}
+
+// Storage keeps copies of everything it is given and hands out copies, just like a database would
+type Storage struct {
+	mu sync.Mutex
+	// clock is what activity and deletion times are recorded by, just like the database storage does
+	clock core.Clock
+
+	lastTrackingID int64
+	trackings      map[int64]*core.Tracking
+	// deletedAt holds soft-deleted trackings, see DeleteTracking
+	deletedAt map[int64]time.Time
+
+	lastNotificationID int64
+	notifications      map[int64]*notification
+
+	lastUpdateRecordID int64
+	updateRecords      []*updateRecord
+
+	lastAuditRecordID int64
+	auditRecords      []*core.AuditRecord
+
+	transitSamples        []*core.TransitSample
+	deliveryConfirmations []*core.DeliveryConfirmation
+
+	levels map[int64]core.NotificationLevel
+}
+
+type notification struct {
+	trackingID int64
+	update     core.TrackingUpdate
+}
+
+// updateRecord is a core.UpdateRecord along with the notification it is marked delivered by
+type updateRecord struct {
+	notificationID int64
+	record         core.UpdateRecord
+}
+
+// SaveTracking upserts the tracking, created tells whether the user wasn't tracking the number before,
+// re-tracking a deleted tracking restores it and counts as creating
+func (s *Storage) SaveTracking(_ context.Context, tracking *core.Tracking) (*core.Tracking, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing := s.findTracking(tracking.UserID, tracking.TrackingNumber, true)
+	created := existing == nil
+	if existing != nil {
+		_, created = s.deletedAt[existing.ID]
+	}
+	saved := s.saveTracking(tracking)
+	if created {
+		s.touchTracking(saved)
+	}
+	return saved, created, nil
+}
+
+func (s *Storage) SaveTrackingUpdate(_ context.Context, tracking *core.Tracking, update *core.TrackingUpdate) (*core.Tracking, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.saveTrackingUpdate(tracking, update), nil
+}
+
+// SaveTrackings saves polled trackings at once, leaving display names alone just like the SQLite storage does
+func (s *Storage) SaveTrackings(_ context.Context, saves []*core.TrackingSave) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, save := range saves {
+		existing, ok := s.trackings[save.Tracking.ID]
+		if _, deleted := s.deletedAt[save.Tracking.ID]; !ok || deleted {
+			save.Deleted = true
+			continue
+		}
+		if save.Update == nil {
+			s.updatePollSchedule(save.Tracking)
+			continue
+		}
+		displayName := existing.DisplayName
+		saved := s.saveTrackingUpdate(save.Tracking, save.Update)
+		existing.DisplayName = displayName
+		save.Tracking.LastActivityAt = saved.LastActivityAt
+	}
+	return nil
+}
+
+func (s *Storage) saveTrackingUpdate(tracking *core.Tracking, update *core.TrackingUpdate) *core.Tracking {
+	saved := s.saveTracking(tracking)
+	s.touchTracking(saved)
+
+	s.lastNotificationID++
+	update.NotificationID = s.lastNotificationID
+	n := &notification{trackingID: saved.ID, update: copyUpdate(update)}
+	// errors are never persisted
+	n.update.TrackingError = nil
+	n.update.ErrorCode = ""
+	s.notifications[n.update.NotificationID] = n
+
+	s.lastUpdateRecordID++
+	recorded := copyUpdate(&n.update)
+	recorded.NotificationID = 0
+	s.updateRecords = append(s.updateRecords, &updateRecord{
+		notificationID: n.update.NotificationID,
+		record: core.UpdateRecord{
+			ID:             s.lastUpdateRecordID,
+			TrackingID:     saved.ID,
+			UserID:         saved.UserID,
+			TrackingNumber: saved.TrackingNumber,
+			Update:         &recorded,
+			CreatedAt:      time.Unix(s.clock.Now().Unix(), 0),
+		},
+	})
+
+	return saved
+}
+
+// touchTracking records activity of the tracking, see core.Tracking.LastActivityAt
+func (s *Storage) touchTracking(saved *core.Tracking) {
+	now := time.Unix(s.clock.Now().Unix(), 0)
+	saved.LastActivityAt = &now
+	s.trackings[saved.ID].LastActivityAt = &now
+}
+
+// saveTracking upserts the tracking by user ID and tracking number. Tracking infos are only saved
+// for trackings that already exist, since re-tracking an existing number must not wipe what we know about it
+func (s *Storage) saveTracking(tracking *core.Tracking) *core.Tracking {
+	saved := copyTracking(tracking)
+	saved.LastPolledAt = truncate(saved.LastPolledAt)
+	saved.NextPollAt = truncate(saved.NextPollAt)
+
+	existing := s.findTracking(tracking.UserID, tracking.TrackingNumber, true)
+	switch {
+	case existing == nil:
+		s.lastTrackingID++
+		saved.ID = s.lastTrackingID
+		stored := copyTracking(saved)
+		stored.PushSubscribed = false
+		stored.Note = ""
+		stored.Tags = nil
+		stored.StuckAlertedAt = nil
+		stored.DeliveryDisputedAt = nil
+		stored.RemindedAt = nil
+		stored.ExpectedAt = truncate(saved.ExpectedAt)
+		stored.NotificationLevel = ""
+		stored.LastActivityAt = nil
+		if tracking.ID == 0 {
+			stored.TrackingInfos = nil
+		}
+		s.trackings[stored.ID] = stored
+	case tracking.ID == 0:
+		saved.ID = existing.ID
+		existing.DisplayName = tracking.DisplayName
+		existing.Manual = tracking.Manual
+		existing.ExpectedAt = truncate(tracking.ExpectedAt)
+		existing.Params = tracking.Params
+		delete(s.deletedAt, existing.ID)
+	default:
+		saved.ID = existing.ID
+		existing.LastPolledAt = saved.LastPolledAt
+		existing.NextPollAt = saved.NextPollAt
+		existing.SeenEventHashes = append([]string(nil), saved.SeenEventHashes...)
+		existing.DisplayName = saved.DisplayName
+		existing.TrackingInfos = copyTrackingInfos(saved.TrackingInfos)
+		existing.Validators = saved.Validators
+	}
+
+	return saved
+}
+
+func (s *Storage) GetTracking(_ context.Context, userID int64, trackingNumber string) (*core.Tracking, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := s.findTracking(userID, trackingNumber, false)
+	if t == nil {
+		return nil, core.ErrTrackingNotFound
+	}
+	return copyTracking(t), nil
+}
+
+func (s *Storage) ListTrackingsDueForPoll(_ context.Context, now time.Time, after *core.PollCursor, limit int) ([]*core.Tracking, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	trackings := s.list(func(t *core.Tracking) bool {
+		return !t.Manual && (t.NextPollAt == nil || t.NextPollAt.Unix() <= now.Unix()) &&
+			(after == nil || pollsBefore(after, core.PollCursorOf(t)))
+	})
+	sort.SliceStable(trackings, func(i, j int) bool {
+		return pollsBefore(core.PollCursorOf(trackings[i]), core.PollCursorOf(trackings[j]))
+	})
+	if len(trackings) > limit {
+		trackings = trackings[:limit]
+	}
+	return trackings, nil
+}
+
+// ListTrackingsByUserID lists user's trackings ordered by ID, starting after cursor (0 for the first page)
+func (s *Storage) ListTrackingsByUserID(_ context.Context, userID int64, cursor int64, limit int) (*core.TrackingsPage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.page(func(t *core.Tracking) bool { return t.UserID == userID }, cursor, limit), nil
+}
+
+// ListTrackingsByTag lists user's trackings tagged with the tag ordered by ID, starting after cursor (0 for the first page)
+func (s *Storage) ListTrackingsByTag(_ context.Context, userID int64, tag string, cursor int64, limit int) (*core.TrackingsPage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.page(func(t *core.Tracking) bool {
+		if t.UserID != userID {
+			return false
+		}
+		for _, tt := range t.Tags {
+			if tt == tag {
+				return true
+			}
+		}
+		return false
+	}, cursor, limit), nil
+}
+
+func (s *Storage) page(filter func(t *core.Tracking) bool, cursor int64, limit int) *core.TrackingsPage {
+	all := s.list(filter)
+	page := &core.TrackingsPage{Total: len(all)}
+	for _, t := range all {
+		if t.ID <= cursor {
+			continue
+		}
+		if len(page.Trackings) == limit {
+			page.NextCursor = page.Trackings[limit-1].ID
+			break
+		}
+		page.Trackings = append(page.Trackings, t)
+	}
+	return page
+}
+
+// ListTrackings lists up to limit trackings of all users ordered by ID, starting after afterID
+func (s *Storage) ListTrackings(_ context.Context, afterID int64, limit int) ([]*core.Tracking, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	trackings := s.list(func(t *core.Tracking) bool { return t.ID > afterID })
+	if len(trackings) > limit {
+		trackings = trackings[:limit]
+	}
+	return trackings, nil
+}
+
+func (s *Storage) ListTrackingsByTrackingNumber(_ context.Context, trackingNumber string) ([]*core.Tracking, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.list(func(t *core.Tracking) bool { return t.TrackingNumber == trackingNumber }), nil
+}
+
+func (s *Storage) CountTrackingsByUserID(_ context.Context, userID int64) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.list(func(t *core.Tracking) bool { return t.UserID == userID })), nil
+}
+
+// DeleteTracking soft-deletes the tracking: it is hidden from everything but RestoreTracking
+// until it is purged with PurgeDeletedTrackings
+func (s *Storage) DeleteTracking(_ context.Context, userID int64, trackingNumber string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := s.findTracking(userID, trackingNumber, false)
+	if t == nil {
+		return nil
+	}
+	s.deleteNotifications(func(n *notification) bool { return n.trackingID == t.ID })
+	s.deletedAt[t.ID] = s.clock.Now()
+	return nil
+}
+
+// RestoreTracking undoes DeleteTracking for trackings deleted after deletedAfter,
+// it returns core.ErrTrackingNotFound if there's no such tracking
+func (s *Storage) RestoreTracking(_ context.Context, userID int64, trackingNumber string, deletedAfter time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := s.findTracking(userID, trackingNumber, true)
+	if t == nil {
+		return core.ErrTrackingNotFound
+	}
+	deletedAt, ok := s.deletedAt[t.ID]
+	if !ok || deletedAt.Unix() < deletedAfter.Unix() {
+		return core.ErrTrackingNotFound
+	}
+	delete(s.deletedAt, t.ID)
+	return nil
+}
+
+// PurgeDeletedTrackings permanently deletes trackings deleted before deletedBefore.
+// It returns the number of purged trackings
+func (s *Storage) PurgeDeletedTrackings(_ context.Context, deletedBefore time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var purged int64
+	for id, deletedAt := range s.deletedAt {
+		if deletedAt.Unix() >= deletedBefore.Unix() {
+			continue
+		}
+		s.deleteNotifications(func(n *notification) bool { return n.trackingID == id })
+		s.deleteUpdateRecords(func(r *core.UpdateRecord) bool { return r.TrackingID == id })
+		delete(s.trackings, id)
+		delete(s.deletedAt, id)
+		purged++
+	}
+	return purged, nil
+}
+
+// DeleteUserData permanently deletes user's trackings (deleted ones too), pending notifications, update records
+// and audit records.
+// Transit samples are kept, since they are anonymous
+func (s *Storage) DeleteUserData(_ context.Context, userID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, t := range s.trackings {
+		if t.UserID == userID {
+			delete(s.trackings, id)
+			delete(s.deletedAt, id)
+		}
+	}
+	s.deleteNotifications(func(n *notification) bool { return n.update.UserID == userID })
+	s.deleteUpdateRecords(func(r *core.UpdateRecord) bool { return r.UserID == userID })
+
+	var records []*core.AuditRecord
+	for _, r := range s.auditRecords {
+		if r.UserID != userID {
+			records = append(records, r)
+		}
+	}
+	s.auditRecords = records
+	delete(s.levels, userID)
+	return nil
+}
+
+func (s *Storage) UpdatePollSchedule(_ context.Context, tracking *core.Tracking) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.updatePollSchedule(tracking)
+	return nil
+}
+
+func (s *Storage) updatePollSchedule(tracking *core.Tracking) {
+	if t, ok := s.trackings[tracking.ID]; ok {
+		t.LastPolledAt = truncate(tracking.LastPolledAt)
+		t.NextPollAt = truncate(tracking.NextPollAt)
+		t.Validators = tracking.Validators
+	}
+}
+
+func (s *Storage) SetPushSubscribed(_ context.Context, trackingID int64, subscribed bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if t, ok := s.trackings[trackingID]; ok {
+		t.PushSubscribed = subscribed
+	}
+	return nil
+}
+
+func (s *Storage) SetTrackingNote(_ context.Context, trackingID int64, note string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if t, ok := s.trackings[trackingID]; ok {
+		t.Note = note
+	}
+	return nil
+}
+
+func (s *Storage) SetTrackingDisplayName(_ context.Context, trackingID int64, displayName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if t, ok := s.trackings[trackingID]; ok {
+		t.DisplayName = displayName
+	}
+	return nil
+}
+
+func (s *Storage) SetTrackingTags(_ context.Context, trackingID int64, tags []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if t, ok := s.trackings[trackingID]; ok {
+		t.Tags = append([]string(nil), tags...)
+	}
+	return nil
+}
+
+func (s *Storage) SetStuckAlertedAt(_ context.Context, trackingID int64, alertedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if t, ok := s.trackings[trackingID]; ok {
+		at := time.Unix(alertedAt.Unix(), 0)
+		t.StuckAlertedAt = &at
+	}
+	return nil
+}
+
+func (s *Storage) SetRemindedAt(_ context.Context, trackingID int64, remindedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if t, ok := s.trackings[trackingID]; ok {
+		at := time.Unix(remindedAt.Unix(), 0)
+		t.RemindedAt = &at
+	}
+	return nil
+}
+
+// AttachTrackingNumber turns the manual tracking into an ordinary one, a deleted tracking of the user with the number
+// is purged, just like the SQLite storage does
+func (s *Storage) AttachTrackingNumber(_ context.Context, trackingID int64, trackingNumber string, nextPollAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.trackings[trackingID]
+	if !ok {
+		return nil
+	}
+	if replaced := s.findTracking(t.UserID, trackingNumber, true); replaced != nil {
+		id := replaced.ID
+		s.deleteNotifications(func(n *notification) bool { return n.trackingID == id })
+		s.deleteUpdateRecords(func(r *core.UpdateRecord) bool { return r.TrackingID == id })
+		delete(s.trackings, id)
+		delete(s.deletedAt, id)
+	}
+	t.TrackingNumber = trackingNumber
+	t.Manual = false
+	t.NextPollAt = truncate(&nextPollAt)
+	return nil
+}
+
+func (s *Storage) SetNotificationLevel(_ context.Context, userID int64, level core.NotificationLevel) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.levels[userID] = level
+	return nil
+}
+
+func (s *Storage) SetTrackingNotificationLevel(_ context.Context, trackingID int64, level core.NotificationLevel) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if t, ok := s.trackings[trackingID]; ok {
+		t.NotificationLevel = level
+	}
+	return nil
+}
+
+func (s *Storage) GetNotificationLevel(_ context.Context, userID int64) (core.NotificationLevel, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if level, ok := s.levels[userID]; ok {
+		return level, nil
+	}
+	return core.NotificationLevelAll, nil
+}
+
+func (s *Storage) ListPendingNotifications(_ context.Context) ([]*core.TrackingUpdate, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var updates []*core.TrackingUpdate
+	for _, n := range s.notifications {
+		u := copyUpdate(&n.update)
+		updates = append(updates, &u)
+	}
+	sort.Slice(updates, func(i, j int) bool { return updates[i].NotificationID < updates[j].NotificationID })
+	return updates, nil
+}
+
+func (s *Storage) DeleteNotification(_ context.Context, notificationID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.notifications, notificationID)
+	for _, r := range s.updateRecords {
+		if r.notificationID == notificationID {
+			deliveredAt := time.Unix(s.clock.Now().Unix(), 0)
+			r.record.DeliveredAt = &deliveredAt
+		}
+	}
+	return nil
+}
+
+func (s *Storage) ListUpdateRecords(_ context.Context, userID int64, trackingNumber string, limit int) ([]*core.UpdateRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var records []*core.UpdateRecord
+	for i := len(s.updateRecords) - 1; i >= 0 && len(records) < limit; i-- {
+		r := s.updateRecords[i].record
+		if r.UserID != userID || r.TrackingNumber != trackingNumber {
+			continue
+		}
+		update := copyUpdate(r.Update)
+		r.Update = &update
+		records = append(records, &r)
+	}
+	return records, nil
+}
+
+func (s *Storage) ListUserIDs(_ context.Context, afterUserID int64, limit int) ([]int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seen := make(map[int64]bool)
+	var userIDs []int64
+	for _, t := range s.trackings {
+		if t.UserID > afterUserID && !seen[t.UserID] {
+			seen[t.UserID] = true
+			userIDs = append(userIDs, t.UserID)
+		}
+	}
+	sort.Slice(userIDs, func(i, j int) bool { return userIDs[i] < userIDs[j] })
+	if len(userIDs) > limit {
+		userIDs = userIDs[:limit]
+	}
+	return userIDs, nil
+}
+
+func (s *Storage) PurgeUpdateRecords(_ context.Context, createdBefore time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	before := len(s.updateRecords)
+	s.deleteUpdateRecords(func(r *core.UpdateRecord) bool { return r.CreatedAt.Unix() < createdBefore.Unix() })
+	return int64(before - len(s.updateRecords)), nil
+}
+
+func (s *Storage) PurgeAuditRecords(_ context.Context, createdBefore time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var kept []*core.AuditRecord
+	for _, r := range s.auditRecords {
+		if r.CreatedAt.Unix() >= createdBefore.Unix() {
+			kept = append(kept, r)
+		}
+	}
+	purged := len(s.auditRecords) - len(kept)
+	s.auditRecords = kept
+	return int64(purged), nil
+}
+
+func (s *Storage) SaveAuditRecord(_ context.Context, record *core.AuditRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastAuditRecordID++
+	r := *record
+	r.ID = s.lastAuditRecordID
+	r.CreatedAt = time.Unix(r.CreatedAt.Unix(), 0)
+	s.auditRecords = append(s.auditRecords, &r)
+	return nil
+}
+
+func (s *Storage) ListAuditRecords(_ context.Context, userID int64, trackingNumber string) ([]*core.AuditRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var records []*core.AuditRecord
+	for _, r := range s.auditRecords {
+		if r.UserID == userID && r.TrackingNumber == trackingNumber {
+			record := *r
+			records = append(records, &record)
+		}
+	}
+	return records, nil
+}
+
+func (s *Storage) SaveTransitSamples(_ context.Context, samples []*core.TransitSample) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, sample := range samples {
+		stored := *sample
+		stored.Duration = stored.Duration.Truncate(time.Second)
+		s.transitSamples = append(s.transitSamples, &stored)
+	}
+	return nil
+}
+
+func (s *Storage) ListTransitDurations(_ context.Context, route string, stage core.Status, limit int) ([]time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var durations []time.Duration
+	// newest first
+	for i := len(s.transitSamples) - 1; i >= 0 && len(durations) < limit; i-- {
+		sample := s.transitSamples[i]
+		if sample.Stage == stage && (route == "" || sample.Route == route) {
+			durations = append(durations, sample.Duration)
+		}
+	}
+	return durations, nil
+}
+
+func (s *Storage) ListRouteTransitTimes(_ context.Context, stage core.Status, minSamples int) ([]*core.RouteTransitTime, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	byRoute := make(map[string]*core.RouteTransitTime)
+	for _, sample := range s.transitSamples {
+		if sample.Stage != stage {
+			continue
+		}
+		r, ok := byRoute[sample.Route]
+		if !ok {
+			r = &core.RouteTransitTime{Route: sample.Route}
+			byRoute[sample.Route] = r
+		}
+		r.SamplesCount++
+		// the sum of durations until the averages are taken below
+		r.Average += sample.Duration
+	}
+	var times []*core.RouteTransitTime
+	for _, r := range byRoute {
+		if r.SamplesCount < minSamples {
+			continue
+		}
+		r.Average = (r.Average / time.Duration(r.SamplesCount)).Truncate(time.Second)
+		times = append(times, r)
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].Route < times[j].Route })
+	return times, nil
+}
+
+func (s *Storage) SaveDeliveryConfirmation(_ context.Context, confirmation *core.DeliveryConfirmation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := *confirmation
+	s.deliveryConfirmations = append(s.deliveryConfirmations, &stored)
+	return nil
+}
+
+func (s *Storage) ListRouteDeliveryConfirmations(_ context.Context) ([]*core.RouteDeliveryConfirmations, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	byRoute := make(map[string]*core.RouteDeliveryConfirmations)
+	for _, c := range s.deliveryConfirmations {
+		r, ok := byRoute[c.Route]
+		if !ok {
+			r = &core.RouteDeliveryConfirmations{Route: c.Route}
+			byRoute[c.Route] = r
+		}
+		if c.Received {
+			r.Received++
+		} else {
+			r.NotReceived++
+		}
+	}
+	confirmations := make([]*core.RouteDeliveryConfirmations, 0, len(byRoute))
+	for _, r := range byRoute {
+		confirmations = append(confirmations, r)
+	}
+	sort.Slice(confirmations, func(i, j int) bool { return confirmations[i].Route < confirmations[j].Route })
+	return confirmations, nil
+}
+
+func (s *Storage) SetDeliveryDisputedAt(_ context.Context, trackingID int64, disputedAt *time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if t, ok := s.trackings[trackingID]; ok {
+		t.DeliveryDisputedAt = truncate(disputedAt)
+	}
+	return nil
+}
+
+// findTracking finds the tracking by its natural key, soft-deleted trackings are only found if withDeleted is set
+func (s *Storage) findTracking(userID int64, trackingNumber string, withDeleted bool) *core.Tracking {
+	for id, t := range s.trackings {
+		if t.UserID != userID || t.TrackingNumber != trackingNumber {
+			continue
+		}
+		if _, deleted := s.deletedAt[id]; deleted && !withDeleted {
+			return nil
+		}
+		return t
+	}
+	return nil
+}
+
+// list returns copies of trackings that are not deleted and match the filter, ordered by ID
+func (s *Storage) list(filter func(t *core.Tracking) bool) []*core.Tracking {
+	var trackings []*core.Tracking
+	for id, t := range s.trackings {
+		if _, deleted := s.deletedAt[id]; deleted || !filter(t) {
+			continue
+		}
+		trackings = append(trackings, copyTracking(t))
+	}
+	sort.Slice(trackings, func(i, j int) bool { return trackings[i].ID < trackings[j].ID })
+	return trackings
+}
+
+func (s *Storage) deleteNotifications(filter func(n *notification) bool) {
+	for id, n := range s.notifications {
+		if filter(n) {
+			delete(s.notifications, id)
+		}
+	}
+}
+
+func (s *Storage) deleteUpdateRecords(filter func(r *core.UpdateRecord) bool) {
+	var kept []*updateRecord
+	for _, r := range s.updateRecords {
+		if !filter(&r.record) {
+			kept = append(kept, r)
+		}
+	}
+	s.updateRecords = kept
+}
+
+func copyTracking(t *core.Tracking) *core.Tracking {
+	c := *t
+	c.SeenEventHashes = append([]string(nil), t.SeenEventHashes...)
+	c.Tags = append([]string(nil), t.Tags...)
+	c.TrackingInfos = copyTrackingInfos(t.TrackingInfos)
+	return &c
+}
+
+func copyTrackingInfos(infos []*parcels_api.TrackingInfo) []*parcels_api.TrackingInfo {
+	if infos == nil {
+		return nil
+	}
+	copies := make([]*parcels_api.TrackingInfo, 0, len(infos))
+	for _, ti := range infos {
+		c := *ti
+		c.Events = append([]parcels_api.TrackingEvent(nil), ti.Events...)
+		copies = append(copies, &c)
+	}
+	return copies
+}
+
+func copyUpdate(u *core.TrackingUpdate) core.TrackingUpdate {
+	c := *u
+	c.NewTrackingInfos = copyTrackingInfos(u.NewTrackingInfos)
+	c.Statuses = append([]core.Status(nil), u.Statuses...)
+	c.Milestones = append([]core.Status(nil), u.Milestones...)
+	c.FailedProviders = append([]string(nil), u.FailedProviders...)
+	c.NewTrackingEvents = nil
+	for _, e := range u.NewTrackingEvents {
+		event := *e
+		c.NewTrackingEvents = append(c.NewTrackingEvents, &event)
+	}
+	if u.ETA != nil {
+		eta := *u.ETA
+		c.ETA = &eta
+	}
+	return c
+}
+
+// truncate drops sub-second precision, since the SQLite storage keeps unix timestamps
+func truncate(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	truncated := time.Unix(t.Unix(), 0)
+	return &truncated
+}
+
+// pollsBefore tells whether a comes before b in the queue of trackings due for poll
+func pollsBefore(a, b *core.PollCursor) bool {
+	if a.Polled != b.Polled {
+		return !a.Polled
+	}
+	var aActivity, bActivity int64
+	if a.LastActivityAt != nil {
+		aActivity = a.LastActivityAt.Unix()
+	}
+	if b.LastActivityAt != nil {
+		bActivity = b.LastActivityAt.Unix()
+	}
+	if aActivity != bActivity {
+		return aActivity > bActivity
+	}
+	return a.ID < b.ID
+}
@@ -0,0 +1,141 @@
+package storage
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dir01/tg-parcels/metrics"
+	"github.com/hori-ryota/zaperr"
+	"go.uber.org/zap"
+)
+
+// QueryDurationBuckets are upper bounds of query duration histogram buckets,
+// durations above the last bound fall into an implicit +Inf bucket
+var QueryDurationBuckets = []time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+}
+
+// OperationStats are statistics of a storage operation
+type OperationStats struct {
+	Count         int64
+	Errors        int64
+	TotalDuration time.Duration
+	// Buckets are counts of operations by duration, see QueryDurationBuckets (non-cumulative, +Inf bucket is the last)
+	Buckets []int64
+}
+
+func NewQueryMetrics(slowQueryThreshold time.Duration, logger *zap.Logger) *QueryMetrics {
+	return &QueryMetrics{
+		slowQueryThreshold: slowQueryThreshold,
+		logger:             logger,
+		operations:         make(map[string]*OperationStats),
+	}
+}
+
+// QueryMetrics times storage operations and logs the ones slower than the threshold.
+// Operations include waiting for a busy database and retries, so lock contention shows up here too.
+// Nil QueryMetrics is valid and records nothing
+type QueryMetrics struct {
+	slowQueryThreshold time.Duration // 0 disables slow query logging
+	logger             *zap.Logger
+
+	mu         sync.Mutex
+	operations map[string]*OperationStats
+}
+
+// Observe is meant to be deferred at the start of an operation: defer m.Observe("op", time.Now(), &err)
+func (m *QueryMetrics) Observe(operation string, startedAt time.Time, err *error) {
+	if m == nil {
+		return
+	}
+	duration := time.Since(startedAt)
+
+	m.mu.Lock()
+	stats, ok := m.operations[operation]
+	if !ok {
+		stats = &OperationStats{Buckets: make([]int64, len(QueryDurationBuckets)+1)}
+		m.operations[operation] = stats
+	}
+	stats.Count++
+	stats.TotalDuration += duration
+	if err != nil && *err != nil {
+		stats.Errors++
+	}
+	bucket := len(QueryDurationBuckets)
+	for i, bound := range QueryDurationBuckets {
+		if duration <= bound {
+			bucket = i
+			break
+		}
+	}
+	stats.Buckets[bucket]++
+	m.mu.Unlock()
+
+	if m.slowQueryThreshold > 0 && duration > m.slowQueryThreshold {
+		fields := []zap.Field{zap.String("operation", operation), zap.Duration("duration", duration)}
+		if err != nil && *err != nil {
+			fields = append(fields, zaperr.ToField(*err))
+		}
+		m.logger.Warn("slow storage operation", fields...)
+	}
+}
+
+// Snapshot returns a copy of statistics of all operations observed so far
+func (m *QueryMetrics) Snapshot() map[string]OperationStats {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := make(map[string]OperationStats, len(m.operations))
+	for operation, stats := range m.operations {
+		s := *stats
+		s.Buckets = append([]int64(nil), stats.Buckets...)
+		result[operation] = s
+	}
+	return result
+}
+
+// Register exposes statistics of storage operations in the registry, they are taken from Snapshot on every scrape
+func (m *QueryMetrics) Register(registry *metrics.Registry) {
+	buckets := make([]float64, len(QueryDurationBuckets))
+	for i, bound := range QueryDurationBuckets {
+		buckets[i] = bound.Seconds()
+	}
+	registry.NewCollector(func(w *metrics.Writer) {
+		snapshot := m.Snapshot()
+		operations := make([]string, 0, len(snapshot))
+		for operation := range snapshot {
+			operations = append(operations, operation)
+		}
+		sort.Strings(operations)
+
+		for _, operation := range operations {
+			stats := snapshot[operation]
+			counts := make([]uint64, len(stats.Buckets))
+			for i, count := range stats.Buckets {
+				counts[i] = uint64(count)
+			}
+			w.Histogram(
+				"tg_parcels_storage_operation_duration_seconds", "Duration of storage operations.",
+				metrics.Labels{{"operation", operation}}, buckets, counts, stats.TotalDuration.Seconds(),
+			)
+		}
+		for _, operation := range operations {
+			w.Counter(
+				"tg_parcels_storage_operation_errors_total", "Failed storage operations.",
+				metrics.Labels{{"operation", operation}}, float64(snapshot[operation].Errors),
+			)
+		}
+	})
+}
@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hori-ryota/zaperr"
+	"github.com/mattn/go-sqlite3"
+)
+
+const (
+	busyRetryAttempts    = 5
+	busyRetryBaseBackoff = 50 * time.Millisecond
+)
+
+// DSN returns go-sqlite3 data source name for the database file at path:
+//   - WAL journal, so that readers don't block the writer and vice versa
+//   - busy timeout, so that a writer waits for another one instead of failing right away
+//   - immediate transactions, so that a transaction takes the write lock upfront
+//     instead of failing with SQLITE_BUSY when upgrading its read lock midway
+//   - foreign keys enforcement
+//
+// Options already present in path take precedence
+func DSN(path string, busyTimeout time.Duration) string {
+	return withOptions(path, map[string]string{
+		"_journal_mode": "WAL",
+		"_busy_timeout": strconv.FormatInt(busyTimeout.Milliseconds(), 10),
+		"_txlock":       "immediate",
+		"_foreign_keys": "on",
+	})
+}
+
+// ReadOnlyDSN returns go-sqlite3 data source name for a pool of read-only connections to the database file at path
+// next to a single writer opened with DSN. It differs from DSN in that queries can't write, transactions don't take
+// the write lock and the journal mode is left to the writer. Readers of the same file in WAL mode see every committed
+// write, so reads guarding writes (e.g. whether a tracking exists) may go through the pool as well
+func ReadOnlyDSN(path string, busyTimeout time.Duration) string {
+	return withOptions(path, map[string]string{
+		"_query_only":   "true",
+		"_busy_timeout": strconv.FormatInt(busyTimeout.Milliseconds(), 10),
+		"_txlock":       "deferred",
+		"_foreign_keys": "on",
+	})
+}
+
+// withOptions adds options to the data source name, unless path already has them
+func withOptions(path string, options map[string]string) string {
+	base, rawQuery, _ := strings.Cut(path, "?")
+	params, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		params = url.Values{}
+	}
+	for k, v := range options {
+		if params.Get(k) == "" {
+			params.Set(k, v)
+		}
+	}
+	return base + "?" + params.Encode()
+}
+
+// isBusy tells whether err means that the database is locked by another connection
+func isBusy(err error) bool {
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	return sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked
+}
+
+// retryOnBusy calls fn again with exponential backoff for as long as it fails with SQLITE_BUSY,
+// which busy timeout alone doesn't rule out (e.g. on checkpoints or when the timeout runs out under load)
+func retryOnBusy(ctx context.Context, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= busyRetryAttempts || !isBusy(err) {
+			return err
+		}
+
+		t := time.NewTimer(busyRetryBaseBackoff << (attempt - 1))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return err
+		case <-t.C:
+		}
+	}
+}
+
+// execContext is db.ExecContext retried on SQLITE_BUSY
+func (s *Storage) execContext(ctx context.Context, query string, args ...interface{}) (res sql.Result, err error) {
+	err = retryOnBusy(ctx, func() error {
+		res, err = s.db.ExecContext(ctx, query, args...)
+		return err
+	})
+	return res, err
+}
+
+// inTx runs fn in a transaction and commits it, the whole transaction is retried on SQLITE_BUSY,
+// so fn must be safe to call more than once
+func (s *Storage) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	return retryOnBusy(ctx, func() error {
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return zaperr.Wrap(err, "failed to begin transaction")
+		}
+		defer tx.Rollback()
+
+		if err := fn(tx); err != nil {
+			return err
+		}
+
+		if err := tx.Commit(); err != nil {
+			return zaperr.Wrap(err, "failed to commit transaction")
+		}
+		return nil
+	})
+}
+
+// PoolConfig configures the connection pool of the database
+type PoolConfig struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration // 0 means connections are reused forever
+	ConnMaxIdleTime time.Duration // 0 means idle connections are kept forever
+}
+
+// DefaultPoolConfig suits SQLite in WAL mode: a handful of connections lets reads run concurrently
+// (writes are serialized by SQLite anyway), and since opening a connection to a local file is cheap
+// but resets its page cache, idle connections are kept around and never recycled
+func DefaultPoolConfig() PoolConfig {
+	return PoolConfig{
+		MaxOpenConns: 8,
+		MaxIdleConns: 8,
+	}
+}
+
+// Writer is the config for the writer when reads go through a pool of their own, see ReadOnlyDSN:
+// SQLite serializes writes anyway, so a single connection spares writers waiting on each other's locks.
+// With a single connection, nothing may use the writer outside of a transaction while the transaction is open,
+// e.g. calling execContext from an inTx callback: it would wait for the connection the transaction holds until
+// the query timeout runs out, or forever without one. Callbacks only ever use the tx they are given
+func (c PoolConfig) Writer() PoolConfig {
+	c.MaxOpenConns, c.MaxIdleConns = 1, 1
+	return c
+}
+
+func (c PoolConfig) Apply(db *sql.DB) {
+	db.SetMaxOpenConns(c.MaxOpenConns)
+	db.SetMaxIdleConns(c.MaxIdleConns)
+	db.SetConnMaxLifetime(c.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(c.ConnMaxIdleTime)
+}
+
+// WithQueryTimeout limits the operation to timeout, 0 means no limit besides the context deadline
+func WithQueryTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
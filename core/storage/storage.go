@@ -2,46 +2,273 @@ package storage
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"strings"
-	"sync"
 	"time"
 
+	"github.com/dir01/parcels/parcels_api"
 	"github.com/dir01/tg-parcels/core"
 	"github.com/hori-ryota/zaperr"
 	"github.com/jmoiron/sqlx"
 	"go.uber.org/zap"
 )
 
-func NewStorage(db *sqlx.DB) *Storage {
-	s := &Storage{db: db, writeAccessMutex: &sync.Mutex{}}
+func NewStorage(db, readDB *sqlx.DB, cipher *Cipher, queryTimeout time.Duration, clock core.Clock, metrics *QueryMetrics) *Storage {
+	s := &Storage{db: db, readDB: readDB, cipher: cipher, queryTimeout: queryTimeout, clock: clock, metrics: metrics}
 	var _ core.Storage = s
+	var _ core.AnalyticsStorage = s
 	return s
 }
 
+// Storage relies on SQLite locking for concurrent writes, the database is expected to be opened with DSN
 type Storage struct {
-	db               *sqlx.DB
-	writeAccessMutex *sync.Mutex
+	db *sqlx.DB
+	// readDB runs reads outside of transactions, it is db itself unless reads are split, see ReadOnlyDSN
+	readDB *sqlx.DB
+	cipher *Cipher // nil unless encryption at rest is enabled
+	// queryTimeout limits every operation, including waiting for a busy database, 0 means no limit
+	queryTimeout time.Duration
+	// clock is what creation, activity and deletion times are recorded by
+	clock   core.Clock
+	metrics *QueryMetrics
 }
 
-func (s *Storage) SaveTracking(ctx context.Context, tracking *core.Tracking) (*core.Tracking, error) {
-	dbTracking, err := dbStruct{}.fromBusinessStruct(tracking)
+// SaveTracking upserts the tracking, created tells whether the user wasn't tracking the number before,
+// re-tracking a deleted tracking restores it and counts as creating
+func (s *Storage) SaveTracking(ctx context.Context, tracking *core.Tracking) (_ *core.Tracking, created bool, err error) {
+	defer s.metrics.Observe("save_tracking", time.Now(), &err)
+	ctx, cancel := WithQueryTimeout(ctx, s.queryTimeout)
+	defer cancel()
+	var saved *core.Tracking
+	err = s.inTx(ctx, func(tx *sql.Tx) (err error) {
+		var deletedAt *int64
+		err = tx.QueryRowContext(ctx, `
+			SELECT deleted_at FROM trackings WHERE user_id = ? AND tracking_number = ?`, tracking.UserID, tracking.TrackingNumber,
+		).Scan(&deletedAt)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return zaperr.Wrap(err, "failed to check if tracking exists")
+		}
+		created = errors.Is(err, sql.ErrNoRows) || deletedAt != nil
+
+		saved, err = s.saveTracking(ctx, tx, tracking)
+		if err != nil || !created {
+			return err
+		}
+		return s.touchTracking(ctx, tx, saved)
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	return saved, created, nil
+}
+
+// SaveTrackingUpdate saves the tracking along with a pending notification about the update in a single transaction,
+// so that the update is never lost once the tracking is saved.
+// The notification stays pending until it is deleted with DeleteNotification, its update record stays for good
+func (s *Storage) SaveTrackingUpdate(ctx context.Context, tracking *core.Tracking, update *core.TrackingUpdate) (_ *core.Tracking, err error) {
+	defer s.metrics.Observe("save_tracking_update", time.Now(), &err)
+	ctx, cancel := WithQueryTimeout(ctx, s.queryTimeout)
+	defer cancel()
+	dbNotification, err := notificationDBStruct{}.fromBusinessStruct(update, s.cipher)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		INSERT INTO pending_notifications (tracking_id, user_id, payload, created_at) VALUES (?, ?, ?, ?)`
+	recordQuery := `
+		INSERT INTO update_records
+			(notification_id, tracking_id, user_id, tracking_number, payload, created_at)
+		VALUES
+			(?, ?, ?, ?, ?, ?)`
+
+	var saved *core.Tracking
+	var notificationID int64
+	err = s.inTx(ctx, func(tx *sql.Tx) (err error) {
+		saved, err = s.saveTracking(ctx, tx, tracking)
+		if err != nil {
+			return err
+		}
+		if err := s.touchTracking(ctx, tx, saved); err != nil {
+			return err
+		}
+
+		now := s.clock.Now().Unix()
+		res, err := tx.ExecContext(ctx, query, saved.ID, dbNotification.UserID, dbNotification.Payload, now)
+		if err != nil {
+			return zaperr.Wrap(err, "failed to execute", zap.String("query", query), core.TrackingIDField(saved.ID))
+		}
+		if notificationID, err = res.LastInsertId(); err != nil {
+			return zaperr.Wrap(err, "failed to get notification id")
+		}
+		_, err = tx.ExecContext(
+			ctx, recordQuery,
+			notificationID, saved.ID, dbNotification.UserID, saved.TrackingNumber, dbNotification.Payload, now,
+		)
+		if err != nil {
+			return zaperr.Wrap(err, "failed to execute", zap.String("query", recordQuery), core.TrackingIDField(saved.ID))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	update.NotificationID = notificationID
+
+	return saved, nil
+}
+
+// SaveTrackings saves polled trackings in a single transaction, the statements are prepared once for all of them.
+// Unlike SaveTrackingUpdate, it never creates trackings and leaves their display names alone,
+// since trackings may be renamed while the rest of them are polled
+func (s *Storage) SaveTrackings(ctx context.Context, saves []*core.TrackingSave) (err error) {
+	defer s.metrics.Observe("save_trackings", time.Now(), &err)
+	ctx, cancel := WithQueryTimeout(ctx, s.queryTimeout)
+	defer cancel()
+	type prepared struct {
+		dbTracking     *dbStruct
+		dbNotification *notificationDBStruct
+	}
+	// encryption is done before the transaction, so that it doesn't hold the database any longer than needed
+	preparedSaves := make([]prepared, len(saves))
+	for i, save := range saves {
+		if preparedSaves[i].dbTracking, err = (dbStruct{}).fromBusinessStruct(save.Tracking, s.cipher); err != nil {
+			return err
+		}
+		if save.Update == nil {
+			continue
+		}
+		if preparedSaves[i].dbNotification, err = (notificationDBStruct{}).fromBusinessStruct(save.Update, s.cipher); err != nil {
+			return err
+		}
+	}
+
+	queries := map[string]string{
+		"exists": `
+		SELECT COUNT(*) FROM trackings WHERE id = ? AND deleted_at IS NULL`,
+		"schedule": `
+		UPDATE trackings SET last_polled_at = ?, next_poll_at = ?, fetch_etag = ?, fetch_last_modified = ? WHERE id = ?`,
+		"tracking": `
+		UPDATE trackings SET last_polled_at = ?, next_poll_at = ?, fetch_etag = ?, fetch_last_modified = ?,
+			seen_event_hashes = ?, last_activity_at = ?
+		WHERE id = ?`,
+		"notification": `
+		INSERT INTO pending_notifications (tracking_id, user_id, payload, created_at) VALUES (?, ?, ?, ?)`,
+		"record": `
+		INSERT INTO update_records
+			(notification_id, tracking_id, user_id, tracking_number, payload, created_at)
+		VALUES
+			(?, ?, ?, ?, ?, ?)`,
+	}
+
+	now := s.clock.Now().Unix()
+	var notificationIDs []int64
+	var deleted []bool
+	err = s.inTx(ctx, func(tx *sql.Tx) error {
+		stmts := make(map[string]*sql.Stmt, len(queries))
+		for name, query := range queries {
+			stmt, err := tx.PrepareContext(ctx, query)
+			if err != nil {
+				return zaperr.Wrap(err, "failed to prepare", zap.String("query", query))
+			}
+			defer stmt.Close()
+			stmts[name] = stmt
+		}
+
+		// the transaction may be retried, so nothing is written to saves until it is committed
+		notificationIDs, deleted = make([]int64, len(saves)), make([]bool, len(saves))
+		for i, save := range saves {
+			d := preparedSaves[i].dbTracking
+			fields := []zap.Field{core.TrackingIDField(d.ID)}
+			var exists int
+			if err := stmts["exists"].QueryRowContext(ctx, d.ID).Scan(&exists); err != nil {
+				return zaperr.Wrap(err, "failed to check if tracking exists", fields...)
+			}
+			if exists == 0 {
+				deleted[i] = true
+				continue
+			}
+
+			if save.Update == nil {
+				if _, err := stmts["schedule"].ExecContext(ctx, d.LastPolledAt, d.NextPollAt, d.FetchETag, d.FetchLastModified, d.ID); err != nil {
+					return zaperr.Wrap(err, "failed to update poll schedule", fields...)
+				}
+				continue
+			}
+			if _, err := stmts["tracking"].ExecContext(
+				ctx, d.LastPolledAt, d.NextPollAt, d.FetchETag, d.FetchLastModified, d.SeenEventHashes, now, d.ID,
+			); err != nil {
+				return zaperr.Wrap(err, "failed to update tracking", fields...)
+			}
+			if err := s.saveTrackingInfos(ctx, tx, d.ID, save.Tracking.TrackingInfos); err != nil {
+				return err
+			}
+			n := preparedSaves[i].dbNotification
+			res, err := stmts["notification"].ExecContext(ctx, d.ID, n.UserID, n.Payload, now)
+			if err != nil {
+				return zaperr.Wrap(err, "failed to save notification", fields...)
+			}
+			if notificationIDs[i], err = res.LastInsertId(); err != nil {
+				return zaperr.Wrap(err, "failed to get notification id", fields...)
+			}
+			if _, err := stmts["record"].ExecContext(ctx, notificationIDs[i], d.ID, n.UserID, d.TrackingNumber, n.Payload, now); err != nil {
+				return zaperr.Wrap(err, "failed to save update record", fields...)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for i, save := range saves {
+		save.Deleted = deleted[i]
+		if save.Update == nil || save.Deleted {
+			continue
+		}
+		save.Update.NotificationID = notificationIDs[i]
+		activityAt := time.Unix(now, 0)
+		save.Tracking.LastActivityAt = &activityAt
+	}
+	return nil
+}
+
+// touchTracking records activity of the tracking, see core.Tracking.LastActivityAt
+func (s *Storage) touchTracking(ctx context.Context, tx *sql.Tx, tracking *core.Tracking) error {
+	now := time.Unix(s.clock.Now().Unix(), 0)
+	if _, err := tx.ExecContext(ctx, `UPDATE trackings SET last_activity_at = ? WHERE id = ?`, now.Unix(), tracking.ID); err != nil {
+		return zaperr.Wrap(err, "failed to record activity", core.TrackingIDField(tracking.ID))
+	}
+	tracking.LastActivityAt = &now
+	return nil
+}
+
+// saveTracking upserts the tracking. Tracking infos are only saved for trackings that already exist,
+// since re-tracking an existing number (e.g. to rename it) must not wipe what we know about it
+func (s *Storage) saveTracking(ctx context.Context, tx *sql.Tx, tracking *core.Tracking) (*core.Tracking, error) {
+	dbTracking, err := dbStruct{}.fromBusinessStruct(tracking, s.cipher)
 	if err != nil {
 		return nil, err
 	}
 
 	query := `
 		INSERT INTO trackings
-			(user_id, tracking_number, display_name, payload, last_polled_at)
+			(user_id, tracking_number, display_name, last_polled_at, next_poll_at, seen_event_hashes, fetch_etag, fetch_last_modified, manual, expected_at, postal_code, carrier)
 		VALUES
-			(:user_id, :tracking_number, :display_name, :payload, :last_polled_at)
+			(:user_id, :tracking_number, :display_name, :last_polled_at, :next_poll_at, :seen_event_hashes, :fetch_etag, :fetch_last_modified, :manual, :expected_at, :postal_code, :carrier)
 		`
-	if dbTracking.ID == 0 || len(dbTracking.Payload) == 0 {
+	updateTrackingInfos := dbTracking.ID != 0
+	if !updateTrackingInfos {
 		query = query + `
-		ON CONFLICT DO UPDATE SET display_name=excluded.display_name
+		ON CONFLICT DO UPDATE SET display_name=excluded.display_name, manual=excluded.manual, expected_at=excluded.expected_at, postal_code=excluded.postal_code, carrier=excluded.carrier,
+			deleted_at=NULL
 		` // can't use `DO NOTHING` or `RETURNING` won't work
 	} else {
 		query = query + `
-		ON CONFLICT DO UPDATE SET payload=excluded.payload, last_polled_at=excluded.last_polled_at, display_name=excluded.display_name
+		ON CONFLICT DO UPDATE SET last_polled_at=excluded.last_polled_at, next_poll_at=excluded.next_poll_at, seen_event_hashes=excluded.seen_event_hashes, display_name=excluded.display_name,
+			fetch_etag=excluded.fetch_etag, fetch_last_modified=excluded.fetch_last_modified
 		`
 	}
 	query = query + `
@@ -63,93 +290,939 @@ func (s *Storage) SaveTracking(ctx context.Context, tracking *core.Tracking) (*c
 		return nil, zaperr.Wrap(err, "failed to bind", fields...)
 	}
 
-	s.writeAccessMutex.Lock()
-	defer s.writeAccessMutex.Unlock()
-
-	if err := s.db.DB.QueryRowContext(ctx, bindQ, bindA...).Scan(&dbTracking.ID); err != nil {
+	if err := tx.QueryRowContext(ctx, bindQ, bindA...).Scan(&dbTracking.ID); err != nil {
 		return nil, zaperr.Wrap(err, "failed to execute", fields...)
 	}
 
-	tracking, err = dbTracking.toBusinessStruct()
+	if updateTrackingInfos {
+		if err := s.saveTrackingInfos(ctx, tx, dbTracking.ID, tracking.TrackingInfos); err != nil {
+			return nil, err
+		}
+	}
+
+	trackingInfos := tracking.TrackingInfos
+	tracking, err = dbTracking.toBusinessStruct(s.cipher)
 	if err != nil {
 		return nil, err
 	}
+	tracking.TrackingInfos = trackingInfos
 
 	return tracking, nil
 }
 
-func (s *Storage) GetTracking(ctx context.Context, userID int64, trackingNumber string) (*core.Tracking, error) {
-	var dbTracking dbStruct
-	err := s.db.GetContext(ctx, &dbTracking, `
-		SELECT * FROM trackings WHERE user_id = ? AND tracking_number = ?`, userID, trackingNumber,
+// saveTrackingInfos replaces tracking infos of the tracking: infos are upserted by API name,
+// their events are rewritten, and infos from APIs that are not there anymore are deleted
+func (s *Storage) saveTrackingInfos(ctx context.Context, tx *sql.Tx, trackingID int64, trackingInfos []*parcels_api.TrackingInfo) error {
+	infoQuery := `
+		INSERT INTO tracking_infos
+			(tracking_id, position, api_name, tracking_number, is_delivered, last_checked_at, last_updated_at)
+		VALUES
+			(?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (tracking_id, api_name) DO UPDATE SET
+			position=excluded.position, tracking_number=excluded.tracking_number, is_delivered=excluded.is_delivered,
+			last_checked_at=excluded.last_checked_at, last_updated_at=excluded.last_updated_at
+		RETURNING id`
+	eventQuery := `
+		INSERT INTO tracking_events (tracking_info_id, position, time, occurred_at, description, status) VALUES (?, ?, ?, ?, ?, ?)`
+
+	infoIDs := make([]any, 0, len(trackingInfos))
+	for position, ti := range trackingInfos {
+		d := trackingInfoDBStruct{}.fromBusinessStruct(trackingID, position, ti)
+		if err := tx.QueryRowContext(
+			ctx, infoQuery, d.TrackingID, d.Position, d.ApiName, d.TrackingNumber, d.IsDelivered, d.LastCheckedAt, d.LastUpdatedAt,
+		).Scan(&d.ID); err != nil {
+			return zaperr.Wrap(err, "failed to execute", zap.String("query", infoQuery), zap.Any("dbTrackingInfo", d))
+		}
+		infoIDs = append(infoIDs, d.ID)
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM tracking_events WHERE tracking_info_id = ?`, d.ID); err != nil {
+			return zaperr.Wrap(err, "failed to delete tracking events", zap.Int64("tracking_info_id", d.ID))
+		}
+		for position, e := range ti.Events {
+			de, err := trackingEventDBStruct{}.fromBusinessStruct(d.ID, position, e, s.cipher)
+			if err != nil {
+				return err
+			}
+			if _, err := tx.ExecContext(ctx, eventQuery, de.TrackingInfoID, de.Position, de.Time, de.OccurredAt, de.Description, de.Status); err != nil {
+				return zaperr.Wrap(err, "failed to execute", zap.String("query", eventQuery), zap.Int64("tracking_info_id", d.ID))
+			}
+		}
+	}
+
+	staleQuery := `
+		SELECT id FROM tracking_infos WHERE tracking_id = ?`
+	args := []any{trackingID}
+	if len(infoIDs) > 0 {
+		staleQuery += ` AND id NOT IN (?)`
+		args = append(args, infoIDs)
+	}
+	staleQuery, args, err := sqlx.In(staleQuery, args...)
+	if err != nil {
+		return zaperr.Wrap(err, "failed to bind", zap.String("query", staleQuery))
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM tracking_events WHERE tracking_info_id IN (`+staleQuery+`)`, args...); err != nil {
+		return zaperr.Wrap(err, "failed to delete stale tracking events", core.TrackingIDField(trackingID))
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM tracking_infos WHERE id IN (`+staleQuery+`)`, args...); err != nil {
+		return zaperr.Wrap(err, "failed to delete stale tracking infos", core.TrackingIDField(trackingID))
+	}
+
+	return nil
+}
+
+// loadTrackingInfos loads tracking infos of the trackings along with their events
+func (s *Storage) loadTrackingInfos(ctx context.Context, trackings []*core.Tracking) error {
+	if len(trackings) == 0 {
+		return nil
+	}
+	trackingsByID := make(map[int64]*core.Tracking, len(trackings))
+	trackingIDs := make([]int64, 0, len(trackings))
+	for _, t := range trackings {
+		trackingsByID[t.ID] = t
+		trackingIDs = append(trackingIDs, t.ID)
+	}
+
+	query, args, err := sqlx.In(`
+		SELECT * FROM tracking_infos WHERE tracking_id IN (?) ORDER BY tracking_id, position`, trackingIDs,
 	)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	var dbInfos []*trackingInfoDBStruct
+	if err := s.readDB.SelectContext(ctx, &dbInfos, query, args...); err != nil {
+		return err
 	}
 
-	tracking, err := dbTracking.toBusinessStruct()
+	query, args, err = sqlx.In(`
+		SELECT e.* FROM tracking_events e JOIN tracking_infos i ON e.tracking_info_id = i.id
+		WHERE i.tracking_id IN (?) ORDER BY e.tracking_info_id, e.position`, trackingIDs,
+	)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	var dbEvents []*trackingEventDBStruct
+	if err := s.readDB.SelectContext(ctx, &dbEvents, query, args...); err != nil {
+		return err
+	}
+	eventsByInfoID := make(map[int64][]*trackingEventDBStruct)
+	for _, e := range dbEvents {
+		eventsByInfoID[e.TrackingInfoID] = append(eventsByInfoID[e.TrackingInfoID], e)
 	}
 
-	return tracking, nil
+	for _, d := range dbInfos {
+		ti, err := d.toBusinessStruct(eventsByInfoID[d.ID], s.cipher)
+		if err != nil {
+			return err
+		}
+		t := trackingsByID[d.TrackingID]
+		t.TrackingInfos = append(t.TrackingInfos, ti)
+	}
+	return nil
 }
 
-func (s *Storage) ListTrackingsByUserID(ctx context.Context, userID int64) ([]*core.Tracking, error) {
-	var dbTrackings []*dbStruct
-	err := s.db.SelectContext(ctx, &dbTrackings, `
-		SELECT * FROM trackings WHERE user_id = ?`, userID,
+// loadTags loads tags of the trackings
+func (s *Storage) loadTags(ctx context.Context, trackings []*core.Tracking) error {
+	if len(trackings) == 0 {
+		return nil
+	}
+	trackingsByID := make(map[int64]*core.Tracking, len(trackings))
+	trackingIDs := make([]int64, 0, len(trackings))
+	for _, t := range trackings {
+		trackingsByID[t.ID] = t
+		trackingIDs = append(trackingIDs, t.ID)
+	}
+
+	query, args, err := sqlx.In(`
+		SELECT tracking_id, tag FROM tracking_tags WHERE tracking_id IN (?) ORDER BY tracking_id, tag`, trackingIDs,
 	)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	var dbTags []struct {
+		TrackingID int64  `db:"tracking_id"`
+		Tag        string `db:"tag"`
+	}
+	if err := s.readDB.SelectContext(ctx, &dbTags, query, args...); err != nil {
+		return err
 	}
+	for _, d := range dbTags {
+		t := trackingsByID[d.TrackingID]
+		t.Tags = append(t.Tags, d.Tag)
+	}
+	return nil
+}
 
+// toBusinessStructs converts trackings and loads their tracking infos
+func (s *Storage) toBusinessStructs(ctx context.Context, dbTrackings []*dbStruct) ([]*core.Tracking, error) {
 	var trackings []*core.Tracking
 	for _, dbTracking := range dbTrackings {
-		tracking, err := dbTracking.toBusinessStruct()
+		tracking, err := dbTracking.toBusinessStruct(s.cipher)
 		if err != nil {
 			return nil, err
 		}
 		trackings = append(trackings, tracking)
 	}
+	if err := s.loadTrackingInfos(ctx, trackings); err != nil {
+		return nil, zaperr.Wrap(err, "failed to load tracking infos")
+	}
+	if err := s.loadTags(ctx, trackings); err != nil {
+		return nil, zaperr.Wrap(err, "failed to load tags")
+	}
 	return trackings, nil
 }
 
-func (s *Storage) ListTrackingsLastPolledBefore(ctx context.Context, time time.Time) ([]*core.Tracking, error) {
+func (s *Storage) GetTracking(ctx context.Context, userID int64, trackingNumber string) (_ *core.Tracking, err error) {
+	defer s.metrics.Observe("get_tracking", time.Now(), &err)
+	ctx, cancel := WithQueryTimeout(ctx, s.queryTimeout)
+	defer cancel()
+	var dbTracking dbStruct
+	err = s.readDB.GetContext(ctx, &dbTracking, `
+		SELECT * FROM trackings WHERE user_id = ? AND tracking_number = ? AND deleted_at IS NULL`, userID, trackingNumber,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, core.ErrTrackingNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	trackings, err := s.toBusinessStructs(ctx, []*dbStruct{&dbTracking})
+	if err != nil {
+		return nil, err
+	}
+
+	return trackings[0], nil
+}
+
+// ListTrackingsByUserID lists user's trackings ordered by ID, starting after cursor (0 for the first page)
+func (s *Storage) ListTrackingsByUserID(ctx context.Context, userID int64, cursor int64, limit int) (_ *core.TrackingsPage, err error) {
+	defer s.metrics.Observe("list_trackings_by_user_id", time.Now(), &err)
+	ctx, cancel := WithQueryTimeout(ctx, s.queryTimeout)
+	defer cancel()
 	var dbTrackings []*dbStruct
-	err := s.db.SelectContext(ctx, &dbTrackings, `
-		SELECT * FROM trackings WHERE last_polled_at is NULL OR last_polled_at < ?`, time,
+	// one extra row tells whether there is a next page
+	err = s.readDB.SelectContext(ctx, &dbTrackings, `
+		SELECT * FROM trackings WHERE user_id = ? AND id > ? AND deleted_at IS NULL ORDER BY id LIMIT ?`, userID, cursor, limit+1,
 	)
 	if err != nil {
 		return nil, err
 	}
 
-	var trackings []*core.Tracking
-	for _, dbTracking := range dbTrackings {
-		tracking, err := dbTracking.toBusinessStruct()
+	page := &core.TrackingsPage{}
+	if len(dbTrackings) > limit {
+		dbTrackings = dbTrackings[:limit]
+		page.NextCursor = dbTrackings[limit-1].ID
+	}
+	if page.Trackings, err = s.toBusinessStructs(ctx, dbTrackings); err != nil {
+		return nil, err
+	}
+	if page.Total, err = s.CountTrackingsByUserID(ctx, userID); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// ListTrackingsByTag lists user's trackings tagged with the tag ordered by ID, starting after cursor (0 for the first page)
+func (s *Storage) ListTrackingsByTag(ctx context.Context, userID int64, tag string, cursor int64, limit int) (_ *core.TrackingsPage, err error) {
+	defer s.metrics.Observe("list_trackings_by_tag", time.Now(), &err)
+	ctx, cancel := WithQueryTimeout(ctx, s.queryTimeout)
+	defer cancel()
+	var dbTrackings []*dbStruct
+	// one extra row tells whether there is a next page
+	err = s.readDB.SelectContext(ctx, &dbTrackings, `
+		SELECT t.* FROM trackings t JOIN tracking_tags tt ON tt.tracking_id = t.id
+		WHERE t.user_id = ? AND tt.tag = ? AND t.id > ? AND t.deleted_at IS NULL ORDER BY t.id LIMIT ?`, userID, tag, cursor, limit+1,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &core.TrackingsPage{}
+	if len(dbTrackings) > limit {
+		dbTrackings = dbTrackings[:limit]
+		page.NextCursor = dbTrackings[limit-1].ID
+	}
+	if page.Trackings, err = s.toBusinessStructs(ctx, dbTrackings); err != nil {
+		return nil, err
+	}
+	err = s.readDB.GetContext(ctx, &page.Total, `
+		SELECT COUNT(*) FROM trackings t JOIN tracking_tags tt ON tt.tracking_id = t.id
+		WHERE t.user_id = ? AND tt.tag = ? AND t.deleted_at IS NULL`, userID, tag,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+func (s *Storage) ListTrackingsByTrackingNumber(ctx context.Context, trackingNumber string) (_ []*core.Tracking, err error) {
+	defer s.metrics.Observe("list_trackings_by_tracking_number", time.Now(), &err)
+	ctx, cancel := WithQueryTimeout(ctx, s.queryTimeout)
+	defer cancel()
+	var dbTrackings []*dbStruct
+	err = s.readDB.SelectContext(ctx, &dbTrackings, `
+		SELECT * FROM trackings WHERE tracking_number = ? AND deleted_at IS NULL`, trackingNumber,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.toBusinessStructs(ctx, dbTrackings)
+}
+
+func (s *Storage) CountTrackingsByUserID(ctx context.Context, userID int64) (_ int, err error) {
+	defer s.metrics.Observe("count_trackings_by_user_id", time.Now(), &err)
+	ctx, cancel := WithQueryTimeout(ctx, s.queryTimeout)
+	defer cancel()
+	var count int
+	err = s.readDB.GetContext(ctx, &count, `
+		SELECT COUNT(*) FROM trackings WHERE user_id = ? AND deleted_at IS NULL`, userID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (s *Storage) ListTrackingsDueForPoll(ctx context.Context, now time.Time, after *core.PollCursor, limit int) (_ []*core.Tracking, err error) {
+	defer s.metrics.Observe("list_trackings_due_for_poll", time.Now(), &err)
+	ctx, cancel := WithQueryTimeout(ctx, s.queryTimeout)
+	defer cancel()
+	// rows are compared by (polled, -activity, id), which is the order of the queue
+	first, polled, activity, afterID := after == nil, false, int64(0), int64(0)
+	if after != nil {
+		polled, afterID = after.Polled, after.ID
+		if after.LastActivityAt != nil {
+			activity = after.LastActivityAt.Unix()
+		}
+	}
+	var dbTrackings []*dbStruct
+	err = s.readDB.SelectContext(ctx, &dbTrackings, `
+		SELECT * FROM trackings WHERE (next_poll_at is NULL OR next_poll_at <= ?) AND deleted_at IS NULL AND NOT manual
+		AND (? OR (last_polled_at IS NOT NULL, -COALESCE(last_activity_at, 0), id) > (?, ?, ?))
+		ORDER BY last_polled_at IS NOT NULL, -COALESCE(last_activity_at, 0), id LIMIT ?`,
+		now.Unix(), first, polled, -activity, afterID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.toBusinessStructs(ctx, dbTrackings)
+}
+
+// ListTrackings lists up to limit trackings of all users ordered by ID, starting after afterID
+func (s *Storage) ListTrackings(ctx context.Context, afterID int64, limit int) (_ []*core.Tracking, err error) {
+	defer s.metrics.Observe("list_trackings", time.Now(), &err)
+	ctx, cancel := WithQueryTimeout(ctx, s.queryTimeout)
+	defer cancel()
+	var dbTrackings []*dbStruct
+	err = s.readDB.SelectContext(ctx, &dbTrackings, `
+		SELECT * FROM trackings WHERE deleted_at IS NULL AND id > ? ORDER BY id LIMIT ?`, afterID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.toBusinessStructs(ctx, dbTrackings)
+}
+
+func (s *Storage) UpdatePollSchedule(ctx context.Context, tracking *core.Tracking) (err error) {
+	defer s.metrics.Observe("update_poll_schedule", time.Now(), &err)
+	ctx, cancel := WithQueryTimeout(ctx, s.queryTimeout)
+	defer cancel()
+	dbTracking, err := dbStruct{}.fromBusinessStruct(tracking, s.cipher)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE trackings SET last_polled_at = ?, next_poll_at = ?, fetch_etag = ?, fetch_last_modified = ? WHERE id = ?`
+	fields := []zap.Field{
+		zap.String("query", query),
+		zap.Any("dbTracking", dbTracking),
+	}
+
+	if _, err := s.execContext(
+		ctx, query, dbTracking.LastPolledAt, dbTracking.NextPollAt, dbTracking.FetchETag, dbTracking.FetchLastModified, dbTracking.ID,
+	); err != nil {
+		return zaperr.Wrap(err, "failed to execute", fields...)
+	}
+
+	return nil
+}
+
+func (s *Storage) ListPendingNotifications(ctx context.Context) (_ []*core.TrackingUpdate, err error) {
+	defer s.metrics.Observe("list_pending_notifications", time.Now(), &err)
+	ctx, cancel := WithQueryTimeout(ctx, s.queryTimeout)
+	defer cancel()
+	var dbNotifications []*notificationDBStruct
+	err = s.readDB.SelectContext(ctx, &dbNotifications, `
+		SELECT id, user_id, payload FROM pending_notifications ORDER BY id`,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var updates []*core.TrackingUpdate
+	for _, dbNotification := range dbNotifications {
+		update, err := dbNotification.toBusinessStruct(s.cipher)
 		if err != nil {
 			return nil, err
 		}
-		trackings = append(trackings, tracking)
+		updates = append(updates, update)
 	}
-	return trackings, nil
+	return updates, nil
+}
+
+// DeleteNotification deletes the pending notification once it is delivered, marking its update record delivered
+func (s *Storage) DeleteNotification(ctx context.Context, notificationID int64) (err error) {
+	defer s.metrics.Observe("delete_notification", time.Now(), &err)
+	ctx, cancel := WithQueryTimeout(ctx, s.queryTimeout)
+	defer cancel()
+	query := `
+		DELETE FROM pending_notifications WHERE id = ?;
+		UPDATE update_records SET delivered_at = ? WHERE notification_id = ?`
+
+	if _, err := s.execContext(ctx, query, notificationID, s.clock.Now().Unix(), notificationID); err != nil {
+		return zaperr.Wrap(err, "failed to execute", zap.String("query", query), zap.Int64("notificationID", notificationID))
+	}
+
+	return nil
+}
+
+func (s *Storage) SetPushSubscribed(ctx context.Context, trackingID int64, subscribed bool) (err error) {
+	defer s.metrics.Observe("set_push_subscribed", time.Now(), &err)
+	ctx, cancel := WithQueryTimeout(ctx, s.queryTimeout)
+	defer cancel()
+	query := `
+		UPDATE trackings SET push_subscribed = ? WHERE id = ?`
+
+	if _, err := s.execContext(ctx, query, subscribed, trackingID); err != nil {
+		return zaperr.Wrap(err, "failed to execute", zap.String("query", query), zap.Int64("trackingID", trackingID))
+	}
+
+	return nil
+}
+
+func (s *Storage) SetTrackingNote(ctx context.Context, trackingID int64, note string) (err error) {
+	defer s.metrics.Observe("set_tracking_note", time.Now(), &err)
+	ctx, cancel := WithQueryTimeout(ctx, s.queryTimeout)
+	defer cancel()
+	encrypted, err := s.cipher.encrypt(note)
+	if err != nil {
+		return zaperr.Wrap(err, "failed to encrypt note", zap.Int64("trackingID", trackingID))
+	}
+	query := `
+		UPDATE trackings SET note = ? WHERE id = ?`
+
+	if _, err := s.execContext(ctx, query, encrypted, trackingID); err != nil {
+		return zaperr.Wrap(err, "failed to execute", zap.String("query", query), zap.Int64("trackingID", trackingID))
+	}
+
+	return nil
+}
+
+func (s *Storage) SetTrackingDisplayName(ctx context.Context, trackingID int64, displayName string) (err error) {
+	defer s.metrics.Observe("set_tracking_display_name", time.Now(), &err)
+	ctx, cancel := WithQueryTimeout(ctx, s.queryTimeout)
+	defer cancel()
+	encrypted, err := s.cipher.encrypt(displayName)
+	if err != nil {
+		return zaperr.Wrap(err, "failed to encrypt display name", zap.Int64("trackingID", trackingID))
+	}
+	query := `
+		UPDATE trackings SET display_name = ? WHERE id = ?`
+
+	if _, err := s.execContext(ctx, query, encrypted, trackingID); err != nil {
+		return zaperr.Wrap(err, "failed to execute", zap.String("query", query), zap.Int64("trackingID", trackingID))
+	}
+
+	return nil
+}
+
+func (s *Storage) SetTrackingTags(ctx context.Context, trackingID int64, tags []string) (err error) {
+	defer s.metrics.Observe("set_tracking_tags", time.Now(), &err)
+	ctx, cancel := WithQueryTimeout(ctx, s.queryTimeout)
+	defer cancel()
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM tracking_tags WHERE tracking_id = ?`, trackingID); err != nil {
+			return zaperr.Wrap(err, "failed to delete tags", zap.Int64("trackingID", trackingID))
+		}
+		for _, tag := range tags {
+			_, err := tx.ExecContext(ctx, `INSERT INTO tracking_tags (tracking_id, tag) VALUES (?, ?)`, trackingID, tag)
+			if err != nil {
+				return zaperr.Wrap(err, "failed to insert tag", zap.Int64("trackingID", trackingID), zap.String("tag", tag))
+			}
+		}
+		return nil
+	})
+}
+
+func (s *Storage) SetStuckAlertedAt(ctx context.Context, trackingID int64, alertedAt time.Time) (err error) {
+	defer s.metrics.Observe("set_stuck_alerted_at", time.Now(), &err)
+	ctx, cancel := WithQueryTimeout(ctx, s.queryTimeout)
+	defer cancel()
+	query := `
+		UPDATE trackings SET stuck_alerted_at = ? WHERE id = ?`
+
+	if _, err := s.execContext(ctx, query, alertedAt.Unix(), trackingID); err != nil {
+		return zaperr.Wrap(err, "failed to execute", zap.String("query", query), zap.Int64("trackingID", trackingID))
+	}
+
+	return nil
+}
+
+func (s *Storage) SetRemindedAt(ctx context.Context, trackingID int64, remindedAt time.Time) (err error) {
+	defer s.metrics.Observe("set_reminded_at", time.Now(), &err)
+	ctx, cancel := WithQueryTimeout(ctx, s.queryTimeout)
+	defer cancel()
+	query := `
+		UPDATE trackings SET reminded_at = ? WHERE id = ?`
+
+	if _, err := s.execContext(ctx, query, remindedAt.Unix(), trackingID); err != nil {
+		return zaperr.Wrap(err, "failed to execute", zap.String("query", query), zap.Int64("trackingID", trackingID))
+	}
+
+	return nil
+}
+
+// AttachTrackingNumber turns the manual tracking into an ordinary one. A deleted tracking of the user with the number
+// would break the uniqueness of user's numbers, so it is purged first, just like PurgeDeletedTrackings does
+func (s *Storage) AttachTrackingNumber(ctx context.Context, trackingID int64, trackingNumber string, nextPollAt time.Time) (err error) {
+	defer s.metrics.Observe("attach_tracking_number", time.Now(), &err)
+	ctx, cancel := WithQueryTimeout(ctx, s.queryTimeout)
+	defer cancel()
+	deleted := `SELECT d.id FROM trackings d JOIN trackings t ON d.user_id = t.user_id
+		WHERE t.id = ? AND d.tracking_number = ? AND d.deleted_at IS NOT NULL`
+	purges := []string{`
+		DELETE FROM tracking_events WHERE tracking_info_id IN (SELECT id FROM tracking_infos WHERE tracking_id IN (` + deleted + `))`, `
+		DELETE FROM tracking_infos WHERE tracking_id IN (` + deleted + `)`, `
+		DELETE FROM tracking_tags WHERE tracking_id IN (` + deleted + `)`, `
+		DELETE FROM pending_notifications WHERE tracking_id IN (` + deleted + `)`, `
+		DELETE FROM update_records WHERE tracking_id IN (` + deleted + `)`, `
+		DELETE FROM trackings WHERE id IN (` + deleted + `)`,
+	}
+	query := `
+		UPDATE trackings SET tracking_number = ?, manual = 0, next_poll_at = ? WHERE id = ?`
+
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		for _, purge := range purges {
+			if _, err := tx.ExecContext(ctx, purge, trackingID, trackingNumber); err != nil {
+				return zaperr.Wrap(err, "failed to execute", zap.String("query", purge), zap.Int64("trackingID", trackingID))
+			}
+		}
+		if _, err := tx.ExecContext(ctx, query, trackingNumber, nextPollAt.Unix(), trackingID); err != nil {
+			return zaperr.Wrap(err, "failed to execute", zap.String("query", query), zap.Int64("trackingID", trackingID))
+		}
+		return nil
+	})
 }
 
-func (s *Storage) DeleteTracking(ctx context.Context, userID int64, trackingNumber string) error {
+func (s *Storage) SetNotificationLevel(ctx context.Context, userID int64, level core.NotificationLevel) (err error) {
+	defer s.metrics.Observe("set_notification_level", time.Now(), &err)
+	ctx, cancel := WithQueryTimeout(ctx, s.queryTimeout)
+	defer cancel()
 	query := `
-		DELETE FROM trackings WHERE user_id = ? AND tracking_number = ?`
+		INSERT INTO notification_levels (user_id, level) VALUES (?, ?)
+		ON CONFLICT DO UPDATE SET level = excluded.level`
+
+	if _, err := s.execContext(ctx, query, userID, string(level)); err != nil {
+		return zaperr.Wrap(err, "failed to execute", zap.String("query", query), zap.Int64("userID", userID))
+	}
+
+	return nil
+}
+
+func (s *Storage) SetTrackingNotificationLevel(ctx context.Context, trackingID int64, level core.NotificationLevel) (err error) {
+	defer s.metrics.Observe("set_tracking_notification_level", time.Now(), &err)
+	ctx, cancel := WithQueryTimeout(ctx, s.queryTimeout)
+	defer cancel()
+	query := `
+		UPDATE trackings SET notification_level = ? WHERE id = ?`
+
+	if _, err := s.execContext(ctx, query, string(level), trackingID); err != nil {
+		return zaperr.Wrap(err, "failed to execute", zap.String("query", query), zap.Int64("trackingID", trackingID))
+	}
+
+	return nil
+}
+
+func (s *Storage) GetNotificationLevel(ctx context.Context, userID int64) (_ core.NotificationLevel, err error) {
+	defer s.metrics.Observe("get_notification_level", time.Now(), &err)
+	ctx, cancel := WithQueryTimeout(ctx, s.queryTimeout)
+	defer cancel()
+	var level string
+	err = s.readDB.GetContext(ctx, &level, `SELECT level FROM notification_levels WHERE user_id = ?`, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return core.NotificationLevelAll, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return core.NotificationLevel(level), nil
+}
+
+// DeleteTracking soft-deletes the tracking: it is hidden from everything but RestoreTracking
+// until it is purged with PurgeDeletedTrackings
+func (s *Storage) DeleteTracking(ctx context.Context, userID int64, trackingNumber string) (err error) {
+	defer s.metrics.Observe("delete_tracking", time.Now(), &err)
+	ctx, cancel := WithQueryTimeout(ctx, s.queryTimeout)
+	defer cancel()
+	// notifications about a deleted tracking are of no use to anyone, so they are deleted right away
+	query := `
+		DELETE FROM pending_notifications WHERE tracking_id IN (
+			SELECT id FROM trackings WHERE user_id = ? AND tracking_number = ? AND deleted_at IS NULL
+		);
+		UPDATE trackings SET deleted_at = ? WHERE user_id = ? AND tracking_number = ? AND deleted_at IS NULL`
 	fields := []zap.Field{
 		zap.String("query", query),
 		zap.Any("userID", userID),
 		zap.Any("trackingNumber", trackingNumber),
 	}
 
-	s.writeAccessMutex.Lock()
-	defer s.writeAccessMutex.Unlock()
+	if _, err := s.execContext(ctx, query, userID, trackingNumber, s.clock.Now().Unix(), userID, trackingNumber); err != nil {
+		return zaperr.Wrap(err, "failed to execute", fields...)
+	}
+
+	return nil
+}
 
-	if _, err := s.db.ExecContext(ctx, query, userID, trackingNumber); err != nil {
+// RestoreTracking undoes DeleteTracking for trackings deleted after deletedAfter,
+// it returns core.ErrTrackingNotFound if there's no such tracking
+func (s *Storage) RestoreTracking(ctx context.Context, userID int64, trackingNumber string, deletedAfter time.Time) (err error) {
+	defer s.metrics.Observe("restore_tracking", time.Now(), &err)
+	ctx, cancel := WithQueryTimeout(ctx, s.queryTimeout)
+	defer cancel()
+	query := `
+		UPDATE trackings SET deleted_at = NULL
+		WHERE user_id = ? AND tracking_number = ? AND deleted_at IS NOT NULL AND deleted_at >= ?`
+	fields := []zap.Field{
+		zap.String("query", query),
+		zap.Any("userID", userID),
+		zap.Any("trackingNumber", trackingNumber),
+	}
+
+	res, err := s.execContext(ctx, query, userID, trackingNumber, deletedAfter.Unix())
+	if err != nil {
 		return zaperr.Wrap(err, "failed to execute", fields...)
 	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return zaperr.Wrap(err, "failed to get affected rows", fields...)
+	}
+	if affected == 0 {
+		return core.ErrTrackingNotFound
+	}
 
 	return nil
 }
+
+// PurgeDeletedTrackings permanently deletes trackings deleted before deletedBefore along with their tracking infos, tags
+// and update records.
+// It returns the number of purged trackings
+func (s *Storage) PurgeDeletedTrackings(ctx context.Context, deletedBefore time.Time) (_ int64, err error) {
+	defer s.metrics.Observe("purge_deleted_trackings", time.Now(), &err)
+	ctx, cancel := WithQueryTimeout(ctx, s.queryTimeout)
+	defer cancel()
+	queries := []string{`
+		DELETE FROM tracking_events WHERE tracking_info_id IN (
+			SELECT i.id FROM tracking_infos i JOIN trackings t ON i.tracking_id = t.id WHERE t.deleted_at < ?
+		)`, `
+		DELETE FROM tracking_infos WHERE tracking_id IN (SELECT id FROM trackings WHERE deleted_at < ?)`, `
+		DELETE FROM tracking_tags WHERE tracking_id IN (SELECT id FROM trackings WHERE deleted_at < ?)`, `
+		DELETE FROM pending_notifications WHERE tracking_id IN (SELECT id FROM trackings WHERE deleted_at < ?)`, `
+		DELETE FROM update_records WHERE tracking_id IN (SELECT id FROM trackings WHERE deleted_at < ?)`, `
+		DELETE FROM trackings WHERE deleted_at < ?`,
+	}
+
+	var purged int64
+	err = s.inTx(ctx, func(tx *sql.Tx) error {
+		for _, query := range queries {
+			res, err := tx.ExecContext(ctx, query, deletedBefore.Unix())
+			if err != nil {
+				return zaperr.Wrap(err, "failed to execute", zap.String("query", query))
+			}
+			// the last query deletes trackings themselves
+			if purged, err = res.RowsAffected(); err != nil {
+				return zaperr.Wrap(err, "failed to get affected rows", zap.String("query", query))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return purged, nil
+}
+
+// DeleteUserData permanently deletes user's trackings (deleted ones too), their tracking infos, tags,
+// pending notifications, update records, audit records and notification level. Transit samples are kept, since they are anonymous
+func (s *Storage) DeleteUserData(ctx context.Context, userID int64) (err error) {
+	defer s.metrics.Observe("delete_user_data", time.Now(), &err)
+	ctx, cancel := WithQueryTimeout(ctx, s.queryTimeout)
+	defer cancel()
+	queries := []string{`
+		DELETE FROM tracking_events WHERE tracking_info_id IN (
+			SELECT i.id FROM tracking_infos i JOIN trackings t ON i.tracking_id = t.id WHERE t.user_id = ?
+		)`, `
+		DELETE FROM tracking_infos WHERE tracking_id IN (SELECT id FROM trackings WHERE user_id = ?)`, `
+		DELETE FROM tracking_tags WHERE tracking_id IN (SELECT id FROM trackings WHERE user_id = ?)`, `
+		DELETE FROM pending_notifications WHERE user_id = ?`, `
+		DELETE FROM update_records WHERE user_id = ?`, `
+		DELETE FROM audit_records WHERE user_id = ?`, `
+		DELETE FROM notification_levels WHERE user_id = ?`, `
+		DELETE FROM trackings WHERE user_id = ?`,
+	}
+
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		for _, query := range queries {
+			if _, err := tx.ExecContext(ctx, query, userID); err != nil {
+				return zaperr.Wrap(err, "failed to execute", zap.String("query", query), zap.Int64("userID", userID))
+			}
+		}
+		return nil
+	})
+}
+
+func (s *Storage) ListUserIDs(ctx context.Context, afterUserID int64, limit int) (_ []int64, err error) {
+	defer s.metrics.Observe("list_user_ids", time.Now(), &err)
+	ctx, cancel := WithQueryTimeout(ctx, s.queryTimeout)
+	defer cancel()
+	var userIDs []int64
+	err = s.readDB.SelectContext(ctx, &userIDs, `
+		SELECT DISTINCT user_id FROM trackings WHERE user_id > ? ORDER BY user_id LIMIT ?`, afterUserID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return userIDs, nil
+}
+
+func (s *Storage) PurgeUpdateRecords(ctx context.Context, createdBefore time.Time) (_ int64, err error) {
+	defer s.metrics.Observe("purge_update_records", time.Now(), &err)
+	ctx, cancel := WithQueryTimeout(ctx, s.queryTimeout)
+	defer cancel()
+	return s.purgeRecords(ctx, `DELETE FROM update_records WHERE created_at < ?`, createdBefore)
+}
+
+func (s *Storage) PurgeAuditRecords(ctx context.Context, createdBefore time.Time) (_ int64, err error) {
+	defer s.metrics.Observe("purge_audit_records", time.Now(), &err)
+	ctx, cancel := WithQueryTimeout(ctx, s.queryTimeout)
+	defer cancel()
+	return s.purgeRecords(ctx, `DELETE FROM audit_records WHERE created_at < ?`, createdBefore)
+}
+
+func (s *Storage) purgeRecords(ctx context.Context, query string, createdBefore time.Time) (int64, error) {
+	res, err := s.execContext(ctx, query, createdBefore.Unix())
+	if err != nil {
+		return 0, zaperr.Wrap(err, "failed to execute", zap.String("query", query))
+	}
+	purged, err := res.RowsAffected()
+	if err != nil {
+		return 0, zaperr.Wrap(err, "failed to get affected rows", zap.String("query", query))
+	}
+	return purged, nil
+}
+
+func (s *Storage) SaveAuditRecord(ctx context.Context, record *core.AuditRecord) (err error) {
+	defer s.metrics.Observe("save_audit_record", time.Now(), &err)
+	ctx, cancel := WithQueryTimeout(ctx, s.queryTimeout)
+	defer cancel()
+	dbRecord, err := auditRecordDBStruct{}.fromBusinessStruct(record, s.cipher)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO audit_records
+			(tracking_id, user_id, tracking_number, action, details, created_at)
+		VALUES
+			(:tracking_id, :user_id, :tracking_number, :action, :details, :created_at)`
+	fields := []zap.Field{
+		zap.String("query", query),
+		zap.Any("dbRecord", dbRecord),
+	}
+
+	err = retryOnBusy(ctx, func() error {
+		_, err := s.db.NamedExecContext(ctx, query, dbRecord)
+		return err
+	})
+	if err != nil {
+		return zaperr.Wrap(err, "failed to execute", fields...)
+	}
+
+	return nil
+}
+
+func (s *Storage) ListAuditRecords(ctx context.Context, userID int64, trackingNumber string) (_ []*core.AuditRecord, err error) {
+	defer s.metrics.Observe("list_audit_records", time.Now(), &err)
+	ctx, cancel := WithQueryTimeout(ctx, s.queryTimeout)
+	defer cancel()
+	var dbRecords []*auditRecordDBStruct
+	err = s.readDB.SelectContext(ctx, &dbRecords, `
+		SELECT * FROM audit_records WHERE user_id = ? AND tracking_number = ? ORDER BY id`, userID, trackingNumber,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []*core.AuditRecord
+	for _, dbRecord := range dbRecords {
+		record, err := dbRecord.toBusinessStruct(s.cipher)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func (s *Storage) ListUpdateRecords(ctx context.Context, userID int64, trackingNumber string, limit int) (_ []*core.UpdateRecord, err error) {
+	defer s.metrics.Observe("list_update_records", time.Now(), &err)
+	ctx, cancel := WithQueryTimeout(ctx, s.queryTimeout)
+	defer cancel()
+	var dbRecords []*updateRecordDBStruct
+	err = s.readDB.SelectContext(ctx, &dbRecords, `
+		SELECT * FROM update_records WHERE user_id = ? AND tracking_number = ? ORDER BY id DESC LIMIT ?`,
+		userID, trackingNumber, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []*core.UpdateRecord
+	for _, dbRecord := range dbRecords {
+		record, err := dbRecord.toBusinessStruct(s.cipher)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func (s *Storage) SaveTransitSamples(ctx context.Context, samples []*core.TransitSample) (err error) {
+	defer s.metrics.Observe("save_transit_samples", time.Now(), &err)
+	ctx, cancel := WithQueryTimeout(ctx, s.queryTimeout)
+	defer cancel()
+	query := `
+		INSERT INTO transit_samples (route, stage, duration_seconds) VALUES (?, ?, ?)`
+
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		for _, sample := range samples {
+			if _, err := tx.ExecContext(ctx, query, sample.Route, string(sample.Stage), int64(sample.Duration.Seconds())); err != nil {
+				return zaperr.Wrap(err, "failed to execute", zap.String("query", query), zap.Any("sample", sample))
+			}
+		}
+		return nil
+	})
+}
+
+func (s *Storage) SaveDeliveryConfirmation(ctx context.Context, confirmation *core.DeliveryConfirmation) (err error) {
+	defer s.metrics.Observe("save_delivery_confirmation", time.Now(), &err)
+	ctx, cancel := WithQueryTimeout(ctx, s.queryTimeout)
+	defer cancel()
+	query := `
+		INSERT INTO delivery_confirmations (route, received) VALUES (?, ?)`
+
+	if _, err := s.execContext(ctx, query, confirmation.Route, confirmation.Received); err != nil {
+		return zaperr.Wrap(err, "failed to execute", zap.String("query", query), zap.Any("confirmation", confirmation))
+	}
+	return nil
+}
+
+func (s *Storage) ListRouteDeliveryConfirmations(ctx context.Context) (_ []*core.RouteDeliveryConfirmations, err error) {
+	defer s.metrics.Observe("list_route_delivery_confirmations", time.Now(), &err)
+	ctx, cancel := WithQueryTimeout(ctx, s.queryTimeout)
+	defer cancel()
+	var rows []struct {
+		Route       string `db:"route"`
+		Received    int    `db:"received"`
+		NotReceived int    `db:"not_received"`
+	}
+	err = s.readDB.SelectContext(ctx, &rows, `
+		SELECT route, SUM(received) AS received, SUM(1 - received) AS not_received FROM delivery_confirmations
+		GROUP BY route ORDER BY route`,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	confirmations := make([]*core.RouteDeliveryConfirmations, 0, len(rows))
+	for _, r := range rows {
+		confirmations = append(confirmations, &core.RouteDeliveryConfirmations{
+			Route:       r.Route,
+			Received:    r.Received,
+			NotReceived: r.NotReceived,
+		})
+	}
+	return confirmations, nil
+}
+
+func (s *Storage) SetDeliveryDisputedAt(ctx context.Context, trackingID int64, disputedAt *time.Time) (err error) {
+	defer s.metrics.Observe("set_delivery_disputed_at", time.Now(), &err)
+	ctx, cancel := WithQueryTimeout(ctx, s.queryTimeout)
+	defer cancel()
+	query := `
+		UPDATE trackings SET delivery_disputed_at = ? WHERE id = ?`
+
+	var at *int64
+	if disputedAt != nil {
+		unix := disputedAt.Unix()
+		at = &unix
+	}
+	if _, err := s.execContext(ctx, query, at, trackingID); err != nil {
+		return zaperr.Wrap(err, "failed to execute", zap.String("query", query), zap.Int64("trackingID", trackingID))
+	}
+	return nil
+}
+
+func (s *Storage) ListTransitDurations(ctx context.Context, route string, stage core.Status, limit int) (_ []time.Duration, err error) {
+	defer s.metrics.Observe("list_transit_durations", time.Now(), &err)
+	ctx, cancel := WithQueryTimeout(ctx, s.queryTimeout)
+	defer cancel()
+	var seconds []int64
+	err = s.readDB.SelectContext(ctx, &seconds, `
+		SELECT duration_seconds FROM transit_samples WHERE stage = ? AND (? = '' OR route = ?) ORDER BY id DESC LIMIT ?`,
+		string(stage), route, route, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	durations := make([]time.Duration, 0, len(seconds))
+	for _, s := range seconds {
+		durations = append(durations, time.Duration(s)*time.Second)
+	}
+	return durations, nil
+}
+
+func (s *Storage) ListRouteTransitTimes(ctx context.Context, stage core.Status, minSamples int) (_ []*core.RouteTransitTime, err error) {
+	defer s.metrics.Observe("list_route_transit_times", time.Now(), &err)
+	ctx, cancel := WithQueryTimeout(ctx, s.queryTimeout)
+	defer cancel()
+	var rows []struct {
+		Route          string  `db:"route"`
+		Count          int     `db:"count"`
+		AverageSeconds float64 `db:"average_seconds"`
+	}
+	err = s.readDB.SelectContext(ctx, &rows, `
+		SELECT route, COUNT(*) AS count, AVG(duration_seconds) AS average_seconds FROM transit_samples
+		WHERE stage = ? GROUP BY route HAVING count >= ? ORDER BY route`,
+		string(stage), minSamples,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	times := make([]*core.RouteTransitTime, 0, len(rows))
+	for _, r := range rows {
+		times = append(times, &core.RouteTransitTime{
+			Route:        r.Route,
+			SamplesCount: r.Count,
+			Average:      time.Duration(r.AverageSeconds) * time.Second,
+		})
+	}
+	return times, nil
+}
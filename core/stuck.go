@@ -0,0 +1,84 @@
+package core
+
+import (
+	"context"
+	"time"
+
+	"github.com/hori-ryota/zaperr"
+	"go.uber.org/zap"
+)
+
+// stuckScanInterval is how often trackings are checked for being stuck, alerts are about days, so there's no hurry
+const stuckScanInterval = time.Hour
+
+// scanStuckTrackings alerts users of their trackings that haven't had new events for stuckAfter while not delivered.
+// Every tracking is alerted of once per latest event, so a stuck tracking is alerted of again only after it moves
+// and gets stuck once more. Alerts aren't persisted: a missed alert is not worth redelivering, unlike a missed event
+func (s *ServiceImpl) scanStuckTrackings(ctx context.Context) {
+	now := s.clock.Now()
+	alerted := 0
+	var afterID int64
+	for {
+		trackings, err := s.storage.ListTrackings(ctx, afterID, pollBatchSize)
+		if err != nil {
+			s.logger.Error("failed to list trackings", zaperr.ToField(err))
+			return
+		}
+		for _, tracking := range trackings {
+			if ctx.Err() != nil {
+				return
+			}
+			stuckSince, ok := stuckSince(tracking, now, s.stuckAfter)
+			if !ok {
+				continue
+			}
+			if err := s.storage.SetStuckAlertedAt(ctx, tracking.ID, now); err != nil {
+				s.logger.Error("failed to save stuck alert", append(TrackingFields(tracking), zaperr.ToField(err))...)
+				continue
+			}
+			s.publishTrackingUpdate(ctx, TrackingUpdate{
+				TrackingNumber: tracking.TrackingNumber,
+				UserID:         tracking.UserID,
+				DisplayName:    tracking.DisplayName,
+				StuckSince:     &stuckSince,
+			})
+			alerted++
+		}
+		if len(trackings) < pollBatchSize {
+			break
+		}
+		afterID = trackings[len(trackings)-1].ID
+	}
+	if alerted > 0 {
+		s.logger.Info("alerted of stuck trackings", zap.Int("trackings_count", alerted))
+	}
+}
+
+// stuckSince returns the time of the latest event of the tracking if the tracking is stuck and hasn't been alerted of yet.
+// Trackings without events with parsable times are never considered stuck
+func stuckSince(tracking *Tracking, now time.Time, stuckAfter time.Duration) (time.Time, bool) {
+	if tracking.Status().IsTerminal() {
+		return time.Time{}, false
+	}
+	latest := latestEventTime(tracking)
+	if latest.IsZero() || now.Sub(latest) < stuckAfter {
+		return time.Time{}, false
+	}
+	if tracking.StuckAlertedAt != nil && tracking.StuckAlertedAt.After(latest) {
+		return time.Time{}, false
+	}
+	return latest, true
+}
+
+// latestEventTime is the time of the latest event of the tracking with a parsable time, zero if there's none
+func latestEventTime(tracking *Tracking) time.Time {
+	var latest time.Time
+	for _, info := range tracking.TrackingInfos {
+		for _, e := range info.Events {
+			if t, ok := ParseEventTime(e.Time); ok && t.After(latest) {
+				latest = t
+			}
+		}
+	}
+	return latest
+}
@@ -0,0 +1,85 @@
+package core
+
+import (
+	"context"
+	"time"
+
+	"github.com/hori-ryota/zaperr"
+)
+
+// Summary is the state of a user's trackings over a period, e.g. a week.
+// Every tracking is in at most one of Delivered, Stuck and Moved, trackings in Expected may have moved as well
+type Summary struct {
+	// Delivered are trackings delivered within the period
+	Delivered []*Tracking
+	// Stuck are undelivered trackings without new events for as long as stuck alerts take, if those are enabled
+	Stuck []*Tracking
+	// Moved are undelivered trackings with new events within the period
+	Moved []*Tracking
+	// Expected are undelivered trackings expected to be delivered within the period after now
+	Expected []ExpectedTracking
+	// ActiveCount is the number of undelivered trackings, including the ones that neither moved nor got stuck
+	ActiveCount int
+}
+
+// ExpectedTracking is a tracking with its predicted delivery window
+type ExpectedTracking struct {
+	Tracking *Tracking
+	ETA      *ETA
+}
+
+// IsEmpty tells whether there's nothing to summarize, i.e. the user has no active trackings and nothing got delivered
+func (s *Summary) IsEmpty() bool {
+	return s.ActiveCount == 0 && len(s.Delivered) == 0
+}
+
+func (s *ServiceImpl) Summary(ctx context.Context, userID int64, period time.Duration) (*Summary, error) {
+	now := s.clock.Now()
+	summary := &Summary{}
+	var cursor int64
+	for {
+		page, err := s.storage.ListTrackingsByUserID(ctx, userID, cursor, summaryPageSize)
+		if err != nil {
+			return nil, zaperr.Wrap(err, "failed to list trackings", UserIDField(userID))
+		}
+		for _, t := range page.Trackings {
+			s.summarize(ctx, summary, t, now, period)
+		}
+		if page.NextCursor == 0 {
+			return summary, nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+func (s *ServiceImpl) summarize(ctx context.Context, summary *Summary, tracking *Tracking, now time.Time, period time.Duration) {
+	status := tracking.Status()
+	if status == StatusDelivered {
+		if at, ok := tracking.DeliveredAt(); ok && now.Sub(at) <= period {
+			summary.Delivered = append(summary.Delivered, tracking)
+		}
+		return
+	}
+	if status.IsTerminal() {
+		return
+	}
+
+	summary.ActiveCount++
+	latest := latestEventTime(tracking)
+	switch {
+	case s.stuckAfter > 0 && !latest.IsZero() && now.Sub(latest) >= s.stuckAfter:
+		summary.Stuck = append(summary.Stuck, tracking)
+	case !latest.IsZero() && now.Sub(latest) <= period:
+		summary.Moved = append(summary.Moved, tracking)
+	}
+
+	eta, err := s.eta.Estimate(ctx, tracking)
+	if err != nil {
+		// the rest of the summary is still worth sending
+		s.logger.Error("failed to estimate delivery", append(TrackingFields(tracking), zaperr.ToField(err))...)
+		return
+	}
+	if eta != nil && eta.From.Before(now.Add(period)) {
+		summary.Expected = append(summary.Expected, ExpectedTracking{Tracking: tracking, ETA: eta})
+	}
+}
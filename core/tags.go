@@ -0,0 +1,50 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// MaxTagsPerTracking limits how many tags a tracking can have
+const MaxTagsPerTracking = 10
+
+var ErrInvalidTag = errors.New("invalid tag")
+var ErrTooManyTags = fmt.Errorf("more than %d tags", MaxTagsPerTracking)
+
+// tagRe follows Telegram hashtags, so that tags are clickable in messages
+var tagRe = regexp.MustCompile(`^[\p{L}\p{N}_]{1,32}$`)
+var digitsRe = regexp.MustCompile(`^[0-9]+$`)
+
+// NormalizeTag lowercases the tag and strips its leading #, it returns ErrInvalidTag for anything but
+// letters, digits and underscores. Tags of digits only (e.g. "#123" of "Order #123") aren't tags either
+func NormalizeTag(tag string) (string, error) {
+	normalized := strings.ToLower(strings.TrimPrefix(tag, "#"))
+	if !tagRe.MatchString(normalized) || digitsRe.MatchString(normalized) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidTag, tag)
+	}
+	return normalized, nil
+}
+
+// normalizeTags normalizes, deduplicates and sorts the tags
+func normalizeTags(tags []string) ([]string, error) {
+	seen := make(map[string]bool, len(tags))
+	var result []string
+	for _, tag := range tags {
+		normalized, err := NormalizeTag(tag)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[normalized] {
+			seen[normalized] = true
+			result = append(result, normalized)
+		}
+	}
+	if len(result) > MaxTagsPerTracking {
+		return nil, ErrTooManyTags
+	}
+	sort.Strings(result)
+	return result, nil
+}
@@ -0,0 +1,41 @@
+package core
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// ErrPostalCodeRequired is returned by Track for numbers of carriers that only tell anything given the postal code
+// of the recipient (see Carrier.NeedsPostalCode), when no postal code is given
+var ErrPostalCodeRequired = errors.New("postal code is required")
+
+// ErrInvalidPostalCode is returned by Track for postal codes that can't be postal codes of any country
+var ErrInvalidPostalCode = errors.New("invalid postal code")
+
+// postalCodeRe is what postal codes of all countries are made of, once spaces and dashes are stripped
+var postalCodeRe = regexp.MustCompile(`^[0-9A-Z]{3,10}$`)
+
+// TrackingParams are what some carriers need along with the tracking number to tell anything about the parcel.
+// The zero value means there are none
+type TrackingParams struct {
+	// PostalCode is the postal code of the recipient, normalized with NormalizePostalCode
+	PostalCode string
+	// Carrier is the code of the carrier the user has told the parcel is handled by (see CarrierByCode),
+	// so that providers query only that carrier instead of guessing. Empty means DetectCarrier guesses it
+	Carrier string
+}
+
+func (p TrackingParams) IsZero() bool {
+	return p == TrackingParams{}
+}
+
+// NormalizePostalCode uppercases the postal code and strips spaces and dashes, e.g. "1234 ab" is "1234AB".
+// It returns ErrInvalidPostalCode if the result can't be a postal code, an empty postal code is left empty
+func NormalizePostalCode(postalCode string) (string, error) {
+	n := strings.NewReplacer(" ", "", "-", "").Replace(strings.ToUpper(strings.TrimSpace(postalCode)))
+	if n != "" && !postalCodeRe.MatchString(n) {
+		return "", ErrInvalidPostalCode
+	}
+	return n, nil
+}
@@ -0,0 +1,44 @@
+package core
+
+import (
+	"context"
+	"time"
+)
+
+// UpdateRecord is a TrackingUpdate as it was saved by SaveTrackingUpdate. Records are kept once their notifications
+// are delivered, so that users can see the last update again and undelivered ones can be told apart.
+// Just like AuditRecord, records refer to the tracking by user and tracking number
+type UpdateRecord struct {
+	ID             int64
+	TrackingID     int64
+	UserID         int64
+	TrackingNumber string
+	// Update never has NotificationID set, the update is not pending anymore once it is read from the history
+	Update    *TrackingUpdate
+	CreatedAt time.Time
+	// DeliveredAt is when the notification about the update was deleted as delivered, nil if it hasn't been yet
+	DeliveredAt *time.Time
+}
+
+// UpdateRecordStorage lists what SaveTrackingUpdate records, see UpdateRecord.
+// Records are purged along with their trackings and deleted with the rest of user data
+type UpdateRecordStorage interface {
+	// ListUpdateRecords lists up to limit latest updates of the tracking, latest first
+	ListUpdateRecords(ctx context.Context, userID int64, trackingNumber string, limit int) ([]*UpdateRecord, error)
+}
+
+// LastUpdate returns the latest update of the tracking, whether the user has been notified of it or not.
+// It returns ErrTrackingNotFound if the user doesn't track the number, and nil if the tracking has never been updated
+func (s *ServiceImpl) LastUpdate(ctx context.Context, userID int64, trackingNumber string) (*UpdateRecord, error) {
+	if _, err := s.storage.GetTracking(ctx, userID, trackingNumber); err != nil {
+		return nil, err
+	}
+	records, err := s.storage.ListUpdateRecords(ctx, userID, trackingNumber, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	return records[0], nil
+}
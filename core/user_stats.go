@@ -0,0 +1,114 @@
+package core
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/hori-ryota/zaperr"
+)
+
+// UserStats are statistics of a user's trackings
+type UserStats struct {
+	// Tracked is the number of the user's trackings, deleted ones aside
+	Tracked   int
+	InTransit int
+	Delivered int
+	// AverageDeliveryTime is the average time from the first event to delivery,
+	// 0 if no delivered tracking has both of those times known
+	AverageDeliveryTime time.Duration
+	// Carriers are the carriers of delivered trackings with known delivery times, fastest first.
+	// Trackings of unknown carriers are left out, see Tracking.Carrier
+	Carriers []CarrierStats
+}
+
+// CarrierStats are statistics of a user's trackings handled by a carrier
+type CarrierStats struct {
+	Name                string
+	Delivered           int
+	AverageDeliveryTime time.Duration
+}
+
+func (s *ServiceImpl) UserStats(ctx context.Context, userID int64) (*UserStats, error) {
+	stats := &UserStats{}
+	var total time.Duration
+	var timed int
+	carriers := make(map[string]*CarrierStats)
+	var cursor int64
+	for {
+		page, err := s.storage.ListTrackingsByUserID(ctx, userID, cursor, userStatsPageSize)
+		if err != nil {
+			return nil, zaperr.Wrap(err, "failed to list trackings", UserIDField(userID))
+		}
+		for _, t := range page.Trackings {
+			stats.Tracked++
+			status := t.Status()
+			if !status.IsTerminal() {
+				stats.InTransit++
+			}
+			if status != StatusDelivered {
+				continue
+			}
+			stats.Delivered++
+			took, ok := deliveryTime(t)
+			if !ok {
+				continue
+			}
+			total += took
+			timed++
+			carrier := t.Carrier()
+			if carrier == nil {
+				continue
+			}
+			c, ok := carriers[carrier.Name]
+			if !ok {
+				c = &CarrierStats{Name: carrier.Name}
+				carriers[carrier.Name] = c
+			}
+			// the sum of delivery times until the averages are taken below
+			c.AverageDeliveryTime += took
+			c.Delivered++
+		}
+		if page.NextCursor == 0 {
+			break
+		}
+		cursor = page.NextCursor
+	}
+
+	if timed > 0 {
+		stats.AverageDeliveryTime = total / time.Duration(timed)
+	}
+	for _, c := range carriers {
+		c.AverageDeliveryTime /= time.Duration(c.Delivered)
+		stats.Carriers = append(stats.Carriers, *c)
+	}
+	sort.Slice(stats.Carriers, func(i, j int) bool {
+		a, b := stats.Carriers[i], stats.Carriers[j]
+		if a.AverageDeliveryTime != b.AverageDeliveryTime {
+			return a.AverageDeliveryTime < b.AverageDeliveryTime
+		}
+		return a.Name < b.Name
+	})
+	return stats, nil
+}
+
+// deliveryTime is how long it took the delivered tracking to get from its first event to delivery,
+// ok is false unless both times are known
+func deliveryTime(tracking *Tracking) (took time.Duration, ok bool) {
+	deliveredAt, ok := tracking.DeliveredAt()
+	if !ok {
+		return 0, false
+	}
+	var first time.Time
+	for _, info := range tracking.TrackingInfos {
+		for _, e := range info.Events {
+			if t, parsed := ParseEventTime(e.Time); parsed && (first.IsZero() || t.Before(first)) {
+				first = t
+			}
+		}
+	}
+	if first.IsZero() || first.After(deliveredAt) {
+		return 0, false
+	}
+	return deliveredAt.Sub(first), true
+}
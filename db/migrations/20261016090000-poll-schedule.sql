@@ -0,0 +1,13 @@
+-- +migrate Up
+ALTER TABLE trackings ADD COLUMN next_poll_at INTEGER;
+
+-- spread already existing trackings over the default polling interval,
+-- so that they don't all become due at once
+UPDATE trackings SET next_poll_at = CAST(strftime('%s', 'now') AS INTEGER) + abs(random() % 600);
+
+CREATE INDEX trackings_next_poll_at ON trackings (next_poll_at);
+
+
+-- +migrate Down
+DROP INDEX trackings_next_poll_at;
+ALTER TABLE trackings DROP COLUMN next_poll_at;
@@ -0,0 +1,6 @@
+-- +migrate Up
+ALTER TABLE trackings ADD COLUMN seen_event_hashes TEXT;
+
+
+-- +migrate Down
+ALTER TABLE trackings DROP COLUMN seen_event_hashes;
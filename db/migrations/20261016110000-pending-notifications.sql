@@ -0,0 +1,14 @@
+-- +migrate Up
+CREATE TABLE pending_notifications (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    tracking_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    payload TEXT NOT NULL,
+    created_at INTEGER NOT NULL
+);
+
+CREATE INDEX pending_notifications_tracking_id ON pending_notifications (tracking_id);
+
+
+-- +migrate Down
+DROP TABLE pending_notifications;
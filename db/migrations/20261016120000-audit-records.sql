@@ -0,0 +1,16 @@
+-- +migrate Up
+CREATE TABLE audit_records (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    tracking_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    tracking_number TEXT NOT NULL,
+    action TEXT NOT NULL,
+    details TEXT NOT NULL DEFAULT '',
+    created_at INTEGER NOT NULL
+);
+
+CREATE INDEX audit_records_user_id_tracking_number ON audit_records (user_id, tracking_number);
+
+
+-- +migrate Down
+DROP TABLE audit_records;
@@ -0,0 +1,13 @@
+-- +migrate Up
+CREATE TABLE transit_samples (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    route TEXT NOT NULL,
+    stage TEXT NOT NULL,
+    duration_seconds INTEGER NOT NULL
+);
+
+CREATE INDEX transit_samples_stage_route ON transit_samples (stage, route);
+
+
+-- +migrate Down
+DROP TABLE transit_samples;
@@ -0,0 +1,6 @@
+-- +migrate Up
+ALTER TABLE trackings ADD COLUMN push_subscribed INTEGER NOT NULL DEFAULT 0;
+
+
+-- +migrate Down
+ALTER TABLE trackings DROP COLUMN push_subscribed;
@@ -0,0 +1,77 @@
+-- +migrate Up
+CREATE TABLE tracking_infos (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    tracking_id INTEGER NOT NULL,
+    position INTEGER NOT NULL,
+    api_name TEXT NOT NULL,
+    tracking_number TEXT NOT NULL,
+    is_delivered INTEGER NOT NULL DEFAULT 0,
+    last_checked_at TEXT NOT NULL DEFAULT '',
+    last_updated_at TEXT NOT NULL DEFAULT ''
+);
+
+CREATE UNIQUE INDEX tracking_infos_tracking_id_api_name ON tracking_infos (tracking_id, api_name);
+
+CREATE TABLE tracking_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    tracking_info_id INTEGER NOT NULL,
+    position INTEGER NOT NULL,
+    time TEXT NOT NULL,
+    description TEXT NOT NULL,
+    status TEXT NOT NULL
+);
+
+CREATE INDEX tracking_events_tracking_info_id ON tracking_events (tracking_info_id);
+
+INSERT OR IGNORE INTO tracking_infos
+    (tracking_id, position, api_name, tracking_number, is_delivered, last_checked_at, last_updated_at)
+SELECT
+    t.id,
+    i.key,
+    json_extract(i.value, '$.api_name'),
+    COALESCE(json_extract(i.value, '$.tracking_number'), t.tracking_number),
+    COALESCE(json_extract(i.value, '$.is_delivered'), 0),
+    COALESCE(json_extract(i.value, '$.last_checked_at'), ''),
+    COALESCE(json_extract(i.value, '$.last_updated_at'), '')
+FROM trackings t, json_each(t.payload) i
+WHERE json_valid(t.payload) AND json_type(t.payload) = 'array' AND i.type = 'object';
+
+INSERT INTO tracking_events
+    (tracking_info_id, position, time, description, status)
+SELECT
+    ti.id,
+    e.key,
+    COALESCE(json_extract(e.value, '$.time'), ''),
+    COALESCE(json_extract(e.value, '$.description'), ''),
+    COALESCE(json_extract(e.value, '$.status'), '')
+FROM trackings t, json_each(t.payload) i, json_each(i.value, '$.events') e
+JOIN tracking_infos ti ON ti.tracking_id = t.id AND ti.position = i.key
+WHERE json_valid(t.payload) AND json_type(t.payload) = 'array' AND i.type = 'object' AND e.type = 'object';
+
+ALTER TABLE trackings DROP COLUMN payload;
+
+
+-- +migrate Down
+ALTER TABLE trackings ADD COLUMN payload TEXT;
+
+UPDATE trackings SET payload = (
+    SELECT json_group_array(json(info)) FROM (
+        SELECT json_object(
+            'tracking_number', ti.tracking_number,
+            'api_name', ti.api_name,
+            'is_delivered', json(CASE WHEN ti.is_delivered THEN 'true' ELSE 'false' END),
+            'last_checked_at', ti.last_checked_at,
+            'last_updated_at', ti.last_updated_at,
+            'events', (
+                SELECT json_group_array(json(event)) FROM (
+                    SELECT json_object('time', e.time, 'description', e.description, 'status', e.status) AS event
+                    FROM tracking_events e WHERE e.tracking_info_id = ti.id ORDER BY e.position
+                )
+            )
+        ) AS info
+        FROM tracking_infos ti WHERE ti.tracking_id = trackings.id ORDER BY ti.position
+    )
+);
+
+DROP TABLE tracking_events;
+DROP TABLE tracking_infos;
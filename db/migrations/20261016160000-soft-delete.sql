@@ -0,0 +1,9 @@
+-- +migrate Up
+ALTER TABLE trackings ADD COLUMN deleted_at INTEGER;
+
+CREATE INDEX trackings_deleted_at ON trackings (deleted_at);
+
+
+-- +migrate Down
+DROP INDEX trackings_deleted_at;
+ALTER TABLE trackings DROP COLUMN deleted_at;
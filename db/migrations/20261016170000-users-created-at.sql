@@ -0,0 +1,7 @@
+-- +migrate Up
+-- users that started the bot before this migration have no created_at
+ALTER TABLE users_chats ADD COLUMN created_at INTEGER;
+
+
+-- +migrate Down
+ALTER TABLE users_chats DROP COLUMN created_at;
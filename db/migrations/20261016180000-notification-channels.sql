@@ -0,0 +1,16 @@
+-- +migrate Up
+-- notification channels other than Telegram, destination is encrypted if encryption is enabled
+CREATE TABLE notification_channels (
+    user_id INTEGER NOT NULL,
+    channel TEXT NOT NULL,
+    destination TEXT NOT NULL,
+    verified INTEGER NOT NULL DEFAULT 0,
+    verification_code TEXT NOT NULL DEFAULT '',
+    failed_attempts INTEGER NOT NULL DEFAULT 0,
+    created_at INTEGER NOT NULL,
+    PRIMARY KEY (user_id, channel)
+);
+
+
+-- +migrate Down
+DROP TABLE notification_channels;
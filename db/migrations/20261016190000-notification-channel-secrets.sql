@@ -0,0 +1,7 @@
+-- +migrate Up
+-- secret signs what is sent through the channel (e.g. webhooks), it is encrypted if encryption is enabled
+ALTER TABLE notification_channels ADD COLUMN secret TEXT NOT NULL DEFAULT '';
+
+
+-- +migrate Down
+ALTER TABLE notification_channels DROP COLUMN secret;
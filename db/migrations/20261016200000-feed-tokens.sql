@@ -0,0 +1,11 @@
+-- +migrate Up
+-- tokens of personal feeds, only their hashes are stored, so that a leaked database doesn't expose feeds
+CREATE TABLE feed_tokens (
+    user_id INTEGER PRIMARY KEY,
+    token_hash TEXT NOT NULL UNIQUE,
+    created_at INTEGER NOT NULL
+);
+
+
+-- +migrate Down
+DROP TABLE feed_tokens;
@@ -0,0 +1,6 @@
+-- +migrate Up
+ALTER TABLE trackings ADD COLUMN note TEXT NOT NULL DEFAULT '';
+
+
+-- +migrate Down
+ALTER TABLE trackings DROP COLUMN note;
@@ -0,0 +1,13 @@
+-- +migrate Up
+-- unlike display names and notes, tags are stored in plain text, so that trackings can be filtered by them
+CREATE TABLE tracking_tags (
+    tracking_id INTEGER NOT NULL,
+    tag TEXT NOT NULL,
+    PRIMARY KEY (tracking_id, tag)
+);
+
+CREATE INDEX tracking_tags_tag ON tracking_tags (tag);
+
+
+-- +migrate Down
+DROP TABLE tracking_tags;
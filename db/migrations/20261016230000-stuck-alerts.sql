@@ -0,0 +1,6 @@
+-- +migrate Up
+ALTER TABLE trackings ADD COLUMN stuck_alerted_at INTEGER;
+
+
+-- +migrate Down
+ALTER TABLE trackings DROP COLUMN stuck_alerted_at;
@@ -0,0 +1,10 @@
+-- +migrate Up
+-- users without a row here are notified of everything
+CREATE TABLE notification_levels (
+    user_id INTEGER PRIMARY KEY,
+    level TEXT NOT NULL
+);
+
+
+-- +migrate Down
+DROP TABLE notification_levels;
@@ -0,0 +1,7 @@
+-- +migrate Up
+-- empty level means the user's level
+ALTER TABLE trackings ADD COLUMN notification_level TEXT NOT NULL DEFAULT '';
+
+
+-- +migrate Down
+ALTER TABLE trackings DROP COLUMN notification_level;
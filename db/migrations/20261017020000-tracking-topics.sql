@@ -0,0 +1,13 @@
+-- +migrate Up
+-- forum topics of supergroups that updates of trackings are sent to instead of chats of their users
+CREATE TABLE tracking_topics (
+    user_id INTEGER NOT NULL,
+    tracking_number TEXT NOT NULL,
+    chat_id INTEGER NOT NULL,
+    thread_id INTEGER NOT NULL,
+    PRIMARY KEY (user_id, tracking_number)
+);
+
+
+-- +migrate Down
+DROP TABLE tracking_topics;
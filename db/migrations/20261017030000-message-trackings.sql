@@ -0,0 +1,14 @@
+-- +migrate Up
+-- messages the bot has sent about trackings, so that replies to them can refer to the trackings
+CREATE TABLE message_trackings (
+    chat_id INTEGER NOT NULL,
+    message_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    tracking_number TEXT NOT NULL,
+    PRIMARY KEY (chat_id, message_id)
+);
+CREATE INDEX message_trackings_user_id ON message_trackings (user_id);
+
+
+-- +migrate Down
+DROP TABLE message_trackings;
@@ -0,0 +1,7 @@
+-- +migrate Up
+-- language of the user's Telegram client when they first talked to the bot, empty if the client didn't tell
+ALTER TABLE users_chats ADD COLUMN language_code TEXT NOT NULL DEFAULT '';
+
+
+-- +migrate Down
+ALTER TABLE users_chats DROP COLUMN language_code;
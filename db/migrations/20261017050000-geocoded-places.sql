@@ -0,0 +1,12 @@
+-- +migrate Up
+-- geocoding results of event places for route maps, places that weren't found have no coordinates
+CREATE TABLE geocoded_places (
+    place TEXT PRIMARY KEY,
+    lat REAL,
+    lon REAL,
+    geocoded_at INTEGER NOT NULL
+);
+
+
+-- +migrate Down
+DROP TABLE geocoded_places;
@@ -0,0 +1,13 @@
+-- +migrate Up
+-- users who opted in to weekly summaries, and when (in UTC) they get them
+CREATE TABLE summary_schedules (
+    user_id INTEGER PRIMARY KEY,
+    weekday INTEGER NOT NULL,
+    hour INTEGER NOT NULL,
+    next_at INTEGER NOT NULL
+);
+CREATE INDEX summary_schedules_next_at ON summary_schedules (next_at);
+
+
+-- +migrate Down
+DROP TABLE summary_schedules;
@@ -0,0 +1,7 @@
+-- +migrate Up
+-- when trackings were added or last got new events, polling goes from the most recently active ones
+ALTER TABLE trackings ADD COLUMN last_activity_at INTEGER;
+
+
+-- +migrate Down
+ALTER TABLE trackings DROP COLUMN last_activity_at;
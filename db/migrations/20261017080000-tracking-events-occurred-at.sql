@@ -0,0 +1,13 @@
+-- +migrate Up
+-- event times as unix seconds in UTC, NULL for times in formats we can't parse. Go parses more formats than SQLite,
+-- so events of formats SQLite doesn't know get their occurred_at when the tracking is fetched next
+ALTER TABLE tracking_events ADD COLUMN occurred_at INTEGER;
+
+UPDATE tracking_events SET occurred_at = CAST(strftime('%s', time) AS INTEGER) WHERE strftime('%s', time) IS NOT NULL;
+
+CREATE INDEX tracking_events_occurred_at ON tracking_events (occurred_at);
+
+
+-- +migrate Down
+DROP INDEX tracking_events_occurred_at;
+ALTER TABLE tracking_events DROP COLUMN occurred_at;
@@ -0,0 +1,15 @@
+-- +migrate Up
+-- users saying parcels reported as delivered haven't reached them
+ALTER TABLE trackings ADD COLUMN delivery_disputed_at INTEGER;
+
+-- anonymous just like transit_samples, for carrier reliability
+CREATE TABLE delivery_confirmations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    route TEXT NOT NULL,
+    received INTEGER NOT NULL
+);
+
+
+-- +migrate Down
+DROP TABLE delivery_confirmations;
+ALTER TABLE trackings DROP COLUMN delivery_disputed_at;
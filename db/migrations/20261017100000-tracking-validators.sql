@@ -0,0 +1,9 @@
+-- +migrate Up
+-- ETag and Last-Modified of tracking infos as last fetched, for conditional fetches
+ALTER TABLE trackings ADD COLUMN fetch_etag TEXT NOT NULL DEFAULT '';
+ALTER TABLE trackings ADD COLUMN fetch_last_modified TEXT NOT NULL DEFAULT '';
+
+
+-- +migrate Down
+ALTER TABLE trackings DROP COLUMN fetch_last_modified;
+ALTER TABLE trackings DROP COLUMN fetch_etag;
@@ -0,0 +1,11 @@
+-- +migrate Up
+-- parcels without tracking numbers, which are never polled
+ALTER TABLE trackings ADD COLUMN manual INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE trackings ADD COLUMN expected_at INTEGER;
+ALTER TABLE trackings ADD COLUMN reminded_at INTEGER;
+
+
+-- +migrate Down
+ALTER TABLE trackings DROP COLUMN reminded_at;
+ALTER TABLE trackings DROP COLUMN expected_at;
+ALTER TABLE trackings DROP COLUMN manual;
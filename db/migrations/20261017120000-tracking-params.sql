@@ -0,0 +1,7 @@
+-- +migrate Up
+-- postal codes some carriers need along with tracking numbers, encrypted just like display names
+ALTER TABLE trackings ADD COLUMN postal_code TEXT NOT NULL DEFAULT '';
+
+
+-- +migrate Down
+ALTER TABLE trackings DROP COLUMN postal_code;
@@ -0,0 +1,7 @@
+-- +migrate Up
+-- carriers users have told trackings are handled by, empty for the ones guessed from tracking numbers
+ALTER TABLE trackings ADD COLUMN carrier TEXT NOT NULL DEFAULT '';
+
+
+-- +migrate Down
+ALTER TABLE trackings DROP COLUMN carrier;
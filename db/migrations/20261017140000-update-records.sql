@@ -0,0 +1,19 @@
+-- +migrate Up
+-- every update saved along with a pending notification, kept once the notification is delivered
+CREATE TABLE update_records (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    notification_id INTEGER NOT NULL,
+    tracking_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    tracking_number TEXT NOT NULL,
+    payload TEXT NOT NULL,
+    created_at INTEGER NOT NULL,
+    delivered_at INTEGER
+);
+
+CREATE INDEX update_records_user_id_tracking_number ON update_records (user_id, tracking_number);
+CREATE INDEX update_records_notification_id ON update_records (notification_id);
+
+
+-- +migrate Down
+DROP TABLE update_records;
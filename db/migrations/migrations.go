@@ -0,0 +1,223 @@
+// Package migrations embeds SQL migrations of the bot database and applies them.
+// Migrations are written for sql-migrate (see Makefile), and applied migrations are recorded
+// in the same table sql-migrate uses, so a database can be migrated with either of them
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hori-ryota/zaperr"
+	"go.uber.org/zap"
+)
+
+//go:embed *.sql
+var files embed.FS
+
+const (
+	upMarker   = "-- +migrate Up"
+	downMarker = "-- +migrate Down"
+)
+
+var (
+	ErrSchemaOutdated = errors.New("database schema is outdated")
+	ErrSchemaTooNew   = errors.New("database schema is newer than this binary")
+)
+
+type migration struct {
+	ID string
+	Up string
+}
+
+// Up applies all migrations that haven't been applied yet, each in its own transaction.
+// It returns the number of applied migrations
+func Up(ctx context.Context, db *sql.DB, logger *zap.Logger) (int, error) {
+	if _, err := db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS gorp_migrations (id VARCHAR(255) NOT NULL PRIMARY KEY, applied_at DATETIME)`,
+	); err != nil {
+		return 0, zaperr.Wrap(err, "failed to create migrations table")
+	}
+
+	applied, err := appliedIDs(ctx, db)
+	if err != nil {
+		return 0, err
+	}
+
+	migrations, err := load()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, m := range migrations {
+		if applied[m.ID] {
+			continue
+		}
+		logger.Info("applying migration", zap.String("id", m.ID))
+		if err := apply(ctx, db, m); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// Version returns the ID of the latest applied migration, empty if none were applied
+func Version(ctx context.Context, db *sql.DB) (string, error) {
+	applied, err := appliedIDs(ctx, db)
+	if err != nil {
+		return "", err
+	}
+	ids := make([]string, 0, len(applied))
+	for id := range applied {
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return "", nil
+	}
+	sortIDs(ids)
+	return ids[len(ids)-1], nil
+}
+
+// Check verifies that the database schema is exactly what this binary expects: every embedded migration is applied
+// and no unknown ones are. It returns ErrSchemaOutdated or ErrSchemaTooNew otherwise
+func Check(ctx context.Context, db *sql.DB) error {
+	applied, err := appliedIDs(ctx, db)
+	if err != nil {
+		return err
+	}
+	migrations, err := load()
+	if err != nil {
+		return err
+	}
+
+	known := make(map[string]bool, len(migrations))
+	var pending []string
+	for _, m := range migrations {
+		known[m.ID] = true
+		if !applied[m.ID] {
+			pending = append(pending, m.ID)
+		}
+	}
+	var unknown []string
+	for id := range applied {
+		if !known[id] {
+			unknown = append(unknown, id)
+		}
+	}
+	sortIDs(unknown)
+
+	if len(unknown) > 0 {
+		return fmt.Errorf("%w: unknown migrations %s are applied, upgrade the binary", ErrSchemaTooNew, strings.Join(unknown, ", "))
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("%w: migrations %s are not applied, enable auto-migration or run `make migrate`", ErrSchemaOutdated, strings.Join(pending, ", "))
+	}
+	return nil
+}
+
+func apply(ctx context.Context, db *sql.DB, m migration) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return zaperr.Wrap(err, "failed to begin transaction", zap.String("id", m.ID))
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, m.Up); err != nil {
+		return zaperr.Wrap(err, "failed to apply migration", zap.String("id", m.ID))
+	}
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO gorp_migrations (id, applied_at) VALUES (?, ?)`, m.ID, time.Now(),
+	); err != nil {
+		return zaperr.Wrap(err, "failed to record migration", zap.String("id", m.ID))
+	}
+
+	if err := tx.Commit(); err != nil {
+		return zaperr.Wrap(err, "failed to commit migration", zap.String("id", m.ID))
+	}
+	return nil
+}
+
+func appliedIDs(ctx context.Context, db *sql.DB) (map[string]bool, error) {
+	var tableCount int
+	if err := db.QueryRowContext(ctx, `
+		SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = 'gorp_migrations'`,
+	).Scan(&tableCount); err != nil {
+		return nil, zaperr.Wrap(err, "failed to check migrations table")
+	}
+	if tableCount == 0 {
+		// nothing was ever migrated
+		return map[string]bool{}, nil
+	}
+
+	rows, err := db.QueryContext(ctx, `SELECT id FROM gorp_migrations`)
+	if err != nil {
+		return nil, zaperr.Wrap(err, "failed to list applied migrations")
+	}
+	defer rows.Close()
+
+	applied := make(map[string]bool)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		applied[id] = true
+	}
+	return applied, rows.Err()
+}
+
+func load() ([]migration, error) {
+	names, err := fs.Glob(files, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+	sortIDs(names)
+
+	migrations := make([]migration, 0, len(names))
+	for _, name := range names {
+		content, err := files.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		up, err := upSection(string(content))
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: %w", name, err)
+		}
+		migrations = append(migrations, migration{ID: name, Up: up})
+	}
+	return migrations, nil
+}
+
+// upSection extracts the part of sql-migrate file between Up and Down markers
+func upSection(content string) (string, error) {
+	start := strings.Index(content, upMarker)
+	if start == -1 {
+		return "", fmt.Errorf("no %q marker", upMarker)
+	}
+	up := content[start+len(upMarker):]
+	if end := strings.Index(up, downMarker); end != -1 {
+		up = up[:end]
+	}
+	return up, nil
+}
+
+// sortIDs orders migrations the way sql-migrate does: by numeric prefix if both have one, by name otherwise
+func sortIDs(ids []string) {
+	sort.Slice(ids, func(i, j int) bool {
+		ni, erri := strconv.ParseUint(strings.SplitN(ids[i], "-", 2)[0], 10, 64)
+		nj, errj := strconv.ParseUint(strings.SplitN(ids[j], "-", 2)[0], 10, 64)
+		if erri == nil && errj == nil && ni != nj {
+			return ni < nj
+		}
+		return ids[i] < ids[j]
+	})
+}
@@ -0,0 +1,35 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zaptest"
+)
+
+func TestScenarios(t *testing.T) {
+	for _, storageKind := range []StorageKind{StorageSQLite, StorageMemory} {
+		storageKind := storageKind
+		t.Run(string(storageKind), func(t *testing.T) {
+			for _, scenario := range Scenarios {
+				scenario := scenario
+				t.Run(scenario.Name, func(t *testing.T) {
+					ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+					defer cancel()
+
+					h, err := NewHarness(ctx, storageKind, zaptest.NewLogger(t))
+					if err != nil {
+						t.Fatal(err)
+					}
+					defer h.Close()
+					if err := scenario.Run(ctx, h); err != nil {
+						t.Fatal(err)
+					}
+				})
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"github.com/dir01/parcels/parcels_api"
+)
+
+// NewFakeParcelsAPI starts an HTTP server emulating parcels service, it has to be closed with Close
+func NewFakeParcelsAPI() *FakeParcelsAPI {
+	f := &FakeParcelsAPI{parcels: make(map[string]*fakeParcel)}
+	f.server = httptest.NewServer(http.HandlerFunc(f.handle))
+	return f
+}
+
+// FakeParcelsAPI serves whatever tracking infos scenarios set for tracking numbers,
+// unknown tracking numbers are not found. Scenarios change responses as they go,
+// so it doesn't matter how many times a tracking number is actually fetched
+type FakeParcelsAPI struct {
+	server *httptest.Server
+
+	mu      sync.Mutex
+	parcels map[string]*fakeParcel
+}
+
+type fakeParcel struct {
+	trackingInfos []*parcels_api.TrackingInfo
+	// failures is the number of upcoming requests to fail with failureStatus
+	failures      int
+	failureStatus int
+	// slowRequests is the number of upcoming requests to delay by delay
+	slowRequests int
+	delay        time.Duration
+	requests     int
+}
+
+func (f *FakeParcelsAPI) URL() string {
+	return f.server.URL
+}
+
+func (f *FakeParcelsAPI) Close() {
+	f.server.Close()
+}
+
+// SetTrackingInfos makes the tracking number found with these tracking infos, nil makes it not found again
+func (f *FakeParcelsAPI) SetTrackingInfos(trackingNumber string, trackingInfos []*parcels_api.TrackingInfo) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.parcel(trackingNumber).trackingInfos = trackingInfos
+}
+
+// FailNext makes next count requests of the tracking number fail with the status
+func (f *FakeParcelsAPI) FailNext(trackingNumber string, count int, status int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	p := f.parcel(trackingNumber)
+	p.failures = count
+	p.failureStatus = status
+}
+
+// SlowDownNext makes next count requests of the tracking number respond after the delay
+func (f *FakeParcelsAPI) SlowDownNext(trackingNumber string, count int, delay time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	p := f.parcel(trackingNumber)
+	p.slowRequests = count
+	p.delay = delay
+}
+
+// Requests returns how many times the tracking number was requested
+func (f *FakeParcelsAPI) Requests(trackingNumber string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.parcel(trackingNumber).requests
+}
+
+func (f *FakeParcelsAPI) parcel(trackingNumber string) *fakeParcel {
+	p, ok := f.parcels[trackingNumber]
+	if !ok {
+		p = &fakeParcel{}
+		f.parcels[trackingNumber] = p
+	}
+	return p
+}
+
+func (f *FakeParcelsAPI) handle(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/trackingInfo/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	f.mu.Lock()
+	p := f.parcel(r.URL.Query().Get("trackingNumber"))
+	p.requests++
+	var delay time.Duration
+	if p.slowRequests > 0 {
+		p.slowRequests--
+		delay = p.delay
+	}
+	failureStatus := 0
+	if p.failures > 0 {
+		p.failures--
+		failureStatus = p.failureStatus
+	}
+	trackingInfos := p.trackingInfos
+	f.mu.Unlock()
+
+	if delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	if failureStatus != 0 {
+		w.WriteHeader(failureStatus)
+		return
+	}
+	if trackingInfos == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(trackingInfos)
+}
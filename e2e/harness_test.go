@@ -0,0 +1,150 @@
+//go:build e2e
+
+// Package e2e drives core.Service end to end, from a fake parcels service to emitted tracking updates.
+// Scenarios are tests behind the e2e build tag, run them with `make e2e` or `go test -tags e2e ./e2e`
+package e2e
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/dir01/tg-parcels/core"
+	"github.com/dir01/tg-parcels/core/storage"
+	"github.com/dir01/tg-parcels/core/storage/memory"
+	"github.com/dir01/tg-parcels/db/migrations"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// PollingDuration is short, so that scenarios go through several poll cycles in seconds
+	PollingDuration = 2 * time.Second
+	// ReadTimeout is how long the service waits for the fake parcels service to respond
+	ReadTimeout = 300 * time.Millisecond
+)
+
+type StorageKind string
+
+const (
+	StorageSQLite StorageKind = "sqlite"
+	StorageMemory StorageKind = "memory"
+)
+
+// NewHarness wires core.Service to a fresh storage of the kind and a fake parcels service,
+// and starts it. The harness has to be closed with Close
+func NewHarness(ctx context.Context, storageKind StorageKind, logger *zap.Logger) (*Harness, error) {
+	h := &Harness{Parcels: NewFakeParcelsAPI()}
+
+	var stor core.Storage
+	switch storageKind {
+	case StorageMemory:
+		stor = memory.NewStorage(core.SystemClock)
+	case StorageSQLite:
+		dir, err := os.MkdirTemp("", "tg-parcels-e2e")
+		if err != nil {
+			h.Close()
+			return nil, err
+		}
+		h.tempDir = dir
+		db := sqlx.MustOpen("sqlite3", storage.DSN(filepath.Join(dir, "db.sqlite"), 5*time.Second))
+		h.db = db.DB
+		if _, err := migrations.Up(ctx, db.DB, logger); err != nil {
+			h.Close()
+			return nil, err
+		}
+		stor = storage.NewStorage(db, db, nil, 0, core.SystemClock, nil)
+	default:
+		h.Close()
+		return nil, fmt.Errorf("unknown storage kind %q", storageKind)
+	}
+
+	retryPolicy := core.RetryPolicy{MaxAttempts: 3, InitialBackoff: 50 * time.Millisecond, MaxBackoff: 200 * time.Millisecond, Multiplier: 2}
+	parcelsAPI := core.NewParcelsAPI(
+		h.Parcels.URL(),
+		core.NewHTTPClient(core.HTTPTimeouts{Connect: time.Second, Read: ReadTimeout}, nil),
+		retryPolicy,
+		core.NewCircuitBreaker("e2e.parcels_api", 100, time.Second, logger),
+		rate.NewLimiter(rate.Inf, 1),
+		logger,
+	)
+	// results are not shared, every fetch has to reach the fake parcels service
+	provider := core.NewFetchCoordinator(core.NewMultiProvider(logger, parcelsAPI), 0)
+	h.Service = core.NewService(stor, provider, nil, PollingDuration, nil, nil, 100, 0, time.Hour, 0, 0, 0, 0, core.SystemClock, nil, logger)
+	h.updates = h.Service.Subscribe()
+
+	ctx, h.cancel = context.WithCancel(ctx)
+	h.Service.Start(ctx)
+	return h, nil
+}
+
+type Harness struct {
+	Service core.Service
+	Parcels *FakeParcelsAPI
+
+	updates <-chan core.TrackingUpdate
+	cancel  context.CancelFunc
+	db      *sql.DB
+	tempDir string
+}
+
+func (h *Harness) Close() {
+	if h.cancel != nil {
+		h.cancel()
+	}
+	if h.Service != nil {
+		h.Service.Unsubscribe(h.updates)
+	}
+	h.Parcels.Close()
+	if h.db != nil {
+		_ = h.db.Close()
+	}
+	if h.tempDir != "" {
+		_ = os.RemoveAll(h.tempDir)
+	}
+}
+
+// Expect waits for an update matching the predicate, updates that don't match are skipped.
+// Matched updates are marked delivered, like the bot does
+func (h *Harness) Expect(ctx context.Context, timeout time.Duration, description string, match func(u core.TrackingUpdate) bool) (core.TrackingUpdate, error) {
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return core.TrackingUpdate{}, ctx.Err()
+		case <-t.C:
+			return core.TrackingUpdate{}, fmt.Errorf("no update within %s: %s", timeout, description)
+		case u := <-h.updates:
+			if match(u) {
+				if err := h.Service.MarkUpdateDelivered(ctx, &u); err != nil {
+					return u, err
+				}
+				return u, nil
+			}
+		}
+	}
+}
+
+// ExpectNone makes sure there are no updates of the tracking number for the duration
+func (h *Harness) ExpectNone(ctx context.Context, duration time.Duration, trackingNumber string) error {
+	t := time.NewTimer(duration)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+			return nil
+		case u := <-h.updates:
+			if u.TrackingNumber == trackingNumber {
+				return fmt.Errorf("unexpected update of %s: %+v", trackingNumber, u)
+			}
+		}
+	}
+}
@@ -0,0 +1,162 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/dir01/parcels/parcels_api"
+	"github.com/dir01/tg-parcels/core"
+)
+
+const (
+	userID = 42
+	// updateTimeout is long enough for a couple of poll cycles
+	updateTimeout = 3 * PollingDuration
+)
+
+type Scenario struct {
+	Name string
+	Run  func(ctx context.Context, h *Harness) error
+}
+
+var Scenarios = []Scenario{
+	{Name: "events appear over time", Run: eventsAppearOverTime},
+	{Name: "not found until registered", Run: notFoundUntilRegistered},
+	{Name: "server errors are retried", Run: serverErrorsAreRetried},
+	{Name: "slow responses are retried", Run: slowResponsesAreRetried},
+	{Name: "persistent server errors are reported", Run: persistentServerErrorsAreReported},
+}
+
+func eventsAppearOverTime(ctx context.Context, h *Harness) error {
+	const number = "EV000000001"
+	h.Parcels.SetTrackingInfos(number, trackingInfos(number, "accepted"))
+	if err := h.Service.Track(ctx, userID, number, "books", core.TrackingParams{}); err != nil {
+		return err
+	}
+	u, err := h.Expect(ctx, updateTimeout, "initial tracking info", isUpdateOf(number))
+	if err != nil {
+		return err
+	}
+	if len(u.NewTrackingInfos) != 1 || len(u.NewTrackingInfos[0].Events) != 1 || u.DisplayName != "books" {
+		return fmt.Errorf("unexpected initial update: %+v", u)
+	}
+
+	// nothing changes, so polls must not produce updates
+	if err := h.ExpectNone(ctx, PollingDuration+time.Second, number); err != nil {
+		return err
+	}
+
+	h.Parcels.SetTrackingInfos(number, trackingInfos(number, "accepted", "departed", "arrived"))
+	u, err = h.Expect(ctx, updateTimeout, "new events", isUpdateOf(number))
+	if err != nil {
+		return err
+	}
+	if len(u.NewTrackingInfos) != 0 || len(u.NewTrackingEvents) != 2 {
+		return fmt.Errorf("expected 2 new events, got %+v", u)
+	}
+	if u.NewTrackingEvents[0].Description != "departed" || u.NewTrackingEvents[1].Description != "arrived" {
+		return fmt.Errorf("unexpected new events: %s, %s", u.NewTrackingEvents[0].Description, u.NewTrackingEvents[1].Description)
+	}
+
+	tracking, err := h.Service.GetTracking(ctx, userID, number)
+	if err != nil {
+		return err
+	}
+	if len(tracking.TrackingInfos) != 1 || len(tracking.TrackingInfos[0].Events) != 3 {
+		return fmt.Errorf("tracking infos were not saved: %+v", tracking.TrackingInfos)
+	}
+	return nil
+}
+
+func notFoundUntilRegistered(ctx context.Context, h *Harness) error {
+	const number = "NF000000001"
+	if err := h.Service.Track(ctx, userID, number, "", core.TrackingParams{}); err != nil {
+		return err
+	}
+	if _, err := h.Expect(ctx, updateTimeout, "not found error", isErrorOf(number, core.ErrorCodeNotFound)); err != nil {
+		return err
+	}
+
+	h.Parcels.SetTrackingInfos(number, trackingInfos(number, "registered"))
+	u, err := h.Expect(ctx, updateTimeout, "tracking info once registered", isUpdateOf(number))
+	if err != nil {
+		return err
+	}
+	if len(u.NewTrackingInfos) != 1 {
+		return fmt.Errorf("expected new tracking info, got %+v", u)
+	}
+	return nil
+}
+
+func serverErrorsAreRetried(ctx context.Context, h *Harness) error {
+	const number = "SE000000001"
+	h.Parcels.SetTrackingInfos(number, trackingInfos(number, "accepted"))
+	h.Parcels.FailNext(number, 2, http.StatusInternalServerError)
+	if err := h.Service.Track(ctx, userID, number, "", core.TrackingParams{}); err != nil {
+		return err
+	}
+	if _, err := h.Expect(ctx, updateTimeout, "tracking info after retries", isUpdateOf(number)); err != nil {
+		return err
+	}
+	if requests := h.Parcels.Requests(number); requests < 3 {
+		return fmt.Errorf("expected at least 3 requests, got %d", requests)
+	}
+	return nil
+}
+
+func slowResponsesAreRetried(ctx context.Context, h *Harness) error {
+	const number = "SL000000001"
+	h.Parcels.SetTrackingInfos(number, trackingInfos(number, "accepted"))
+	h.Parcels.SlowDownNext(number, 1, 2*ReadTimeout)
+	if err := h.Service.Track(ctx, userID, number, "", core.TrackingParams{}); err != nil {
+		return err
+	}
+	_, err := h.Expect(ctx, updateTimeout, "tracking info after timeout", isUpdateOf(number))
+	return err
+}
+
+func persistentServerErrorsAreReported(ctx context.Context, h *Harness) error {
+	const number = "PE000000001"
+	h.Parcels.SetTrackingInfos(number, trackingInfos(number, "accepted"))
+	h.Parcels.FailNext(number, 100, http.StatusBadGateway)
+	if err := h.Service.Track(ctx, userID, number, "", core.TrackingParams{}); err != nil {
+		return err
+	}
+	if _, err := h.Expect(ctx, updateTimeout, "upstream down error", isErrorOf(number, core.ErrorCodeUpstreamDown)); err != nil {
+		return err
+	}
+
+	// polling recovers once the service is back
+	h.Parcels.FailNext(number, 0, 0)
+	_, err := h.Expect(ctx, updateTimeout, "tracking info once recovered", isUpdateOf(number))
+	return err
+}
+
+// trackingInfos builds tracking info of a single API with events described so, an hour apart
+func trackingInfos(number string, descriptions ...string) []*parcels_api.TrackingInfo {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	info := &parcels_api.TrackingInfo{TrackingNumber: number, ApiName: "fake"}
+	for i, d := range descriptions {
+		info.Events = append(info.Events, parcels_api.TrackingEvent{
+			Time:        start.Add(time.Duration(i) * time.Hour).Format(time.RFC3339),
+			Description: d,
+		})
+	}
+	return []*parcels_api.TrackingInfo{info}
+}
+
+func isUpdateOf(number string) func(u core.TrackingUpdate) bool {
+	return func(u core.TrackingUpdate) bool {
+		return u.TrackingNumber == number && u.TrackingError == nil
+	}
+}
+
+func isErrorOf(number string, code core.ErrorCode) func(u core.TrackingUpdate) bool {
+	return func(u core.TrackingUpdate) bool {
+		return u.TrackingNumber == number && u.TrackingError != nil && u.ErrorCode == code
+	}
+}
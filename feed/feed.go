@@ -0,0 +1,253 @@
+// Package feed serves personal Atom feeds of tracking events, so that updates can be read in feed readers,
+// and iCalendar feeds of expected and past deliveries for calendar apps.
+// Feed URLs contain a secret token, users get one with /feed in the bot and can revoke it at any time
+package feed
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dir01/tg-parcels/core"
+	"github.com/hori-ryota/zaperr"
+	"go.uber.org/zap"
+)
+
+const (
+	// Prefix is the path feeds are served at, followed by the token
+	Prefix = "/feeds/"
+	// maxEntries is how many most recent events a feed lists
+	maxEntries = 50
+	// trackingsPageSize is how many trackings are loaded at once while building a feed
+	trackingsPageSize = 100
+)
+
+var ErrTokenNotFound = errors.New("feed token not found")
+
+//go:generate moq -pkg mocks -out mocks/storage.go . Storage
+
+// Storage keeps hashes of feed tokens, a user has at most one token
+type Storage interface {
+	// SaveToken replaces the user's token
+	SaveToken(ctx context.Context, userID int64, tokenHash string, createdAt time.Time) error
+	// GetUserID returns ErrTokenNotFound if there is no such token
+	GetUserID(ctx context.Context, tokenHash string) (int64, error)
+	DeleteToken(ctx context.Context, userID int64) error
+}
+
+// New creates Feeds with URLs under baseURL, the public URL of the listener Feeds are served by
+func New(service core.Service, storage Storage, baseURL string, logger *zap.Logger) *Feeds {
+	f := &Feeds{
+		service: service,
+		storage: storage,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		logger:  logger,
+	}
+	var _ http.Handler = f
+	return f
+}
+
+// Feeds issues feed tokens and serves feeds.
+// Nil Feeds means feeds are disabled, Enabled tells so
+type Feeds struct {
+	service core.Service
+	storage Storage
+	baseURL string
+	logger  *zap.Logger
+}
+
+func (f *Feeds) Enabled() bool {
+	return f != nil
+}
+
+// Rotate issues a new token to the user, revoking the previous one, and returns URLs of the feed and the calendar.
+// Only the hash of the token is stored, so the URLs can't be shown again later
+func (f *Feeds) Rotate(ctx context.Context, userID int64) (feedURL string, calendarURL string, err error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", zaperr.Wrap(err, "failed to generate feed token")
+	}
+	token := hex.EncodeToString(secret)
+	if err := f.storage.SaveToken(ctx, userID, hashToken(token), time.Now()); err != nil {
+		return "", "", zaperr.Wrap(err, "failed to save feed token", core.UserIDField(userID))
+	}
+	feedURL = f.baseURL + Prefix + token
+	return feedURL, feedURL + calendarSuffix, nil
+}
+
+// Revoke makes the user's feed URL stop working, it's fine if there is none
+func (f *Feeds) Revoke(ctx context.Context, userID int64) error {
+	if f == nil {
+		return nil
+	}
+	if err := f.storage.DeleteToken(ctx, userID); err != nil {
+		return zaperr.Wrap(err, "failed to delete feed token", core.UserIDField(userID))
+	}
+	return nil
+}
+
+func (f *Feeds) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	token := strings.TrimPrefix(r.URL.Path, Prefix)
+	calendar := strings.HasSuffix(token, calendarSuffix)
+	token = strings.TrimSuffix(token, calendarSuffix)
+	if token == "" || strings.Contains(token, "/") {
+		http.NotFound(w, r)
+		return
+	}
+	userID, err := f.storage.GetUserID(r.Context(), hashToken(token))
+	if errors.Is(err, ErrTokenNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		f.logger.Error("failed to look feed token up", zaperr.ToField(err))
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	if calendar {
+		f.serveCalendar(w, r, userID)
+		return
+	}
+
+	feed, err := f.build(r.Context(), userID)
+	if err != nil {
+		f.logger.Error("failed to build feed", core.UserIDField(userID), zaperr.ToField(err))
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	body, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		f.logger.Error("failed to marshal feed", core.UserIDField(userID), zaperr.ToField(err))
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	// the URL is a secret, proxies must not keep copies
+	w.Header().Set("Cache-Control", "private, no-cache")
+	_, _ = w.Write([]byte(xml.Header))
+	_, _ = w.Write(body)
+}
+
+func (f *Feeds) serveCalendar(w http.ResponseWriter, r *http.Request, userID int64) {
+	events, err := f.buildCalendar(r.Context(), userID)
+	if err != nil {
+		f.logger.Error("failed to build calendar", core.UserIDField(userID), zaperr.ToField(err))
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	var b strings.Builder
+	writeCalendar(&b, events, time.Now())
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Cache-Control", "private, no-cache")
+	_, _ = w.Write([]byte(b.String()))
+}
+
+// build lists the most recent events of all user's trackings, newest first
+func (f *Feeds) build(ctx context.Context, userID int64) (*atomFeed, error) {
+	var entries []atomEntry
+	var times []time.Time
+	for cursor := int64(0); ; {
+		page, err := f.service.ListTrackings(ctx, userID, cursor, trackingsPageSize)
+		if err != nil {
+			return nil, err
+		}
+		for _, tracking := range page.Trackings {
+			title := tracking.TrackingNumber
+			if tracking.DisplayName != "" {
+				title = tracking.DisplayName
+			}
+			// events without a time we can parse are dated by the last poll, which saw them at the latest
+			var fallbackTime time.Time
+			if tracking.LastPolledAt != nil {
+				fallbackTime = *tracking.LastPolledAt
+			}
+			for _, info := range tracking.TrackingInfos {
+				for _, e := range info.Events {
+					t, ok := core.ParseEventTime(e.Time)
+					if !ok {
+						t = fallbackTime
+					}
+					entries = append(entries, atomEntry{
+						Title:   fmt.Sprintf("%s: %s", title, e.Description),
+						ID:      "urn:tg-parcels:" + tracking.TrackingNumber + ":" + core.EventHash(e),
+						Updated: t.UTC().Format(time.RFC3339),
+						Content: atomText{Type: "text", Text: strings.TrimSpace(e.Time + " " + e.Description)},
+					})
+					times = append(times, t)
+				}
+			}
+		}
+		if page.NextCursor == 0 {
+			break
+		}
+		cursor = page.NextCursor
+	}
+
+	order := make([]int, len(entries))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return times[order[i]].After(times[order[j]]) })
+	if len(order) > maxEntries {
+		order = order[:maxEntries]
+	}
+
+	feed := &atomFeed{
+		Title: "Parcels",
+		ID:    fmt.Sprintf("urn:tg-parcels:feed:%d", userID),
+		// an empty feed hasn't been updated ever
+		Updated: time.Unix(0, 0).UTC().Format(time.RFC3339),
+		Author:  atomAuthor{Name: "tg-parcels"},
+		Entries: make([]atomEntry, 0, len(order)),
+	}
+	for _, i := range order {
+		feed.Entries = append(feed.Entries, entries[i])
+	}
+	if len(order) > 0 {
+		feed.Updated = times[order[0]].UTC().Format(time.RFC3339)
+	}
+	return feed, nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Author  atomAuthor  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomEntry struct {
+	Title   string   `xml:"title"`
+	ID      string   `xml:"id"`
+	Updated string   `xml:"updated"`
+	Content atomText `xml:"content"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr"`
+	Text string `xml:",chardata"`
+}
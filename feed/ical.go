@@ -0,0 +1,109 @@
+package feed
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// calendarSuffix turns a feed URL into the URL of the calendar of the same user
+const calendarSuffix = ".ics"
+
+// icalEscaper escapes TEXT values as RFC 5545 requires
+var icalEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+// calendarEvent is an all-day VEVENT, times of events are reported in carriers' local time without the zone,
+// so dates are all we can put on a calendar reliably
+type calendarEvent struct {
+	uid     string
+	summary string
+	// from and to are the first and the last day, inclusive
+	from, to time.Time
+}
+
+// buildCalendar lists expected deliveries of user's trackings in transit, and deliveries of delivered ones
+func (f *Feeds) buildCalendar(ctx context.Context, userID int64) ([]calendarEvent, error) {
+	var events []calendarEvent
+	for cursor := int64(0); ; {
+		page, err := f.service.ListTrackings(ctx, userID, cursor, trackingsPageSize)
+		if err != nil {
+			return nil, err
+		}
+		for _, tracking := range page.Trackings {
+			name := tracking.TrackingNumber
+			if tracking.DisplayName != "" {
+				name = tracking.DisplayName + " (" + tracking.TrackingNumber + ")"
+			}
+			if deliveredAt, ok := tracking.DeliveredAt(); ok {
+				events = append(events, calendarEvent{
+					uid:     tracking.TrackingNumber + "-delivered@tg-parcels",
+					summary: "Delivered: " + name,
+					from:    deliveredAt,
+					to:      deliveredAt,
+				})
+				continue
+			}
+			eta, err := f.service.EstimateDelivery(ctx, tracking)
+			if err != nil {
+				return nil, err
+			}
+			if eta != nil {
+				events = append(events, calendarEvent{
+					uid:     tracking.TrackingNumber + "-expected@tg-parcels",
+					summary: "Expected delivery: " + name,
+					from:    eta.From,
+					to:      eta.To,
+				})
+			}
+		}
+		if page.NextCursor == 0 {
+			return events, nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+// writeCalendar serializes events as an RFC 5545 calendar
+func writeCalendar(b *strings.Builder, events []calendarEvent, now time.Time) {
+	const dateLayout = "20060102"
+	line := func(format string, args ...interface{}) {
+		writeFolded(b, fmt.Sprintf(format, args...))
+	}
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//tg-parcels//Parcels//EN")
+	line("CALSCALE:GREGORIAN")
+	line("X-WR-CALNAME:Parcels")
+	for _, e := range events {
+		line("BEGIN:VEVENT")
+		line("UID:%s", icalEscaper.Replace(e.uid))
+		line("DTSTAMP:%s", now.UTC().Format("20060102T150405Z"))
+		line("DTSTART;VALUE=DATE:%s", e.from.Format(dateLayout))
+		// the end date is exclusive
+		line("DTEND;VALUE=DATE:%s", e.to.AddDate(0, 0, 1).Format(dateLayout))
+		line("SUMMARY:%s", icalEscaper.Replace(e.summary))
+		line("TRANSP:TRANSPARENT")
+		line("END:VEVENT")
+	}
+	line("END:VCALENDAR")
+}
+
+// writeFolded writes the content line, folding it into lines of at most 75 octets without splitting characters
+func writeFolded(b *strings.Builder, line string) {
+	// continuation lines start with a space, which counts too
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		for !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		limit = 74
+	}
+	b.WriteString(line)
+	b.WriteString("\r\n")
+}
@@ -0,0 +1,42 @@
+package feed
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// NewMemoryStorage creates Storage that keeps tokens in memory, for tests and demos
+func NewMemoryStorage() Storage {
+	return &MemoryStorage{tokenHashes: make(map[int64]string)}
+}
+
+type MemoryStorage struct {
+	mu          sync.Mutex
+	tokenHashes map[int64]string
+}
+
+func (s *MemoryStorage) SaveToken(_ context.Context, userID int64, tokenHash string, _ time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokenHashes[userID] = tokenHash
+	return nil
+}
+
+func (s *MemoryStorage) GetUserID(_ context.Context, tokenHash string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for userID, h := range s.tokenHashes {
+		if h == tokenHash {
+			return userID, nil
+		}
+	}
+	return 0, ErrTokenNotFound
+}
+
+func (s *MemoryStorage) DeleteToken(_ context.Context, userID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.tokenHashes, userID)
+	return nil
+}
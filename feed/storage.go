@@ -0,0 +1,55 @@
+package feed
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/dir01/tg-parcels/core/storage"
+	"github.com/jmoiron/sqlx"
+)
+
+// NewStorage creates Storage backed by SQLite
+func NewStorage(db, readDB *sqlx.DB, queryTimeout time.Duration, metrics *storage.QueryMetrics) Storage {
+	return &SqliteStorage{db: db, readDB: readDB, queryTimeout: queryTimeout, metrics: metrics}
+}
+
+type SqliteStorage struct {
+	db *sqlx.DB
+	// readDB runs reads outside of transactions, it is db itself unless reads are split, see storage.ReadOnlyDSN
+	readDB       *sqlx.DB
+	queryTimeout time.Duration
+	metrics      *storage.QueryMetrics
+}
+
+func (s *SqliteStorage) SaveToken(ctx context.Context, userID int64, tokenHash string, createdAt time.Time) (err error) {
+	defer s.metrics.Observe("save_feed_token", time.Now(), &err)
+	ctx, cancel := storage.WithQueryTimeout(ctx, s.queryTimeout)
+	defer cancel()
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO feed_tokens (user_id, token_hash, created_at) VALUES (?, ?, ?)
+		ON CONFLICT (user_id) DO UPDATE SET token_hash = excluded.token_hash, created_at = excluded.created_at`,
+		userID, tokenHash, createdAt.Unix(),
+	)
+	return err
+}
+
+func (s *SqliteStorage) GetUserID(ctx context.Context, tokenHash string) (userID int64, err error) {
+	defer s.metrics.Observe("get_feed_token", time.Now(), &err)
+	ctx, cancel := storage.WithQueryTimeout(ctx, s.queryTimeout)
+	defer cancel()
+	err = s.readDB.GetContext(ctx, &userID, `SELECT user_id FROM feed_tokens WHERE token_hash = ?`, tokenHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrTokenNotFound
+	}
+	return userID, err
+}
+
+func (s *SqliteStorage) DeleteToken(ctx context.Context, userID int64) (err error) {
+	defer s.metrics.Observe("delete_feed_token", time.Now(), &err)
+	ctx, cancel := storage.WithQueryTimeout(ctx, s.queryTimeout)
+	defer cancel()
+	_, err = s.db.ExecContext(ctx, `DELETE FROM feed_tokens WHERE user_id = ?`, userID)
+	return err
+}
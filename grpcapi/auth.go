@@ -0,0 +1,71 @@
+package grpcapi
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"errors"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// ErrNoCredentials is returned by NewServer when Auth allows unauthenticated clients
+var ErrNoCredentials = errors.New("gRPC API needs a token or client certificates to authenticate clients")
+
+// Auth is how clients of the API are authenticated: with a token, with client certificates, or both
+type Auth struct {
+	// Token is expected in "authorization" metadata of every call as "Bearer <token>", empty means no token is expected
+	Token string
+	// TLS serves the API over TLS, clients are authenticated with certificates if it requires and verifies them
+	TLS *tls.Config
+}
+
+func (a Auth) requiresClientCerts() bool {
+	return a.TLS != nil && a.TLS.ClientAuth == tls.RequireAndVerifyClientCert
+}
+
+// serverOptions are options of a server authenticating clients so, it returns ErrNoCredentials
+// if neither a token nor client certificates are required
+func (a Auth) serverOptions() ([]grpc.ServerOption, error) {
+	if a.Token == "" && !a.requiresClientCerts() {
+		return nil, ErrNoCredentials
+	}
+	var opts []grpc.ServerOption
+	if a.TLS != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(a.TLS)))
+	}
+	if a.Token != "" {
+		opts = append(opts, grpc.UnaryInterceptor(a.unaryInterceptor), grpc.StreamInterceptor(a.streamInterceptor))
+	}
+	return opts, nil
+}
+
+func (a Auth) unaryInterceptor(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if err := a.authenticate(ctx); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (a Auth) streamInterceptor(srv any, stream grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := a.authenticate(stream.Context()); err != nil {
+		return err
+	}
+	return handler(srv, stream)
+}
+
+func (a Auth) authenticate(ctx context.Context) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, value := range md.Get("authorization") {
+		token := strings.TrimPrefix(value, "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(a.Token)) == 1 {
+			return nil
+		}
+	}
+	return status.Error(codes.Unauthenticated, "invalid token")
+}
@@ -0,0 +1,201 @@
+// Package grpcapi serves core.Service over gRPC, see parcelspb/parcels.proto.
+// Clients are trusted to act on behalf of any user, so they have to authenticate, see Auth
+package grpcapi
+
+//go:generate protoc -I parcelspb --go_out=parcelspb --go_opt=paths=source_relative --go-grpc_out=parcelspb --go-grpc_opt=paths=source_relative parcels.proto
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/dir01/parcels/parcels_api"
+	"github.com/dir01/tg-parcels/core"
+	"github.com/dir01/tg-parcels/grpcapi/parcelspb"
+	"github.com/hori-ryota/zaperr"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// defaultPageSize is the page size of ListTrackings when the request doesn't set one
+const defaultPageSize = 50
+
+// NewServer creates a gRPC server with the Parcels service registered.
+// Every call is authenticated, so it returns ErrNoCredentials unless auth requires a token or client certificates
+func NewServer(service core.Service, auth Auth, logger *zap.Logger) (*grpc.Server, error) {
+	opts, err := auth.serverOptions()
+	if err != nil {
+		return nil, err
+	}
+	server := grpc.NewServer(opts...)
+	parcelspb.RegisterParcelsServer(server, NewParcelsServer(service, logger))
+	return server, nil
+}
+
+func NewParcelsServer(service core.Service, logger *zap.Logger) *ParcelsServer {
+	s := &ParcelsServer{service: service, logger: logger}
+	var _ parcelspb.ParcelsServer = s
+	return s
+}
+
+type ParcelsServer struct {
+	parcelspb.UnimplementedParcelsServer
+	service core.Service
+	logger  *zap.Logger
+}
+
+func (s *ParcelsServer) Track(ctx context.Context, req *parcelspb.TrackRequest) (*parcelspb.TrackResponse, error) {
+	if req.UserId == 0 || req.TrackingNumber == "" {
+		return nil, status.Error(codes.InvalidArgument, "user_id and tracking_number are required")
+	}
+	if err := s.service.Track(ctx, req.UserId, req.TrackingNumber, req.DisplayName, core.TrackingParams{}); err != nil {
+		return nil, s.toStatus(err)
+	}
+	return &parcelspb.TrackResponse{}, nil
+}
+
+func (s *ParcelsServer) GetTracking(ctx context.Context, req *parcelspb.GetTrackingRequest) (*parcelspb.Tracking, error) {
+	tracking, err := s.service.GetTracking(ctx, req.UserId, req.TrackingNumber)
+	if err != nil {
+		return nil, s.toStatus(err)
+	}
+	return toTracking(tracking), nil
+}
+
+func (s *ParcelsServer) ListTrackings(ctx context.Context, req *parcelspb.ListTrackingsRequest) (*parcelspb.ListTrackingsResponse, error) {
+	if req.Limit < 0 {
+		return nil, status.Error(codes.InvalidArgument, "limit can't be negative")
+	}
+	limit := int(req.Limit)
+	if limit == 0 {
+		limit = defaultPageSize
+	}
+	page, err := s.service.ListTrackings(ctx, req.UserId, req.Cursor, limit)
+	if err != nil {
+		return nil, s.toStatus(err)
+	}
+
+	resp := &parcelspb.ListTrackingsResponse{NextCursor: page.NextCursor, Total: int32(page.Total)}
+	for _, tracking := range page.Trackings {
+		resp.Trackings = append(resp.Trackings, toTracking(tracking))
+	}
+	return resp, nil
+}
+
+func (s *ParcelsServer) DeleteTracking(ctx context.Context, req *parcelspb.DeleteTrackingRequest) (*parcelspb.DeleteTrackingResponse, error) {
+	if err := s.service.DeleteTracking(ctx, req.UserId, req.TrackingNumber); err != nil {
+		return nil, s.toStatus(err)
+	}
+	return &parcelspb.DeleteTrackingResponse{}, nil
+}
+
+// Updates subscribes to the service for as long as the stream is open.
+// Updates are not confirmed with MarkUpdateDelivered, that is up to the bot
+func (s *ParcelsServer) Updates(req *parcelspb.UpdatesRequest, stream parcelspb.Parcels_UpdatesServer) error {
+	updates := s.service.Subscribe()
+	defer s.service.Unsubscribe(updates)
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case update, ok := <-updates:
+			if !ok {
+				return status.Error(codes.Unavailable, "service is shutting down")
+			}
+			if req.UserId != 0 && update.UserID != req.UserId {
+				continue
+			}
+			if err := stream.Send(toTrackingUpdate(&update)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// toStatus maps errors of the service to gRPC codes, unexpected errors are logged and their details hidden
+func (s *ParcelsServer) toStatus(err error) error {
+	var quotaErr *core.TrackingQuotaExceededError
+	switch {
+	case errors.Is(err, core.ErrTrackingExists):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, core.ErrTrackingNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, core.ErrInvalidTrackingNumber), errors.Is(err, core.ErrPostalCodeRequired):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.As(err, &quotaErr):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	}
+	s.logger.Error("gRPC request failed", zaperr.ToField(err))
+	return status.Error(codes.Internal, "internal error")
+}
+
+func toTracking(t *core.Tracking) *parcelspb.Tracking {
+	return &parcelspb.Tracking{
+		Id:             t.ID,
+		UserId:         t.UserID,
+		TrackingNumber: t.TrackingNumber,
+		DisplayName:    t.DisplayName,
+		TrackingInfos:  toTrackingInfos(t.TrackingInfos),
+		LastPolledAt:   toTimestamp(t.LastPolledAt),
+		NextPollAt:     toTimestamp(t.NextPollAt),
+		PushSubscribed: t.PushSubscribed,
+	}
+}
+
+func toTrackingUpdate(u *core.TrackingUpdate) *parcelspb.TrackingUpdate {
+	update := &parcelspb.TrackingUpdate{
+		UserId:           u.UserID,
+		TrackingNumber:   u.TrackingNumber,
+		DisplayName:      u.DisplayName,
+		NewTrackingInfos: toTrackingInfos(u.NewTrackingInfos),
+	}
+	for _, e := range u.NewTrackingEvents {
+		update.NewTrackingEvents = append(update.NewTrackingEvents, toTrackingEvent(e))
+	}
+	if u.ETA != nil {
+		update.Eta = &parcelspb.ETA{
+			From:         timestamppb.New(u.ETA.From),
+			To:           timestamppb.New(u.ETA.To),
+			SamplesCount: int32(u.ETA.SamplesCount),
+		}
+	}
+	if u.TrackingError != nil {
+		update.Error = &parcelspb.Error{Code: string(u.ErrorCode), Message: u.TrackingError.Error()}
+	}
+	return update
+}
+
+func toTrackingInfos(infos []*parcels_api.TrackingInfo) []*parcelspb.TrackingInfo {
+	result := make([]*parcelspb.TrackingInfo, 0, len(infos))
+	for _, info := range infos {
+		pbInfo := &parcelspb.TrackingInfo{
+			ApiName:       info.ApiName,
+			IsDelivered:   info.IsDelivered,
+			LastCheckedAt: info.LastCheckedAt,
+			LastUpdatedAt: info.LastUpdatedAt,
+		}
+		for i := range info.Events {
+			pbInfo.Events = append(pbInfo.Events, toTrackingEvent(&info.Events[i]))
+		}
+		result = append(result, pbInfo)
+	}
+	return result
+}
+
+func toTrackingEvent(e *parcels_api.TrackingEvent) *parcelspb.TrackingEvent {
+	return &parcelspb.TrackingEvent{Time: e.Time, Description: e.Description, Status: e.Status}
+}
+
+func toTimestamp(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
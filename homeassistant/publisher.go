@@ -0,0 +1,243 @@
+// Package homeassistant publishes states of trackings to an MQTT broker, along with Home Assistant discovery messages,
+// so that every tracking shows up in Home Assistant as a sensor of its status without any configuration.
+// Everything is published retained, so that Home Assistant picks the states up whenever it (re)connects
+package homeassistant
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/dir01/tg-parcels/core"
+	"github.com/dir01/tg-parcels/metrics"
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"github.com/hori-ryota/zaperr"
+	"go.uber.org/zap"
+)
+
+const (
+	// publishTimeout limits waiting for the broker to acknowledge a message
+	publishTimeout = 10 * time.Second
+	// qos 1 makes the broker acknowledge messages, so failures are noticed
+	qos = 1
+	// sensorIcon is what Home Assistant shows next to parcel sensors
+	sensorIcon = "mdi:package-variant-closed"
+)
+
+// unsafeTopicChars can't be used in topic levels and Home Assistant object IDs
+var unsafeTopicChars = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+
+// Options configure the connection to the broker and topics
+type Options struct {
+	// BrokerURL is e.g. "tcp://localhost:1883" or "ssl://broker.example.com:8883"
+	BrokerURL string
+	Username  string
+	Password  string
+	ClientID  string
+	// TopicPrefix is the root of state topics, e.g. "tg-parcels"
+	TopicPrefix string
+	// DiscoveryPrefix is the root of topics Home Assistant discovers entities at, "homeassistant" by default
+	DiscoveryPrefix string
+	// UserIDs whose trackings are published, all users' trackings are published if it is empty
+	UserIDs []int64
+}
+
+// NewPublisher creates Publisher, it doesn't connect until Run
+func NewPublisher(opts Options, registry *metrics.Registry, logger *zap.Logger) *Publisher {
+	p := &Publisher{
+		opts:    opts,
+		userIDs: make(map[int64]bool, len(opts.UserIDs)),
+		logger:  logger,
+		publishes: registry.NewCounterVec(
+			"tg_parcels_mqtt_publishes_total", "Tracking states published to MQTT by result.", "result",
+		),
+	}
+	for _, userID := range opts.UserIDs {
+		p.userIDs[userID] = true
+	}
+	clientOpts := paho.NewClientOptions().
+		AddBroker(opts.BrokerURL).
+		SetClientID(opts.ClientID).
+		SetUsername(opts.Username).
+		SetPassword(opts.Password).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		// the broker tells Home Assistant the sensors are unavailable once the bot is gone
+		SetWill(p.availabilityTopic(), "offline", qos, true).
+		SetOnConnectHandler(func(client paho.Client) {
+			if err := p.publish(client, p.availabilityTopic(), []byte("online")); err != nil {
+				logger.Error("failed to publish MQTT availability", zaperr.ToField(err))
+			}
+		}).
+		SetConnectionLostHandler(func(_ paho.Client, err error) {
+			logger.Warn("MQTT connection lost, reconnecting", zap.Error(err))
+		})
+	p.client = paho.NewClient(clientOpts)
+	return p
+}
+
+// Publisher publishes the state of a tracking every time the tracking is updated
+type Publisher struct {
+	opts      Options
+	userIDs   map[int64]bool
+	client    paho.Client
+	publishes *metrics.CounterVec
+	logger    *zap.Logger
+}
+
+// Run publishes tracking updates until updates are unsubscribed, then marks the sensors offline and disconnects.
+// Cancelling ctx stops publishing right away. updates should be subscribed before the service starts,
+// so that redelivered updates are published too. Nil Publisher does nothing
+func (p *Publisher) Run(ctx context.Context, service core.Service, updates <-chan core.TrackingUpdate) {
+	if p == nil {
+		return
+	}
+	// with connect retry the token completes only once connected, updates wait in the buffer until then
+	connected := p.client.Connect()
+	select {
+	case <-connected.Done():
+		if err := connected.Error(); err != nil {
+			p.logger.Error("failed to connect to MQTT broker", zaperr.ToField(err))
+		}
+	case <-ctx.Done():
+		p.client.Disconnect(0)
+		return
+	}
+	defer func() {
+		if err := p.publish(p.client, p.availabilityTopic(), []byte("offline")); err != nil {
+			p.logger.Warn("failed to publish MQTT availability", zaperr.ToField(err))
+		}
+		p.client.Disconnect(uint(time.Second / time.Millisecond))
+	}()
+	for update := range updates {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := p.publishUpdate(ctx, service, &update); err != nil {
+			p.publishes.Inc("error")
+			p.logger.Error("failed to publish tracking state to MQTT", append(core.UpdateFields(&update), zaperr.ToField(err))...)
+			continue
+		}
+		p.publishes.Inc("ok")
+	}
+}
+
+type state struct {
+	TrackingNumber string     `json:"tracking_number"`
+	DisplayName    string     `json:"display_name"`
+	Status         string     `json:"status"`
+	LastEvent      string     `json:"last_event"`
+	LastEventTime  string     `json:"last_event_time"`
+	ETAFrom        *time.Time `json:"eta_from"`
+	ETATo          *time.Time `json:"eta_to"`
+}
+
+type discovery struct {
+	Name                string          `json:"name"`
+	UniqueID            string          `json:"unique_id"`
+	ObjectID            string          `json:"object_id"`
+	StateTopic          string          `json:"state_topic"`
+	ValueTemplate       string          `json:"value_template"`
+	JSONAttributesTopic string          `json:"json_attributes_topic"`
+	AvailabilityTopic   string          `json:"availability_topic"`
+	Icon                string          `json:"icon"`
+	Device              discoveryDevice `json:"device"`
+}
+
+type discoveryDevice struct {
+	Identifiers []string `json:"identifiers"`
+	Name        string   `json:"name"`
+}
+
+func (p *Publisher) publishUpdate(ctx context.Context, service core.Service, update *core.TrackingUpdate) error {
+	// errors are answers to commands and alerts have no news, they don't change the state
+	if update.TrackingError != nil || update.StuckSince != nil {
+		return nil
+	}
+	if len(p.userIDs) > 0 && !p.userIDs[update.UserID] {
+		return nil
+	}
+	// updates carry new events only, the status is of the whole tracking
+	tracking, err := service.GetTracking(ctx, update.UserID, update.TrackingNumber)
+	if err != nil {
+		return zaperr.Wrap(err, "failed to get tracking")
+	}
+
+	st := state{
+		TrackingNumber: tracking.TrackingNumber,
+		DisplayName:    tracking.DisplayName,
+		Status:         string(tracking.Status()),
+	}
+	for _, info := range tracking.TrackingInfos {
+		if n := len(info.Events); n > 0 {
+			st.LastEvent, st.LastEventTime = info.Events[n-1].Description, info.Events[n-1].Time
+		}
+	}
+	if update.ETA != nil {
+		st.ETAFrom, st.ETATo = &update.ETA.From, &update.ETA.To
+	}
+	stateTopic := p.stateTopic(update.UserID, update.TrackingNumber)
+
+	name := tracking.DisplayName
+	if name == "" {
+		name = tracking.TrackingNumber
+	}
+	objectID := fmt.Sprintf("tg_parcels_%d_%s", update.UserID, sanitize(update.TrackingNumber))
+	config := discovery{
+		Name:                name,
+		UniqueID:            objectID,
+		ObjectID:            objectID,
+		StateTopic:          stateTopic,
+		ValueTemplate:       "{{ value_json.status }}",
+		JSONAttributesTopic: stateTopic,
+		AvailabilityTopic:   p.availabilityTopic(),
+		Icon:                sensorIcon,
+		Device: discoveryDevice{
+			Identifiers: []string{fmt.Sprintf("tg_parcels_%d", update.UserID)},
+			Name:        "Parcels",
+		},
+	}
+
+	// discovery goes first, so that Home Assistant is subscribed to the state by the time it arrives
+	if err := p.publishJSON(p.discoveryTopic(objectID), config); err != nil {
+		return err
+	}
+	return p.publishJSON(stateTopic, st)
+}
+
+func (p *Publisher) publishJSON(topic string, v interface{}) error {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return zaperr.Wrap(err, "failed to marshal MQTT message", zap.String("topic", topic))
+	}
+	return p.publish(p.client, topic, payload)
+}
+
+func (p *Publisher) publish(client paho.Client, topic string, payload []byte) error {
+	token := client.Publish(topic, qos, true, payload)
+	if !token.WaitTimeout(publishTimeout) {
+		return zaperr.New("timed out publishing to MQTT", zap.String("topic", topic))
+	}
+	if err := token.Error(); err != nil {
+		return zaperr.Wrap(err, "failed to publish to MQTT", zap.String("topic", topic))
+	}
+	return nil
+}
+
+func (p *Publisher) stateTopic(userID int64, trackingNumber string) string {
+	return fmt.Sprintf("%s/%d/%s/state", p.opts.TopicPrefix, userID, sanitize(trackingNumber))
+}
+
+func (p *Publisher) availabilityTopic() string {
+	return p.opts.TopicPrefix + "/availability"
+}
+
+func (p *Publisher) discoveryTopic(objectID string) string {
+	return p.opts.DiscoveryPrefix + "/sensor/" + objectID + "/config"
+}
+
+func sanitize(s string) string {
+	return unsafeTopicChars.ReplaceAllString(s, "_")
+}
@@ -0,0 +1,323 @@
+// Package httpapi is an HTTP API for operators of the bot. Every request has to be authenticated
+// with the token in the Authorization header: "Bearer <token>".
+//
+//	GET    /admin/users?after=<user id>&limit=<n>                  lists users page by page
+//	GET    /admin/users/<user id>/trackings?cursor=<c>&limit=<n>   lists trackings of the user page by page
+//	DELETE /admin/users/<user id>/trackings/<tracking number>      stops tracking, it can be restored by the user
+//	GET    /admin/users/<user id>/channels                         lists notification channels of the user
+//	PUT    /admin/users/<user id>/channels/<channel>               sets the channel up, the body is {"destination": "..."}
+//	DELETE /admin/users/<user id>/channels/<channel>               stops sending updates through the channel
+//	POST   /admin/trackings/<tracking number>/refresh              fetches fresh info for every user tracking the number
+//	POST   /admin/poll                                             polls due trackings right away
+//	GET    /admin/errors                                           lists recent errors, newest first
+package httpapi
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dir01/parcels/parcels_api"
+	"github.com/dir01/tg-parcels/bot"
+	"github.com/dir01/tg-parcels/core"
+	"github.com/dir01/tg-parcels/notify"
+	"github.com/hori-ryota/zaperr"
+	"go.uber.org/zap"
+)
+
+// Prefix is the path the API has to be mounted at
+const Prefix = "/admin/"
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 1000
+)
+
+// Service is the part of core.ServiceImpl the API operates
+type Service interface {
+	ListTrackings(ctx context.Context, userID int64, cursor int64, limit int) (*core.TrackingsPage, error)
+	DeleteTracking(ctx context.Context, userID int64, trackingNumber string) error
+	RefreshTrackingNumber(ctx context.Context, trackingNumber string) (int, error)
+	TriggerPoll() bool
+}
+
+type UserStorage interface {
+	ListUsers(ctx context.Context, afterUserID int64, limit int) ([]*bot.User, error)
+}
+
+// New creates the API, channels may be nil if no notification channels other than Telegram are configured
+func New(service Service, users UserStorage, channels *notify.Channels, errorLog *ErrorLog, token string, logger *zap.Logger) *API {
+	a := &API{service: service, users: users, channels: channels, errorLog: errorLog, token: token, logger: logger}
+	var _ http.Handler = a
+	return a
+}
+
+type API struct {
+	service  Service
+	users    UserStorage
+	channels *notify.Channels
+	errorLog *ErrorLog
+	token    string
+	logger   *zap.Logger
+}
+
+type user struct {
+	ID           int64      `json:"id"`
+	ChatID       int64      `json:"chat_id"`
+	CreatedAt    *time.Time `json:"created_at"`
+	LanguageCode string     `json:"language_code,omitempty"`
+}
+
+type channel struct {
+	Name        string `json:"name"`
+	Destination string `json:"destination"`
+	Verified    bool   `json:"verified"`
+	// Secret is only returned when the channel is set
+	Secret string `json:"secret,omitempty"`
+	// SigningInstructions tell how to check signatures made with the secret
+	SigningInstructions string `json:"signing_instructions,omitempty"`
+}
+
+type tracking struct {
+	ID             int64                       `json:"id"`
+	UserID         int64                       `json:"user_id"`
+	TrackingNumber string                      `json:"tracking_number"`
+	DisplayName    string                      `json:"display_name"`
+	LastPolledAt   *time.Time                  `json:"last_polled_at"`
+	NextPollAt     *time.Time                  `json:"next_poll_at"`
+	PushSubscribed bool                        `json:"push_subscribed"`
+	TrackingInfos  []*parcels_api.TrackingInfo `json:"tracking_infos"`
+}
+
+func (a *API) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !a.isAuthorized(r) {
+		a.logger.Warn("unauthorized admin API request", zap.String("remote_addr", r.RemoteAddr), zap.String("path", r.URL.Path))
+		writeError(w, http.StatusUnauthorized, "invalid token")
+		return
+	}
+
+	path := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, Prefix), "/"), "/")
+	switch {
+	case r.Method == http.MethodGet && len(path) == 1 && path[0] == "users":
+		a.listUsers(w, r)
+	case r.Method == http.MethodGet && len(path) == 3 && path[0] == "users" && path[2] == "trackings":
+		a.listTrackings(w, r, path[1])
+	case r.Method == http.MethodDelete && len(path) == 4 && path[0] == "users" && path[2] == "trackings":
+		a.deleteTracking(w, r, path[1], path[3])
+	case r.Method == http.MethodGet && len(path) == 3 && path[0] == "users" && path[2] == "channels":
+		a.listChannels(w, r, path[1])
+	case r.Method == http.MethodPut && len(path) == 4 && path[0] == "users" && path[2] == "channels":
+		a.setChannel(w, r, path[1], path[3])
+	case r.Method == http.MethodDelete && len(path) == 4 && path[0] == "users" && path[2] == "channels":
+		a.removeChannel(w, r, path[1], path[3])
+	case r.Method == http.MethodPost && len(path) == 3 && path[0] == "trackings" && path[2] == "refresh":
+		a.refreshTrackingNumber(w, r, path[1])
+	case r.Method == http.MethodPost && len(path) == 1 && path[0] == "poll":
+		writeJSON(w, http.StatusAccepted, map[string]bool{"triggered": a.service.TriggerPoll()})
+	case r.Method == http.MethodGet && len(path) == 1 && path[0] == "errors":
+		writeJSON(w, http.StatusOK, map[string]interface{}{"errors": a.errorLog.Recent()})
+	default:
+		writeError(w, http.StatusNotFound, "no such endpoint")
+	}
+}
+
+func (a *API) isAuthorized(r *http.Request) bool {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return a.token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(a.token)) == 1
+}
+
+func (a *API) listUsers(w http.ResponseWriter, r *http.Request) {
+	after, limit, err := pageParams(r.URL.Query(), "after")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	users, err := a.users.ListUsers(r.Context(), after, limit)
+	if err != nil {
+		a.fail(w, r, err)
+		return
+	}
+
+	resp := struct {
+		Users []user `json:"users"`
+		// NextAfter is the "after" of the next page, 0 if this is the last one
+		NextAfter int64 `json:"next_after"`
+	}{Users: make([]user, 0, len(users))}
+	for _, u := range users {
+		resp.Users = append(resp.Users, user{ID: u.ID, ChatID: u.ChatID, CreatedAt: u.CreatedAt, LanguageCode: u.LanguageCode})
+	}
+	if len(users) == limit {
+		resp.NextAfter = users[len(users)-1].ID
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (a *API) listTrackings(w http.ResponseWriter, r *http.Request, rawUserID string) {
+	userID, err := strconv.ParseInt(rawUserID, 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid user id")
+		return
+	}
+	cursor, limit, err := pageParams(r.URL.Query(), "cursor")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	page, err := a.service.ListTrackings(r.Context(), userID, cursor, limit)
+	if err != nil {
+		a.fail(w, r, err)
+		return
+	}
+
+	resp := struct {
+		Trackings  []tracking `json:"trackings"`
+		NextCursor int64      `json:"next_cursor"`
+		Total      int        `json:"total"`
+	}{Trackings: make([]tracking, 0, len(page.Trackings)), NextCursor: page.NextCursor, Total: page.Total}
+	for _, t := range page.Trackings {
+		resp.Trackings = append(resp.Trackings, tracking{
+			ID:             t.ID,
+			UserID:         t.UserID,
+			TrackingNumber: t.TrackingNumber,
+			DisplayName:    t.DisplayName,
+			LastPolledAt:   t.LastPolledAt,
+			NextPollAt:     t.NextPollAt,
+			PushSubscribed: t.PushSubscribed,
+			TrackingInfos:  t.TrackingInfos,
+		})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (a *API) deleteTracking(w http.ResponseWriter, r *http.Request, rawUserID string, trackingNumber string) {
+	userID, err := strconv.ParseInt(rawUserID, 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid user id")
+		return
+	}
+	if err := a.service.DeleteTracking(r.Context(), userID, trackingNumber); err != nil {
+		a.fail(w, r, err)
+		return
+	}
+	a.logger.Info("tracking deleted by admin", core.UserIDField(userID), core.TrackingNumberField(trackingNumber))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *API) listChannels(w http.ResponseWriter, r *http.Request, rawUserID string) {
+	userID, err := strconv.ParseInt(rawUserID, 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid user id")
+		return
+	}
+	channels, err := a.channels.List(r.Context(), userID)
+	if err != nil {
+		a.fail(w, r, err)
+		return
+	}
+	resp := struct {
+		Channels []channel `json:"channels"`
+	}{Channels: make([]channel, 0, len(channels))}
+	for _, c := range channels {
+		resp.Channels = append(resp.Channels, channel{Name: c.Name, Destination: c.Destination, Verified: c.Verified})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// setChannel sets the channel up, channels needing verification stay unverified until the user enters the code in the bot
+func (a *API) setChannel(w http.ResponseWriter, r *http.Request, rawUserID string, name string) {
+	userID, err := strconv.ParseInt(rawUserID, 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid user id")
+		return
+	}
+	var req struct {
+		Destination string `json:"destination"`
+	}
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<16)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid body")
+		return
+	}
+	set, err := a.channels.Set(r.Context(), userID, name, req.Destination)
+	if errors.Is(err, notify.ErrInvalidDestination) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		a.fail(w, r, err)
+		return
+	}
+	a.logger.Info("notification channel set by admin", core.UserIDField(userID), zap.String("channel", name))
+	writeJSON(w, http.StatusOK, channel{
+		Name:                set.Name,
+		Destination:         set.Destination,
+		Verified:            set.Verified,
+		Secret:              set.Secret,
+		SigningInstructions: a.channels.SigningInstructions(name),
+	})
+}
+
+func (a *API) removeChannel(w http.ResponseWriter, r *http.Request, rawUserID string, name string) {
+	userID, err := strconv.ParseInt(rawUserID, 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid user id")
+		return
+	}
+	if err := a.channels.Remove(r.Context(), userID, name); err != nil {
+		a.fail(w, r, err)
+		return
+	}
+	a.logger.Info("notification channel removed by admin", core.UserIDField(userID), zap.String("channel", name))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *API) refreshTrackingNumber(w http.ResponseWriter, r *http.Request, trackingNumber string) {
+	refreshed, err := a.service.RefreshTrackingNumber(r.Context(), trackingNumber)
+	if err != nil {
+		a.fail(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]int{"refreshed": refreshed})
+}
+
+// fail responds with 404 to errors of missing things and with 500 to other errors
+func (a *API) fail(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, core.ErrTrackingNotFound) || errors.Is(err, notify.ErrUnknownChannel) || errors.Is(err, notify.ErrChannelNotFound) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	a.logger.Error("admin API request failed", zap.String("method", r.Method), zap.String("path", r.URL.Path), zaperr.ToField(err))
+	writeError(w, http.StatusInternalServerError, "internal error")
+}
+
+// pageParams parses the cursor named cursorName (0 if it is missing) and the page size
+func pageParams(query url.Values, cursorName string) (cursor int64, limit int, err error) {
+	limit = defaultPageSize
+	if raw := query.Get(cursorName); raw != "" {
+		if cursor, err = strconv.ParseInt(raw, 10, 64); err != nil {
+			return 0, 0, errors.New("invalid " + cursorName)
+		}
+	}
+	if raw := query.Get("limit"); raw != "" {
+		if limit, err = strconv.Atoi(raw); err != nil || limit < 1 || limit > maxPageSize {
+			return 0, 0, errors.New("limit must be between 1 and " + strconv.Itoa(maxPageSize))
+		}
+	}
+	return cursor, limit, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
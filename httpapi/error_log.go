@@ -0,0 +1,96 @@
+package httpapi
+
+import (
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// NewErrorLog keeps the last size error-level log entries, it is meant to be teed into the logger:
+//
+//	logger = logger.WithOptions(zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+//		return zapcore.NewTee(c, errorLog)
+//	}))
+func NewErrorLog(size int) *ErrorLog {
+	l := &ErrorLog{LevelEnabler: zapcore.ErrorLevel, ring: &errorRing{entries: make([]*ErrorEntry, 0, size), size: size}}
+	var _ zapcore.Core = l
+	return l
+}
+
+// ErrorLog is a zapcore.Core that remembers recent errors, so that they can be looked at without digging in logs
+type ErrorLog struct {
+	zapcore.LevelEnabler
+	ring   *errorRing
+	fields []zapcore.Field
+}
+
+type ErrorEntry struct {
+	Time    time.Time              `json:"time"`
+	Level   string                 `json:"level"`
+	Message string                 `json:"message"`
+	Caller  string                 `json:"caller,omitempty"`
+	Fields  map[string]interface{} `json:"fields,omitempty"`
+}
+
+type errorRing struct {
+	mu      sync.Mutex
+	entries []*ErrorEntry
+	size    int
+	next    int
+}
+
+func (l *ErrorLog) With(fields []zapcore.Field) zapcore.Core {
+	return &ErrorLog{
+		LevelEnabler: l.LevelEnabler,
+		ring:         l.ring,
+		fields:       append(l.fields[:len(l.fields):len(l.fields)], fields...),
+	}
+}
+
+func (l *ErrorLog) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if l.Enabled(entry.Level) {
+		return checked.AddCore(entry, l)
+	}
+	return checked
+}
+
+func (l *ErrorLog) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	enc := zapcore.NewMapObjectEncoder()
+	for _, f := range append(l.fields[:len(l.fields):len(l.fields)], fields...) {
+		f.AddTo(enc)
+	}
+	e := &ErrorEntry{Time: entry.Time, Level: entry.Level.String(), Message: entry.Message, Fields: enc.Fields}
+	if entry.Caller.Defined {
+		e.Caller = entry.Caller.TrimmedPath()
+	}
+
+	r := l.ring
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.entries) < r.size {
+		r.entries = append(r.entries, e)
+	} else if r.size > 0 {
+		r.entries[r.next] = e
+	}
+	if r.size > 0 {
+		r.next = (r.next + 1) % r.size
+	}
+	return nil
+}
+
+func (l *ErrorLog) Sync() error {
+	return nil
+}
+
+// Recent returns remembered errors, newest first
+func (l *ErrorLog) Recent() []*ErrorEntry {
+	r := l.ring
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	recent := make([]*ErrorEntry, 0, len(r.entries))
+	for i := 1; i <= len(r.entries); i++ {
+		recent = append(recent, r.entries[(r.next-i+len(r.entries))%len(r.entries)])
+	}
+	return recent
+}
@@ -0,0 +1,285 @@
+// Package metrics is a minimal Prometheus instrumentation: counters, histograms and functions
+// evaluated on scrape, exposed in the Prometheus text format.
+// Nil Registry is valid and so are metrics created by it, they record nothing
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DurationBuckets are upper bounds (in seconds) of duration histogram buckets,
+// durations above the last bound fall into the +Inf bucket
+var DurationBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30}
+
+func NewRegistry() *Registry {
+	return &Registry{names: make(map[string]bool)}
+}
+
+// Registry keeps metrics in the order they were registered, names have to be unique
+type Registry struct {
+	mu         sync.Mutex
+	names      map[string]bool
+	collectors []func(w *Writer)
+}
+
+func (r *Registry) register(name string, collect func(w *Writer)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if name != "" {
+		if r.names[name] {
+			panic("metric " + name + " is registered twice")
+		}
+		r.names[name] = true
+	}
+	r.collectors = append(r.collectors, collect)
+}
+
+// NewCounterVec registers a counter partitioned by the labels
+func (r *Registry) NewCounterVec(name, help string, labelNames ...string) *CounterVec {
+	if r == nil {
+		return nil
+	}
+	c := &CounterVec{vec: newVec(labelNames), values: make(map[string]float64)}
+	r.register(name, func(w *Writer) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		for _, key := range c.sortedKeys() {
+			w.Counter(name, help, c.labels(key), c.values[key])
+		}
+	})
+	return c
+}
+
+// NewHistogramVec registers a histogram partitioned by the labels, buckets are upper bounds in ascending order
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	if r == nil {
+		return nil
+	}
+	h := &HistogramVec{vec: newVec(labelNames), buckets: buckets, values: make(map[string]*histogramValue)}
+	r.register(name, func(w *Writer) {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		for _, key := range h.sortedKeys() {
+			v := h.values[key]
+			w.Histogram(name, help, h.labels(key), h.buckets, v.counts, v.sum)
+		}
+	})
+	return h
+}
+
+// NewCounterFunc registers a counter whose value is taken from fn on every scrape
+func (r *Registry) NewCounterFunc(name, help string, fn func() float64) {
+	if r == nil {
+		return
+	}
+	r.register(name, func(w *Writer) {
+		w.Counter(name, help, nil, fn())
+	})
+}
+
+// NewGaugeFunc registers a gauge whose value is taken from fn on every scrape
+func (r *Registry) NewGaugeFunc(name, help string, fn func() float64) {
+	if r == nil {
+		return
+	}
+	r.register(name, func(w *Writer) {
+		w.Gauge(name, help, nil, fn())
+	})
+}
+
+// NewCollector registers fn that writes metrics kept elsewhere on every scrape.
+// All samples of a metric have to be written in one go
+func (r *Registry) NewCollector(fn func(w *Writer)) {
+	if r == nil {
+		return
+	}
+	r.register("", fn)
+}
+
+// Handler serves all metrics in the Prometheus text format
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		w := &Writer{}
+		if r != nil {
+			r.mu.Lock()
+			collectors := append([]func(*Writer){}, r.collectors...)
+			r.mu.Unlock()
+			for _, collect := range collectors {
+				collect(w)
+			}
+		}
+		rw.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_, _ = rw.Write(w.buf.Bytes())
+	})
+}
+
+// Labels are label names and values of a sample
+type Labels [][2]string
+
+type vec struct {
+	labelNames  []string
+	mu          sync.Mutex
+	labelValues map[string][]string
+}
+
+func newVec(labelNames []string) vec {
+	return vec{labelNames: labelNames, labelValues: make(map[string][]string)}
+}
+
+// key has to be called with mu locked
+func (v *vec) key(labelValues []string) string {
+	if len(labelValues) != len(v.labelNames) {
+		panic(fmt.Sprintf("expected %d label values, got %d", len(v.labelNames), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	if _, ok := v.labelValues[key]; !ok {
+		v.labelValues[key] = append([]string(nil), labelValues...)
+	}
+	return key
+}
+
+func (v *vec) labels(key string) Labels {
+	labels := make(Labels, len(v.labelNames))
+	for i, name := range v.labelNames {
+		labels[i] = [2]string{name, v.labelValues[key][i]}
+	}
+	return labels
+}
+
+func (v *vec) sortedKeys() []string {
+	keys := make([]string, 0, len(v.labelValues))
+	for key := range v.labelValues {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+type CounterVec struct {
+	vec
+	values map[string]float64
+}
+
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c *CounterVec) Add(delta float64, labelValues ...string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[c.key(labelValues)] += delta
+}
+
+type HistogramVec struct {
+	vec
+	buckets []float64
+	values  map[string]*histogramValue
+}
+
+type histogramValue struct {
+	counts []uint64 // non-cumulative, +Inf bucket is the last
+	sum    float64
+}
+
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	key := h.key(labelValues)
+	v, ok := h.values[key]
+	if !ok {
+		v = &histogramValue{counts: make([]uint64, len(h.buckets)+1)}
+		h.values[key] = v
+	}
+	bucket := sort.SearchFloat64s(h.buckets, value)
+	v.counts[bucket]++
+	v.sum += value
+}
+
+// ObserveSince observes time elapsed since startedAt in seconds
+func (h *HistogramVec) ObserveSince(startedAt time.Time, labelValues ...string) {
+	h.Observe(time.Since(startedAt).Seconds(), labelValues...)
+}
+
+// Writer writes samples in the Prometheus text format,
+// HELP and TYPE lines are written before the first sample of every metric
+type Writer struct {
+	buf  bytes.Buffer
+	last string
+}
+
+func (w *Writer) Counter(name, help string, labels Labels, value float64) {
+	w.header(name, help, "counter")
+	w.sample(name, labels, value)
+}
+
+func (w *Writer) Gauge(name, help string, labels Labels, value float64) {
+	w.header(name, help, "gauge")
+	w.sample(name, labels, value)
+}
+
+// Histogram writes a histogram from non-cumulative counts of buckets, the last count is of the +Inf bucket
+func (w *Writer) Histogram(name, help string, labels Labels, buckets []float64, counts []uint64, sum float64) {
+	w.header(name, help, "histogram")
+	var cumulative uint64
+	for i, count := range counts {
+		cumulative += count
+		le := math.Inf(1)
+		if i < len(buckets) {
+			le = buckets[i]
+		}
+		w.sample(name+"_bucket", append(labels[:len(labels):len(labels)], [2]string{"le", formatFloat(le)}), float64(cumulative))
+	}
+	w.sample(name+"_sum", labels, sum)
+	w.sample(name+"_count", labels, float64(cumulative))
+}
+
+func (w *Writer) header(name, help, typ string) {
+	if w.last == name {
+		return
+	}
+	w.last = name
+	fmt.Fprintf(&w.buf, "# HELP %s %s\n# TYPE %s %s\n", name, strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help), name, typ)
+}
+
+func (w *Writer) sample(name string, labels Labels, value float64) {
+	w.buf.WriteString(name)
+	if len(labels) > 0 {
+		w.buf.WriteByte('{')
+		for i, l := range labels {
+			if i > 0 {
+				w.buf.WriteByte(',')
+			}
+			w.buf.WriteString(l[0] + `="` + labelValueEscaper.Replace(l[1]) + `"`)
+		}
+		w.buf.WriteByte('}')
+	}
+	w.buf.WriteString(" " + formatFloat(value) + "\n")
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,95 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/dir01/tg-parcels/core"
+	"github.com/hori-ryota/zaperr"
+)
+
+const (
+	DefaultDiscordURL = "https://discord.com/api/v10"
+	// discordMaxContent is the limit of message length set by Discord
+	discordMaxContent = 2000
+)
+
+// discordSnowflake is the format of Discord IDs
+var discordSnowflake = regexp.MustCompile(`^\d{17,20}$`)
+
+// NewDiscordNotifier creates a notifier that posts updates to Discord channels as the bot with the token.
+// The bot has to be invited to the server and allowed to send messages to the channel
+func NewDiscordNotifier(baseURL string, token string, retryPolicy core.RetryPolicy) *DiscordNotifier {
+	n := &DiscordNotifier{
+		baseURL:     strings.TrimSuffix(baseURL, "/"),
+		token:       token,
+		retryPolicy: retryPolicy,
+		client:      &http.Client{Timeout: httpRequestTimeout},
+	}
+	var _ Notifier = n
+	var _ Verifier = n
+	return n
+}
+
+// DiscordNotifier posts updates to Discord channels, destinations are channel IDs.
+// The bot can post to every channel it has access to, so channels are verified with a code posted there
+type DiscordNotifier struct {
+	baseURL     string
+	token       string
+	retryPolicy core.RetryPolicy
+	client      *http.Client
+}
+
+func (n *DiscordNotifier) Channel() string {
+	return "discord"
+}
+
+func (n *DiscordNotifier) NormalizeDestination(destination string) (string, error) {
+	if !discordSnowflake.MatchString(destination) {
+		return "", fmt.Errorf("%q is not a Discord channel ID, it can be copied from the channel menu in developer mode", destination)
+	}
+	return destination, nil
+}
+
+func (n *DiscordNotifier) SendVerification(ctx context.Context, destination string, code string) error {
+	return n.post(ctx, destination, fmt.Sprintf(
+		"Your verification code is %s\nSend /settings verify discord %s to the Telegram bot to start receiving parcel updates here",
+		code, code,
+	))
+}
+
+func (n *DiscordNotifier) Notify(ctx context.Context, channel *Channel, update *core.TrackingUpdate) error {
+	return n.post(ctx, channel.Destination, formatText(update))
+}
+
+func (n *DiscordNotifier) post(ctx context.Context, channelID string, content string) error {
+	if len(content) > discordMaxContent {
+		content = truncate(content, discordMaxContent)
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"content": content,
+		// tracking events come from third parties, they must not ping anyone
+		"allowed_mentions": map[string]interface{}{"parse": []string{}},
+	})
+	if err != nil {
+		return zaperr.Wrap(err, "failed to marshal Discord message")
+	}
+	_, err = doWithRetries(ctx, n.client, n.retryPolicy, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.baseURL+"/channels/"+channelID+"/messages", bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bot "+n.token)
+		return req, nil
+	})
+	if err != nil {
+		return zaperr.Wrap(err, "failed to post Discord message")
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dir01/tg-parcels/core"
+	"github.com/hori-ryota/zaperr"
+)
+
+// SMTPConfig is the server emails are sent through
+type SMTPConfig struct {
+	Host string
+	// Port 465 means implicit TLS, with other ports STARTTLS is used if the server supports it
+	Port     int
+	Username string
+	Password string
+	// From is the sender address, e.g. "Parcels <parcels@example.com>"
+	From string
+}
+
+func NewEmailNotifier(cfg SMTPConfig) *EmailNotifier {
+	n := &EmailNotifier{cfg: cfg}
+	var _ Notifier = n
+	var _ Verifier = n
+	return n
+}
+
+// EmailNotifier sends updates as plain text emails
+type EmailNotifier struct {
+	cfg SMTPConfig
+}
+
+func (n *EmailNotifier) Channel() string {
+	return "email"
+}
+
+func (n *EmailNotifier) NormalizeDestination(destination string) (string, error) {
+	addr, err := mail.ParseAddress(destination)
+	if err != nil {
+		return "", fmt.Errorf("%q is not an email address", destination)
+	}
+	return addr.Address, nil
+}
+
+func (n *EmailNotifier) SendVerification(ctx context.Context, destination string, code string) error {
+	body := fmt.Sprintf("Your verification code is %s\n\n"+
+		"Send /settings verify email %s to the bot to start receiving parcel updates at this address.\n"+
+		"If you didn't ask for it, just ignore this email.", code, code)
+	return n.send(ctx, destination, "Verify your email", body)
+}
+
+func (n *EmailNotifier) Notify(ctx context.Context, channel *Channel, update *core.TrackingUpdate) error {
+	return n.send(ctx, channel.Destination, formatSubject(update), formatText(update))
+}
+
+func (n *EmailNotifier) send(ctx context.Context, to string, subject string, body string) error {
+	from, err := mail.ParseAddress(n.cfg.From)
+	if err != nil {
+		return zaperr.Wrap(err, "invalid sender address")
+	}
+	msg, err := composeEmail(from, to, subject, body)
+	if err != nil {
+		return err
+	}
+
+	client, err := n.dial(ctx)
+	if err != nil {
+		return zaperr.Wrap(err, "failed to connect to SMTP server")
+	}
+	defer client.Close()
+	if n.cfg.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", n.cfg.Username, n.cfg.Password, n.cfg.Host)); err != nil {
+			return zaperr.Wrap(err, "failed to authenticate with SMTP server")
+		}
+	}
+	if err := client.Mail(from.Address); err != nil {
+		return zaperr.Wrap(err, "SMTP server rejected the sender")
+	}
+	if err := client.Rcpt(to); err != nil {
+		return zaperr.Wrap(err, "SMTP server rejected the recipient")
+	}
+	w, err := client.Data()
+	if err != nil {
+		return zaperr.Wrap(err, "failed to send email")
+	}
+	if _, err := w.Write(msg); err != nil {
+		return zaperr.Wrap(err, "failed to send email")
+	}
+	if err := w.Close(); err != nil {
+		return zaperr.Wrap(err, "failed to send email")
+	}
+	return client.Quit()
+}
+
+// dial connects to the server, the whole conversation has to fit into ctx deadline
+func (n *EmailNotifier) dial(ctx context.Context) (*smtp.Client, error) {
+	addr := net.JoinHostPort(n.cfg.Host, strconv.Itoa(n.cfg.Port))
+	dialer := &net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	tlsConfig := &tls.Config{ServerName: n.cfg.Host}
+	if n.cfg.Port == 465 {
+		conn = tls.Client(conn, tlsConfig)
+	}
+	client, err := smtp.NewClient(conn, n.cfg.Host)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	if ok, _ := client.Extension("STARTTLS"); ok && n.cfg.Port != 465 {
+		if err := client.StartTLS(tlsConfig); err != nil {
+			_ = client.Close()
+			return nil, err
+		}
+	}
+	return client, nil
+}
+
+func composeEmail(from *mail.Address, to string, subject string, body string) ([]byte, error) {
+	var buf bytes.Buffer
+	messageID := make([]byte, 16)
+	if _, err := rand.Read(messageID); err != nil {
+		return nil, zaperr.Wrap(err, "failed to generate message id")
+	}
+	domain := "localhost"
+	if at := strings.LastIndexByte(from.Address, '@'); at >= 0 {
+		domain = from.Address[at+1:]
+	}
+
+	fmt.Fprintf(&buf, "From: %s\r\n", from.String())
+	fmt.Fprintf(&buf, "To: %s\r\n", (&mail.Address{Address: to}).String())
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "Message-ID: <%s@%s>\r\n", hex.EncodeToString(messageID), domain)
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+	w := quotedprintable.NewWriter(&buf)
+	if _, err := w.Write(bytes.ReplaceAll([]byte(body), []byte("\n"), []byte("\r\n"))); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,100 @@
+package notify
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/dir01/tg-parcels/core"
+)
+
+// formatSubject is a one-line summary of the update, such as an email subject
+func formatSubject(update *core.TrackingUpdate) string {
+	name := update.TrackingNumber
+	if update.DisplayName != "" {
+		name = fmt.Sprintf("%s (%s)", update.DisplayName, update.TrackingNumber)
+	}
+	if update.StuckSince != nil {
+		return "Parcel appears stuck: " + name
+	}
+	if update.ExpectedAt != nil {
+		return "Parcel expected: " + name
+	}
+	if len(update.Milestones) > 0 {
+		return fmt.Sprintf("Parcel %s: %s", milestoneSubject(update.Milestones[len(update.Milestones)-1]), name)
+	}
+	return "Parcel update: " + name
+}
+
+// formatText lists new events of the update in plain text, the same way the bot does in Telegram
+func formatText(update *core.TrackingUpdate) string {
+	title := update.TrackingNumber
+	if update.DisplayName != "" {
+		title = fmt.Sprintf("%s - %s", title, update.DisplayName)
+	}
+
+	lines := []string{title}
+	for _, milestone := range update.Milestones {
+		lines = append(lines, milestoneText(milestone))
+	}
+	if update.StuckSince != nil {
+		lines = append(lines, fmt.Sprintf("The parcel appears stuck: there's been no news of it since %s", update.StuckSince.Format("Jan 2")))
+	}
+	if update.ExpectedAt != nil {
+		lines = append(lines, fmt.Sprintf("The parcel was expected on %s, has it arrived?", update.ExpectedAt.Format("Jan 2")))
+	}
+	for _, e := range update.Events() {
+		lines = append(lines, fmt.Sprintf("%s - %s", e.Time, e.Description))
+	}
+	if update.ETA != nil {
+		lines = append(lines, "", formatETA(update.ETA))
+	}
+	if len(update.FailedProviders) > 0 {
+		lines = append(lines, "", "Couldn't check "+strings.Join(update.FailedProviders, ", ")+" this time")
+	}
+	return strings.Join(lines, "\n")
+}
+
+func milestoneSubject(milestone core.Status) string {
+	switch milestone {
+	case core.StatusCustoms:
+		return "is at customs"
+	case core.StatusArrivedDestination:
+		return "has arrived in the destination country"
+	}
+	return "update"
+}
+
+// milestoneText tells the user what to expect of the milestone, the same way the bot does
+func milestoneText(milestone core.Status) string {
+	switch milestone {
+	case core.StatusCustoms:
+		return "The parcel is at customs. " +
+			"Customs may ask you for documents or to pay duties and taxes, keep an eye on messages from the carrier"
+	case core.StatusArrivedDestination:
+		return "The parcel has arrived in the destination country. The rest of the way is up to the local carrier"
+	}
+	return ""
+}
+
+func formatETA(eta *core.ETA) string {
+	const layout = "Jan 2"
+	from, to := eta.From.Format(layout), eta.To.Format(layout)
+	if from == to {
+		return fmt.Sprintf("Expected delivery: %s", from)
+	}
+	return fmt.Sprintf("Expected delivery: %s - %s", from, to)
+}
+
+// truncate cuts text to at most limit bytes without breaking UTF-8 sequences, marking the cut with an ellipsis
+func truncate(text string, limit int) string {
+	const ellipsis = "…"
+	if len(text) <= limit {
+		return text
+	}
+	cut := limit - len(ellipsis)
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	return text[:cut] + ellipsis
+}
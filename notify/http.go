@@ -0,0 +1,122 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/dir01/tg-parcels/core"
+	"github.com/hori-ryota/zaperr"
+)
+
+const (
+	// httpRequestTimeout limits a single attempt of an HTTP call, retries are limited by notifyTimeout
+	httpRequestTimeout = 10 * time.Second
+	// maxResponseSize limits how much of a successful response is read, APIs answer with a short status
+	maxResponseSize = 64 << 10
+)
+
+// ErrForbiddenAddress is returned when a URL set up by a user resolves to a private address
+var ErrForbiddenAddress = errors.New("URL resolves to a private address")
+
+// newUserURLClient creates a client for URLs users set up, which doesn't follow redirects.
+// Unless allowPrivateAddresses is set, it refuses to connect to loopback, private and link-local addresses,
+// so that users can't reach the bot's own listeners or other internal services through it
+func newUserURLClient(allowPrivateAddresses bool) *http.Client {
+	dialer := &net.Dialer{Timeout: httpRequestTimeout}
+	if !allowPrivateAddresses {
+		// the check happens after resolution, so DNS can't be used to sneak around it
+		dialer.Control = func(_, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+				return ErrForbiddenAddress
+			}
+			return nil
+		}
+	}
+	return &http.Client{
+		Timeout:   httpRequestTimeout,
+		Transport: &http.Transport{DialContext: dialer.DialContext},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+func isPublicIP(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() && !ip.IsMulticast() && !ip.IsUnspecified()
+}
+
+// statusError is returned when a service responds with anything but 2xx
+type statusError struct {
+	StatusCode int
+	// Body is the beginning of the response body, services explain what's wrong there
+	Body string
+}
+
+func (e *statusError) Error() string {
+	msg := "responded with " + strconv.Itoa(e.StatusCode)
+	if e.Body != "" {
+		msg += ": " + e.Body
+	}
+	return msg
+}
+
+// doWithRetries sends the request made by newRequest, retrying on network errors, 5xx and 429 responses.
+// newRequest is called for every attempt, so that bodies and signatures are fresh.
+// The body of the successful response is returned for APIs that report errors in it
+func doWithRetries(ctx context.Context, client *http.Client, retryPolicy core.RetryPolicy, newRequest func() (*http.Request, error)) ([]byte, error) {
+	for attempt := 1; ; attempt++ {
+		body, err := do(client, newRequest)
+		if err == nil || attempt >= retryPolicy.MaxAttempts || !isRetryable(err) {
+			return body, err
+		}
+		select {
+		case <-time.After(retryPolicy.Backoff(attempt)):
+		case <-ctx.Done():
+			return nil, err
+		}
+	}
+}
+
+func do(client *http.Client, newRequest func() (*http.Request, error)) ([]byte, error) {
+	req, err := newRequest()
+	if err != nil {
+		return nil, zaperr.Wrap(err, "failed to create request")
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, &statusError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, zaperr.Wrap(err, "failed to read response")
+	}
+	return body, nil
+}
+
+func isRetryable(err error) bool {
+	if errors.Is(err, ErrForbiddenAddress) || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var statusErr *statusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= 500 || statusErr.StatusCode == http.StatusTooManyRequests
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
@@ -0,0 +1,108 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/dir01/tg-parcels/core"
+	"github.com/hori-ryota/zaperr"
+)
+
+// matrixRoomID is the format of room IDs, aliases can't be used since they can be moved to another room
+var matrixRoomID = regexp.MustCompile(`^![^:\s]+:\S+$`)
+
+// NewMatrixNotifier creates a notifier that posts updates to Matrix rooms as the user of the access token.
+// Users invite that account to their rooms, the invites are accepted when the rooms are set up
+func NewMatrixNotifier(homeserverURL string, accessToken string, retryPolicy core.RetryPolicy) *MatrixNotifier {
+	n := &MatrixNotifier{
+		homeserverURL: strings.TrimSuffix(homeserverURL, "/"),
+		accessToken:   accessToken,
+		retryPolicy:   retryPolicy,
+		client:        &http.Client{Timeout: httpRequestTimeout},
+	}
+	var _ Notifier = n
+	var _ Verifier = n
+	return n
+}
+
+// MatrixNotifier posts updates to Matrix rooms, destinations are room IDs.
+// The account can post to every room it is in, so rooms are verified with a code posted there
+type MatrixNotifier struct {
+	homeserverURL string
+	accessToken   string
+	retryPolicy   core.RetryPolicy
+	client        *http.Client
+}
+
+func (n *MatrixNotifier) Channel() string {
+	return "matrix"
+}
+
+func (n *MatrixNotifier) NormalizeDestination(destination string) (string, error) {
+	if !matrixRoomID.MatchString(destination) {
+		return "", fmt.Errorf("%q is not a Matrix room ID, it looks like !abcdef:example.org and can be found in room settings", destination)
+	}
+	return destination, nil
+}
+
+func (n *MatrixNotifier) SendVerification(ctx context.Context, destination string, code string) error {
+	// joining is how invites are accepted, and a no-op for rooms the account is in already
+	_, err := doWithRetries(ctx, n.client, n.retryPolicy, func() (*http.Request, error) {
+		return n.newRequest(ctx, http.MethodPost, "/join/"+url.PathEscape(destination), []byte("{}"))
+	})
+	if err != nil {
+		return zaperr.Wrap(err, "failed to join Matrix room, has the bot been invited?")
+	}
+	return n.send(ctx, destination, fmt.Sprintf(
+		"Your verification code is %s\nSend /settings verify matrix %s to the Telegram bot to start receiving parcel updates here",
+		code, code,
+	))
+}
+
+func (n *MatrixNotifier) Notify(ctx context.Context, channel *Channel, update *core.TrackingUpdate) error {
+	return n.send(ctx, channel.Destination, formatText(update))
+}
+
+func (n *MatrixNotifier) send(ctx context.Context, roomID string, text string) error {
+	body, err := json.Marshal(map[string]interface{}{
+		// notices are what bots send, clients don't notify of them as loudly
+		"msgtype": "m.notice",
+		"body":    text,
+		// tracking events come from third parties, they must not ping anyone
+		"m.mentions": map[string]interface{}{},
+	})
+	if err != nil {
+		return zaperr.Wrap(err, "failed to marshal Matrix message")
+	}
+	// the same transaction ID across retries makes the homeserver deduplicate the message
+	txnID := make([]byte, 16)
+	if _, err := rand.Read(txnID); err != nil {
+		return zaperr.Wrap(err, "failed to generate transaction ID")
+	}
+	path := "/rooms/" + url.PathEscape(roomID) + "/send/m.room.message/" + hex.EncodeToString(txnID)
+	_, err = doWithRetries(ctx, n.client, n.retryPolicy, func() (*http.Request, error) {
+		return n.newRequest(ctx, http.MethodPut, path, body)
+	})
+	if err != nil {
+		return zaperr.Wrap(err, "failed to send Matrix message")
+	}
+	return nil
+}
+
+func (n *MatrixNotifier) newRequest(ctx context.Context, method string, path string, body []byte) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, n.homeserverURL+"/_matrix/client/v3"+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+n.accessToken)
+	return req, nil
+}
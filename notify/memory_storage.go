@@ -0,0 +1,63 @@
+package notify
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// NewMemoryStorage creates Storage that keeps channels in memory, for tests and demos
+func NewMemoryStorage() Storage {
+	return &MemoryStorage{channels: make(map[int64]map[string]Channel)}
+}
+
+type MemoryStorage struct {
+	mu       sync.Mutex
+	channels map[int64]map[string]Channel
+}
+
+func (s *MemoryStorage) SaveChannel(_ context.Context, channel *Channel) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.channels[channel.UserID] == nil {
+		s.channels[channel.UserID] = make(map[string]Channel)
+	}
+	s.channels[channel.UserID][channel.Name] = *channel
+	return nil
+}
+
+func (s *MemoryStorage) GetChannel(_ context.Context, userID int64, name string) (*Channel, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	channel, ok := s.channels[userID][name]
+	if !ok {
+		return nil, ErrChannelNotFound
+	}
+	return &channel, nil
+}
+
+func (s *MemoryStorage) ListChannels(_ context.Context, userID int64) ([]*Channel, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	channels := make([]*Channel, 0, len(s.channels[userID]))
+	for _, channel := range s.channels[userID] {
+		channel := channel
+		channels = append(channels, &channel)
+	}
+	sort.Slice(channels, func(i, j int) bool { return channels[i].Name < channels[j].Name })
+	return channels, nil
+}
+
+func (s *MemoryStorage) DeleteChannel(_ context.Context, userID int64, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.channels[userID], name)
+	return nil
+}
+
+func (s *MemoryStorage) DeleteUserData(_ context.Context, userID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.channels, userID)
+	return nil
+}
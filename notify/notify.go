@@ -0,0 +1,308 @@
+// Package notify delivers tracking updates through channels other than Telegram, such as email.
+// Users set their channels up in /settings of the bot, where channels that can be abused to message other people
+// (see Verifier) have to be verified first
+package notify
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/dir01/tg-parcels/core"
+	"github.com/dir01/tg-parcels/metrics"
+	"github.com/hori-ryota/zaperr"
+	"go.uber.org/zap"
+)
+
+const (
+	// notifyTimeout limits delivery of a single update to a single channel
+	notifyTimeout = 30 * time.Second
+	// maxVerificationAttempts is how many wrong codes can be entered before the code is discarded
+	maxVerificationAttempts = 5
+)
+
+var (
+	ErrUnknownChannel     = errors.New("unknown notification channel")
+	ErrChannelNotFound    = errors.New("notification channel is not set up")
+	ErrAlreadyVerified    = errors.New("notification channel is already verified")
+	ErrWrongCode          = errors.New("wrong verification code")
+	ErrTooManyAttempts    = errors.New("too many wrong verification codes")
+	ErrInvalidDestination = errors.New("invalid destination")
+)
+
+// Notifier delivers tracking updates through a channel
+type Notifier interface {
+	// Channel is the name users refer to the channel by in /settings, e.g. "email"
+	Channel() string
+	// NormalizeDestination validates destination entered by the user (e.g. an email address) and normalizes it,
+	// errors are shown to the user
+	NormalizeDestination(destination string) (string, error)
+	Notify(ctx context.Context, channel *Channel, update *core.TrackingUpdate) error
+}
+
+// Verifier is implemented by notifiers that could be abused to message other people,
+// their destinations get updates only once the user proves they control them with the code
+type Verifier interface {
+	SendVerification(ctx context.Context, destination string, code string) error
+}
+
+// Signer is implemented by notifiers that sign what they send with the secret of the channel,
+// so that receivers can tell it comes from the bot. The secret is generated when the channel is set
+type Signer interface {
+	// SigningInstructions tells the user how to check signatures, it is shown along with the secret
+	SigningInstructions() string
+}
+
+// Channel is a notification channel set up by a user
+type Channel struct {
+	UserID int64
+	// Name is Notifier.Channel
+	Name        string
+	Destination string
+	Verified    bool
+	// VerificationCode is the code sent to the destination, it is empty once the channel is verified
+	// or the code is discarded after too many failed attempts
+	VerificationCode string
+	FailedAttempts   int
+	CreatedAt        time.Time
+	// Secret signs what is sent through the channel, it is empty unless the notifier is a Signer
+	Secret string
+}
+
+//go:generate moq -pkg mocks -out mocks/storage.go . Storage
+
+type Storage interface {
+	// SaveChannel upserts the channel by user ID and name
+	SaveChannel(ctx context.Context, channel *Channel) error
+	// GetChannel returns ErrChannelNotFound if the user hasn't set the channel up
+	GetChannel(ctx context.Context, userID int64, name string) (*Channel, error)
+	ListChannels(ctx context.Context, userID int64) ([]*Channel, error)
+	DeleteChannel(ctx context.Context, userID int64, name string) error
+	DeleteUserData(ctx context.Context, userID int64) error
+}
+
+func NewChannels(storage Storage, registry *metrics.Registry, logger *zap.Logger, notifiers ...Notifier) *Channels {
+	c := &Channels{
+		storage:   storage,
+		notifiers: make(map[string]Notifier, len(notifiers)),
+		logger:    logger,
+		sends: registry.NewCounterVec(
+			"tg_parcels_notifications_sent_total", "Tracking updates sent through channels other than Telegram by channel and result.",
+			"channel", "result",
+		),
+	}
+	for _, n := range notifiers {
+		c.notifiers[n.Channel()] = n
+	}
+	return c
+}
+
+// Channels manages users' notification channels and delivers updates through them.
+// Nil Channels is valid, it has no channels available
+type Channels struct {
+	storage   Storage
+	notifiers map[string]Notifier
+	logger    *zap.Logger
+	sends     *metrics.CounterVec
+}
+
+// Available lists names of channels users can set up, sorted
+func (c *Channels) Available() []string {
+	if c == nil {
+		return nil
+	}
+	names := make([]string, 0, len(c.notifiers))
+	for name := range c.notifiers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// List lists channels the user has set up, including unverified ones
+func (c *Channels) List(ctx context.Context, userID int64) ([]*Channel, error) {
+	if c == nil {
+		return nil, nil
+	}
+	return c.storage.ListChannels(ctx, userID)
+}
+
+// Set points the channel to the destination, replacing the previous one.
+// If the channel needs verification, the code is sent to the destination and the channel stays unverified until
+// Verify is called with it
+func (c *Channels) Set(ctx context.Context, userID int64, name string, destination string) (*Channel, error) {
+	notifier, err := c.notifier(name)
+	if err != nil {
+		return nil, err
+	}
+	destination, err = notifier.NormalizeDestination(destination)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDestination, err)
+	}
+
+	channel := &Channel{UserID: userID, Name: name, Destination: destination, Verified: true, CreatedAt: time.Now()}
+	if _, ok := notifier.(Signer); ok {
+		if channel.Secret, err = randomSecret(); err != nil {
+			return nil, err
+		}
+	}
+	verifier, needsVerification := notifier.(Verifier)
+	if needsVerification {
+		channel.Verified = false
+		if channel.VerificationCode, err = verificationCode(); err != nil {
+			return nil, err
+		}
+		if err := verifier.SendVerification(ctx, destination, channel.VerificationCode); err != nil {
+			return nil, zaperr.Wrap(err, "failed to send verification code", core.UserIDField(userID), zap.String("channel", name))
+		}
+	}
+	if err := c.storage.SaveChannel(ctx, channel); err != nil {
+		return nil, zaperr.Wrap(err, "failed to save notification channel", core.UserIDField(userID), zap.String("channel", name))
+	}
+	c.logger.Info("notification channel set", core.UserIDField(userID), zap.String("channel", name), zap.Bool("verified", channel.Verified))
+	return channel, nil
+}
+
+// Verify checks the code sent by Set, after maxVerificationAttempts wrong codes the code is discarded
+// and ErrTooManyAttempts is returned, so the channel has to be set again
+func (c *Channels) Verify(ctx context.Context, userID int64, name string, code string) error {
+	if _, err := c.notifier(name); err != nil {
+		return err
+	}
+	channel, err := c.storage.GetChannel(ctx, userID, name)
+	if err != nil {
+		return err
+	}
+	if channel.Verified {
+		return ErrAlreadyVerified
+	}
+	if channel.VerificationCode == "" {
+		return ErrTooManyAttempts
+	}
+
+	if subtle.ConstantTimeCompare([]byte(code), []byte(channel.VerificationCode)) == 1 {
+		channel.Verified = true
+		channel.VerificationCode = ""
+		channel.FailedAttempts = 0
+	} else {
+		channel.FailedAttempts++
+		err = ErrWrongCode
+		if channel.FailedAttempts >= maxVerificationAttempts {
+			channel.VerificationCode = ""
+			err = ErrTooManyAttempts
+		}
+	}
+	if saveErr := c.storage.SaveChannel(ctx, channel); saveErr != nil {
+		return zaperr.Wrap(saveErr, "failed to save notification channel", core.UserIDField(userID), zap.String("channel", name))
+	}
+	if err == nil {
+		c.logger.Info("notification channel verified", core.UserIDField(userID), zap.String("channel", name))
+	}
+	return err
+}
+
+// Remove stops sending updates through the channel, it returns ErrChannelNotFound if the channel isn't set up
+func (c *Channels) Remove(ctx context.Context, userID int64, name string) error {
+	if _, err := c.notifier(name); err != nil {
+		return err
+	}
+	if _, err := c.storage.GetChannel(ctx, userID, name); err != nil {
+		return err
+	}
+	return c.storage.DeleteChannel(ctx, userID, name)
+}
+
+func (c *Channels) DeleteUserData(ctx context.Context, userID int64) error {
+	if c == nil {
+		return nil
+	}
+	return c.storage.DeleteUserData(ctx, userID)
+}
+
+// Run delivers tracking updates to verified channels of their users until updates are unsubscribed,
+// sending the ones left in the buffer first. Cancelling ctx aborts sends in flight.
+// updates should be subscribed before the service starts, so that redelivered updates are sent to channels too.
+// Unlike Telegram, channels don't confirm delivery, so updates that fail to be delivered are not retried
+func (c *Channels) Run(ctx context.Context, updates <-chan core.TrackingUpdate) {
+	if c == nil || len(c.notifiers) == 0 {
+		return
+	}
+	for update := range updates {
+		c.deliver(ctx, &update)
+	}
+}
+
+func (c *Channels) deliver(ctx context.Context, update *core.TrackingUpdate) {
+	// errors are answers to commands, they only make sense in the chat
+	if update.TrackingError != nil {
+		return
+	}
+	fields := core.UpdateFields(update)
+	channels, err := c.storage.ListChannels(ctx, update.UserID)
+	if err != nil {
+		c.logger.Error("failed to list notification channels", append(fields, zaperr.ToField(err))...)
+		return
+	}
+	for _, channel := range channels {
+		notifier, ok := c.notifiers[channel.Name]
+		if !ok || !channel.Verified {
+			continue
+		}
+		notifyCtx, cancel := context.WithTimeout(ctx, notifyTimeout)
+		err := notifier.Notify(notifyCtx, channel, update)
+		cancel()
+		if err != nil {
+			c.sends.Inc(channel.Name, "error")
+			c.logger.Error("failed to send notification", append(fields, zap.String("channel", channel.Name), zaperr.ToField(err))...)
+			continue
+		}
+		c.sends.Inc(channel.Name, "ok")
+		c.logger.Debug("notification sent", append(fields, zap.String("channel", channel.Name))...)
+	}
+}
+
+// SigningInstructions tells how to check signatures of the channel, it is empty if the channel doesn't sign anything
+func (c *Channels) SigningInstructions(name string) string {
+	notifier, err := c.notifier(name)
+	if err != nil {
+		return ""
+	}
+	if signer, ok := notifier.(Signer); ok {
+		return signer.SigningInstructions()
+	}
+	return ""
+}
+
+func (c *Channels) notifier(name string) (Notifier, error) {
+	if c == nil {
+		return nil, ErrUnknownChannel
+	}
+	notifier, ok := c.notifiers[name]
+	if !ok {
+		return nil, ErrUnknownChannel
+	}
+	return notifier, nil
+}
+
+func randomSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", zaperr.Wrap(err, "failed to generate secret")
+	}
+	return hex.EncodeToString(secret), nil
+}
+
+// verificationCode generates a random 6-digit code
+func verificationCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1_000_000))
+	if err != nil {
+		return "", zaperr.Wrap(err, "failed to generate verification code")
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
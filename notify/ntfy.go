@@ -0,0 +1,114 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/dir01/tg-parcels/core"
+	"github.com/hori-ryota/zaperr"
+)
+
+const DefaultNtfyServer = "https://ntfy.sh"
+
+// ntfyTopic is the format of topic names ntfy accepts
+var ntfyTopic = regexp.MustCompile(`^[-_A-Za-z0-9]{1,64}$`)
+
+// NewNtfyNotifier creates a notifier that publishes updates to ntfy topics, on defaultServer
+// or on any server if users give full topic URLs.
+// Unless allowPrivateAddresses is set, servers resolving to private addresses are refused, see WebhookNotifier
+func NewNtfyNotifier(defaultServer string, retryPolicy core.RetryPolicy, allowPrivateAddresses bool) *NtfyNotifier {
+	n := &NtfyNotifier{
+		defaultServer: strings.TrimSuffix(defaultServer, "/"),
+		retryPolicy:   retryPolicy,
+		client:        newUserURLClient(allowPrivateAddresses),
+	}
+	var _ Notifier = n
+	var _ Verifier = n
+	return n
+}
+
+// NtfyNotifier publishes updates to ntfy topics, destinations are topic URLs, e.g. https://ntfy.sh/my-parcels.
+// Anyone can publish to an open topic, so topics are verified with a code published there
+type NtfyNotifier struct {
+	defaultServer string
+	retryPolicy   core.RetryPolicy
+	client        *http.Client
+}
+
+func (n *NtfyNotifier) Channel() string {
+	return "ntfy"
+}
+
+// NormalizeDestination turns bare topic names into URLs on the default server
+func (n *NtfyNotifier) NormalizeDestination(destination string) (string, error) {
+	if ntfyTopic.MatchString(destination) {
+		return n.defaultServer + "/" + destination, nil
+	}
+	if _, _, err := splitNtfyURL(destination); err != nil {
+		return "", fmt.Errorf("%q is neither an ntfy topic nor a topic URL like %s/my-parcels", destination, n.defaultServer)
+	}
+	return destination, nil
+}
+
+func (n *NtfyNotifier) SendVerification(ctx context.Context, destination string, code string) error {
+	return n.publish(ctx, destination, "Parcels verification code", fmt.Sprintf(
+		"Your verification code is %s\nSend /settings verify ntfy %s to the Telegram bot to start receiving parcel updates here",
+		code, code,
+	))
+}
+
+func (n *NtfyNotifier) Notify(ctx context.Context, channel *Channel, update *core.TrackingUpdate) error {
+	return n.publish(ctx, channel.Destination, formatSubject(update), formatText(update))
+}
+
+// publish posts JSON to the root of the server, unlike headers of plain publishing it can carry any text in titles
+func (n *NtfyNotifier) publish(ctx context.Context, topicURL string, title string, message string) error {
+	server, topic, err := splitNtfyURL(topicURL)
+	if err != nil {
+		return zaperr.Wrap(err, "invalid ntfy topic URL")
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"topic":   topic,
+		"title":   title,
+		"message": message,
+		"tags":    []string{"package"},
+	})
+	if err != nil {
+		return zaperr.Wrap(err, "failed to marshal ntfy message")
+	}
+	_, err = doWithRetries(ctx, n.client, n.retryPolicy, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, server, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		return req, nil
+	})
+	if err != nil {
+		return zaperr.Wrap(err, "failed to publish to ntfy")
+	}
+	return nil
+}
+
+// splitNtfyURL splits a topic URL into the URL of the server (credentials included) and the topic
+func splitNtfyURL(topicURL string) (server string, topic string, err error) {
+	u, err := url.Parse(topicURL)
+	if err != nil {
+		return "", "", err
+	}
+	i := strings.LastIndexByte(u.Path, '/')
+	if (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" || i < 0 || !ntfyTopic.MatchString(u.Path[i+1:]) ||
+		u.RawQuery != "" || u.Fragment != "" {
+		return "", "", fmt.Errorf("%q is not an ntfy topic URL", topicURL)
+	}
+	topic = u.Path[i+1:]
+	u.Path = u.Path[:i+1]
+	u.RawPath = ""
+	return u.String(), topic, nil
+}
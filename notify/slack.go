@@ -0,0 +1,137 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/dir01/tg-parcels/core"
+	"github.com/hori-ryota/zaperr"
+)
+
+const (
+	DefaultSlackAPIURL      = "https://slack.com/api"
+	DefaultSlackWebhooksURL = "https://hooks.slack.com/services/"
+	// slackMaxText is the limit of message length set by Slack
+	slackMaxText = 40000
+)
+
+// slackChannelID is the format of IDs of public and private channels and direct messages
+var slackChannelID = regexp.MustCompile(`^[CGD][A-Z0-9]{8,}$`)
+
+// slackEscaper escapes the characters Slack treats as markup, so that events can't mention anyone
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// NewSlackNotifier creates a notifier that posts updates to Slack incoming webhooks under webhooksURL,
+// each user sets up one for their own workspace.
+// If botToken is set, users can also set up IDs of channels of the workspace the bot is installed to
+func NewSlackNotifier(apiURL string, webhooksURL string, botToken string, retryPolicy core.RetryPolicy) *SlackNotifier {
+	n := &SlackNotifier{
+		apiURL:      strings.TrimSuffix(apiURL, "/"),
+		webhooksURL: webhooksURL,
+		botToken:    botToken,
+		retryPolicy: retryPolicy,
+		client: &http.Client{
+			Timeout: httpRequestTimeout,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+	var _ Notifier = n
+	var _ Verifier = n
+	return n
+}
+
+// SlackNotifier posts updates to Slack, destinations are either incoming webhook URLs or channel IDs.
+// Both are verified with a code posted there: anyone who knows a webhook URL can post to it,
+// and the bot can post to every channel of its workspace
+type SlackNotifier struct {
+	apiURL      string
+	webhooksURL string
+	botToken    string
+	retryPolicy core.RetryPolicy
+	client      *http.Client
+}
+
+func (n *SlackNotifier) Channel() string {
+	return "slack"
+}
+
+func (n *SlackNotifier) NormalizeDestination(destination string) (string, error) {
+	if strings.HasPrefix(destination, n.webhooksURL) && len(destination) > len(n.webhooksURL) {
+		return destination, nil
+	}
+	if n.botToken != "" && slackChannelID.MatchString(destination) {
+		return destination, nil
+	}
+	if n.botToken != "" {
+		return "", fmt.Errorf("%q is neither a Slack incoming webhook URL nor a channel ID", destination)
+	}
+	return "", fmt.Errorf("%q is not a Slack incoming webhook URL, it should start with %s", destination, n.webhooksURL)
+}
+
+func (n *SlackNotifier) SendVerification(ctx context.Context, destination string, code string) error {
+	return n.post(ctx, destination, fmt.Sprintf(
+		"Your verification code is %s\nSend /settings verify slack %s to the Telegram bot to start receiving parcel updates here",
+		code, code,
+	))
+}
+
+func (n *SlackNotifier) Notify(ctx context.Context, channel *Channel, update *core.TrackingUpdate) error {
+	return n.post(ctx, channel.Destination, formatText(update))
+}
+
+func (n *SlackNotifier) post(ctx context.Context, destination string, text string) error {
+	text = slackEscaper.Replace(text)
+	if len(text) > slackMaxText {
+		text = truncate(text, slackMaxText)
+	}
+
+	url := destination
+	message := map[string]interface{}{"text": text}
+	if !strings.HasPrefix(destination, n.webhooksURL) {
+		url = n.apiURL + "/chat.postMessage"
+		message["channel"] = destination
+	}
+	body, err := json.Marshal(message)
+	if err != nil {
+		return zaperr.Wrap(err, "failed to marshal Slack message")
+	}
+
+	respBody, err := doWithRetries(ctx, n.client, n.retryPolicy, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json; charset=utf-8")
+		if url != destination {
+			req.Header.Set("Authorization", "Bearer "+n.botToken)
+		}
+		return req, nil
+	})
+	if err != nil {
+		return zaperr.Wrap(err, "failed to post Slack message")
+	}
+	if url == destination {
+		return nil
+	}
+
+	// Web API responds with 200 to most failures, the outcome is in the body
+	var resp struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(respBody, &resp); err != nil {
+		return zaperr.Wrap(err, "failed to parse Slack response")
+	}
+	if !resp.OK {
+		return zaperr.Wrap(errors.New(resp.Error), "Slack rejected the message")
+	}
+	return nil
+}
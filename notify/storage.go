@@ -0,0 +1,144 @@
+package notify
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/dir01/tg-parcels/core/storage"
+	"github.com/jmoiron/sqlx"
+)
+
+// NewStorage creates Storage backed by SQLite, destinations are encrypted with cipher unless it is nil
+func NewStorage(db, readDB *sqlx.DB, cipher *storage.Cipher, queryTimeout time.Duration, metrics *storage.QueryMetrics) Storage {
+	return &SqliteStorage{db: db, readDB: readDB, cipher: cipher, queryTimeout: queryTimeout, metrics: metrics}
+}
+
+type SqliteStorage struct {
+	db *sqlx.DB
+	// readDB runs reads outside of transactions, it is db itself unless reads are split, see storage.ReadOnlyDSN
+	readDB       *sqlx.DB
+	cipher       *storage.Cipher
+	queryTimeout time.Duration
+	metrics      *storage.QueryMetrics
+}
+
+type dbChannel struct {
+	UserID           int64  `db:"user_id"`
+	Channel          string `db:"channel"`
+	Destination      string `db:"destination"`
+	Verified         bool   `db:"verified"`
+	VerificationCode string `db:"verification_code"`
+	FailedAttempts   int    `db:"failed_attempts"`
+	CreatedAt        int64  `db:"created_at"`
+	Secret           string `db:"secret"`
+}
+
+func (s *SqliteStorage) SaveChannel(ctx context.Context, channel *Channel) (err error) {
+	defer s.metrics.Observe("save_notification_channel", time.Now(), &err)
+	ctx, cancel := storage.WithQueryTimeout(ctx, s.queryTimeout)
+	defer cancel()
+	destination, err := s.cipher.Encrypt(channel.Destination)
+	if err != nil {
+		return err
+	}
+	secret, err := s.cipher.Encrypt(channel.Secret)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.NamedExecContext(ctx, `
+		INSERT INTO notification_channels (user_id, channel, destination, verified, verification_code, failed_attempts, created_at, secret)
+		VALUES (:user_id, :channel, :destination, :verified, :verification_code, :failed_attempts, :created_at, :secret)
+		ON CONFLICT (user_id, channel) DO UPDATE SET
+			destination = excluded.destination,
+			verified = excluded.verified,
+			verification_code = excluded.verification_code,
+			failed_attempts = excluded.failed_attempts,
+			created_at = excluded.created_at,
+			secret = excluded.secret`,
+		dbChannel{
+			UserID:           channel.UserID,
+			Channel:          channel.Name,
+			Destination:      destination,
+			Verified:         channel.Verified,
+			VerificationCode: channel.VerificationCode,
+			FailedAttempts:   channel.FailedAttempts,
+			CreatedAt:        channel.CreatedAt.Unix(),
+			Secret:           secret,
+		},
+	)
+	return err
+}
+
+func (s *SqliteStorage) GetChannel(ctx context.Context, userID int64, name string) (_ *Channel, err error) {
+	defer s.metrics.Observe("get_notification_channel", time.Now(), &err)
+	ctx, cancel := storage.WithQueryTimeout(ctx, s.queryTimeout)
+	defer cancel()
+	var row dbChannel
+	err = s.readDB.GetContext(ctx, &row, `SELECT * FROM notification_channels WHERE user_id = ? AND channel = ?`, userID, name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrChannelNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.toChannel(&row)
+}
+
+func (s *SqliteStorage) ListChannels(ctx context.Context, userID int64) (_ []*Channel, err error) {
+	defer s.metrics.Observe("list_notification_channels", time.Now(), &err)
+	ctx, cancel := storage.WithQueryTimeout(ctx, s.queryTimeout)
+	defer cancel()
+	var rows []dbChannel
+	err = s.readDB.SelectContext(ctx, &rows, `SELECT * FROM notification_channels WHERE user_id = ? ORDER BY channel`, userID)
+	if err != nil {
+		return nil, err
+	}
+	channels := make([]*Channel, 0, len(rows))
+	for i := range rows {
+		channel, err := s.toChannel(&rows[i])
+		if err != nil {
+			return nil, err
+		}
+		channels = append(channels, channel)
+	}
+	return channels, nil
+}
+
+func (s *SqliteStorage) DeleteChannel(ctx context.Context, userID int64, name string) (err error) {
+	defer s.metrics.Observe("delete_notification_channel", time.Now(), &err)
+	ctx, cancel := storage.WithQueryTimeout(ctx, s.queryTimeout)
+	defer cancel()
+	_, err = s.db.ExecContext(ctx, `DELETE FROM notification_channels WHERE user_id = ? AND channel = ?`, userID, name)
+	return err
+}
+
+func (s *SqliteStorage) DeleteUserData(ctx context.Context, userID int64) (err error) {
+	defer s.metrics.Observe("delete_notification_channels", time.Now(), &err)
+	ctx, cancel := storage.WithQueryTimeout(ctx, s.queryTimeout)
+	defer cancel()
+	_, err = s.db.ExecContext(ctx, `DELETE FROM notification_channels WHERE user_id = ?`, userID)
+	return err
+}
+
+func (s *SqliteStorage) toChannel(row *dbChannel) (*Channel, error) {
+	destination, err := s.cipher.Decrypt(row.Destination)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := s.cipher.Decrypt(row.Secret)
+	if err != nil {
+		return nil, err
+	}
+	return &Channel{
+		UserID:           row.UserID,
+		Name:             row.Channel,
+		Destination:      destination,
+		Verified:         row.Verified,
+		VerificationCode: row.VerificationCode,
+		FailedAttempts:   row.FailedAttempts,
+		CreatedAt:        time.Unix(row.CreatedAt, 0),
+		Secret:           secret,
+	}, nil
+}
@@ -0,0 +1,138 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/dir01/parcels/parcels_api"
+	"github.com/dir01/tg-parcels/core"
+	"github.com/hori-ryota/zaperr"
+)
+
+// NewWebhookNotifier creates a notifier that posts updates as JSON to users' URLs.
+// Unless allowPrivateAddresses is set, URLs resolving to loopback, private and link-local addresses are refused,
+// so that users can't reach the bot's own listeners or other internal services through it
+func NewWebhookNotifier(retryPolicy core.RetryPolicy, allowPrivateAddresses bool) *WebhookNotifier {
+	n := &WebhookNotifier{
+		retryPolicy: retryPolicy,
+		client:      newUserURLClient(allowPrivateAddresses),
+	}
+	var _ Notifier = n
+	var _ Signer = n
+	return n
+}
+
+// WebhookNotifier posts updates to URLs of users' own automations, signing them with the channel secret
+type WebhookNotifier struct {
+	retryPolicy core.RetryPolicy
+	client      *http.Client
+}
+
+type webhookPayload struct {
+	Event             string                       `json:"event"`
+	UserID            int64                        `json:"user_id"`
+	TrackingNumber    string                       `json:"tracking_number"`
+	DisplayName       string                       `json:"display_name"`
+	NewTrackingInfos  []*parcels_api.TrackingInfo  `json:"new_tracking_infos"`
+	NewTrackingEvents []*parcels_api.TrackingEvent `json:"new_tracking_events"`
+	ETA               *webhookETA                  `json:"eta"`
+	// Milestones are canonical statuses worth a special mention the parcel has just reached, e.g. "customs"
+	Milestones []core.Status `json:"milestones,omitempty"`
+	// StuckSince is set for "tracking_stuck" events only
+	StuckSince *time.Time `json:"stuck_since,omitempty"`
+	// ExpectedAt is set for "manual_parcel_expected" events only
+	ExpectedAt *time.Time `json:"expected_at,omitempty"`
+	// FailedProviders are providers that couldn't be checked this time, the update may miss what they would have told
+	FailedProviders []string `json:"failed_providers,omitempty"`
+}
+
+type webhookETA struct {
+	From         time.Time `json:"from"`
+	To           time.Time `json:"to"`
+	SamplesCount int       `json:"samples_count"`
+}
+
+func (n *WebhookNotifier) Channel() string {
+	return "webhook"
+}
+
+func (n *WebhookNotifier) NormalizeDestination(destination string) (string, error) {
+	u, err := url.Parse(destination)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return "", fmt.Errorf("%q is not an http(s) URL", destination)
+	}
+	return u.String(), nil
+}
+
+func (n *WebhookNotifier) SigningInstructions() string {
+	return "Every request carries X-Parcels-Signature header of the form t=<unix time>,v1=<signature>, " +
+		"where signature is hex of HMAC-SHA256 of \"<unix time>.<request body>\" keyed with the secret. " +
+		"X-Parcels-Delivery header stays the same when a failed delivery is retried"
+}
+
+// Notify posts the update, retrying on network errors, 5xx and 429 responses
+func (n *WebhookNotifier) Notify(ctx context.Context, channel *Channel, update *core.TrackingUpdate) error {
+	payload := webhookPayload{
+		Event:             "tracking_update",
+		UserID:            update.UserID,
+		TrackingNumber:    update.TrackingNumber,
+		DisplayName:       update.DisplayName,
+		NewTrackingInfos:  update.NewTrackingInfos,
+		NewTrackingEvents: update.NewTrackingEvents,
+		Milestones:        update.Milestones,
+		FailedProviders:   update.FailedProviders,
+	}
+	if update.StuckSince != nil {
+		payload.Event = "tracking_stuck"
+		payload.StuckSince = update.StuckSince
+	}
+	if update.ExpectedAt != nil {
+		payload.Event = "manual_parcel_expected"
+		payload.ExpectedAt = update.ExpectedAt
+	}
+	if update.ETA != nil {
+		payload.ETA = &webhookETA{From: update.ETA.From, To: update.ETA.To, SamplesCount: update.ETA.SamplesCount}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return zaperr.Wrap(err, "failed to marshal webhook payload")
+	}
+	deliveryID := make([]byte, 16)
+	if _, err := rand.Read(deliveryID); err != nil {
+		return zaperr.Wrap(err, "failed to generate delivery id")
+	}
+
+	_, err = doWithRetries(ctx, n.client, n.retryPolicy, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, channel.Destination, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", "tg-parcels")
+		req.Header.Set("X-Parcels-Delivery", hex.EncodeToString(deliveryID))
+		req.Header.Set("X-Parcels-Signature", "t="+timestamp+",v1="+signWebhook(channel.Secret, timestamp, body))
+		return req, nil
+	})
+	if err != nil {
+		return zaperr.Wrap(err, "failed to deliver webhook")
+	}
+	return nil
+}
+
+func signWebhook(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,33 @@
+package routemap
+
+import (
+	"context"
+	"sync"
+)
+
+// NewMemoryStorage creates Storage that caches places in memory, for tests and demos
+func NewMemoryStorage() Storage {
+	return &MemoryStorage{places: make(map[string]CachedPlace)}
+}
+
+type MemoryStorage struct {
+	mu     sync.Mutex
+	places map[string]CachedPlace
+}
+
+func (s *MemoryStorage) GetPlace(_ context.Context, place string) (*CachedPlace, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cached, ok := s.places[place]
+	if !ok {
+		return nil, ErrPlaceNotCached
+	}
+	return &cached, nil
+}
+
+func (s *MemoryStorage) SavePlace(_ context.Context, place string, cached *CachedPlace) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.places[place] = *cached
+	return nil
+}
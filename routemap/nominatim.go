@@ -0,0 +1,83 @@
+package routemap
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/dir01/tg-parcels/core"
+	"github.com/hori-ryota/zaperr"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+)
+
+// DefaultNominatimURL is the public Nominatim of OpenStreetMap
+const DefaultNominatimURL = "https://nominatim.openstreetmap.org"
+
+// NewNominatim creates Geocoder calling a Nominatim-compatible service at baseURL.
+// Usage policy of the public Nominatim asks for at most a request per second and a user agent identifying the app
+func NewNominatim(baseURL string, userAgent string, httpClient *http.Client) *Nominatim {
+	n := &Nominatim{
+		baseURL:     strings.TrimSuffix(baseURL, "/"),
+		userAgent:   userAgent,
+		httpClient:  httpClient,
+		rateLimiter: rate.NewLimiter(1, 1),
+	}
+	var _ Geocoder = n
+	return n
+}
+
+type Nominatim struct {
+	baseURL     string
+	userAgent   string
+	httpClient  *http.Client
+	rateLimiter *rate.Limiter
+}
+
+func (n *Nominatim) Geocode(ctx context.Context, place string) (*Point, error) {
+	if err := n.rateLimiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+
+	query := url.Values{}
+	query.Set("q", place)
+	query.Set("format", "json")
+	query.Set("limit", "1")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, n.baseURL+"/search?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", n.userAgent)
+
+	resp, err := n.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, &core.UpstreamError{StatusCode: resp.StatusCode}
+	}
+
+	var results []struct {
+		Lat string `json:"lat"`
+		Lon string `json:"lon"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&results); err != nil {
+		return nil, zaperr.Wrap(err, "failed to decode response", zap.String("place", place))
+	}
+	if len(results) == 0 {
+		return nil, nil
+	}
+	lat, err := strconv.ParseFloat(results[0].Lat, 64)
+	if err != nil {
+		return nil, zaperr.Wrap(err, "failed to parse latitude", zap.String("place", place))
+	}
+	lon, err := strconv.ParseFloat(results[0].Lon, 64)
+	if err != nil {
+		return nil, zaperr.Wrap(err, "failed to parse longitude", zap.String("place", place))
+	}
+	return &Point{Lat: lat, Lon: lon}, nil
+}
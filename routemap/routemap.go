@@ -0,0 +1,211 @@
+// Package routemap draws routes of parcels on static maps. Places of tracking events (see core.EventLocation)
+// are geocoded, with results cached since geocoders are slow and rate limited, and marked on the map in the order
+// the parcel passed them
+package routemap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dir01/parcels/parcels_api"
+	"github.com/dir01/tg-parcels/core"
+	"github.com/hori-ryota/zaperr"
+	"go.uber.org/zap"
+)
+
+const (
+	// maxPlaces is how many latest places of a route are marked, older ones are left out
+	maxPlaces = 10
+	// notFoundTTL is how long places a geocoder couldn't find aren't asked about again
+	notFoundTTL = 30 * 24 * time.Hour
+	// maxImageSize limits images fetched from the static map service
+	maxImageSize = 5 << 20
+	// mapSize is the size of maps in pixels, as the static map service expects it
+	mapSize = "600x400"
+)
+
+// ErrNoRoute means none of the places of the tracking could be located, so there is nothing to draw
+var ErrNoRoute = errors.New("no known places on the route")
+
+// ErrPlaceNotCached is returned by Storage for places that haven't been geocoded yet
+var ErrPlaceNotCached = errors.New("place is not cached")
+
+// Point is a location in degrees
+type Point struct {
+	Lat float64
+	Lon float64
+}
+
+// CachedPlace is a geocoding result, Point is nil for places the geocoder couldn't find
+type CachedPlace struct {
+	Point      *Point
+	GeocodedAt time.Time
+}
+
+//go:generate moq -pkg mocks -out mocks/storage.go . Storage
+
+// Storage caches geocoding results, including places that weren't found
+type Storage interface {
+	// GetPlace returns ErrPlaceNotCached for places that haven't been geocoded yet
+	GetPlace(ctx context.Context, place string) (*CachedPlace, error)
+	SavePlace(ctx context.Context, place string, cached *CachedPlace) error
+}
+
+// Geocoder finds coordinates of places, nil point means the place wasn't found
+type Geocoder interface {
+	Geocode(ctx context.Context, place string) (*Point, error)
+}
+
+// New creates Maps drawn by a staticMapLite-compatible service at staticMapURL
+func New(geocoder Geocoder, storage Storage, staticMapURL string, httpClient *http.Client, logger *zap.Logger) *Maps {
+	return &Maps{
+		geocoder:     geocoder,
+		storage:      storage,
+		staticMapURL: staticMapURL,
+		httpClient:   httpClient,
+		logger:       logger,
+	}
+}
+
+// Maps draws routes of trackings.
+// Nil Maps means route maps are disabled, Enabled tells so
+type Maps struct {
+	geocoder     Geocoder
+	storage      Storage
+	staticMapURL string
+	httpClient   *http.Client
+	logger       *zap.Logger
+}
+
+func (m *Maps) Enabled() bool {
+	return m != nil
+}
+
+// Render draws the route of the tracking and returns the image, it returns ErrNoRoute if no place is known
+func (m *Maps) Render(ctx context.Context, tracking *core.Tracking) ([]byte, error) {
+	var points []Point
+	for _, place := range routePlaces(tracking) {
+		point, err := m.locate(ctx, place)
+		if err != nil {
+			return nil, err
+		}
+		if point != nil {
+			points = append(points, *point)
+		}
+	}
+	if len(points) == 0 {
+		return nil, ErrNoRoute
+	}
+	return m.fetchImage(ctx, points)
+}
+
+// locate geocodes the place unless it is cached, nil point means the place wasn't found
+func (m *Maps) locate(ctx context.Context, place string) (*Point, error) {
+	cached, err := m.storage.GetPlace(ctx, place)
+	if err == nil && (cached.Point != nil || time.Since(cached.GeocodedAt) < notFoundTTL) {
+		return cached.Point, nil
+	}
+	if err != nil && !errors.Is(err, ErrPlaceNotCached) {
+		return nil, zaperr.Wrap(err, "failed to get cached place", zap.String("place", place))
+	}
+
+	point, err := m.geocoder.Geocode(ctx, place)
+	if err != nil {
+		return nil, zaperr.Wrap(err, "failed to geocode", zap.String("place", place))
+	}
+	if err := m.storage.SavePlace(ctx, place, &CachedPlace{Point: point, GeocodedAt: time.Now()}); err != nil {
+		// it will just be geocoded again next time
+		m.logger.Error("failed to cache place", zap.String("place", place), zaperr.ToField(err))
+	}
+	return point, nil
+}
+
+func (m *Maps) fetchImage(ctx context.Context, points []Point) ([]byte, error) {
+	markers := make([]string, len(points))
+	for i, p := range points {
+		color := "lightblue"
+		if i == len(points)-1 {
+			color = "red"
+		}
+		markers[i] = fmt.Sprintf("%s,%s,%s%d", formatDegrees(p.Lat), formatDegrees(p.Lon), color, i+1)
+	}
+	query := url.Values{}
+	query.Set("size", mapSize)
+	query.Set("markers", strings.Join(markers, "|"))
+	u := m.staticMapURL
+	if strings.Contains(u, "?") {
+		u += "&" + query.Encode()
+	} else {
+		u += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := m.httpClient.Do(req)
+	if err != nil {
+		return nil, zaperr.Wrap(err, "failed to fetch map")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, &core.UpstreamError{StatusCode: resp.StatusCode}
+	}
+	if contentType := resp.Header.Get("Content-Type"); !strings.HasPrefix(contentType, "image/") {
+		return nil, zaperr.New("static map service responded with something other than an image", zap.String("content_type", contentType))
+	}
+	image, err := io.ReadAll(io.LimitReader(resp.Body, maxImageSize+1))
+	if err != nil {
+		return nil, zaperr.Wrap(err, "failed to read map")
+	}
+	if len(image) > maxImageSize {
+		return nil, zaperr.New("map is too large", zap.Int("max_size", maxImageSize))
+	}
+	return image, nil
+}
+
+// routePlaces are the places of events of the tracking in chronological order, without repeats in a row.
+// Only the latest maxPlaces of them are kept
+func routePlaces(tracking *core.Tracking) []string {
+	type timedEvent struct {
+		event parcels_api.TrackingEvent
+		at    time.Time
+	}
+	var events []timedEvent
+	for _, info := range tracking.TrackingInfos {
+		var at time.Time
+		for _, e := range info.Events {
+			// events of an info are in chronological order already, so unparsable times are taken for the previous one
+			if parsed, ok := core.ParseEventTime(e.Time); ok {
+				at = parsed
+			}
+			events = append(events, timedEvent{event: e, at: at})
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].at.Before(events[j].at) })
+
+	var places []string
+	for _, e := range events {
+		place := core.EventLocation(e.event)
+		if place == "" || (len(places) > 0 && strings.EqualFold(places[len(places)-1], place)) {
+			continue
+		}
+		places = append(places, place)
+	}
+	if len(places) > maxPlaces {
+		places = places[len(places)-maxPlaces:]
+	}
+	return places
+}
+
+func formatDegrees(d float64) string {
+	return strconv.FormatFloat(d, 'f', 5, 64)
+}
@@ -0,0 +1,64 @@
+package routemap
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/dir01/tg-parcels/core/storage"
+	"github.com/jmoiron/sqlx"
+)
+
+// NewStorage creates Storage backed by SQLite
+func NewStorage(db, readDB *sqlx.DB, queryTimeout time.Duration, metrics *storage.QueryMetrics) Storage {
+	return &SqliteStorage{db: db, readDB: readDB, queryTimeout: queryTimeout, metrics: metrics}
+}
+
+type SqliteStorage struct {
+	db *sqlx.DB
+	// readDB runs reads outside of transactions, it is db itself unless reads are split, see storage.ReadOnlyDSN
+	readDB       *sqlx.DB
+	queryTimeout time.Duration
+	metrics      *storage.QueryMetrics
+}
+
+func (s *SqliteStorage) GetPlace(ctx context.Context, place string) (_ *CachedPlace, err error) {
+	defer s.metrics.Observe("get_geocoded_place", time.Now(), &err)
+	ctx, cancel := storage.WithQueryTimeout(ctx, s.queryTimeout)
+	defer cancel()
+	var row struct {
+		Lat        sql.NullFloat64 `db:"lat"`
+		Lon        sql.NullFloat64 `db:"lon"`
+		GeocodedAt int64           `db:"geocoded_at"`
+	}
+	err = s.readDB.GetContext(ctx, &row, `SELECT lat, lon, geocoded_at FROM geocoded_places WHERE place = ?`, place)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrPlaceNotCached
+	}
+	if err != nil {
+		return nil, err
+	}
+	cached := &CachedPlace{GeocodedAt: time.Unix(row.GeocodedAt, 0)}
+	if row.Lat.Valid && row.Lon.Valid {
+		cached.Point = &Point{Lat: row.Lat.Float64, Lon: row.Lon.Float64}
+	}
+	return cached, nil
+}
+
+func (s *SqliteStorage) SavePlace(ctx context.Context, place string, cached *CachedPlace) (err error) {
+	defer s.metrics.Observe("save_geocoded_place", time.Now(), &err)
+	ctx, cancel := storage.WithQueryTimeout(ctx, s.queryTimeout)
+	defer cancel()
+	var lat, lon sql.NullFloat64
+	if cached.Point != nil {
+		lat = sql.NullFloat64{Float64: cached.Point.Lat, Valid: true}
+		lon = sql.NullFloat64{Float64: cached.Point.Lon, Valid: true}
+	}
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO geocoded_places (place, lat, lon, geocoded_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT (place) DO UPDATE SET lat = excluded.lat, lon = excluded.lon, geocoded_at = excluded.geocoded_at`,
+		place, lat, lon, cached.GeocodedAt.Unix(),
+	)
+	return err
+}